@@ -10,8 +10,10 @@ import (
 	"strings"
 	"syscall"
 
+	"fivetran.com/fivetran_sdk/destination/common/files"
 	"fivetran.com/fivetran_sdk/destination/common/flags"
 	"fivetran.com/fivetran_sdk/destination/common/log"
+	"fivetran.com/fivetran_sdk/destination/db"
 	"fivetran.com/fivetran_sdk/destination/service"
 	pb "fivetran.com/fivetran_sdk/proto"
 	"google.golang.org/grpc"
@@ -27,6 +29,11 @@ func StartServer() {
 		log.Error(fmt.Errorf("failed to initialize logger: %w", err))
 		os.Exit(1)
 	}
+	_, err = files.FormatFromFlags()
+	if err != nil {
+		log.Error(err)
+		os.Exit(1)
+	}
 	var lc net.ListenConfig
 	listener, err := lc.Listen(context.Background(), "tcp", fmt.Sprintf(":%d", *flags.Port))
 	if err != nil {
@@ -56,6 +63,7 @@ func StartServer() {
 	case <-ExitChan:
 		log.Info("Shutting down the server...")
 		s.GracefulStop()
+		db.ClosePool()
 	case err = <-errChan:
 		log.Error(fmt.Errorf("failed to serve: %w", err))
 		os.Exit(1)
@@ -13,7 +13,9 @@ const (
 	XMLColumnComment       = "XML"
 	JSONColumnComment      = "JSON"
 	BinaryColumnComment    = "BINARY"
+	RawBinaryColumnComment = "RAW_BINARY" // BINARY column with the decoded bytes, see config.BinaryTypes
 	NaiveTimeColumnComment = "NAIVE_TIME"
+	DecimalColumnComment   = "DECIMAL" // e.g. DECIMAL(100,10), only for the decimals out of the ClickHouse range
 )
 
 const (
@@ -29,12 +31,55 @@ const (
 	Float32     = "Float32"
 	Float64     = "Float64"
 	Nullable    = "Nullable"
+
+	LowCardinality = "LowCardinality"
+	JSON           = "JSON"        // only used if the native JSON type is enabled, see config.JSONTypes
+	Time           = "Time"        // only used if the native NAIVE_TIME type is enabled, see config.Config.NaiveTimeType
+	Time64         = "Time64"      // same as Time, with fractional seconds
+	FixedString    = "FixedString" // only created for the decoded BINARY columns, see config.BinaryTypes
+	DateTime64     = "DateTime64"  // DateTime and DateTimeUTC, or as configured, see config.DateTimeTypes
+)
+
+// ClickHouse types that the destination never creates, but accepts in the pre-existing tables,
+// see data_types.ClickHouseCompatibleToFivetranType.
+const (
+	Int8       = "Int8"
+	UInt8      = "UInt8"
+	UInt16     = "UInt16"
+	UInt32     = "UInt32"
+	UInt64     = "UInt64"
+	Date16     = "Date"     // a narrower range than Date32, see values.Convert
+	DateTime32 = "DateTime" // with an optional timezone, e.g. DateTime('Europe/Berlin')
+	Enum8      = "Enum8"
+	Enum16     = "Enum16"
 )
 
 const MaxDecimalPrecision = 76
 
+// Policies for the Fivetran decimals that don't fit the ClickHouse Decimal type, see config.Config.DecimalOverflow.
+const (
+	DecimalOverflowClamp      = "clamp"
+	DecimalOverflowString     = "string"
+	DecimalOverflowDecimal256 = "decimal256"
+)
+
+// Coercion policies for the values out of the ClickHouse range, see types.Coercions.
+const (
+	CoercionClamp = "clamp"
+	CoercionNull  = "null"
+	CoercionFail  = "fail"
+)
+
+// Encodings of the BINARY values in the batch files, see config.BinaryTypes.
+const (
+	Base64Encoding = "base64"
+	HexEncoding    = "hex"
+)
+
 const (
-	UTCDateTimeFormat   = "2006-01-02T15:04:05.9Z" // allows arbitrary timestamp precision from 0 to 9
-	NaiveDateTimeFormat = "2006-01-02T15:04:05"
-	NaiveDateFormat     = "2006-01-02"
+	UTCDateTimeFormat    = "2006-01-02T15:04:05.9Z" // allows arbitrary timestamp precision from 0 to 9
+	NaiveDateTimeFormat  = "2006-01-02T15:04:05"
+	NaiveDateFormat      = "2006-01-02"
+	NaiveTimeFormat      = "15:04:05.999999999" // allows arbitrary precision from 0 to 9
+	NaiveTimeShortFormat = "15:04"
 )
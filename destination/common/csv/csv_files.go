@@ -5,28 +5,62 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"os"
+	"slices"
 
-	"fivetran.com/fivetran_sdk/destination/encryption/aes"
+	"fivetran.com/fivetran_sdk/destination/common/files"
 	pb "fivetran.com/fivetran_sdk/proto"
-	"github.com/klauspost/compress/gzip"
-	"github.com/klauspost/compress/zstd"
 )
 
 // CSVFileReader provides streaming access to a CSV file,
 // handling decryption and decompression transparently.
 // The header is read eagerly on construction; subsequent data rows
-// are read in batches via ReadBatch to limit memory usage.
+// are read in batches via ReadBatch (or ReadRecords and ReadRawBatch) to limit memory usage.
+// The CSV records are reused (see csv.Reader.ReuseRecord), so ReadBatch copies them.
 type CSVFileReader struct {
 	fileName  string
 	csvReader *csv.Reader
 	header    []string
+	rawHeader []byte
+	raw       *rawReader
 	done      bool
-	// Keep track of the open files to close them in the Close method
-	file        *os.File
-	aesDecoder  *aes.Decoder
-	zstdDecoder *zstd.Decoder
-	gzipReader  *gzip.Reader
+	file      *files.DecodedFile
+}
+
+var (
+	_ files.RecordBatchFileReader = (*CSVFileReader)(nil)
+	_ files.RawBatchFileReader    = (*CSVFileReader)(nil)
+)
+
+// rawReader keeps a copy of the decoded bytes read by the CSV reader, starting at the given input offset,
+// so that the rows can be returned as they are in the file (see CSVFileReader.ReadRawBatch).
+// Copying stops once the file is read with ReadBatch or ReadRecords.
+type rawReader struct {
+	reader   io.Reader
+	buf      []byte
+	offset   int64 // the input offset of buf[0]
+	disabled bool
+}
+
+func (r *rawReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	if !r.disabled {
+		r.buf = append(r.buf, p[:n]...)
+	}
+	return n, err
+}
+
+// next returns the bytes from the previous input offset up to the given one.
+func (r *rawReader) next(offset int64) []byte {
+	n := offset - r.offset
+	result := r.buf[:n:n]
+	r.buf = r.buf[n:]
+	r.offset = offset
+	return result
+}
+
+func (r *rawReader) disable() {
+	r.disabled = true
+	r.buf = nil
 }
 
 func NewCSVFileReader(
@@ -35,57 +69,21 @@ func NewCSVFileReader(
 	compression pb.Compression,
 	encryption pb.Encryption,
 ) (*CSVFileReader, error) {
-	key, ok := keys[fileName]
-	if !ok {
-		return nil, fmt.Errorf("key for file %s not found", fileName)
-	}
-	file, err := os.Open(fileName)
+	file, err := files.OpenDecodedFile(fileName, keys, compression, encryption)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open file %s: %w", fileName, err)
+		return nil, err
 	}
 
+	raw := &rawReader{reader: file}
+	csvReader := csv.NewReader(raw)
+	csvReader.ReuseRecord = true
 	r := &CSVFileReader{
-		fileName: fileName,
-		file:     file,
+		fileName:  fileName,
+		file:      file,
+		csvReader: csvReader,
+		raw:       raw,
 	}
 
-	var decryptedReader io.Reader
-	if encryption == pb.Encryption_AES {
-		aesReader, err := aes.NewReader(file, key)
-		if err != nil {
-			r.Close()
-			return nil, fmt.Errorf("failed to decrypt file %s, cause: %w", fileName, err)
-		}
-		r.aesDecoder = aesReader
-		decryptedReader = aesReader
-	} else {
-		decryptedReader = file
-	}
-
-	var decompressedReader io.Reader
-	switch compression {
-	case pb.Compression_ZSTD:
-		zstdReader, err := zstd.NewReader(decryptedReader)
-		if err != nil {
-			r.Close()
-			return nil, fmt.Errorf("failed to decompress file %s with ZSTD, cause: %w", fileName, err)
-		}
-		r.zstdDecoder = zstdReader
-		decompressedReader = zstdReader
-	case pb.Compression_GZIP:
-		gzipReader, err := gzip.NewReader(decryptedReader)
-		if err != nil {
-			r.Close()
-			return nil, fmt.Errorf("failed to decompress file %s with GZIP, cause: %w", fileName, err)
-		}
-		r.gzipReader = gzipReader
-		decompressedReader = gzipReader
-	case pb.Compression_OFF:
-		decompressedReader = decryptedReader
-	}
-
-	r.csvReader = csv.NewReader(decompressedReader)
-
 	header, err := r.csvReader.Read()
 	if errors.Is(err, io.EOF) {
 		r.Close()
@@ -95,7 +93,8 @@ func NewCSVFileReader(
 		r.Close()
 		return nil, fmt.Errorf("failed to read CSV header from file %s: %w", fileName, err)
 	}
-	r.header = header
+	r.header = slices.Clone(header)
+	r.rawHeader = slices.Clone(raw.next(r.csvReader.InputOffset()))
 
 	return r, nil
 }
@@ -104,6 +103,10 @@ func (r *CSVFileReader) Header() []string {
 	return r.header
 }
 
+func (r *CSVFileReader) RawHeader() []byte {
+	return r.rawHeader
+}
+
 // ReadBatch reads up to batchSize data rows from the CSV.
 // Returns (nil, nil) when there are no more rows to read.
 func (r *CSVFileReader) ReadBatch(batchSize uint) ([][]string, error) {
@@ -113,6 +116,7 @@ func (r *CSVFileReader) ReadBatch(batchSize uint) ([][]string, error) {
 	if r.done {
 		return nil, nil
 	}
+	r.raw.disable()
 	batch := make([][]string, 0, batchSize)
 	for range batchSize {
 		record, err := r.csvReader.Read()
@@ -123,7 +127,7 @@ func (r *CSVFileReader) ReadBatch(batchSize uint) ([][]string, error) {
 		if err != nil {
 			return nil, fmt.Errorf("failed to read CSV row from file %s: %w", r.fileName, err)
 		}
-		batch = append(batch, record)
+		batch = append(batch, slices.Clone(record))
 	}
 	if len(batch) == 0 {
 		return nil, nil
@@ -131,17 +135,66 @@ func (r *CSVFileReader) ReadBatch(batchSize uint) ([][]string, error) {
 	return batch, nil
 }
 
-func (r *CSVFileReader) Close() {
-	if r.gzipReader != nil {
-		r.gzipReader.Close() //nolint:errcheck
+// ReadRecords reads up to batchSize data rows from the CSV, and calls fn for each of them.
+// The row slice is reused for the next row, the strings in it are not.
+// Returns 0 when there are no more rows to read.
+func (r *CSVFileReader) ReadRecords(batchSize uint, fn func(row []string) error) (uint, error) {
+	if batchSize <= 0 {
+		return 0, fmt.Errorf("batchSize must be greater than 0")
 	}
-	if r.zstdDecoder != nil {
-		r.zstdDecoder.Close()
+	if r.done {
+		return 0, nil
+	}
+	r.raw.disable()
+	count := uint(0)
+	for count < batchSize {
+		record, err := r.csvReader.Read()
+		if err == io.EOF {
+			r.done = true
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to read CSV row from file %s: %w", r.fileName, err)
+		}
+		if err = fn(record); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// ReadRawBatch reads up to batchSize data rows from the CSV, and returns them as they are in the file.
+// The rows are still parsed to find their boundaries, as the quoted values can contain line breaks.
+// Returns (nil, 0, nil) when there are no more rows to read.
+func (r *CSVFileReader) ReadRawBatch(batchSize uint) ([]byte, uint, error) {
+	if batchSize <= 0 {
+		return nil, 0, fmt.Errorf("batchSize must be greater than 0")
 	}
-	if r.aesDecoder != nil {
-		r.aesDecoder.Close()
+	if r.raw.disabled {
+		return nil, 0, fmt.Errorf("raw rows of file %s can't be read after ReadBatch or ReadRecords", r.fileName)
 	}
-	if r.file != nil {
-		r.file.Close() //nolint:errcheck
+	if r.done {
+		return nil, 0, nil
 	}
+	count := uint(0)
+	for count < batchSize {
+		_, err := r.csvReader.Read()
+		if err == io.EOF {
+			r.done = true
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("failed to read CSV row from file %s: %w", r.fileName, err)
+		}
+		count++
+	}
+	if count == 0 {
+		return nil, 0, nil
+	}
+	return r.raw.next(r.csvReader.InputOffset()), count, nil
+}
+
+func (r *CSVFileReader) Close() {
+	r.file.Close()
 }
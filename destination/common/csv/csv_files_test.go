@@ -4,7 +4,9 @@ import (
 	"bytes"
 	"encoding/base64"
 	"encoding/csv"
+	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	pb "fivetran.com/fivetran_sdk/proto"
@@ -61,6 +63,64 @@ func TestCSVFileReaderReadsRowsInBatches(t *testing.T) {
 	assert.Nil(t, batch3)
 }
 
+func TestCSVFileReaderReadsRecords(t *testing.T) {
+	expectedCSV := readExpectedCSV(t, "../../../tests/resources/campaign.csv")
+	fileName := "../../../tests/resources/campaign.csv.zst.aes"
+
+	reader, err := NewCSVFileReader(fileName, map[string][]byte{fileName: key}, pb.Compression_ZSTD, pb.Encryption_AES)
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	// the record slice is reused, but the values are not
+	var values [][]string
+	var records [][]string
+	count, err := reader.ReadRecords(100, func(row []string) error {
+		values = append(values, append([]string(nil), row...))
+		records = append(records, row)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(len(expectedCSV)-1), count)
+	assert.Equal(t, expectedCSV[1:], values)
+	assert.Same(t, &records[0][0], &records[1][0])
+	assert.Equal(t, expectedCSV[0], reader.Header())
+
+	count, err = reader.ReadRecords(100, func(row []string) error { return nil })
+	assert.NoError(t, err)
+	assert.Equal(t, uint(0), count)
+
+	// ReadBatch copies the records
+	reader, err = NewCSVFileReader(fileName, map[string][]byte{fileName: key}, pb.Compression_ZSTD, pb.Encryption_AES)
+	assert.NoError(t, err)
+	defer reader.Close()
+	batch, err := reader.ReadBatch(100)
+	assert.NoError(t, err)
+	assert.Equal(t, expectedCSV[1:], batch)
+	assert.NotSame(t, &batch[0][0], &batch[1][0])
+}
+
+func TestCSVFileReaderRecordsErrors(t *testing.T) {
+	fileName := "../../../tests/resources/campaign.csv.zst.aes"
+	reader, err := NewCSVFileReader(fileName, map[string][]byte{fileName: key}, pb.Compression_ZSTD, pb.Encryption_AES)
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	_, err = reader.ReadRecords(0, func(row []string) error { return nil })
+	assert.ErrorContains(t, err, "batchSize must be greater than 0")
+	count, err := reader.ReadRecords(100, func(row []string) error { return fmt.Errorf("test error") })
+	assert.ErrorContains(t, err, "test error")
+	assert.Equal(t, uint(0), count)
+	_, _, err = reader.ReadRawBatch(1)
+	assert.ErrorContains(t, err, "can't be read after ReadBatch or ReadRecords")
+
+	invalidCSVFileName := "../../../tests/resources/invalid.csv"
+	invalidReader, err := NewCSVFileReader(invalidCSVFileName, map[string][]byte{invalidCSVFileName: key}, pb.Compression_OFF, pb.Encryption_NONE)
+	assert.NoError(t, err)
+	defer invalidReader.Close()
+	_, err = invalidReader.ReadRecords(100, func(row []string) error { return nil })
+	assert.ErrorContains(t, err, "parse error")
+}
+
 func TestCSVFileReaderHeaderOnly(t *testing.T) {
 	fileName := "../../../tests/resources/short.csv"
 
@@ -76,6 +136,69 @@ func TestCSVFileReaderHeaderOnly(t *testing.T) {
 	assert.Nil(t, batch)
 }
 
+func TestCSVFileReaderReadsRawRows(t *testing.T) {
+	expectedBytes, err := os.ReadFile("../../../tests/resources/campaign.csv")
+	assert.NoError(t, err)
+	headerEnd := bytes.IndexByte(expectedBytes, '\n') + 1
+	fileName := "../../../tests/resources/campaign.csv.zst.aes"
+
+	reader, err := NewCSVFileReader(fileName, map[string][]byte{fileName: key}, pb.Compression_ZSTD, pb.Encryption_AES)
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	assert.Equal(t, expectedBytes[:headerEnd], reader.RawHeader())
+	var rows []byte
+	for {
+		batch, count, err := reader.ReadRawBatch(1)
+		assert.NoError(t, err)
+		if batch == nil {
+			assert.Equal(t, uint(0), count)
+			break
+		}
+		assert.Equal(t, uint(1), count)
+		rows = append(rows, batch...)
+	}
+	assert.Equal(t, expectedBytes[headerEnd:], rows)
+}
+
+func TestCSVFileReaderReadsRawRowsWithLineBreaks(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "line_breaks.csv")
+	err := os.WriteFile(fileName, []byte("id,note\r\n1,\"foo\nbar\"\r\n2,\"\"\"baz\"\"\"\r\n3,qux"), 0o600)
+	assert.NoError(t, err)
+
+	reader, err := NewCSVFileReader(fileName, map[string][]byte{fileName: nil}, pb.Compression_OFF, pb.Encryption_NONE)
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	assert.Equal(t, []byte("id,note\r\n"), reader.RawHeader())
+	batch, count, err := reader.ReadRawBatch(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), count)
+	assert.Equal(t, "1,\"foo\nbar\"\r\n2,\"\"\"baz\"\"\"\r\n", string(batch))
+	batch, count, err = reader.ReadRawBatch(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), count)
+	assert.Equal(t, "3,qux", string(batch))
+	batch, count, err = reader.ReadRawBatch(2)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(0), count)
+	assert.Nil(t, batch)
+}
+
+func TestCSVFileReaderRawRowsAfterReadBatch(t *testing.T) {
+	fileName := "../../../tests/resources/campaign.csv.zst.aes"
+	reader, err := NewCSVFileReader(fileName, map[string][]byte{fileName: key}, pb.Compression_ZSTD, pb.Encryption_AES)
+	assert.NoError(t, err)
+	defer reader.Close()
+
+	_, _, err = reader.ReadRawBatch(0)
+	assert.ErrorContains(t, err, "batchSize must be greater than 0")
+	_, err = reader.ReadBatch(1)
+	assert.NoError(t, err)
+	_, _, err = reader.ReadRawBatch(1)
+	assert.ErrorContains(t, err, "raw rows of file ../../../tests/resources/campaign.csv.zst.aes can't be read after ReadBatch")
+}
+
 func TestCSVFileReaderErrors(t *testing.T) {
 	fileName := "../../../tests/resources/campaign.csv.zst.aes"
 
@@ -2,6 +2,7 @@ package data_types
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	c "fivetran.com/fivetran_sdk/destination/common/constants"
@@ -40,13 +41,35 @@ var (
 		pb.DataType_UTC_DATETIME:   {Type: c.DateTimeUTC},
 		pb.DataType_STRING:         {Type: c.String},
 	}
+	// ClickHouseCompatibleToFivetranType maps the ClickHouse types that the destination never creates itself
+	// to the nearest Fivetran type, so the pre-existing tables with such types can be written to;
+	// the values are converted to the column type on insert, see values.Convert.
+	// The parameterized types are mapped by their name: DateTime('TZ') and the DateTime64 types
+	// that are not mapped otherwise (see DateTime64Params) to UTC_DATETIME, Enum8, Enum16 and FixedString(N) to STRING.
+	// The decoded BINARY columns can be FixedString(N) as well, but they have the RAW_BINARY comment.
+	ClickHouseCompatibleToFivetranType = map[string]pb.DataType{
+		c.Int8:        pb.DataType_SHORT,
+		c.UInt8:       pb.DataType_SHORT,
+		c.UInt16:      pb.DataType_INT,
+		c.UInt32:      pb.DataType_LONG,
+		c.UInt64:      pb.DataType_LONG,
+		c.Date16:      pb.DataType_NAIVE_DATE,
+		c.DateTime32:  pb.DataType_UTC_DATETIME,
+		c.DateTime64:  pb.DataType_UTC_DATETIME,
+		c.Enum8:       pb.DataType_STRING,
+		c.Enum16:      pb.DataType_STRING,
+		c.FixedString: pb.DataType_STRING,
+	}
 	// FivetranToClickHouseTypeWithComment
 	// Fivetran STRING, XML, BINARY, JSON all are valid ClickHouse String types,
 	// and by default we don't have a way to get the original Fivetran type from just a ClickHouse String.
 	// So we add comments to the table columns using COMMENT clause to be able to distinguish them.
-	// There is no corresponding type for Fivetran NAIVE_TIME in ClickHouse, so we use String with a comment as well.
+	// Fivetran NAIVE_TIME is stored as String with a comment as well; ClickHouse Time/Time64 types are opt-in,
+	// see config.Config.NaiveTimeType.
+	// Fivetran BINARY values can be decoded into raw bytes instead, see config.BinaryTypes;
+	// such columns have the RAW_BINARY comment, and are either String or FixedString.
 	// NB: ClickHouse has JSON data type, see https://clickhouse.com/docs/en/sql-reference/data-types/json
-	// however, it's marked as experimental and not production ready, so we use String instead.
+	// it is opt-in via the JSON configurations (see config.JSONTypes), so we use String by default.
 	FivetranToClickHouseTypeWithComment = map[pb.DataType]ClickHouseType{
 		pb.DataType_XML:        {Type: c.String, Comment: c.XMLColumnComment},
 		pb.DataType_JSON:       {Type: c.String, Comment: c.JSONColumnComment},
@@ -59,6 +82,7 @@ var (
 		c.XMLColumnComment:       pb.DataType_XML,
 		c.JSONColumnComment:      pb.DataType_JSON,
 		c.BinaryColumnComment:    pb.DataType_BINARY,
+		c.RawBinaryColumnComment: pb.DataType_BINARY,
 		c.NaiveTimeColumnComment: pb.DataType_NAIVE_TIME,
 	}
 	// FivetranMetadataColumnToClickHouseType
@@ -81,20 +105,105 @@ func ToFivetranDataType(
 	if ok {
 		return dataType, nil, nil
 	}
-	// Since LowCardinality is never applied by the destination app, we only need to handle Nullable.
+	// the decimals out of the ClickHouse range keep the original precision and scale in the comment
+	if params, ok := parseDecimalComment(colComment); ok {
+		return pb.DataType_DECIMAL, params, nil
+	}
+	// LowCardinality is only applied via the column options, and it doesn't change the Fivetran type
+	if strings.HasPrefix(colType, c.LowCardinality+"(") { // LowCardinality(Nullable(String)) -> Nullable(String)
+		colType = colType[len(c.LowCardinality)+1 : len(colType)-1]
+	}
 	if strings.HasPrefix(colType, c.Nullable) { // Nullable(String) -> String
 		colType = colType[len(c.Nullable)+1 : len(colType)-1]
 	}
+	// JSON and Time columns are normally commented, but the comment can be lost
+	if IsJSONType(colType) {
+		return pb.DataType_JSON, nil, nil
+	}
+	if IsTimeType(colType) {
+		return pb.DataType_NAIVE_TIME, nil, nil
+	}
+	// the precision and the timezone can be configured, see config.DateTimeTypes
+	if precision, timezone, ok := DateTime64Params(colType); ok {
+		if precision == 0 {
+			return pb.DataType_NAIVE_DATETIME, nil, nil
+		}
+		if timezone == "UTC" {
+			return pb.DataType_UTC_DATETIME, nil, nil
+		}
+	}
 	if decimalParams != nil {
 		return pb.DataType_DECIMAL, decimalParams, nil
 	}
 	dataType, ok = ClickHouseToFivetranType[colType]
-	if !ok { // shouldn't happen if the tables are created by the connector
+	if ok {
+		return dataType, nil, nil
+	}
+	// the pre-existing tables might have other types
+	dataType, ok = ClickHouseCompatibleToFivetranType[typeName(colType)]
+	if !ok {
 		return pb.DataType_UNSPECIFIED, nil, fmt.Errorf("can't map type %s to Fivetran types", colType)
 	}
 	return dataType, nil, nil
 }
 
+// IsCompatibleType reports whether the type is never created by the destination for its Fivetran type,
+// Nullable or not, e.g. Nullable(UInt32); see ClickHouseCompatibleToFivetranType.
+// LowCardinality types are compatible as well, as they are only created via the column options.
+func IsCompatibleType(colType string) bool {
+	if strings.HasPrefix(colType, c.LowCardinality+"(") {
+		return true
+	}
+	if strings.HasPrefix(colType, c.Nullable+"(") {
+		colType = colType[len(c.Nullable)+1 : len(colType)-1]
+	}
+	if _, ok := ClickHouseToFivetranType[colType]; ok {
+		return false
+	}
+	// the configured datetime types, see config.DateTimeTypes
+	if precision, timezone, ok := DateTime64Params(colType); ok && (precision == 0 || timezone == "UTC") {
+		return false
+	}
+	_, ok := ClickHouseCompatibleToFivetranType[typeName(colType)]
+	return ok
+}
+
+// BaseType returns the type without the LowCardinality and Nullable wrappers,
+// e.g. UInt32 for Nullable(UInt32) and String for LowCardinality(Nullable(String)).
+func BaseType(colType string) string {
+	if strings.HasPrefix(colType, c.LowCardinality+"(") {
+		colType = colType[len(c.LowCardinality)+1 : len(colType)-1]
+	}
+	if strings.HasPrefix(colType, c.Nullable+"(") {
+		colType = colType[len(c.Nullable)+1 : len(colType)-1]
+	}
+	return colType
+}
+
+// DateTimePrecision returns the precision of the ClickHouse DateTime or DateTime64 type, Nullable or not,
+// e.g. 0 for DateTime('Europe/Berlin') and 3 for Nullable(DateTime64(3)); false for the other types.
+func DateTimePrecision(colType string) (uint, bool) {
+	colType = BaseType(colType)
+	if typeName(colType) == c.DateTime32 {
+		return 0, true
+	}
+	if !strings.HasPrefix(colType, c.DateTime64+"(") || !strings.HasSuffix(colType, ")") {
+		return 0, false
+	}
+	precisionStr, _, _ := strings.Cut(colType[len(c.DateTime64)+1:len(colType)-1], ",")
+	precision, err := strconv.ParseUint(strings.TrimSpace(precisionStr), 10, 32)
+	if err != nil || precision > 9 {
+		return 0, false
+	}
+	return uint(precision), true
+}
+
+// typeName returns the type name without the parameters, e.g. Enum8 for Enum8('a' = 1, 'b' = 2).
+func typeName(colType string) string {
+	name, _, _ := strings.Cut(colType, "(")
+	return name
+}
+
 // ToClickHouseDataType converts a Fivetran column definition to a ClickHouse type.
 //   - Fivetran Metadata fields have known types and are not Nullable
 //   - Primary key fields are not Nullable
@@ -145,3 +254,137 @@ func ToClickHouseDecimalType(decimalParams *pb.DecimalParams) string {
 	}
 	return fmt.Sprintf("%s(%d, %d)", c.Decimal, precision, scale)
 }
+
+// IsDecimalOverflow reports whether the Fivetran decimal parameters don't fit the ClickHouse Decimal type,
+// i.e. the precision is greater than 76 or the scale is greater than the precision; see ToClickHouseDecimalType.
+func IsDecimalOverflow(decimalParams *pb.DecimalParams) bool {
+	return decimalParams != nil &&
+		(decimalParams.Precision > c.MaxDecimalPrecision || decimalParams.Scale > decimalParams.Precision)
+}
+
+// ToClickHouseDecimal256Type returns the widest ClickHouse Decimal type for the Fivetran decimal parameters
+// that don't fit the ClickHouse Decimal type: Decimal(76, S), where S is the Fivetran scale clamped to 76.
+func ToClickHouseDecimal256Type(decimalParams *pb.DecimalParams) string {
+	scale := min(decimalParams.Scale, c.MaxDecimalPrecision)
+	return fmt.Sprintf("%s(%d, %d)", c.Decimal, c.MaxDecimalPrecision, scale)
+}
+
+// ToDecimalComment returns the comment with the original Fivetran decimal parameters, e.g. DECIMAL(100,10),
+// for the decimals out of the ClickHouse range that are not clamped (see config.Config.DecimalOverflow).
+func ToDecimalComment(decimalParams *pb.DecimalParams) string {
+	return fmt.Sprintf("%s(%d,%d)", c.DecimalColumnComment, decimalParams.Precision, decimalParams.Scale)
+}
+
+// IsDecimalComment reports whether the comment is set by ToDecimalComment.
+func IsDecimalComment(comment string) bool {
+	_, ok := parseDecimalComment(comment)
+	return ok
+}
+
+func parseDecimalComment(comment string) (*pb.DecimalParams, bool) {
+	params, ok := strings.CutPrefix(comment, c.DecimalColumnComment+"(")
+	if !ok {
+		return nil, false
+	}
+	params, ok = strings.CutSuffix(params, ")")
+	if !ok {
+		return nil, false
+	}
+	return parseDecimalParams(params)
+}
+
+// DecimalTypeParams returns the precision and the scale of the ClickHouse Decimal type, Nullable or not,
+// e.g. 76 and 10 for Nullable(Decimal(76, 10)); false if the type is not Decimal(P, S).
+func DecimalTypeParams(colType string) (*pb.DecimalParams, bool) {
+	if strings.HasPrefix(colType, c.Nullable+"(") {
+		colType = colType[len(c.Nullable)+1 : len(colType)-1]
+	}
+	if !strings.HasPrefix(colType, c.Decimal+"(") || !strings.HasSuffix(colType, ")") {
+		return nil, false
+	}
+	return parseDecimalParams(colType[len(c.Decimal)+1 : len(colType)-1])
+}
+
+// parseDecimalParams parses "P,S" or "P, S".
+func parseDecimalParams(params string) (*pb.DecimalParams, bool) {
+	precisionStr, scaleStr, ok := strings.Cut(params, ",")
+	if !ok {
+		return nil, false
+	}
+	precision, err := strconv.ParseUint(strings.TrimSpace(precisionStr), 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	scale, err := strconv.ParseUint(strings.TrimSpace(scaleStr), 10, 32)
+	if err != nil {
+		return nil, false
+	}
+	return &pb.DecimalParams{Precision: uint32(precision), Scale: uint32(scale)}, true
+}
+
+// DateTime64Params returns the precision and the timezone of the ClickHouse DateTime64 type, Nullable or not,
+// e.g. 6 and UTC for Nullable(DateTime64(6, 'UTC')); false if the type is not DateTime64(P, 'TZ').
+func DateTime64Params(colType string) (precision uint, timezone string, ok bool) {
+	if strings.HasPrefix(colType, c.Nullable+"(") {
+		colType = colType[len(c.Nullable)+1 : len(colType)-1]
+	}
+	if !strings.HasPrefix(colType, c.DateTime64+"(") || !strings.HasSuffix(colType, ")") {
+		return 0, "", false
+	}
+	precisionStr, timezoneStr, ok := strings.Cut(colType[len(c.DateTime64)+1:len(colType)-1], ",")
+	if !ok {
+		return 0, "", false
+	}
+	parsedPrecision, err := strconv.ParseUint(strings.TrimSpace(precisionStr), 10, 32)
+	if err != nil || parsedPrecision > 9 {
+		return 0, "", false
+	}
+	timezone = strings.TrimSpace(timezoneStr)
+	if len(timezone) < 3 || timezone[0] != '\'' || timezone[len(timezone)-1] != '\'' {
+		return 0, "", false
+	}
+	return uint(parsedPrecision), timezone[1 : len(timezone)-1], true
+}
+
+// ToLowCardinalityType wraps a String type (Nullable or not) with LowCardinality, e.g. LowCardinality(Nullable(String)).
+// The type is returned as is if it is already LowCardinality; other types are not supported.
+func ToLowCardinalityType(colType string) (string, error) {
+	if strings.HasPrefix(colType, c.LowCardinality+"(") {
+		return colType, nil
+	}
+	if colType != c.String && colType != fmt.Sprintf("%s(%s)", c.Nullable, c.String) {
+		return "", fmt.Errorf("LowCardinality is only supported for String columns, got %s", colType)
+	}
+	return fmt.Sprintf("%s(%s)", c.LowCardinality, colType), nil
+}
+
+// IsJSONType reports whether the type is the native ClickHouse JSON type, with or without parameters,
+// e.g. JSON or JSON(max_dynamic_paths=256, user.id UInt64).
+func IsJSONType(colType string) bool {
+	return colType == c.JSON || strings.HasPrefix(colType, c.JSON+"(")
+}
+
+// IsTimeType reports whether the type is the ClickHouse Time or Time64 type, Nullable or not,
+// e.g. Time or Nullable(Time64(6)).
+func IsTimeType(colType string) bool {
+	if strings.HasPrefix(colType, c.Nullable+"(") {
+		colType = colType[len(c.Nullable)+1 : len(colType)-1]
+	}
+	return colType == c.Time || strings.HasPrefix(colType, c.Time64+"(")
+}
+
+// FixedStringLength returns the length of the ClickHouse FixedString type, Nullable or not,
+// e.g. 16 for Nullable(FixedString(16)); false if the type is not FixedString.
+func FixedStringLength(colType string) (uint, bool) {
+	if strings.HasPrefix(colType, c.Nullable+"(") {
+		colType = colType[len(c.Nullable)+1 : len(colType)-1]
+	}
+	if !strings.HasPrefix(colType, c.FixedString+"(") || !strings.HasSuffix(colType, ")") {
+		return 0, false
+	}
+	length, err := strconv.ParseUint(colType[len(c.FixedString)+1:len(colType)-1], 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(length), true
+}
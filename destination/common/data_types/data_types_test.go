@@ -160,8 +160,33 @@ func TestGetFivetranDataType(t *testing.T) {
 		{"Nullable(DateTime64(0, 'UTC'))", pb.DataType_NAIVE_DATETIME},
 		{"DateTime64(9, 'UTC')", pb.DataType_UTC_DATETIME},
 		{"Nullable(DateTime64(9, 'UTC'))", pb.DataType_UTC_DATETIME},
+		{"DateTime64(0, 'Europe/Berlin')", pb.DataType_NAIVE_DATETIME},
+		{"Nullable(DateTime64(0, 'America/New_York'))", pb.DataType_NAIVE_DATETIME},
+		{"DateTime64(3, 'UTC')", pb.DataType_UTC_DATETIME},
+		{"Nullable(DateTime64(6, 'UTC'))", pb.DataType_UTC_DATETIME},
+		{"Int8", pb.DataType_SHORT},
+		{"Nullable(UInt8)", pb.DataType_SHORT},
+		{"UInt16", pb.DataType_INT},
+		{"Nullable(UInt32)", pb.DataType_LONG},
+		{"UInt64", pb.DataType_LONG},
+		{"Date", pb.DataType_NAIVE_DATE},
+		{"Nullable(DateTime)", pb.DataType_UTC_DATETIME},
+		{"DateTime('Europe/Berlin')", pb.DataType_UTC_DATETIME},
+		{"DateTime64(3)", pb.DataType_UTC_DATETIME},
+		{"DateTime64(3, 'Europe/Berlin')", pb.DataType_UTC_DATETIME},
+		{"Enum8('a' = 1, 'b' = 2)", pb.DataType_STRING},
+		{"LowCardinality(Nullable(Enum16('a' = 1)))", pb.DataType_STRING},
+		{"LowCardinality(FixedString(2))", pb.DataType_STRING},
 		{"String", pb.DataType_STRING},
 		{"Nullable(String)", pb.DataType_STRING},
+		{"LowCardinality(String)", pb.DataType_STRING},
+		{"LowCardinality(Nullable(String))", pb.DataType_STRING},
+		{"JSON", pb.DataType_JSON},
+		{"JSON(max_dynamic_paths=256, user.id UInt64)", pb.DataType_JSON},
+		{"Time", pb.DataType_NAIVE_TIME},
+		{"Nullable(Time64(6))", pb.DataType_NAIVE_TIME},
+		{"FixedString(16)", pb.DataType_STRING},
+		{"Nullable(FixedString(32))", pb.DataType_STRING},
 	}
 	for _, arg := range args {
 		dataType, decimalParams, err := ToFivetranDataType(arg.string, "", nil)
@@ -202,6 +227,11 @@ func TestGetFivetranDataTypeWithComments(t *testing.T) {
 		{"Nullable(String)", "JSON", pb.DataType_JSON},
 		{"String", "NAIVE_TIME", pb.DataType_NAIVE_TIME},
 		{"Nullable(String)", "NAIVE_TIME", pb.DataType_NAIVE_TIME},
+		{"LowCardinality(Nullable(String))", "XML", pb.DataType_XML},
+		{"JSON", "JSON", pb.DataType_JSON},
+		{"JSON(max_dynamic_paths=256)", "JSON", pb.DataType_JSON},
+		{"Nullable(String)", "RAW_BINARY", pb.DataType_BINARY},
+		{"FixedString(16)", "RAW_BINARY", pb.DataType_BINARY},
 	}
 	for _, arg := range args {
 		dataType, decimalParams, err := ToFivetranDataType(arg.Type, arg.Comment, nil)
@@ -217,6 +247,155 @@ func TestGetFivetranDataTypeWithComments(t *testing.T) {
 	}
 }
 
+func TestGetFivetranDataTypeWithDecimalComment(t *testing.T) {
+	// the comment has priority over the parameters of the ClickHouse Decimal type
+	dataType, decimalParams, err := ToFivetranDataType(
+		"Nullable(Decimal(76, 10))", "DECIMAL(100,10)", &pb.DecimalParams{Precision: 76, Scale: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, pb.DataType_DECIMAL, dataType)
+	assert.Equal(t, &pb.DecimalParams{Precision: 100, Scale: 10}, decimalParams)
+
+	dataType, decimalParams, err = ToFivetranDataType("Nullable(String)", "DECIMAL(5,10)", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, pb.DataType_DECIMAL, dataType)
+	assert.Equal(t, &pb.DecimalParams{Precision: 5, Scale: 10}, decimalParams)
+
+	// not a decimal comment
+	dataType, decimalParams, err = ToFivetranDataType("Nullable(String)", "DECIMAL(100)", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, pb.DataType_STRING, dataType)
+	assert.Nil(t, decimalParams)
+}
+
+func TestDecimalOverflow(t *testing.T) {
+	assert.False(t, IsDecimalOverflow(nil))
+	assert.False(t, IsDecimalOverflow(&pb.DecimalParams{Precision: 76, Scale: 76}))
+	assert.True(t, IsDecimalOverflow(&pb.DecimalParams{Precision: 77, Scale: 2}))
+	assert.True(t, IsDecimalOverflow(&pb.DecimalParams{Precision: 5, Scale: 6}))
+
+	assert.Equal(t, "Decimal(76, 10)", ToClickHouseDecimal256Type(&pb.DecimalParams{Precision: 100, Scale: 10}))
+	assert.Equal(t, "Decimal(76, 76)", ToClickHouseDecimal256Type(&pb.DecimalParams{Precision: 100, Scale: 90}))
+	assert.Equal(t, "DECIMAL(100,10)", ToDecimalComment(&pb.DecimalParams{Precision: 100, Scale: 10}))
+	assert.True(t, IsDecimalComment("DECIMAL(100,10)"))
+	assert.False(t, IsDecimalComment("DECIMAL(100,)"))
+	assert.False(t, IsDecimalComment("JSON"))
+
+	params, ok := DecimalTypeParams("Nullable(Decimal(76, 10))")
+	assert.True(t, ok)
+	assert.Equal(t, &pb.DecimalParams{Precision: 76, Scale: 10}, params)
+	params, ok = DecimalTypeParams("Decimal(10, 2)")
+	assert.True(t, ok)
+	assert.Equal(t, &pb.DecimalParams{Precision: 10, Scale: 2}, params)
+	_, ok = DecimalTypeParams("Nullable(String)")
+	assert.False(t, ok)
+}
+
+func TestToLowCardinalityType(t *testing.T) {
+	lowCardinalityType, err := ToLowCardinalityType("String")
+	assert.NoError(t, err)
+	assert.Equal(t, "LowCardinality(String)", lowCardinalityType)
+
+	lowCardinalityType, err = ToLowCardinalityType("Nullable(String)")
+	assert.NoError(t, err)
+	assert.Equal(t, "LowCardinality(Nullable(String))", lowCardinalityType)
+
+	// already wrapped, e.g. a column of an existing table
+	lowCardinalityType, err = ToLowCardinalityType("LowCardinality(Nullable(String))")
+	assert.NoError(t, err)
+	assert.Equal(t, "LowCardinality(Nullable(String))", lowCardinalityType)
+
+	_, err = ToLowCardinalityType("Nullable(Int32)")
+	assert.ErrorContains(t, err, "LowCardinality is only supported for String columns, got Nullable(Int32)")
+}
+
+func TestIsJSONType(t *testing.T) {
+	assert.True(t, IsJSONType("JSON"))
+	assert.True(t, IsJSONType("JSON(max_dynamic_paths=256, user.id UInt64)"))
+	assert.False(t, IsJSONType("String"))
+	assert.False(t, IsJSONType("Nullable(String)"))
+	assert.False(t, IsJSONType("JSONString"))
+}
+
+func TestIsTimeType(t *testing.T) {
+	assert.True(t, IsTimeType("Time"))
+	assert.True(t, IsTimeType("Time64(6)"))
+	assert.True(t, IsTimeType("Nullable(Time)"))
+	assert.True(t, IsTimeType("Nullable(Time64(3))"))
+	assert.False(t, IsTimeType("String"))
+	assert.False(t, IsTimeType("DateTime64(9, 'UTC')"))
+	assert.False(t, IsTimeType("Nullable(DateTime)"))
+}
+
+func TestFixedStringLength(t *testing.T) {
+	length, ok := FixedStringLength("FixedString(16)")
+	assert.True(t, ok)
+	assert.Equal(t, uint(16), length)
+	length, ok = FixedStringLength("Nullable(FixedString(32))")
+	assert.True(t, ok)
+	assert.Equal(t, uint(32), length)
+
+	for _, colType := range []string{"String", "Nullable(String)", "FixedString", "FixedString(foo)", "LowCardinality(FixedString(16))"} {
+		_, ok = FixedStringLength(colType)
+		assert.False(t, ok, "type %s is not FixedString", colType)
+	}
+}
+
+func TestDateTime64Params(t *testing.T) {
+	precision, timezone, ok := DateTime64Params("DateTime64(9, 'UTC')")
+	assert.True(t, ok)
+	assert.Equal(t, uint(9), precision)
+	assert.Equal(t, "UTC", timezone)
+	precision, timezone, ok = DateTime64Params("Nullable(DateTime64(0, 'Europe/Berlin'))")
+	assert.True(t, ok)
+	assert.Equal(t, uint(0), precision)
+	assert.Equal(t, "Europe/Berlin", timezone)
+
+	for _, colType := range []string{"String", "DateTime", "DateTime64(3)", "DateTime64(10, 'UTC')", "DateTime64(foo, 'UTC')", "DateTime64(3, UTC)"} {
+		_, _, ok = DateTime64Params(colType)
+		assert.False(t, ok, "type %s is not DateTime64(P, 'TZ')", colType)
+	}
+}
+
+func TestIsCompatibleType(t *testing.T) {
+	for _, colType := range []string{"UInt32", "Nullable(Int8)", "Date", "DateTime", "Nullable(DateTime('Europe/Berlin'))",
+		"DateTime64(3)", "DateTime64(6, 'Europe/Berlin')", "Enum8('a' = 1)", "LowCardinality(String)", "FixedString(2)"} {
+		assert.True(t, IsCompatibleType(colType), "type %s is compatible", colType)
+	}
+	for _, colType := range []string{"Int32", "Nullable(String)", "Date32", "DateTime64(9, 'UTC')", "DateTime64(3, 'UTC')",
+		"Nullable(DateTime64(0, 'Europe/Berlin'))", "Decimal(10, 2)", "JSON", "Time64(6)", "Array(String)"} {
+		assert.False(t, IsCompatibleType(colType), "type %s is not compatible", colType)
+	}
+}
+
+func TestBaseType(t *testing.T) {
+	assert.Equal(t, "UInt32", BaseType("UInt32"))
+	assert.Equal(t, "UInt32", BaseType("Nullable(UInt32)"))
+	assert.Equal(t, "String", BaseType("LowCardinality(Nullable(String))"))
+	assert.Equal(t, "String", BaseType("LowCardinality(String)"))
+}
+
+func TestDateTimePrecision(t *testing.T) {
+	args := []struct {
+		colType   string
+		precision uint
+	}{
+		{"DateTime", 0},
+		{"Nullable(DateTime('Europe/Berlin'))", 0},
+		{"DateTime64(3)", 3},
+		{"Nullable(DateTime64(6, 'UTC'))", 6},
+		{"DateTime64(9, 'UTC')", 9},
+	}
+	for _, arg := range args {
+		precision, ok := DateTimePrecision(arg.colType)
+		assert.True(t, ok, "type %s", arg.colType)
+		assert.Equal(t, arg.precision, precision, "type %s", arg.colType)
+	}
+	for _, colType := range []string{"Date", "String", "DateTime64(foo)", "Time64(3)"} {
+		_, ok := DateTimePrecision(colType)
+		assert.False(t, ok, "type %s is not DateTime", colType)
+	}
+}
+
 func getDecimalDataTypeParams(precision uint32, scale uint32) *pb.DataTypeParams {
 	return &pb.DataTypeParams{
 		Params: &pb.DataTypeParams_Decimal{
@@ -0,0 +1,158 @@
+package files
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"fivetran.com/fivetran_sdk/destination/encryption/aes"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// BatchFileReader provides streaming access to a batch file sent by Fivetran (CSV or Parquet).
+// Rows are returned in their CSV string representation, so every reader can be used
+// by the same write path: NULL values are represented by the null string from the file params,
+// and all other values use the same formats as in Fivetran CSV files.
+type BatchFileReader interface {
+	// Header returns the column names in the order they appear in the rows returned by ReadBatch.
+	Header() []string
+	// ReadBatch reads up to batchSize rows. Returns (nil, nil) when there are no more rows to read.
+	ReadBatch(batchSize uint) ([][]string, error)
+	Close()
+}
+
+// TypedBatchFileReader is implemented by readers of typed batch files (such as Parquet),
+// which can return already typed values and avoid parsing them from strings.
+// NULL values are returned as nil. See values.ParseTyped for the supported Go types.
+type TypedBatchFileReader interface {
+	BatchFileReader
+	// ReadTypedBatch reads up to batchSize rows. Returns (nil, nil) when there are no more rows to read.
+	// It shares the position in the file with ReadBatch.
+	ReadTypedBatch(batchSize uint) ([][]any, error)
+}
+
+// RecordBatchFileReader is implemented by readers of CSV batch files, which can pass the rows one at a time
+// to a consumer that copies the values into its own storage, such as db.InsertColumns.
+type RecordBatchFileReader interface {
+	BatchFileReader
+	// ReadRecords reads up to batchSize rows, and calls fn for each of them. The row slice is reused for the next row,
+	// so it is only valid until fn returns; the strings in it can be kept. Returns the number of rows read,
+	// 0 when there are no more rows to read. It shares the position in the file with ReadBatch.
+	ReadRecords(batchSize uint, fn func(row []string) error) (uint, error)
+}
+
+// RawBatchFileReader is implemented by readers of CSV batch files, which can return the rows as they are in the file,
+// so that the values can be parsed by ClickHouse itself (see config.Settings.ServerSideCSVParsing).
+type RawBatchFileReader interface {
+	BatchFileReader
+	// RawHeader returns the header as it is in the file, including the line terminator.
+	RawHeader() []byte
+	// ReadRawBatch reads up to batchSize rows, and returns them as they are in the file, with the number of rows.
+	// Returns (nil, 0, nil) when there are no more rows to read. It can't be used after ReadBatch.
+	ReadRawBatch(batchSize uint) ([]byte, uint, error)
+}
+
+// FormatFromFlags returns the batch file format that we request from Fivetran in the Capabilities call.
+func FormatFromFlags() (pb.BatchFileFormat, error) {
+	switch strings.ToLower(*flags.BatchFileFormat) {
+	case "csv":
+		return pb.BatchFileFormat_CSV, nil
+	case "parquet":
+		return pb.BatchFileFormat_PARQUET, nil
+	default:
+		return pb.BatchFileFormat_CSV, fmt.Errorf("invalid batch file format: %s, allowed values: csv, parquet", *flags.BatchFileFormat)
+	}
+}
+
+// DecodedFile is a batch file opened for reading,
+// handling decryption and decompression transparently.
+type DecodedFile struct {
+	io.Reader
+	// Keep track of the open files to close them in the Close method
+	file        *os.File
+	aesDecoder  *aes.Decoder
+	zstdDecoder *zstd.Decoder
+	gzipReader  *gzip.Reader
+}
+
+// OpenDecodedFile opens a batch file; if the file is encrypted or compressed,
+// the returned reader yields the decrypted and decompressed contents.
+func OpenDecodedFile(
+	fileName string,
+	keys map[string][]byte,
+	compression pb.Compression,
+	encryption pb.Encryption,
+) (*DecodedFile, error) {
+	key, ok := keys[fileName]
+	if !ok {
+		return nil, fmt.Errorf("key for file %s not found", fileName)
+	}
+	file, err := os.Open(fileName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file %s: %w", fileName, err)
+	}
+
+	f := &DecodedFile{file: file}
+
+	var decryptedReader io.Reader
+	if encryption == pb.Encryption_AES {
+		aesReader, err := aes.NewReader(file, key)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to decrypt file %s, cause: %w", fileName, err)
+		}
+		f.aesDecoder = aesReader
+		decryptedReader = aesReader
+	} else {
+		decryptedReader = file
+	}
+
+	switch compression {
+	case pb.Compression_ZSTD:
+		zstdReader, err := zstd.NewReader(decryptedReader)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to decompress file %s with ZSTD, cause: %w", fileName, err)
+		}
+		f.zstdDecoder = zstdReader
+		f.Reader = zstdReader
+	case pb.Compression_GZIP:
+		gzipReader, err := gzip.NewReader(decryptedReader)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to decompress file %s with GZIP, cause: %w", fileName, err)
+		}
+		f.gzipReader = gzipReader
+		f.Reader = gzipReader
+	default:
+		f.Reader = decryptedReader
+	}
+	return f, nil
+}
+
+// File returns the underlying file if its contents are neither encrypted nor compressed, nil otherwise.
+func (f *DecodedFile) File() *os.File {
+	if f.aesDecoder != nil || f.zstdDecoder != nil || f.gzipReader != nil {
+		return nil
+	}
+	return f.file
+}
+
+func (f *DecodedFile) Close() {
+	if f.gzipReader != nil {
+		f.gzipReader.Close() //nolint:errcheck
+	}
+	if f.zstdDecoder != nil {
+		f.zstdDecoder.Close()
+	}
+	if f.aesDecoder != nil {
+		f.aesDecoder.Close()
+	}
+	if f.file != nil {
+		f.file.Close() //nolint:errcheck
+	}
+}
@@ -0,0 +1,50 @@
+package files
+
+import (
+	"io"
+	"testing"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatFromFlags(t *testing.T) {
+	original := *flags.BatchFileFormat
+	defer func() { *flags.BatchFileFormat = original }()
+
+	*flags.BatchFileFormat = "csv"
+	format, err := FormatFromFlags()
+	assert.NoError(t, err)
+	assert.Equal(t, pb.BatchFileFormat_CSV, format)
+
+	*flags.BatchFileFormat = "Parquet"
+	format, err = FormatFromFlags()
+	assert.NoError(t, err)
+	assert.Equal(t, pb.BatchFileFormat_PARQUET, format)
+
+	*flags.BatchFileFormat = "avro"
+	_, err = FormatFromFlags()
+	assert.ErrorContains(t, err, "invalid batch file format: avro, allowed values: csv, parquet")
+}
+
+func TestOpenDecodedFile(t *testing.T) {
+	fileName := "../../../tests/resources/campaign.csv"
+	plainFile, err := OpenDecodedFile(fileName, map[string][]byte{fileName: nil}, pb.Compression_OFF, pb.Encryption_NONE)
+	assert.NoError(t, err)
+	defer plainFile.Close()
+	// Plain files are exposed as-is for random access
+	assert.NotNil(t, plainFile.File())
+
+	compressedFileName := "../../../tests/resources/campaign.csv.zst"
+	compressedFile, err := OpenDecodedFile(compressedFileName, map[string][]byte{compressedFileName: nil}, pb.Compression_ZSTD, pb.Encryption_NONE)
+	assert.NoError(t, err)
+	defer compressedFile.Close()
+	assert.Nil(t, compressedFile.File())
+
+	plain, err := io.ReadAll(plainFile)
+	assert.NoError(t, err)
+	decompressed, err := io.ReadAll(compressedFile)
+	assert.NoError(t, err)
+	assert.Equal(t, plain, decompressed)
+}
@@ -28,6 +28,9 @@ func (s *ConfigDefinition) RegisterFlag() *uint {
 	return s.Flag
 }
 
+var BatchFileFormat = flag.String("batch-file-format", "csv",
+	"Batch file format requested from Fivetran: csv, parquet")
+
 var WriteBatchSizeSetting = ConfigDefinition{
 	Name: "write_batch_size", DefaultValue: 100_000, MinValue: 5_000, MaxValue: 100_000,
 	Description: "Batch size for INSERT operations (uses native protocol)"}
@@ -48,6 +51,21 @@ var HardDeleteBatchSizeSetting = ConfigDefinition{
 	Description: "Batch size for DELETE mutations (builds SQL strings, keep low to avoid large queries)"}
 var HardDeleteBatchSize = HardDeleteBatchSizeSetting.RegisterFlag()
 
+var WriteQueueDepthSetting = ConfigDefinition{
+	Name: "write_queue_depth", DefaultValue: 1, MinValue: 0, MaxValue: 4,
+	Description: "Number of batches read ahead while the previous batch is inserted (0 reads and inserts in sequence)"}
+var WriteQueueDepth = WriteQueueDepthSetting.RegisterFlag()
+
+var ParallelReplaceFilesSetting = ConfigDefinition{
+	Name: "parallel_replace_files", DefaultValue: 1, MinValue: 1, MaxValue: 16,
+	Description: "Number of replace files of a batch inserted at the same time (no more than max-open-connections in total)"}
+var ParallelReplaceFiles = ParallelReplaceFilesSetting.RegisterFlag()
+
+var ServerSideCSVParsing = flag.Bool("server-side-csv-parsing", false,
+	"Insert the replace CSV files with INSERT ... FORMAT CSVWithNames, so that the values are parsed by ClickHouse")
+var InsertDeduplication = flag.Bool("insert-deduplication", false,
+	"Set insert_deduplication_token for every batch of the replace files, so that ClickHouse skips the batches that were already inserted")
+
 var MaxParallelSelects = flag.Uint("max-parallel-selects", 10,
 	"Max number of parallel SELECT queries")
 
@@ -57,6 +75,14 @@ var MaxOpenConnections = flag.Uint("max-open-connections", 10,
 	"Max number of open connections for ClickHouse client (recommended: max-idle-connections + 5)")
 var RequestTimeoutDuration = flag.Duration("request-timeout-duration", 300*time.Second,
 	"Timeout for ClickHouse client requests")
+var ConnectionPoolIdleTimeout = flag.Duration("connection-pool-idle-timeout", 5*time.Minute,
+	"How long a pooled ClickHouse connection can stay unused before it is closed")
+var ConnectionPoolHealthCheckInterval = flag.Duration("connection-pool-health-check-interval", 30*time.Second,
+	"A pooled ClickHouse connection that was not used for longer than this is pinged before it is reused")
+var MetadataCacheTTL = flag.Duration("metadata-cache-ttl", 60*time.Second,
+	"How long the table descriptions and column types are cached between the calls; changes made outside of the destination are picked up after this time. 0 disables the cache")
+var HostFailureCooldown = flag.Duration("host-failure-cooldown", 30*time.Second,
+	"How long a ClickHouse host that failed to accept a connection is only tried after the other configured hosts")
 
 var MaxRetries = flag.Uint("max-retries", 10,
 	"Max number of retries for ClickHouse client in case of network errors")
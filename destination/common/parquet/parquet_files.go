@@ -0,0 +1,358 @@
+package parquet
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+	"fivetran.com/fivetran_sdk/destination/common/files"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/google/uuid"
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/shopspring/decimal"
+)
+
+const naiveTimeFormat = "15:04:05.999999999"
+
+// ParquetFileReader provides batched access to a Parquet file,
+// handling decryption and decompression transparently.
+//
+// As Parquet requires random access to the file (the metadata is stored in the footer),
+// encrypted or compressed files are decoded into a temporary file first. It is created next to the batch file,
+// so the decrypted data never leaves the directory that Fivetran provided for the batch,
+// and it is removed right away: only the open file descriptor keeps it until the Close method.
+//
+// Values are converted to the Go types expected by values.ParseTyped (see ReadTypedBatch),
+// or to the same string formats as in Fivetran CSV files (see ReadBatch).
+type ParquetFileReader struct {
+	fileName string
+	nullStr  string
+	header   []string
+	columns  []*column
+	reader   *parquet.Reader
+	rows     []parquet.Row
+	done     bool
+	// Keep track of the open files to close them in the Close method
+	file         *os.File
+	tempFileName string
+}
+
+var _ files.TypedBatchFileReader = (*ParquetFileReader)(nil)
+
+// column describes how to convert the values of a flat Parquet column.
+type column struct {
+	name string
+	// toValue converts a non-null Parquet value to one of the Go types returned by ReadTypedBatch.
+	toValue func(v parquet.Value) (any, error)
+	// timeFormat is used to format time.Time values in ReadBatch
+	timeFormat string
+}
+
+func NewParquetFileReader(
+	fileName string,
+	keys map[string][]byte,
+	compression pb.Compression,
+	encryption pb.Encryption,
+	nullStr string,
+) (*ParquetFileReader, error) {
+	decodedFile, err := files.OpenDecodedFile(fileName, keys, compression, encryption)
+	if err != nil {
+		return nil, err
+	}
+	r := &ParquetFileReader{
+		fileName: fileName,
+		nullStr:  nullStr,
+	}
+	if file := decodedFile.File(); file != nil {
+		r.file = file
+	} else {
+		err = r.decodeToTempFile(decodedFile)
+		decodedFile.Close()
+		if err != nil {
+			r.Close()
+			return nil, err
+		}
+	}
+
+	stat, err := r.file.Stat()
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to stat file %s: %w", fileName, err)
+	}
+	parquetFile, err := parquet.OpenFile(r.file, stat.Size())
+	if err != nil {
+		r.Close()
+		return nil, fmt.Errorf("failed to open Parquet file %s: %w", fileName, err)
+	}
+
+	fields := parquetFile.Schema().Fields()
+	if len(fields) == 0 {
+		r.Close()
+		return nil, fmt.Errorf("received a Parquet file %s without columns", fileName)
+	}
+	r.header = make([]string, len(fields))
+	r.columns = make([]*column, len(fields))
+	for i, field := range fields {
+		col, err := makeColumn(field)
+		if err != nil {
+			r.Close()
+			return nil, fmt.Errorf("unsupported column in Parquet file %s: %w", fileName, err)
+		}
+		r.header[i] = col.name
+		r.columns[i] = col
+	}
+	r.reader = parquet.NewReader(parquetFile)
+	return r, nil
+}
+
+func (r *ParquetFileReader) decodeToTempFile(decodedFile *files.DecodedFile) error {
+	tempFile, err := os.CreateTemp(filepath.Dir(r.fileName), "fivetran-batch-*.parquet")
+	if err != nil {
+		return fmt.Errorf("failed to create a temporary file for %s: %w", r.fileName, err)
+	}
+	r.file = tempFile
+	// if it can't be removed while open, try again after closing it in the Close method
+	if os.Remove(tempFile.Name()) != nil {
+		r.tempFileName = tempFile.Name()
+	}
+	if _, err = io.Copy(tempFile, decodedFile); err != nil {
+		return fmt.Errorf("failed to decode file %s: %w", r.fileName, err)
+	}
+	return nil
+}
+
+func (r *ParquetFileReader) Header() []string {
+	return r.header
+}
+
+// ReadTypedBatch reads up to batchSize data rows from the Parquet file.
+// NULL values are returned as nil; the rest of the values are returned as one of:
+// bool, int32, int64, float32, float64, decimal.Decimal, time.Time or string.
+// Returns (nil, nil) when there are no more rows to read.
+func (r *ParquetFileReader) ReadTypedBatch(batchSize uint) ([][]any, error) {
+	if batchSize <= 0 {
+		return nil, fmt.Errorf("batchSize must be greater than 0")
+	}
+	if r.done {
+		return nil, nil
+	}
+	if uint(len(r.rows)) < batchSize {
+		r.rows = make([]parquet.Row, batchSize)
+	}
+	rows := r.rows[:batchSize]
+	n, err := r.reader.ReadRows(rows)
+	if errors.Is(err, io.EOF) {
+		r.done = true
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read Parquet rows from file %s: %w", r.fileName, err)
+	}
+	if n == 0 {
+		return nil, nil
+	}
+	batch := make([][]any, n)
+	for i, row := range rows[:n] {
+		record := make([]any, len(r.columns))
+		for _, v := range row {
+			if v.IsNull() {
+				continue
+			}
+			col := r.columns[v.Column()]
+			value, err := col.toValue(v)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read Parquet value for column %s from file %s: %w", col.name, r.fileName, err)
+			}
+			record[v.Column()] = value
+		}
+		batch[i] = record
+	}
+	return batch, nil
+}
+
+// ReadBatch reads up to batchSize data rows from the Parquet file,
+// formatting the values the same way as they appear in Fivetran CSV files.
+// NULL values are replaced with the null string.
+// Returns (nil, nil) when there are no more rows to read.
+func (r *ParquetFileReader) ReadBatch(batchSize uint) ([][]string, error) {
+	typedBatch, err := r.ReadTypedBatch(batchSize)
+	if err != nil || typedBatch == nil {
+		return nil, err
+	}
+	batch := make([][]string, len(typedBatch))
+	for i, typedRecord := range typedBatch {
+		record := make([]string, len(typedRecord))
+		for j, value := range typedRecord {
+			record[j] = r.format(r.columns[j], value)
+		}
+		batch[i] = record
+	}
+	return batch, nil
+}
+
+func (r *ParquetFileReader) format(col *column, value any) string {
+	switch value := value.(type) {
+	case nil:
+		return r.nullStr
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case int32:
+		return strconv.FormatInt(int64(value), 10)
+	case int64:
+		return strconv.FormatInt(value, 10)
+	case float32:
+		return strconv.FormatFloat(float64(value), 'g', -1, 32)
+	case float64:
+		return strconv.FormatFloat(value, 'g', -1, 64)
+	case decimal.Decimal:
+		return value.String()
+	case time.Time:
+		return value.Format(col.timeFormat)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+func (r *ParquetFileReader) Close() {
+	if r.reader != nil {
+		r.reader.Close() //nolint:errcheck
+	}
+	if r.file != nil {
+		r.file.Close() //nolint:errcheck
+	}
+	if r.tempFileName != "" {
+		os.Remove(r.tempFileName) //nolint:errcheck
+	}
+}
+
+func makeColumn(field parquet.Field) (*column, error) {
+	col := &column{name: field.Name()}
+	if !field.Leaf() || field.Repeated() {
+		return nil, fmt.Errorf("nested or repeated column %s", col.name)
+	}
+	fieldType := field.Type()
+	var logicalType format.LogicalTypeValue
+	if lt := fieldType.LogicalType(); lt != nil {
+		logicalType = lt.Value
+	}
+	kind := fieldType.Kind()
+	switch lt := logicalType.(type) {
+	case *format.DecimalType:
+		scale := lt.Scale
+		switch kind {
+		case parquet.Int32:
+			col.toValue = func(v parquet.Value) (any, error) {
+				return decimal.New(int64(v.Int32()), -scale), nil
+			}
+		case parquet.Int64:
+			col.toValue = func(v parquet.Value) (any, error) {
+				return decimal.New(v.Int64(), -scale), nil
+			}
+		case parquet.ByteArray, parquet.FixedLenByteArray:
+			col.toValue = func(v parquet.Value) (any, error) {
+				return decimal.NewFromBigInt(bigIntFromTwosComplement(v.ByteArray()), -scale), nil
+			}
+		default:
+			return nil, fmt.Errorf("column %s has DECIMAL logical type with unexpected physical type %s", col.name, kind)
+		}
+		return col, nil
+	case *format.DateType:
+		col.timeFormat = constants.NaiveDateFormat
+		col.toValue = func(v parquet.Value) (any, error) {
+			return time.Unix(int64(v.Int32())*24*60*60, 0).UTC(), nil
+		}
+		return col, nil
+	case *format.TimestampType:
+		if lt.IsAdjustedToUTC {
+			col.timeFormat = time.RFC3339Nano
+		} else {
+			col.timeFormat = constants.NaiveDateTimeFormat + ".999999999"
+		}
+		unit := timeUnitDuration(lt.Unit)
+		col.toValue = func(v parquet.Value) (any, error) {
+			return timeFromUnits(v.Int64(), unit), nil
+		}
+		return col, nil
+	case *format.TimeType:
+		unit := timeUnitDuration(lt.Unit)
+		col.toValue = func(v parquet.Value) (any, error) {
+			var units int64
+			if v.Kind() == parquet.Int32 {
+				units = int64(v.Int32())
+			} else {
+				units = v.Int64()
+			}
+			return time.Time{}.Add(time.Duration(units) * unit).Format(naiveTimeFormat), nil
+		}
+		return col, nil
+	case *format.UUIDType:
+		col.toValue = func(v parquet.Value) (any, error) {
+			id, err := uuid.FromBytes(v.ByteArray())
+			if err != nil {
+				return nil, err
+			}
+			return id.String(), nil
+		}
+		return col, nil
+	case *format.StringType, *format.EnumType, *format.JsonType:
+		col.toValue = func(v parquet.Value) (any, error) {
+			return string(v.ByteArray()), nil
+		}
+		return col, nil
+	}
+	switch kind {
+	case parquet.Boolean:
+		col.toValue = func(v parquet.Value) (any, error) { return v.Boolean(), nil }
+	case parquet.Int32:
+		col.toValue = func(v parquet.Value) (any, error) { return v.Int32(), nil }
+	case parquet.Int64:
+		col.toValue = func(v parquet.Value) (any, error) { return v.Int64(), nil }
+	case parquet.Float:
+		col.toValue = func(v parquet.Value) (any, error) { return v.Float(), nil }
+	case parquet.Double:
+		col.toValue = func(v parquet.Value) (any, error) { return v.Double(), nil }
+	case parquet.ByteArray, parquet.FixedLenByteArray:
+		// binary values without a logical type are encoded as Base64, same as in Fivetran CSV files
+		col.toValue = func(v parquet.Value) (any, error) {
+			return base64.StdEncoding.EncodeToString(v.ByteArray()), nil
+		}
+	default:
+		return nil, fmt.Errorf("column %s has unsupported physical type %s", col.name, kind)
+	}
+	return col, nil
+}
+
+func timeUnitDuration(unit format.TimeUnit) time.Duration {
+	if unit.Value == nil {
+		return time.Microsecond
+	}
+	return unit.Value.Duration()
+}
+
+func timeFromUnits(units int64, unit time.Duration) time.Time {
+	switch unit {
+	case time.Millisecond:
+		return time.UnixMilli(units).UTC()
+	case time.Microsecond:
+		return time.UnixMicro(units).UTC()
+	default:
+		return time.Unix(0, units).UTC()
+	}
+}
+
+// bigIntFromTwosComplement decodes a big-endian two's complement integer, as used by DECIMAL values.
+func bigIntFromTwosComplement(b []byte) *big.Int {
+	result := new(big.Int).SetBytes(b)
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		result.Sub(result, new(big.Int).Lsh(big.NewInt(1), uint(len(b)*8)))
+	}
+	return result
+}
@@ -0,0 +1,187 @@
+package parquet
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/klauspost/compress/zstd"
+	"github.com/parquet-go/parquet-go"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var key, _ = base64.StdEncoding.DecodeString("VyEZCkPngvf4mtRHemjGkC6tmd/22j0R9z+DQv2he/Q=")
+
+type testRow struct {
+	ID        int64     `parquet:"id"`
+	Name      *string   `parquet:"name,optional"`
+	Active    bool      `parquet:"active"`
+	Score     float64   `parquet:"score"`
+	Amount    int64     `parquet:"amount,decimal(2:18)"`
+	Day       int32     `parquet:"day,date"`
+	UpdatedAt time.Time `parquet:"updated_at,timestamp(microsecond)"`
+	Payload   []byte    `parquet:"payload"`
+}
+
+var testRows = []testRow{
+	{
+		ID:        1,
+		Name:      ptr("foo"),
+		Active:    true,
+		Score:     100.5,
+		Amount:    4747,
+		Day:       19056, // 2022-03-05
+		UpdatedAt: time.Date(2022, 3, 5, 4, 45, 12, 123456000, time.UTC),
+		Payload:   []byte{0x42},
+	},
+	{
+		ID:        2,
+		Name:      nil,
+		Active:    false,
+		Score:     -200.25,
+		Amount:    -10055,
+		Day:       19483, // 2023-05-06
+		UpdatedAt: time.Date(2023, 5, 6, 2, 12, 15, 0, time.UTC),
+		Payload:   []byte{0xFF, 0x00},
+	},
+}
+
+func TestParquetFileReaderReadTypedBatch(t *testing.T) {
+	fileName := writeTestParquetFile(t, pb.Compression_OFF, pb.Encryption_NONE)
+
+	reader, err := NewParquetFileReader(fileName, map[string][]byte{fileName: key}, pb.Compression_OFF, pb.Encryption_NONE, "my-null-str")
+	require.NoError(t, err)
+	defer reader.Close()
+
+	assert.Equal(t, []string{"id", "name", "active", "score", "amount", "day", "updated_at", "payload"}, reader.Header())
+
+	batch, err := reader.ReadTypedBatch(100)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]any{
+		{
+			int64(1), "foo", true, float64(100.5), decimal.New(4747, -2),
+			time.Date(2022, 3, 5, 0, 0, 0, 0, time.UTC),
+			time.Date(2022, 3, 5, 4, 45, 12, 123456000, time.UTC),
+			"Qg==",
+		},
+		{
+			int64(2), nil, false, float64(-200.25), decimal.New(-10055, -2),
+			time.Date(2023, 5, 6, 0, 0, 0, 0, time.UTC),
+			time.Date(2023, 5, 6, 2, 12, 15, 0, time.UTC),
+			"/wA=",
+		},
+	}, batch)
+
+	batch, err = reader.ReadTypedBatch(100)
+	assert.NoError(t, err)
+	assert.Nil(t, batch)
+}
+
+func TestParquetFileReaderReadsRowsInBatches(t *testing.T) {
+	fileName := writeTestParquetFile(t, pb.Compression_ZSTD, pb.Encryption_AES)
+
+	reader, err := NewParquetFileReader(fileName, map[string][]byte{fileName: key}, pb.Compression_ZSTD, pb.Encryption_AES, "my-null-str")
+	require.NoError(t, err)
+	defer reader.Close()
+
+	// The file is decoded into a temporary file next to the batch file, which is removed right away
+	assert.Equal(t, filepath.Dir(fileName), filepath.Dir(reader.file.Name()))
+	assert.Empty(t, reader.tempFileName)
+	_, err = os.Stat(reader.file.Name())
+	assert.True(t, os.IsNotExist(err))
+
+	batch1, err := reader.ReadBatch(1)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"1", "foo", "true", "100.5", "47.47", "2022-03-05", "2022-03-05T04:45:12.123456Z", "Qg=="},
+	}, batch1)
+
+	batch2, err := reader.ReadBatch(1)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{
+		{"2", "my-null-str", "false", "-200.25", "-100.55", "2023-05-06", "2023-05-06T02:12:15Z", "/wA="},
+	}, batch2)
+
+	batch3, err := reader.ReadBatch(1)
+	assert.NoError(t, err)
+	assert.Nil(t, batch3)
+}
+
+func TestParquetFileReaderErrors(t *testing.T) {
+	fileName := writeTestParquetFile(t, pb.Compression_ZSTD, pb.Encryption_AES)
+
+	// File not found
+	_, err := NewParquetFileReader("nonexistent.parquet", map[string][]byte{"nonexistent.parquet": key}, pb.Compression_OFF, pb.Encryption_NONE, "")
+	assert.ErrorContains(t, err, "failed to open file nonexistent.parquet")
+
+	// Key was not provided
+	_, err = NewParquetFileReader(fileName, map[string][]byte{"not-found": key}, pb.Compression_ZSTD, pb.Encryption_AES, "")
+	assert.ErrorContains(t, err, "key for file "+fileName+" not found")
+
+	// Wrong encryption type -> can't decompress
+	_, err = NewParquetFileReader(fileName, map[string][]byte{fileName: key}, pb.Compression_ZSTD, pb.Encryption_NONE, "")
+	assert.ErrorContains(t, err, "magic number mismatch")
+
+	// Not a Parquet file
+	csvFileName := "../../../tests/resources/campaign.csv"
+	_, err = NewParquetFileReader(csvFileName, map[string][]byte{csvFileName: key}, pb.Compression_OFF, pb.Encryption_NONE, "")
+	assert.ErrorContains(t, err, "failed to open Parquet file")
+
+	// Batch size is 0
+	plainFileName := writeTestParquetFile(t, pb.Compression_OFF, pb.Encryption_NONE)
+	reader, err := NewParquetFileReader(plainFileName, map[string][]byte{plainFileName: key}, pb.Compression_OFF, pb.Encryption_NONE, "")
+	require.NoError(t, err)
+	_, err = reader.ReadBatch(0)
+	assert.ErrorContains(t, err, "batchSize must be greater than 0")
+	reader.Close()
+}
+
+func TestBigIntFromTwosComplement(t *testing.T) {
+	assert.Equal(t, "0", bigIntFromTwosComplement([]byte{}).String())
+	assert.Equal(t, "1", bigIntFromTwosComplement([]byte{0x01}).String())
+	assert.Equal(t, "127", bigIntFromTwosComplement([]byte{0x7F}).String())
+	assert.Equal(t, "-128", bigIntFromTwosComplement([]byte{0x80}).String())
+	assert.Equal(t, "-1", bigIntFromTwosComplement([]byte{0xFF, 0xFF}).String())
+	assert.Equal(t, "256", bigIntFromTwosComplement([]byte{0x01, 0x00}).String())
+}
+
+// writeTestParquetFile writes testRows into a temporary Parquet file,
+// compressing and encrypting it the same way as Fivetran batch files.
+func writeTestParquetFile(t *testing.T, compression pb.Compression, encryption pb.Encryption) string {
+	var buf bytes.Buffer
+	require.NoError(t, parquet.Write(&buf, testRows))
+	data := buf.Bytes()
+
+	if compression == pb.Compression_ZSTD {
+		encoder, err := zstd.NewWriter(nil)
+		require.NoError(t, err)
+		data = encoder.EncodeAll(data, nil)
+		require.NoError(t, encoder.Close())
+	}
+	if encryption == pb.Encryption_AES {
+		block, err := aes.NewCipher(key)
+		require.NoError(t, err)
+		padding := aes.BlockSize - len(data)%aes.BlockSize
+		data = append(data, bytes.Repeat([]byte{byte(padding)}, padding)...)
+		iv := make([]byte, aes.BlockSize)
+		encrypted := make([]byte, len(data))
+		cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, data)
+		data = append(iv, encrypted...)
+	}
+
+	fileName := filepath.Join(t.TempDir(), "batch.parquet")
+	require.NoError(t, os.WriteFile(fileName, data, 0o600))
+	return fileName
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -2,10 +2,14 @@ package retry
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"regexp"
+	"strconv"
 	"time"
 
 	"fivetran.com/fivetran_sdk/destination/common/benchmark"
@@ -20,6 +24,30 @@ import (
 // Reference: https://github.com/ClickHouse/ClickHouse/blob/master/src/Common/ErrorCodes.cpp
 const chCodeKeeperException = 999
 
+// Over HTTP, the driver does not return *clickhouse.Exception for non-200 responses;
+// instead, the error message contains the HTTP status code and the response body,
+// for example: [HTTP 500] response body: "Code: 341. DB::Exception: Mutation is not finished ..."
+var (
+	httpExceptionCodeRegex = regexp.MustCompile(`\[HTTP \d+\] response body: "Code: (\d+)\. DB::Exception`)
+	httpUnavailableRegex   = regexp.MustCompile(`\[HTTP (502|503|504)\]`)
+)
+
+// Settings configures the exponential backoff used by OnNetError and OnNetErrorWithData.
+type Settings struct {
+	MaxRetries                    uint
+	InitialRetryDelayMilliseconds uint
+	MaxRetryDelayMilliseconds     uint
+}
+
+// SettingsFromFlags returns the retry Settings defined by the process flags.
+func SettingsFromFlags() Settings {
+	return Settings{
+		MaxRetries:                    *flags.MaxRetries,
+		InitialRetryDelayMilliseconds: *flags.InitialRetryDelayMilliseconds,
+		MaxRetryDelayMilliseconds:     *flags.MaxRetryDelayMilliseconds,
+	}
+}
+
 // OnNetError retries the given operation if it returns a transient error
 // (network failure or a ClickHouse Keeper exception) using an exponential
 // backoff strategy. Any other error will be returned immediately.
@@ -27,10 +55,11 @@ const chCodeKeeperException = 999
 func OnNetError(
 	op func() error,
 	ctx context.Context,
+	settings Settings,
 	opName string,
 	withBenchmark bool,
 ) (err error) {
-	initialDelay, maxDelay := GetDelayConfig()
+	initialDelay, maxDelay := GetDelayConfig(settings)
 	failCount := uint(0)
 	for {
 		if withBenchmark {
@@ -46,7 +75,7 @@ func OnNetError(
 		}
 		failCount++
 		log.Warn(fmt.Sprintf("retrying %s, cause: %s", opName, err))
-		if failCount < *flags.MaxRetries {
+		if failCount < settings.MaxRetries {
 			delay := GetBackoffDelay(initialDelay, maxDelay, failCount)
 			select {
 			case <-ctx.Done():
@@ -59,7 +88,7 @@ func OnNetError(
 		}
 	}
 	if err != nil {
-		return fmt.Errorf("%s failed after %d attempts: %w", opName, *flags.MaxRetries, err)
+		return fmt.Errorf("%s failed after %d attempts: %w", opName, settings.MaxRetries, err)
 	}
 	return nil
 }
@@ -71,10 +100,11 @@ func OnNetError(
 func OnNetErrorWithData[T any](
 	op func() (T, error),
 	ctx context.Context,
+	settings Settings,
 	opName string,
 	withBenchmark bool,
 ) (data T, err error) {
-	initialDelay, maxDelay := GetDelayConfig()
+	initialDelay, maxDelay := GetDelayConfig(settings)
 	failCount := uint(0)
 	for {
 		if withBenchmark {
@@ -91,7 +121,7 @@ func OnNetErrorWithData[T any](
 		}
 		failCount++
 		log.Warn(fmt.Sprintf("retrying %s, cause: %s", opName, err))
-		if failCount < *flags.MaxRetries {
+		if failCount < settings.MaxRetries {
 			delay := GetBackoffDelay(initialDelay, maxDelay, failCount)
 			select {
 			case <-ctx.Done():
@@ -106,7 +136,7 @@ func OnNetErrorWithData[T any](
 	}
 	if err != nil {
 		var empty T
-		return empty, fmt.Errorf("%s failed after %d attempts: %w", opName, *flags.MaxRetries, err)
+		return empty, fmt.Errorf("%s failed after %d attempts: %w", opName, settings.MaxRetries, err)
 	}
 	return data, nil
 }
@@ -148,12 +178,40 @@ func OnFalseWithFixedDelay(
 // syscall.ECONNRESET (syscall.Errno has Timeout/Temporary methods) and net.ErrClosed
 // (its underlying type does too). The explicit io.EOF branch is required because
 // both ch-go and clickhouse-go wrap io.EOF in additional
+//
+// Over HTTP, io.ErrUnexpectedEOF (truncated response) and 502/503/504 responses
+// (typically from proxies and load balancers in front of ClickHouse) are treated as network failures too.
 func IsNetError(err error) bool {
 	if err == nil {
 		return false
 	}
+	// a failed TLS handshake (e.g. a rejected client certificate) is reported as *net.OpError,
+	// but it is a configuration problem, and retrying it won't help
+	if IsTLSError(err) {
+		return false
+	}
 	var netErr net.Error
-	return errors.As(err, &netErr) || errors.Is(err, io.EOF)
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		httpUnavailableRegex.MatchString(err.Error())
+}
+
+// IsTLSError returns true if err is (or wraps) a TLS handshake or certificate verification failure,
+// either detected locally or reported by the server via a TLS alert.
+func IsTLSError(err error) bool {
+	var alertErr tls.AlertError
+	var recordHeaderErr tls.RecordHeaderError
+	var verificationErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	return errors.As(err, &alertErr) ||
+		errors.As(err, &recordHeaderErr) ||
+		errors.As(err, &verificationErr) ||
+		errors.As(err, &unknownAuthorityErr) ||
+		errors.As(err, &hostnameErr) ||
+		errors.As(err, &certificateInvalidErr)
 }
 
 // IsKeeperException returns true if err is (or wraps) a ClickHouse server
@@ -161,11 +219,29 @@ func IsNetError(err error) bool {
 // the underlying ZooKeeper/Keeper layer — most commonly "Session expired",
 // connection loss, or operation timeout — and should be retried.
 func IsKeeperException(err error) bool {
+	code, ok := ExceptionCode(err)
+	return ok && code == chCodeKeeperException
+}
+
+// ExceptionCode returns the ClickHouse server exception code if err is (or wraps) a server exception,
+// received either via the Native protocol (*clickhouse.Exception) or via HTTP (parsed from the response body).
+func ExceptionCode(err error) (int32, bool) {
 	if err == nil {
-		return false
+		return 0, false
 	}
 	var ex *clickhouse.Exception
-	return errors.As(err, &ex) && ex.Code == chCodeKeeperException
+	if errors.As(err, &ex) {
+		return ex.Code, true
+	}
+	match := httpExceptionCodeRegex.FindStringSubmatch(err.Error())
+	if match == nil {
+		return 0, false
+	}
+	code, parseErr := strconv.ParseInt(match[1], 10, 32)
+	if parseErr != nil {
+		return 0, false
+	}
+	return int32(code), true
 }
 
 // IsRetryable returns true if err represents a transient failure that the
@@ -174,16 +250,16 @@ func IsRetryable(err error) bool {
 	return IsNetError(err) || IsKeeperException(err)
 }
 
-func GetDelayConfig() (initial time.Duration, maxDelay time.Duration) {
-	if *flags.InitialRetryDelayMilliseconds == 0 {
+func GetDelayConfig(settings Settings) (initial time.Duration, maxDelay time.Duration) {
+	if settings.InitialRetryDelayMilliseconds == 0 {
 		initial = time.Second
 	} else {
-		initial = time.Duration(*flags.InitialRetryDelayMilliseconds) * time.Millisecond
+		initial = time.Duration(settings.InitialRetryDelayMilliseconds) * time.Millisecond
 	}
-	if *flags.MaxRetryDelayMilliseconds == 0 || *flags.MaxRetryDelayMilliseconds < *flags.InitialRetryDelayMilliseconds {
+	if settings.MaxRetryDelayMilliseconds == 0 || settings.MaxRetryDelayMilliseconds < settings.InitialRetryDelayMilliseconds {
 		return initial, initial
 	}
-	return initial, time.Duration(*flags.MaxRetryDelayMilliseconds) * time.Millisecond
+	return initial, time.Duration(settings.MaxRetryDelayMilliseconds) * time.Millisecond
 }
 
 func GetBackoffDelay(initialDelay time.Duration, maxDelay time.Duration, failCount uint) time.Duration {
@@ -2,6 +2,8 @@ package retry
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -10,7 +12,6 @@ import (
 	"testing"
 	"time"
 
-	"fivetran.com/fivetran_sdk/destination/common/flags"
 	"github.com/ClickHouse/clickhouse-go/v2"
 	"github.com/stretchr/testify/assert"
 )
@@ -44,6 +45,50 @@ func TestIsNetError(t *testing.T) {
 	// ClickHouse Keeper exceptions are NOT net errors — they are server-side
 	// exceptions handled by IsKeeperException / IsRetryable.
 	assert.False(t, IsNetError(&clickhouse.Exception{Code: 999, Message: "Session expired"}))
+
+	// HTTP protocol: truncated responses and proxy / load balancer errors.
+	assert.True(t, IsNetError(fmt.Errorf("readData: %w", io.ErrUnexpectedEOF)))
+	assert.True(t, IsNetError(errors.New(`sendQuery: [HTTP 503] response body: "upstream connect error"`)))
+	assert.True(t, IsNetError(errors.New(`sendQuery: [HTTP 502] response body: "Bad Gateway"`)))
+	assert.True(t, IsNetError(errors.New(`sendQuery: [HTTP 504] response body: "Gateway Timeout"`)))
+	assert.False(t, IsNetError(errors.New(`sendQuery: [HTTP 500] response body: "Code: 341. DB::Exception: Mutation is not finished"`)))
+
+	// TLS handshake failures are not retried, even if reported as *net.OpError.
+	assert.False(t, IsNetError(&net.OpError{Op: "remote error", Err: tls.AlertError(42)}))
+}
+
+func TestIsTLSError(t *testing.T) {
+	assert.False(t, IsTLSError(nil))
+	assert.False(t, IsTLSError(makeNetError()))
+	assert.False(t, IsTLSError(errors.New("x509: certificate signed by unknown authority")))
+	// client certificate rejected by the server
+	assert.True(t, IsTLSError(&net.OpError{Op: "remote error", Err: tls.AlertError(42)}))
+	// the server does not speak TLS on this port
+	assert.True(t, IsTLSError(fmt.Errorf("dial: %w", tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"})))
+	// server certificate verification failures
+	assert.True(t, IsTLSError(&tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}))
+	assert.True(t, IsTLSError(fmt.Errorf("dial: %w", x509.HostnameError{Host: "my.host", Certificate: &x509.Certificate{}})))
+	assert.True(t, IsTLSError(x509.CertificateInvalidError{Reason: x509.Expired}))
+}
+
+func TestExceptionCode(t *testing.T) {
+	_, ok := ExceptionCode(nil)
+	assert.False(t, ok)
+	_, ok = ExceptionCode(errors.New("plain error"))
+	assert.False(t, ok)
+	_, ok = ExceptionCode(errors.New(`sendQuery: [HTTP 503] response body: "upstream connect error"`))
+	assert.False(t, ok)
+
+	// Native protocol
+	code, ok := ExceptionCode(fmt.Errorf("wrapped: %w", &clickhouse.Exception{Code: 341, Message: "Mutation is not finished"}))
+	assert.True(t, ok)
+	assert.Equal(t, int32(341), code)
+
+	// HTTP protocol
+	code, ok = ExceptionCode(fmt.Errorf("error while executing ALTER TABLE x [query_id=abc]: %w",
+		errors.New(`sendQuery: [HTTP 500] response body: "Code: 341. DB::Exception: Mutation is not finished because some replicas are inactive right now. (UNFINISHED) (version 25.12.1.1)"`)))
+	assert.True(t, ok)
+	assert.Equal(t, int32(341), code)
 }
 
 func TestIsKeeperException(t *testing.T) {
@@ -61,6 +106,9 @@ func TestIsKeeperException(t *testing.T) {
 
 	// Other ClickHouse codes must not match.
 	assert.False(t, IsKeeperException(&clickhouse.Exception{Code: 516, Message: "Authentication failed"}))
+
+	// Over HTTP, the exception is only available in the error message.
+	assert.True(t, IsKeeperException(errors.New(`sendQuery: [HTTP 500] response body: "Code: 999. DB::Exception: Session expired. (KEEPER_EXCEPTION)"`)))
 }
 
 func TestIsRetryable(t *testing.T) {
@@ -80,40 +128,29 @@ func TestGetBackoffDelay(t *testing.T) {
 }
 
 func TestGetRetryDelayConfig(t *testing.T) {
-	td := teardown()
-	defer td(t)
-
-	*flags.InitialRetryDelayMilliseconds = 0
-	*flags.MaxRetryDelayMilliseconds = 10
-	initial, maxDelay := GetDelayConfig()
+	initial, maxDelay := GetDelayConfig(Settings{InitialRetryDelayMilliseconds: 0, MaxRetryDelayMilliseconds: 10})
 	assert.Equal(t, time.Second, initial)
 	assert.Equal(t, time.Millisecond*10, maxDelay)
 
-	*flags.InitialRetryDelayMilliseconds = 50
-	*flags.MaxRetryDelayMilliseconds = 0
-	initial, maxDelay = GetDelayConfig()
+	initial, maxDelay = GetDelayConfig(Settings{InitialRetryDelayMilliseconds: 50, MaxRetryDelayMilliseconds: 0})
 	assert.Equal(t, time.Millisecond*50, initial)
 	assert.Equal(t, time.Millisecond*50, maxDelay)
 
-	*flags.InitialRetryDelayMilliseconds = 42
-	*flags.MaxRetryDelayMilliseconds = 144
-	initial, maxDelay = GetDelayConfig()
+	initial, maxDelay = GetDelayConfig(Settings{InitialRetryDelayMilliseconds: 42, MaxRetryDelayMilliseconds: 144})
 	assert.Equal(t, time.Millisecond*42, initial)
 	assert.Equal(t, time.Millisecond*144, maxDelay)
 
-	*flags.InitialRetryDelayMilliseconds = 144
-	*flags.MaxRetryDelayMilliseconds = 42
-	initial, maxDelay = GetDelayConfig()
+	initial, maxDelay = GetDelayConfig(Settings{InitialRetryDelayMilliseconds: 144, MaxRetryDelayMilliseconds: 42})
 	assert.Equal(t, time.Millisecond*144, initial)
 	assert.Equal(t, time.Millisecond*144, maxDelay)
 }
 
 func TestRetryNetError(t *testing.T) {
-	defer setupSuite()(t)
+	settings := Settings{MaxRetries: 2, InitialRetryDelayMilliseconds: 10}
 
 	err := OnNetError(func() error {
 		return nil
-	}, context.Background(), "TestRetryNetError", false)
+	}, context.Background(), settings, "TestRetryNetError", false)
 	assert.NoError(t, err)
 
 	count := 0
@@ -123,7 +160,7 @@ func TestRetryNetError(t *testing.T) {
 			return nil
 		}
 		return makeNetError()
-	}, context.Background(), "TestRetryNetError", false)
+	}, context.Background(), settings, "TestRetryNetError", false)
 	assert.NoError(t, err)
 
 	count = 0
@@ -133,12 +170,12 @@ func TestRetryNetError(t *testing.T) {
 			return nil
 		}
 		return makeNetError()
-	}, context.Background(), "TestRetryNetError", false)
+	}, context.Background(), settings, "TestRetryNetError", false)
 	assert.ErrorContains(t, err, "TestRetryNetError failed after 2 attempts")
 }
 
 func TestRetryKeeperException(t *testing.T) {
-	defer setupSuite()(t)
+	settings := Settings{MaxRetries: 2, InitialRetryDelayMilliseconds: 10}
 
 	// A code 999 / "Session expired" eventually succeeds after one retry.
 	count := 0
@@ -148,7 +185,7 @@ func TestRetryKeeperException(t *testing.T) {
 			return nil
 		}
 		return &clickhouse.Exception{Code: 999, Message: "Session expired"}
-	}, context.Background(), "TestRetryKeeperException", false)
+	}, context.Background(), settings, "TestRetryKeeperException", false)
 	assert.NoError(t, err)
 	assert.Equal(t, 2, count)
 
@@ -157,7 +194,7 @@ func TestRetryKeeperException(t *testing.T) {
 	err = OnNetError(func() error {
 		count++
 		return &clickhouse.Exception{Code: 497, Message: "Not enough privileges"}
-	}, context.Background(), "TestRetryKeeperException", false)
+	}, context.Background(), settings, "TestRetryKeeperException", false)
 	assert.Error(t, err)
 	assert.Equal(t, 1, count)
 	var ex *clickhouse.Exception
@@ -166,7 +203,7 @@ func TestRetryKeeperException(t *testing.T) {
 }
 
 func TestRetryNetErrorWithData(t *testing.T) {
-	defer setupSuite()(t)
+	settings := Settings{MaxRetries: 2, InitialRetryDelayMilliseconds: 10}
 
 	type myType struct {
 		value int
@@ -174,7 +211,7 @@ func TestRetryNetErrorWithData(t *testing.T) {
 
 	data, err := OnNetErrorWithData(func() (int, error) {
 		return 42, nil
-	}, context.Background(), "TestRetryNetErrorWithData", false)
+	}, context.Background(), settings, "TestRetryNetErrorWithData", false)
 	assert.NoError(t, err)
 	assert.Equal(t, 42, data)
 
@@ -185,7 +222,7 @@ func TestRetryNetErrorWithData(t *testing.T) {
 			return 144, nil
 		}
 		return 0, makeNetError()
-	}, context.Background(), "TestRetryNetErrorWithData", false)
+	}, context.Background(), settings, "TestRetryNetErrorWithData", false)
 	assert.NoError(t, err)
 	assert.Equal(t, 144, data)
 
@@ -196,13 +233,13 @@ func TestRetryNetErrorWithData(t *testing.T) {
 			return 1, nil
 		}
 		return 0, makeNetError()
-	}, context.Background(), "TestRetryNetErrorWithData", false)
+	}, context.Background(), settings, "TestRetryNetErrorWithData", false)
 	assert.ErrorContains(t, err, "TestRetryNetErrorWithData failed after 2 attempts")
 
 	// also works with an arbitrary type
 	dataT, err := OnNetErrorWithData(func() (myType, error) {
 		return myType{42}, nil
-	}, context.Background(), "TestRetryNetErrorWithData(T)", false)
+	}, context.Background(), settings, "TestRetryNetErrorWithData(T)", false)
 	assert.NoError(t, err)
 	assert.Equal(t, myType{42}, dataT)
 
@@ -213,7 +250,7 @@ func TestRetryNetErrorWithData(t *testing.T) {
 			return myType{144}, nil
 		}
 		return myType{}, makeNetError()
-	}, context.Background(), "TestRetryNetErrorWithData(T)", false)
+	}, context.Background(), settings, "TestRetryNetErrorWithData(T)", false)
 	assert.NoError(t, err)
 	assert.Equal(t, myType{144}, dataT)
 
@@ -224,17 +261,12 @@ func TestRetryNetErrorWithData(t *testing.T) {
 			return myType{42}, nil
 		}
 		return myType{}, makeNetError()
-	}, context.Background(), "TestRetryNetErrorWithData(T)", false)
+	}, context.Background(), settings, "TestRetryNetErrorWithData(T)", false)
 	assert.ErrorContains(t, err, "TestRetryNetErrorWithData(T) failed after 2 attempts")
 }
 
 func TestRetryNetErrorDelayConfiguration(t *testing.T) {
-	defer func() func(t *testing.T) {
-		td := teardown()
-		*flags.MaxRetries = 3
-		*flags.InitialRetryDelayMilliseconds = 50
-		return td
-	}()(t)
+	settings := Settings{MaxRetries: 3, InitialRetryDelayMilliseconds: 50}
 
 	count := 0
 	start := time.Now()
@@ -244,7 +276,7 @@ func TestRetryNetErrorDelayConfiguration(t *testing.T) {
 			return nil
 		}
 		return makeNetError()
-	}, context.Background(), "TestRetryNetError", false)
+	}, context.Background(), settings, "TestRetryNetError", false)
 	assert.NoError(t, err)
 	assert.GreaterOrEqual(t, time.Since(start).Milliseconds(), int64(50*2))
 
@@ -256,7 +288,7 @@ func TestRetryNetErrorDelayConfiguration(t *testing.T) {
 			return 144, nil
 		}
 		return 0, makeNetError()
-	}, context.Background(), "TestRetryNetErrorWithData", false)
+	}, context.Background(), settings, "TestRetryNetErrorWithData", false)
 	assert.NoError(t, err)
 	assert.Equal(t, 144, data)
 	assert.GreaterOrEqual(t, time.Since(start).Milliseconds(), int64(50*2))
@@ -295,22 +327,6 @@ func TestRetryOnFalseWithFixedDelay(t *testing.T) {
 	assert.GreaterOrEqual(t, time.Since(start).Milliseconds(), int64(delayMs*3)) // #1 (delay) #2 (delay) #3 (delay) #4
 }
 
-func setupSuite() func(t *testing.T) {
-	td := teardown()
-	*flags.MaxRetries = 2
-	*flags.InitialRetryDelayMilliseconds = 10
-	return td
-}
-
-func teardown() func(t *testing.T) {
-	currentMaxRetries := *flags.MaxRetries
-	currentMaxDelayMs := *flags.InitialRetryDelayMilliseconds
-	return func(t *testing.T) {
-		*flags.MaxRetries = currentMaxRetries
-		*flags.InitialRetryDelayMilliseconds = currentMaxDelayMs
-	}
-}
-
 func makeNetError() net.Error {
 	return net.Error(&net.OpError{Op: "read", Err: net.UnknownNetworkError("net error")})
 }
@@ -35,10 +35,34 @@ func CheckScanTypes(
 		case constants.FivetranDeleted:
 			scanType = scanTypeBool
 		default:
+			if fivetranCol.Type == pb.DataType_JSON && driverCol.IsNativeJSON() {
+				// native JSON columns can't be Nullable, see MakeDriverColumns
+				scanType = scanTypeString
+				break
+			}
+			if fivetranCol.Type == pb.DataType_NAIVE_TIME && driverCol.IsNativeTime() {
+				scanType = scanTypeNullableDuration
+				if fivetranCol.PrimaryKey {
+					scanType = scanTypeDuration
+				}
+				break
+			}
+			if fivetranCol.Type == pb.DataType_DECIMAL && isStringScanType(driverCol.ScanType) {
+				// the decimals out of the ClickHouse range can be stored as String, see config.Config.DecimalOverflow
+				scanType = scanTypeNullableString
+				if fivetranCol.PrimaryKey {
+					scanType = scanTypeString
+				}
+				break
+			}
 			scanType, ok = pkToFivetranToScanType[fivetranCol.PrimaryKey][fivetranCol.Type]
 			if !ok {
 				return fmt.Errorf("unknown Fivetran data type %s", fivetranCol.Type.String())
 			}
+			if isCompatibleIntScanType(scanType, driverCol.ScanType) {
+				// the pre-existing tables might have other integer types, see values.Convert
+				scanType = driverCol.ScanType
+			}
 		}
 		if driverCol.ScanType != scanType {
 			return fmt.Errorf("database column %s (PK: %v) has type %s (scan type: %s) which is incompatible with the input %s (scan type: %s)",
@@ -57,37 +81,40 @@ func joinColNames[Value any](cols map[string]Value) string {
 }
 
 var (
-	zeroBool    = false
-	zeroInt16   = int16(0)
-	zeroInt32   = int32(0)
-	zeroInt64   = int64(0)
-	zeroFloat32 = float32(0)
-	zeroFloat64 = float64(0)
-	zeroTime    = time.Time{}
-	zeroDecimal = decimal.Decimal{}
-	zeroString  = ""
+	zeroBool     = false
+	zeroInt16    = int16(0)
+	zeroInt32    = int32(0)
+	zeroInt64    = int64(0)
+	zeroFloat32  = float32(0)
+	zeroFloat64  = float64(0)
+	zeroTime     = time.Time{}
+	zeroDecimal  = decimal.Decimal{}
+	zeroString   = ""
+	zeroDuration = time.Duration(0)
 
 	// Known Fivetran metadata and PK columns are non-Nullable.
-	scanTypeBool    = reflect.TypeOf(zeroBool)
-	scanTypeInt16   = reflect.TypeOf(zeroInt16)
-	scanTypeInt32   = reflect.TypeOf(zeroInt32)
-	scanTypeInt64   = reflect.TypeOf(zeroInt64)
-	scanTypeFloat32 = reflect.TypeOf(zeroFloat32)
-	scanTypeFloat64 = reflect.TypeOf(zeroFloat64)
-	scanTypeTime    = reflect.TypeOf(zeroTime)
-	scanTypeDecimal = reflect.TypeOf(zeroDecimal)
-	scanTypeString  = reflect.TypeOf(zeroString)
+	scanTypeBool     = reflect.TypeOf(zeroBool)
+	scanTypeInt16    = reflect.TypeOf(zeroInt16)
+	scanTypeInt32    = reflect.TypeOf(zeroInt32)
+	scanTypeInt64    = reflect.TypeOf(zeroInt64)
+	scanTypeFloat32  = reflect.TypeOf(zeroFloat32)
+	scanTypeFloat64  = reflect.TypeOf(zeroFloat64)
+	scanTypeTime     = reflect.TypeOf(zeroTime)
+	scanTypeDecimal  = reflect.TypeOf(zeroDecimal)
+	scanTypeString   = reflect.TypeOf(zeroString)
+	scanTypeDuration = reflect.TypeOf(zeroDuration)
 
 	// All other columns are defined as Nullable (i.e. in a row, it's a pointer to a pointer).
-	scanTypeNullableBool    = reflect.TypeOf(&zeroBool)
-	scanTypeNullableInt16   = reflect.TypeOf(&zeroInt16)
-	scanTypeNullableInt32   = reflect.TypeOf(&zeroInt32)
-	scanTypeNullableInt64   = reflect.TypeOf(&zeroInt64)
-	scanTypeNullableFloat32 = reflect.TypeOf(&zeroFloat32)
-	scanTypeNullableFloat64 = reflect.TypeOf(&zeroFloat64)
-	scanTypeNullableTime    = reflect.TypeOf(&zeroTime)
-	scanTypeNullableDecimal = reflect.TypeOf(&zeroDecimal)
-	scanTypeNullableString  = reflect.TypeOf(&zeroString)
+	scanTypeNullableBool     = reflect.TypeOf(&zeroBool)
+	scanTypeNullableInt16    = reflect.TypeOf(&zeroInt16)
+	scanTypeNullableInt32    = reflect.TypeOf(&zeroInt32)
+	scanTypeNullableInt64    = reflect.TypeOf(&zeroInt64)
+	scanTypeNullableFloat32  = reflect.TypeOf(&zeroFloat32)
+	scanTypeNullableFloat64  = reflect.TypeOf(&zeroFloat64)
+	scanTypeNullableTime     = reflect.TypeOf(&zeroTime)
+	scanTypeNullableDecimal  = reflect.TypeOf(&zeroDecimal)
+	scanTypeNullableString   = reflect.TypeOf(&zeroString)
+	scanTypeNullableDuration = reflect.TypeOf(&zeroDuration)
 )
 
 // IsPrimaryKey? -> Fivetran data type -> Go driver scan type
@@ -127,3 +154,32 @@ var pkToFivetranToScanType = map[bool]map[pb.DataType]reflect.Type{
 		pb.DataType_NAIVE_TIME:     scanTypeNullableString,
 	},
 }
+
+// isCompatibleIntScanType reports whether both scan types are integers, and either both or none of them are Nullable.
+func isCompatibleIntScanType(expected reflect.Type, actual reflect.Type) bool {
+	if expected.Kind() == reflect.Pointer {
+		if actual.Kind() != reflect.Pointer {
+			return false
+		}
+		expected, actual = expected.Elem(), actual.Elem()
+	}
+	return isIntType(expected) && isIntType(actual)
+}
+
+// isIntType reports whether the type is a built-in integer type; named types such as time.Duration are not.
+func isIntType(t reflect.Type) bool {
+	if t.PkgPath() != "" {
+		return false
+	}
+	switch t.Kind() {
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	default:
+		return false
+	}
+}
+
+func isStringScanType(scanType reflect.Type) bool {
+	return scanType == scanTypeString || scanType == scanTypeNullableString
+}
@@ -0,0 +1,69 @@
+package types
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+	pb "fivetran.com/fivetran_sdk/proto"
+)
+
+// Coercions holds the coercion policies for the values out of the ClickHouse range (see config.Config.CoercionPolicies),
+// and counts the coerced values per column while a batch is written.
+// A single instance is shared by all CSV columns of a WriteBatch request (see DriverColumns.Coercions),
+// and it is safe for concurrent use. All methods are safe to call on nil, which means the clamp policy for all types.
+type Coercions struct {
+	policies map[string]string // lowercase Fivetran type name -> policy
+	mutex    sync.Mutex
+	counts   map[string]uint64 // column name -> number of coerced values
+}
+
+func NewCoercions(policies map[string]string) *Coercions {
+	return &Coercions{
+		policies: policies,
+		counts:   make(map[string]uint64),
+	}
+}
+
+// Policy returns the coercion policy for the Fivetran data type; clamp if it is not configured.
+func (c *Coercions) Policy(dataType pb.DataType) string {
+	if c == nil {
+		return constants.CoercionClamp
+	}
+	if policy, ok := c.policies[strings.ToLower(dataType.String())]; ok {
+		return policy
+	}
+	return constants.CoercionClamp
+}
+
+// Add counts a coerced value of the column.
+func (c *Coercions) Add(colName string) {
+	if c == nil {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.counts[colName]++
+}
+
+// Summary returns the number of the coerced values per column, e.g. "created_at: 2, price: 1", sorted by column name;
+// empty if no values were coerced.
+func (c *Coercions) Summary() string {
+	if c == nil {
+		return ""
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	colNames := make([]string, 0, len(c.counts))
+	for colName := range c.counts {
+		colNames = append(colNames, colName)
+	}
+	slices.Sort(colNames)
+	parts := make([]string, len(colNames))
+	for i, colName := range colNames {
+		parts[i] = fmt.Sprintf("%s: %d", colName, c.counts[colName])
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,35 @@
+package types
+
+import (
+	"sync"
+	"testing"
+
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoercions(t *testing.T) {
+	coercions := NewCoercions(map[string]string{"naive_date": "null", "double": "fail"})
+	assert.Equal(t, "null", coercions.Policy(pb.DataType_NAIVE_DATE))
+	assert.Equal(t, "fail", coercions.Policy(pb.DataType_DOUBLE))
+	assert.Equal(t, "clamp", coercions.Policy(pb.DataType_UTC_DATETIME))
+	assert.Empty(t, coercions.Summary())
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			coercions.Add("updated_at")
+		}()
+	}
+	wg.Wait()
+	coercions.Add("amount")
+	assert.Equal(t, "amount: 1, updated_at: 10", coercions.Summary())
+
+	// nil means the clamp policy, and nothing is counted
+	var empty *Coercions
+	assert.Equal(t, "clamp", empty.Policy(pb.DataType_NAIVE_DATE))
+	empty.Add("amount")
+	assert.Empty(t, empty.Summary())
+}
@@ -1,7 +1,14 @@
 package types
 
-import "github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+import (
+	"reflect"
 
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// MakeDriverColumns extracts the driver column types.
+// Native JSON columns are always scanned as strings (with output_format_native_write_json_as_string enabled);
+// the driver would report its own JSON object type otherwise.
 func MakeDriverColumns(colTypes []driver.ColumnType) *DriverColumns {
 	mapping := make(map[string]*DriverColumn, len(colTypes))
 	columns := make([]*DriverColumn, len(colTypes))
@@ -12,6 +19,9 @@ func MakeDriverColumns(colTypes []driver.ColumnType) *DriverColumns {
 			ScanType:     colType.ScanType(),
 			DatabaseType: colType.DatabaseTypeName(),
 		}
+		if col.IsNativeJSON() {
+			col.ScanType = reflect.TypeOf("")
+		}
 		mapping[colType.Name()] = col
 		columns[i] = col
 	}
@@ -2,8 +2,12 @@ package types
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"time"
 
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+	dt "fivetran.com/fivetran_sdk/destination/common/data_types"
 	pb "fivetran.com/fivetran_sdk/proto"
 )
 
@@ -62,7 +66,29 @@ func MakeCSVColumns(
 			Name:         csvColName,
 			Type:         fivetranCol.Type,
 			IsPrimaryKey: fivetranCol.PrimaryKey,
+			NativeJSON:   fivetranCol.Type == pb.DataType_JSON && driverColType.IsNativeJSON(),
+			NativeTime:   fivetranCol.Type == pb.DataType_NAIVE_TIME && driverColType.IsNativeTime(),
+			Coercions:    driverColumns.Coercions,
 		}
+		if fivetranCol.Type == pb.DataType_BINARY && driverColType.BinaryEncoding != "" {
+			col.BinaryEncoding = driverColType.BinaryEncoding
+			col.FixedLength, _ = dt.FixedStringLength(driverColType.DatabaseType)
+		}
+		if fivetranCol.Type == pb.DataType_DECIMAL {
+			col.DecimalAsString = isStringScanType(driverColType.ScanType)
+			col.DecimalLimits, _ = dt.DecimalTypeParams(driverColType.DatabaseType)
+		}
+		if precision, ok := dt.DateTimePrecision(driverColType.DatabaseType); ok &&
+			fivetranCol.Type == pb.DataType_UTC_DATETIME && precision != 9 {
+			col.UTCPrecision = &precision
+		}
+		if _, timezone, ok := dt.DateTime64Params(driverColType.DatabaseType); ok &&
+			fivetranCol.Type == pb.DataType_NAIVE_DATETIME && timezone != "UTC" {
+			if col.Location, err = time.LoadLocation(timezone); err != nil {
+				return nil, fmt.Errorf("can't load timezone %s of column %s: %w", timezone, csvColName, err)
+			}
+		}
+		col.ConvertTo = convertTo(fivetranCol.Type, driverColType.DatabaseType)
 		allCSVColumns[i] = col
 		if fivetranCol.PrimaryKey {
 			primaryKeyCSVColumns = append(primaryKeyCSVColumns, col)
@@ -77,6 +103,32 @@ func MakeCSVColumns(
 	}, nil
 }
 
+// intTypes are the ClickHouse integer types that the Fivetran SHORT, INT and LONG values can be converted to.
+var intTypes = []string{
+	constants.Int8, constants.UInt8, constants.Int16, constants.UInt16,
+	constants.Int32, constants.UInt32, constants.Int64, constants.UInt64,
+}
+
+// convertTo returns the type of a compatible column that requires a value conversion, see CSVColumn.ConvertTo;
+// empty if the values are inserted as is.
+func convertTo(dataType pb.DataType, databaseType string) string {
+	baseType := dt.BaseType(databaseType)
+	switch dataType {
+	case pb.DataType_SHORT, pb.DataType_INT, pb.DataType_LONG:
+		if baseType != dt.FivetranToClickHouseType[dataType].Type && slices.Contains(intTypes, baseType) {
+			return baseType
+		}
+	case pb.DataType_NAIVE_DATE, pb.DataType_NAIVE_DATETIME, pb.DataType_UTC_DATETIME:
+		if baseType == constants.Date16 {
+			return constants.Date16
+		}
+		if baseType == constants.DateTime32 || strings.HasPrefix(baseType, constants.DateTime32+"(") {
+			return constants.DateTime32
+		}
+	}
+	return ""
+}
+
 // format columns as a string in the right order (using their database table definition index values).
 func joinDriverColumns(driverColumns []*DriverColumn) string {
 	var sb strings.Builder
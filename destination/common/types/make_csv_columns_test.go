@@ -3,6 +3,7 @@ package types
 import (
 	"reflect"
 	"testing"
+	"time"
 
 	pb "fivetran.com/fivetran_sdk/proto"
 	"github.com/stretchr/testify/assert"
@@ -32,6 +33,9 @@ func TestMakeCSVColumnMappingDifferentOrder(t *testing.T) {
 		PrimaryKeys: []*CSVColumn{
 			{Index: 0, TableIndex: 1, Name: "col2", Type: pb.DataType_STRING, IsPrimaryKey: true}},
 	}, mapping)
+	// the values of the insert rows are in the order of the table columns
+	assert.Equal(t, []string{"col1", "col2", "col3"}, mapping.TableColumnNames())
+	assert.Equal(t, []string{"col1", "col2", "col3"}, dbCols.Names())
 }
 
 func TestMakeCSVColumnMappingSingleColumn(t *testing.T) {
@@ -63,10 +67,165 @@ func TestMakeCSVColumnMappingCountMismatch(t *testing.T) {
 	assert.ErrorContains(t, err, "columns count in ClickHouse table (3) does not match the input file (2)")
 }
 
+func TestMakeCSVColumnMappingNativeJSON(t *testing.T) {
+	dbJSONCol := &DriverColumn{Name: "payload", DatabaseType: "JSON(max_dynamic_paths=256)", ScanType: scanTypeString, Index: 3}
+	driverColumns := &DriverColumns{
+		Mapping: map[string]*DriverColumn{"col1": dbCol1, "col2": dbCol2, "col3": dbCol3, "payload": dbJSONCol},
+		Columns: []*DriverColumn{dbCol1, dbCol2, dbCol3, dbJSONCol}}
+	fivetranJSONCol := &pb.Column{Name: "payload", Type: pb.DataType_JSON}
+	fivetranColMap := map[string]*pb.Column{
+		"col1": fivetranCol1, "col2": fivetranCol2, "col3": fivetranCol3, "payload": fivetranJSONCol}
+
+	mapping, err := MakeCSVColumns([]string{"payload", "col1", "col2", "col3"}, driverColumns, fivetranColMap, true)
+	assert.NoError(t, err)
+	assert.Equal(t, &CSVColumn{Index: 0, TableIndex: 3, Name: "payload", Type: pb.DataType_JSON, NativeJSON: true}, mapping.All[0])
+	assert.False(t, mapping.All[2].NativeJSON)
+	assert.True(t, driverColumns.HasNativeJSON())
+	assert.False(t, dbCols.HasNativeJSON())
+
+	// a native JSON column is not Nullable, and scanned as a string
+	dbJSONCol.ScanType = scanTypeNullableString
+	_, err = MakeCSVColumns([]string{"payload", "col1", "col2", "col3"}, driverColumns, fivetranColMap, true)
+	assert.ErrorContains(t, err, "database column payload (PK: false) has type JSON(max_dynamic_paths=256) (scan type: *string)")
+}
+
+func TestMakeCSVColumnMappingNativeTime(t *testing.T) {
+	dbTimeCol := &DriverColumn{Name: "opens_at", DatabaseType: "Nullable(Time64(6))", ScanType: scanTypeNullableDuration, Index: 3}
+	driverColumns := &DriverColumns{
+		Mapping: map[string]*DriverColumn{"col1": dbCol1, "col2": dbCol2, "col3": dbCol3, "opens_at": dbTimeCol},
+		Columns: []*DriverColumn{dbCol1, dbCol2, dbCol3, dbTimeCol}}
+	fivetranTimeCol := &pb.Column{Name: "opens_at", Type: pb.DataType_NAIVE_TIME}
+	fivetranColMap := map[string]*pb.Column{
+		"col1": fivetranCol1, "col2": fivetranCol2, "col3": fivetranCol3, "opens_at": fivetranTimeCol}
+
+	mapping, err := MakeCSVColumns([]string{"opens_at", "col1", "col2", "col3"}, driverColumns, fivetranColMap, true)
+	assert.NoError(t, err)
+	assert.Equal(t, &CSVColumn{Index: 0, TableIndex: 3, Name: "opens_at", Type: pb.DataType_NAIVE_TIME, NativeTime: true}, mapping.All[0])
+	assert.False(t, mapping.All[2].NativeTime)
+
+	// a native time column is scanned as a duration
+	dbTimeCol.ScanType = scanTypeNullableString
+	_, err = MakeCSVColumns([]string{"opens_at", "col1", "col2", "col3"}, driverColumns, fivetranColMap, true)
+	assert.ErrorContains(t, err, "database column opens_at (PK: false) has type Nullable(Time64(6)) (scan type: *string)")
+}
+
+func TestMakeCSVColumnMappingRawBinary(t *testing.T) {
+	dbHashCol := &DriverColumn{Name: "hash", DatabaseType: "Nullable(FixedString(32))", ScanType: scanTypeNullableString, Index: 3,
+		BinaryEncoding: "hex"}
+	dbPayloadCol := &DriverColumn{Name: "payload", DatabaseType: "Nullable(String)", ScanType: scanTypeNullableString, Index: 4}
+	driverColumns := &DriverColumns{
+		Mapping: map[string]*DriverColumn{"col1": dbCol1, "col2": dbCol2, "col3": dbCol3, "hash": dbHashCol, "payload": dbPayloadCol},
+		Columns: []*DriverColumn{dbCol1, dbCol2, dbCol3, dbHashCol, dbPayloadCol}}
+	fivetranColMap := map[string]*pb.Column{
+		"col1": fivetranCol1, "col2": fivetranCol2, "col3": fivetranCol3,
+		"hash":    {Name: "hash", Type: pb.DataType_BINARY},
+		"payload": {Name: "payload", Type: pb.DataType_BINARY},
+	}
+
+	mapping, err := MakeCSVColumns([]string{"hash", "payload", "col1", "col2", "col3"}, driverColumns, fivetranColMap, true)
+	assert.NoError(t, err)
+	assert.Equal(t, &CSVColumn{Index: 0, TableIndex: 3, Name: "hash", Type: pb.DataType_BINARY,
+		BinaryEncoding: "hex", FixedLength: 32}, mapping.All[0])
+	assert.Equal(t, &CSVColumn{Index: 1, TableIndex: 4, Name: "payload", Type: pb.DataType_BINARY}, mapping.All[1])
+}
+
+func TestMakeCSVColumnMappingDecimal(t *testing.T) {
+	dbStringCol := &DriverColumn{Name: "total", DatabaseType: "Nullable(String)", ScanType: scanTypeNullableString, Index: 3}
+	dbDecimalCol := &DriverColumn{Name: "amount", DatabaseType: "Nullable(Decimal(76, 10))", ScanType: scanTypeNullableDecimal, Index: 4}
+	driverColumns := &DriverColumns{
+		Mapping: map[string]*DriverColumn{"col1": dbCol1, "col2": dbCol2, "col3": dbCol3, "total": dbStringCol, "amount": dbDecimalCol},
+		Columns: []*DriverColumn{dbCol1, dbCol2, dbCol3, dbStringCol, dbDecimalCol}}
+	decimalParams := &pb.DataTypeParams{Params: &pb.DataTypeParams_Decimal{Decimal: &pb.DecimalParams{Precision: 100, Scale: 10}}}
+	fivetranColMap := map[string]*pb.Column{
+		"col1": fivetranCol1, "col2": fivetranCol2, "col3": fivetranCol3,
+		"total":  {Name: "total", Type: pb.DataType_DECIMAL, Params: decimalParams},
+		"amount": {Name: "amount", Type: pb.DataType_DECIMAL, Params: decimalParams},
+	}
+
+	mapping, err := MakeCSVColumns([]string{"total", "amount", "col1", "col2", "col3"}, driverColumns, fivetranColMap, true)
+	assert.NoError(t, err)
+	assert.Equal(t, &CSVColumn{Index: 0, TableIndex: 3, Name: "total", Type: pb.DataType_DECIMAL, DecimalAsString: true}, mapping.All[0])
+	assert.Equal(t, &CSVColumn{Index: 1, TableIndex: 4, Name: "amount", Type: pb.DataType_DECIMAL,
+		DecimalLimits: &pb.DecimalParams{Precision: 76, Scale: 10}}, mapping.All[1])
+}
+
+func TestMakeCSVColumnMappingDateTime(t *testing.T) {
+	dbUTCCol := &DriverColumn{Name: "created_at", DatabaseType: "Nullable(DateTime64(3, 'UTC'))", ScanType: scanTypeNullableTime, Index: 3}
+	dbLocalCol := &DriverColumn{Name: "local_at", DatabaseType: "Nullable(DateTime64(0, 'Europe/Berlin'))", ScanType: scanTypeNullableTime, Index: 4}
+	dbDefaultCol := &DriverColumn{Name: "updated_at", DatabaseType: "Nullable(DateTime64(9, 'UTC'))", ScanType: scanTypeNullableTime, Index: 5}
+	driverColumns := &DriverColumns{
+		Mapping: map[string]*DriverColumn{"col1": dbCol1, "col2": dbCol2, "col3": dbCol3,
+			"created_at": dbUTCCol, "local_at": dbLocalCol, "updated_at": dbDefaultCol},
+		Columns: []*DriverColumn{dbCol1, dbCol2, dbCol3, dbUTCCol, dbLocalCol, dbDefaultCol}}
+	fivetranColMap := map[string]*pb.Column{
+		"col1": fivetranCol1, "col2": fivetranCol2, "col3": fivetranCol3,
+		"created_at": {Name: "created_at", Type: pb.DataType_UTC_DATETIME},
+		"local_at":   {Name: "local_at", Type: pb.DataType_NAIVE_DATETIME},
+		"updated_at": {Name: "updated_at", Type: pb.DataType_UTC_DATETIME},
+	}
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	assert.NoError(t, err)
+
+	header := []string{"created_at", "local_at", "updated_at", "col1", "col2", "col3"}
+	mapping, err := MakeCSVColumns(header, driverColumns, fivetranColMap, true)
+	assert.NoError(t, err)
+	precision := uint(3)
+	assert.Equal(t, &CSVColumn{Index: 0, TableIndex: 3, Name: "created_at", Type: pb.DataType_UTC_DATETIME, UTCPrecision: &precision}, mapping.All[0])
+	assert.Equal(t, &CSVColumn{Index: 1, TableIndex: 4, Name: "local_at", Type: pb.DataType_NAIVE_DATETIME, Location: berlin}, mapping.All[1])
+	assert.Equal(t, &CSVColumn{Index: 2, TableIndex: 5, Name: "updated_at", Type: pb.DataType_UTC_DATETIME}, mapping.All[2])
+
+	dbLocalCol.DatabaseType = "Nullable(DateTime64(0, 'Mars/Olympus_Mons'))"
+	_, err = MakeCSVColumns(header, driverColumns, fivetranColMap, true)
+	assert.ErrorContains(t, err, "can't load timezone Mars/Olympus_Mons of column local_at")
+}
+
+func TestMakeCSVColumnMappingCompatibleTypes(t *testing.T) {
+	zeroUint32, zeroUint8 := uint32(0), uint8(0)
+	dbCountCol := &DriverColumn{Name: "count", DatabaseType: "Nullable(UInt32)", ScanType: reflect.TypeOf(&zeroUint32), Index: 3}
+	dbFlagCol := &DriverColumn{Name: "flag", DatabaseType: "UInt8", ScanType: reflect.TypeOf(zeroUint8), Index: 4}
+	dbDayCol := &DriverColumn{Name: "day", DatabaseType: "Nullable(Date)", ScanType: scanTypeNullableTime, Index: 5}
+	dbSeenCol := &DriverColumn{Name: "seen_at", DatabaseType: "Nullable(DateTime('Europe/Berlin'))", ScanType: scanTypeNullableTime, Index: 6}
+	dbStatusCol := &DriverColumn{Name: "status", DatabaseType: "Enum8('a' = 1, 'b' = 2)", ScanType: scanTypeString, Index: 7}
+	driverColumns := &DriverColumns{
+		Mapping: map[string]*DriverColumn{"col1": dbCol1, "col2": dbCol2, "col3": dbCol3,
+			"count": dbCountCol, "flag": dbFlagCol, "day": dbDayCol, "seen_at": dbSeenCol, "status": dbStatusCol},
+		Columns: []*DriverColumn{dbCol1, dbCol2, dbCol3, dbCountCol, dbFlagCol, dbDayCol, dbSeenCol, dbStatusCol}}
+	fivetranColMap := map[string]*pb.Column{
+		"col1": fivetranCol1, "col2": fivetranCol2, "col3": fivetranCol3,
+		"count":   {Name: "count", Type: pb.DataType_LONG},
+		"flag":    {Name: "flag", Type: pb.DataType_SHORT, PrimaryKey: true},
+		"day":     {Name: "day", Type: pb.DataType_NAIVE_DATE},
+		"seen_at": {Name: "seen_at", Type: pb.DataType_UTC_DATETIME},
+		"status":  {Name: "status", Type: pb.DataType_STRING, PrimaryKey: true},
+	}
+
+	header := []string{"count", "flag", "day", "seen_at", "status", "col1", "col2", "col3"}
+	mapping, err := MakeCSVColumns(header, driverColumns, fivetranColMap, true)
+	assert.NoError(t, err)
+	precision := uint(0)
+	assert.Equal(t, &CSVColumn{Index: 0, TableIndex: 3, Name: "count", Type: pb.DataType_LONG, ConvertTo: "UInt32"}, mapping.All[0])
+	assert.Equal(t, &CSVColumn{Index: 1, TableIndex: 4, Name: "flag", Type: pb.DataType_SHORT, IsPrimaryKey: true,
+		ConvertTo: "UInt8"}, mapping.All[1])
+	assert.Equal(t, &CSVColumn{Index: 2, TableIndex: 5, Name: "day", Type: pb.DataType_NAIVE_DATE, ConvertTo: "Date"}, mapping.All[2])
+	assert.Equal(t, &CSVColumn{Index: 3, TableIndex: 6, Name: "seen_at", Type: pb.DataType_UTC_DATETIME,
+		UTCPrecision: &precision, ConvertTo: "DateTime"}, mapping.All[3])
+	assert.Equal(t, &CSVColumn{Index: 4, TableIndex: 7, Name: "status", Type: pb.DataType_STRING, IsPrimaryKey: true}, mapping.All[4])
+
+	// the nullability should still match
+	dbFlagCol.ScanType = reflect.TypeOf(&zeroUint8)
+	_, err = MakeCSVColumns(header, driverColumns, fivetranColMap, true)
+	assert.ErrorContains(t, err, "database column flag (PK: true) has type UInt8 (scan type: *uint8)")
+
+	// named integer types are not compatible
+	dbFlagCol.ScanType = scanTypeDuration
+	_, err = MakeCSVColumns(header, driverColumns, fivetranColMap, true)
+	assert.ErrorContains(t, err, "database column flag (PK: true) has type UInt8 (scan type: time.Duration)")
+}
+
 var (
 	dbCol1 = &DriverColumn{Name: "col1", DatabaseType: "Int32", ScanType: scanTypeNullableInt32, Index: 0}
 	dbCol2 = &DriverColumn{Name: "col2", DatabaseType: "String", ScanType: scanTypeString, Index: 1}
-	dbCol3 = &DriverColumn{Name: "col3", DatabaseType: "DateTime", ScanType: scanTypeNullableTime, Index: 2}
+	dbCol3 = &DriverColumn{Name: "col3", DatabaseType: "Nullable(DateTime64(0, 'UTC'))", ScanType: scanTypeNullableTime, Index: 2}
 	dbCols = &DriverColumns{
 		Mapping: map[string]*DriverColumn{"col1": dbCol1, "col2": dbCol2, "col3": dbCol3},
 		Columns: []*DriverColumn{dbCol1, dbCol2, dbCol3}}
@@ -2,27 +2,61 @@ package types
 
 import (
 	"reflect"
+	"time"
 
+	dt "fivetran.com/fivetran_sdk/destination/common/data_types"
 	pb "fivetran.com/fivetran_sdk/proto"
 )
 
 // ColumnDefinition as it is defined or should be defined in ClickHouse
+// Codec and SkipIndexes are set from the column options (see ColumnOptions) when the column is created or altered;
+// they are never read back from ClickHouse.
 type ColumnDefinition struct {
 	Name          string
 	Type          string
 	Comment       string
 	IsPrimaryKey  bool
 	DecimalParams *pb.DecimalParams // only for Decimal types, nil otherwise
+	Codec         string            // CODEC clause arguments, e.g. "Delta, ZSTD(3)"; empty for the default compression
+	SkipIndexes   []*SkipIndex
+	// DefaultKind and DefaultExpression are only set for the user-owned columns, see TableDescription.UserColumns;
+	// DefaultKind is one of DEFAULT, MATERIALIZED, ALIAS or EPHEMERAL.
+	DefaultKind       string
+	DefaultExpression string
+}
+
+// ColumnOptions are the physical hints for a single column, configured in the advanced configuration;
+// see config.ColumnConfiguration.
+// Codec = CODEC clause arguments; empty for the default compression.
+// LowCardinality = wrap the column type with LowCardinality.
+// SkipIndexes = data-skipping indexes on the column.
+type ColumnOptions struct {
+	Codec          string
+	LowCardinality bool
+	SkipIndexes    []*SkipIndex
+}
+
+// SkipIndex is a data-skipping index on a single column, see https://clickhouse.com/docs/optimize/skipping-indexes
+// Name = index name, unique within the table.
+// Type = index type with its arguments, e.g. bloom_filter(0.01), minmax or set(100).
+type SkipIndex struct {
+	Name        string
+	Type        string
+	Granularity uint
 }
 
 // TableDescription is a description of a ClickHouse table.
 // Mapping is ColumnDefinition.Name -> ColumnDefinition (unordered)
 // Columns are the same as in Mapping, but ordered, used to preserve column order for CREATE TABLE statement generation
 // PrimaryKeys is a convenience list of ColumnDefinition.Name that are primary keys
+// UserColumns are the columns added to the table outside of Fivetran, which are not a part of the Fivetran table
+// definition: the MATERIALIZED, ALIAS and EPHEMERAL columns, and optionally the columns with a DEFAULT expression.
+// They are not in Mapping or Columns, and are never altered or dropped.
 type TableDescription struct {
 	Mapping     map[string]*ColumnDefinition
 	Columns     []*ColumnDefinition
 	PrimaryKeys []string
+	UserColumns []*ColumnDefinition
 }
 
 // PrimaryKeyColumn as it is defined in a Fivetran request or in ClickHouse
@@ -46,13 +80,17 @@ const (
 	AlterTableAdd AlterTableOpType = iota
 	AlterTableModify
 	AlterTableDrop
+	AlterTableAddIndex
+	AlterTableDropIndex
 )
 
 type AlterTableOp struct {
 	Op      AlterTableOpType
 	Column  string
-	Type    *string // nil for AlterTableDrop
-	Comment *string // nil for AlterTableDrop
+	Type    *string    // nil for AlterTableDrop and the index ops
+	Comment *string    // nil for AlterTableDrop and the index ops
+	Codec   *string    // optional for AlterTableAdd and AlterTableModify
+	Index   *SkipIndex // only for AlterTableAddIndex and AlterTableDropIndex
 }
 
 // UserGrant represents a row from system.grants table.
@@ -63,6 +101,46 @@ type UserGrant struct {
 	Column     *string
 }
 
+// Cluster describes a self-hosted ClickHouse cluster deployment.
+// Name = cluster name as defined in the remote_servers server configuration; used for ON CLUSTER DDL.
+// KeeperPath and ReplicaName = ReplicatedReplacingMergeTree engine arguments;
+// if KeeperPath is empty, the server defaults (default_replica_path, default_replica_name) are used.
+// Distributed = each table is stored in a shard-local table, and queried/inserted via a Distributed table
+// with the Fivetran table name; ShardingKey is the Distributed engine sharding expression
+// (if empty, a hash of the primary key columns is used).
+//
+// A nil *Cluster means ClickHouse Cloud, where DDL is replicated by the database engine itself.
+type Cluster struct {
+	Name        string
+	KeeperPath  string
+	ReplicaName string
+	Distributed bool
+	ShardingKey string
+}
+
+// IsDistributed returns true if the tables are sharded using the Distributed engine; safe to call on nil.
+func (c *Cluster) IsDistributed() bool {
+	return c != nil && c.Distributed
+}
+
+// TableEngineOptions are the optional clauses of the CREATE TABLE statement, configured per table
+// (or for all tables) in the advanced configuration; see config.TableConfigurations.
+// PartitionBy and TTL = ClickHouse expressions, used as is.
+// OrderByPrefix and OrderBySuffix = column names added before and after the primary key columns in the ORDER BY;
+// PrimaryKey = column names for the PRIMARY KEY clause, which has to be a prefix of the ORDER BY.
+// Settings = SETTINGS clause values (string, float64 or bool), including storage_policy and index_granularity.
+// IgnoreDefaultColumns = not a CREATE TABLE clause; the columns with a DEFAULT expression are treated as user-owned,
+// see TableDescription.UserColumns.
+type TableEngineOptions struct {
+	PartitionBy          string
+	OrderByPrefix        []string
+	OrderBySuffix        []string
+	PrimaryKey           []string
+	TTL                  string
+	Settings             map[string]any
+	IgnoreDefaultColumns bool
+}
+
 // CSVColumn represents a column in a CSV file with added information from the fivetran_sdk.Table.
 // Index = CSV column index.
 // TableIndex = ClickHouse table index.
@@ -72,6 +150,43 @@ type CSVColumn struct {
 	Name         string
 	Type         pb.DataType
 	IsPrimaryKey bool
+	// NativeJSON is set for the JSON columns with the native ClickHouse JSON type (see config.JSONTypes);
+	// such columns are not Nullable, and only accept JSON objects.
+	NativeJSON bool
+	// NativeTime is set for the NAIVE_TIME columns with the ClickHouse Time or Time64 type (see config.Config.NaiveTimeType).
+	NativeTime bool
+	// BinaryEncoding is set for the BINARY columns with the decoded bytes (see config.BinaryTypes),
+	// and is the encoding of their values in the batch files; FixedLength is set if the column is FixedString.
+	BinaryEncoding string
+	FixedLength    uint
+	// DecimalAsString is set for the DECIMAL columns stored as String (see config.Config.DecimalOverflow);
+	// the values are validated, and inserted as is.
+	DecimalAsString bool
+	// DecimalLimits is set for the DECIMAL columns with the ClickHouse Decimal type, and is its precision and scale;
+	// the values that don't fit are coerced according to the policy instead of being truncated on insert
+	// (see values.CoerceDecimal).
+	DecimalLimits *pb.DecimalParams
+	// UTCPrecision is set for the UTC_DATETIME columns with a precision other than 9 (see config.DateTimeTypes),
+	// e.g. 0 for DateTime and 3 for DateTime64(3, 'UTC'); the values are truncated to it on insert,
+	// so the SQL literals and the mapping keys are too. See UTCDateTimePrecision.
+	UTCPrecision *uint
+	// Location is set for the NAIVE_DATETIME columns with a DateTime64 timezone other than UTC (see config.DateTimeTypes);
+	// the values are inserted as the wall clock time in that timezone, see values.InLocation.
+	Location *time.Location
+	// ConvertTo is set for the columns of the pre-existing tables with a compatible ClickHouse type
+	// (see dt.IsCompatibleType) that requires a conversion, and is that type without the parameters, e.g. UInt32;
+	// the values are converted on insert, see values.Convert.
+	ConvertTo string
+	// Coercions is shared by all columns, see DriverColumns.Coercions.
+	Coercions *Coercions
+}
+
+// UTCDateTimePrecision returns the precision of a UTC_DATETIME column, 9 unless UTCPrecision is set.
+func (c *CSVColumn) UTCDateTimePrecision() uint {
+	if c.UTCPrecision == nil {
+		return 9
+	}
+	return *c.UTCPrecision
 }
 
 // CSVColumns is an ordered list of CSVColumn, matching the CSV header definition.
@@ -97,6 +212,19 @@ type DriverColumn struct {
 	Name         string
 	ScanType     reflect.Type
 	DatabaseType string
+	// BinaryEncoding is set for the columns with the decoded BINARY values, see CSVColumn.BinaryEncoding.
+	// It is not reported by the driver, as such columns are distinguished by their comment.
+	BinaryEncoding string
+}
+
+// IsNativeJSON reports whether the column has the native ClickHouse JSON type (see config.JSONTypes).
+func (c *DriverColumn) IsNativeJSON() bool {
+	return dt.IsJSONType(c.DatabaseType)
+}
+
+// IsNativeTime reports whether the column has the ClickHouse Time or Time64 type (see config.Config.NaiveTimeType).
+func (c *DriverColumn) IsNativeTime() bool {
+	return dt.IsTimeType(c.DatabaseType)
 }
 
 // DriverColumns is a mapping of driver column names to driver columns.
@@ -105,6 +233,39 @@ type DriverColumn struct {
 type DriverColumns struct {
 	Mapping map[string]*DriverColumn
 	Columns []*DriverColumn
+	// Coercions is set per WriteBatch request, and counts the coerced values of the request; nil means the clamp policy.
+	Coercions *Coercions
+}
+
+// HasNativeJSON reports whether any of the columns has the native ClickHouse JSON type.
+func (c *DriverColumns) HasNativeJSON() bool {
+	for _, col := range c.Columns {
+		if col.IsNativeJSON() {
+			return true
+		}
+	}
+	return false
+}
+
+// TableColumnNames returns the names of the columns ordered by CSVColumn.TableIndex,
+// which is the order of the values in the rows built by db.ToInsertRow.
+func (c *CSVColumns) TableColumnNames() []string {
+	names := make([]string, len(c.All))
+	for _, col := range c.All {
+		if col.TableIndex < uint(len(names)) {
+			names[col.TableIndex] = col.Name
+		}
+	}
+	return names
+}
+
+// Names returns the names of the columns in the order of the table.
+func (c *DriverColumns) Names() []string {
+	names := make([]string, len(c.Columns))
+	for i, col := range c.Columns {
+		names[i] = col.Name
+	}
+	return names
 }
 
 // RemovePrimaryKey removes a primary key column by name from the CSVColumns.PrimaryKeys slice.
@@ -1,12 +1,18 @@
 package db
 
 import (
+	"slices"
+
 	"fivetran.com/fivetran_sdk/destination/common/types"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 )
 
 // GetAlterTableOps returns a list of operations to alter the table from the current to the new definition.
 // `from` is the table definition from ClickHouse
 // `to` is the table definition from a Fivetran AlterTable request
+// Skip indexes of the added or modified columns are added if they don't exist yet,
+// and the skip indexes of the dropped columns are dropped before the columns themselves.
+// Codec or skip index differences alone do not produce any operations.
 func GetAlterTableOps(
 	from *types.TableDescription,
 	to *types.TableDescription,
@@ -27,7 +33,9 @@ func GetAlterTableOps(
 				Column:  toCol.Name,
 				Type:    &toCol.Type,
 				Comment: &toCol.Comment,
+				Codec:   columnCodec(toCol),
 			})
+			ops = append(ops, skipIndexOps(types.AlterTableAddIndex, toCol)...)
 		} else {
 			if fromCol.IsPrimaryKey != toCol.IsPrimaryKey {
 				hasChangedPK = true
@@ -41,7 +49,9 @@ func GetAlterTableOps(
 					Column:  toCol.Name,
 					Type:    &toCol.Type,
 					Comment: &toCol.Comment,
+					Codec:   columnCodec(toCol),
 				})
+				ops = append(ops, skipIndexOps(types.AlterTableAddIndex, toCol)...)
 			}
 		}
 	}
@@ -53,6 +63,7 @@ func GetAlterTableOps(
 			if fromCol.IsPrimaryKey {
 				hasChangedPK = true
 			}
+			ops = append(ops, skipIndexOps(types.AlterTableDropIndex, fromCol)...)
 			ops = append(ops, &types.AlterTableOp{
 				Op:     types.AlterTableDrop,
 				Column: fromCol.Name,
@@ -64,3 +75,93 @@ func GetAlterTableOps(
 
 	return ops, hasChangedPK, unchangedColNames, nil
 }
+
+func skipIndexOps(op types.AlterTableOpType, col *types.ColumnDefinition) []*types.AlterTableOp {
+	ops := make([]*types.AlterTableOp, len(col.SkipIndexes))
+	for i, index := range col.SkipIndexes {
+		ops[i] = &types.AlterTableOp{Op: op, Column: col.Name, Index: index}
+	}
+	return ops
+}
+
+// columnCodec returns nil if the column has the default compression.
+func columnCodec(col *types.ColumnDefinition) *string {
+	if col.Codec == "" {
+		return nil
+	}
+	return &col.Codec
+}
+
+// withoutIndexOps returns the ops without AlterTableAddIndex and AlterTableDropIndex.
+func withoutIndexOps(ops []*types.AlterTableOp) []*types.AlterTableOp {
+	result := make([]*types.AlterTableOp, 0, len(ops))
+	for _, op := range ops {
+		if op.Op != types.AlterTableAddIndex && op.Op != types.AlterTableDropIndex {
+			result = append(result, op)
+		}
+	}
+	return result
+}
+
+// Supported values of the system.columns default_kind column, besides an empty string for the ordinary columns.
+const (
+	defaultKindDefault      = "DEFAULT"
+	defaultKindMaterialized = "MATERIALIZED"
+	defaultKindAlias        = "ALIAS"
+	defaultKindEphemeral    = "EPHEMERAL"
+)
+
+// isUserColumn returns true if the column with the given system.columns default_kind is user-owned,
+// see types.TableDescription.UserColumns. Such columns are never created by the destination itself.
+func isUserColumn(defaultKind string, ignoreDefaultColumns bool) bool {
+	switch defaultKind {
+	case defaultKindMaterialized, defaultKindAlias, defaultKindEphemeral:
+		return true
+	case defaultKindDefault:
+		return ignoreDefaultColumns
+	}
+	return false
+}
+
+// withoutUserColumns returns the column types without the user-owned columns of the table;
+// SELECT * does not return the MATERIALIZED, ALIAS and EPHEMERAL columns, but it does return the DEFAULT ones.
+func withoutUserColumns(columnTypes []driver.ColumnType, description *types.TableDescription) []driver.ColumnType {
+	if description == nil || len(description.UserColumns) == 0 {
+		return columnTypes
+	}
+	return slices.DeleteFunc(slices.Clone(columnTypes), func(colType driver.ColumnType) bool {
+		return slices.ContainsFunc(description.UserColumns, func(col *types.ColumnDefinition) bool {
+			return col.Name == colType.Name()
+		})
+	})
+}
+
+// userColumnOps returns the operations to add the user-owned columns with their expressions to a new table.
+func userColumnOps(userColumns []*types.ColumnDefinition) []*types.AlterTableOp {
+	ops := make([]*types.AlterTableOp, len(userColumns))
+	for i, col := range userColumns {
+		definition := col.Type + " " + col.DefaultKind
+		if col.DefaultExpression != "" {
+			definition += " " + col.DefaultExpression
+		}
+		ops[i] = &types.AlterTableOp{
+			Op:      types.AlterTableAdd,
+			Column:  col.Name,
+			Type:    &definition,
+			Comment: &col.Comment,
+		}
+	}
+	return ops
+}
+
+// storedUserColumnNames returns the names of the user-owned columns with a DEFAULT expression;
+// unlike the other user-owned columns, their values are stored as inserted, and have to be copied to a new table.
+func storedUserColumnNames(userColumns []*types.ColumnDefinition) []string {
+	var names []string
+	for _, col := range userColumns {
+		if col.DefaultKind == defaultKindDefault {
+			names = append(names, col.Name)
+		}
+	}
+	return names
+}
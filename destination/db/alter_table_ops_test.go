@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"fivetran.com/fivetran_sdk/destination/common/types"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -231,3 +232,91 @@ func TestGetAlterTableOpsAddPrimaryKey(t *testing.T) {
 	})
 	assert.Equal(t, unchangedColNames, []string{"qaz"}) // the only "original" column name
 }
+
+func TestGetAlterTableOpsWithColumnOptions(t *testing.T) {
+	strType := "LowCardinality(Nullable(String))"
+	int64Type := "Nullable(Int64)"
+	emptyComment := ""
+	codec := "ZSTD(3)"
+	setIndex := &types.SkipIndex{Name: "qux_set_idx", Type: "set(100)", Granularity: 1}
+	bloomFilterIndex := &types.SkipIndex{Name: "zaq_bloom_filter_idx", Type: "bloom_filter", Granularity: 1}
+	minMaxIndex := &types.SkipIndex{Name: "qwe_minmax_idx", Type: "minmax", Granularity: 1}
+	ops, hasChangedPK, unchangedColNames, err := GetAlterTableOps(
+		types.MakeTableDescription([]*types.ColumnDefinition{
+			{Name: "qaz", Type: "Int32", IsPrimaryKey: true},
+			{Name: "qux", Type: "Nullable(String)"},
+			{Name: "qwe", Type: "Nullable(Int64)", SkipIndexes: []*types.SkipIndex{minMaxIndex}},
+			{Name: "asd", Type: "Nullable(Int64)"},
+		}),
+		types.MakeTableDescription([]*types.ColumnDefinition{
+			{Name: "qaz", Type: "Int32", IsPrimaryKey: true},
+			{Name: "qux", Type: strType, SkipIndexes: []*types.SkipIndex{setIndex}},
+			{Name: "zaq", Type: int64Type, Codec: codec, SkipIndexes: []*types.SkipIndex{bloomFilterIndex}},
+			// codecs alone do not produce any operations
+			{Name: "asd", Type: "Nullable(Int64)", Codec: codec},
+		}))
+	assert.NoError(t, err)
+	assert.False(t, hasChangedPK)
+	assert.Equal(t, []*types.AlterTableOp{
+		{Op: types.AlterTableModify, Column: "qux", Type: &strType, Comment: &emptyComment},
+		{Op: types.AlterTableAddIndex, Column: "qux", Index: setIndex},
+		{Op: types.AlterTableAdd, Column: "zaq", Type: &int64Type, Comment: &emptyComment, Codec: &codec},
+		{Op: types.AlterTableAddIndex, Column: "zaq", Index: bloomFilterIndex},
+		{Op: types.AlterTableDropIndex, Column: "qwe", Index: minMaxIndex},
+		{Op: types.AlterTableDrop, Column: "qwe"},
+	}, ops)
+	assert.Equal(t, []string{"qaz", "qux", "asd"}, unchangedColNames)
+
+	assert.Equal(t, []*types.AlterTableOp{
+		{Op: types.AlterTableModify, Column: "qux", Type: &strType, Comment: &emptyComment},
+		{Op: types.AlterTableAdd, Column: "zaq", Type: &int64Type, Comment: &emptyComment, Codec: &codec},
+		{Op: types.AlterTableDrop, Column: "qwe"},
+	}, withoutIndexOps(ops))
+}
+
+func TestIsUserColumn(t *testing.T) {
+	assert.False(t, isUserColumn("", false))
+	assert.False(t, isUserColumn("", true))
+	assert.False(t, isUserColumn("DEFAULT", false))
+	assert.True(t, isUserColumn("DEFAULT", true))
+	for _, kind := range []string{"MATERIALIZED", "ALIAS", "EPHEMERAL"} {
+		assert.True(t, isUserColumn(kind, false), kind)
+	}
+}
+
+func TestUserColumns(t *testing.T) {
+	description := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Int64", IsPrimaryKey: true},
+		{Name: "name", Type: "Nullable(String)"},
+	})
+	description.UserColumns = []*types.ColumnDefinition{
+		{Name: "name_length", Type: "UInt64", DefaultKind: "MATERIALIZED", DefaultExpression: "length(name)"},
+		{Name: "region", Type: "String", Comment: "enrichment", DefaultKind: "DEFAULT", DefaultExpression: "'eu'"},
+		{Name: "raw", Type: "String", DefaultKind: "EPHEMERAL"},
+	}
+
+	columnTypes := withoutUserColumns([]driver.ColumnType{
+		&fakeColumnType{name: "id"},
+		&fakeColumnType{name: "name"},
+		&fakeColumnType{name: "region"},
+	}, description)
+	assert.Equal(t, []driver.ColumnType{&fakeColumnType{name: "id"}, &fakeColumnType{name: "name"}}, columnTypes)
+
+	materializedType := "UInt64 MATERIALIZED length(name)"
+	defaultType := "String DEFAULT 'eu'"
+	ephemeralType := "String EPHEMERAL"
+	emptyComment := ""
+	comment := "enrichment"
+	assert.Equal(t, []*types.AlterTableOp{
+		{Op: types.AlterTableAdd, Column: "name_length", Type: &materializedType, Comment: &emptyComment},
+		{Op: types.AlterTableAdd, Column: "region", Type: &defaultType, Comment: &comment},
+		{Op: types.AlterTableAdd, Column: "raw", Type: &ephemeralType, Comment: &emptyComment},
+	}, userColumnOps(description.UserColumns))
+	assert.Equal(t, []string{"region"}, storedUserColumnNames(description.UserColumns))
+
+	// the user-owned columns are never dropped
+	ops, hasChangedPK, _, err := GetAlterTableOps(description, description)
+	assert.NoError(t, err)
+	assert.False(t, hasChangedPK)
+	assert.Empty(t, ops)
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/tls"
 	"errors"
 	"fmt"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
@@ -13,7 +14,8 @@ import (
 	"fivetran.com/fivetran_sdk/destination/common"
 	"fivetran.com/fivetran_sdk/destination/common/benchmark"
 	"fivetran.com/fivetran_sdk/destination/common/constants"
-	csvfile "fivetran.com/fivetran_sdk/destination/common/csv"
+	dt "fivetran.com/fivetran_sdk/destination/common/data_types"
+	"fivetran.com/fivetran_sdk/destination/common/files"
 	"fivetran.com/fivetran_sdk/destination/common/flags"
 	"fivetran.com/fivetran_sdk/destination/common/log"
 	"fivetran.com/fivetran_sdk/destination/common/retry"
@@ -33,8 +35,20 @@ const (
 
 type ClickHouseConnection struct {
 	driver.Conn
+	poolEntry     *poolEntry
+	releaseOnce   sync.Once
+	hosts         string // see tableMetadataKey
+	identity      string // see tableMetadataKey
 	username      string
 	isLocal       bool
+	cluster       *types.Cluster
+	tableEngines  *config.TableEngines
+	columnOptions config.ColumnOptions
+	nativeTypes   nativeTypes
+	coercions     map[string]string
+	settings      *config.Settings
+	// statsMu guards the query stats, as the files of a batch can be inserted in parallel with the same connection
+	statsMu       sync.Mutex
 	queryCount    int64
 	errorCount    int64
 	totalDuration time.Duration
@@ -53,6 +67,8 @@ func (conn *ClickHouseConnection) logConnectionStats() {
 }
 
 func (conn *ClickHouseConnection) recordQuery(duration time.Duration, success bool) {
+	conn.statsMu.Lock()
+	defer conn.statsMu.Unlock()
 	conn.queryCount++
 	conn.totalDuration += duration
 	if !success {
@@ -67,11 +83,71 @@ func (conn *ClickHouseConnection) recordQuery(duration time.Duration, success bo
 	}
 }
 
-func GetClickHouseConnection(ctx context.Context, connConfig *config.Config) (*ClickHouseConnection, error) {
-	log.Info(fmt.Sprintf("Initializing ClickHouse connection to %s:%d",
-		connConfig.Host, connConfig.Port))
+// GetClickHouseConnection returns a connection from the process-wide pool (see connPool),
+// pinging it first if it is new or was not used for a while. Close releases the connection back to the pool.
+func GetClickHouseConnection(
+	ctx context.Context,
+	connConfig *config.Config,
+	settings *config.Settings,
+) (*ClickHouseConnection, error) {
+	entry, needsCheck, err := pool.acquire(connConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening a connection to ClickHouse: %w", err)
+	}
+	if needsCheck {
+		err = retry.OnNetError(func() error {
+			return entry.conn.Ping(ctx)
+		}, ctx, settings.Retry, "ping", false)
+		if err != nil {
+			pool.evict(entry)
+			pool.release(entry)
+			return nil, fmt.Errorf("ClickHouse connection error: %w", err)
+		}
+		pool.checked(entry)
+		log.Info("ClickHouse connection established successfully")
+	}
+	return &ClickHouseConnection{
+		Conn:          entry.conn,
+		poolEntry:     entry,
+		hosts:         strings.Join(connConfig.Addresses, ","),
+		identity:      metadataIdentity(connConfig),
+		username:      connConfig.Username,
+		isLocal:       connConfig.Local,
+		cluster:       connConfig.Cluster,
+		tableEngines:  connConfig.TableEngines,
+		columnOptions: connConfig.ColumnOptions,
+		nativeTypes:   newNativeTypes(connConfig),
+		coercions:     connConfig.CoercionPolicies,
+		settings:      settings,
+	}, nil
+}
+
+// Close releases the connection back to the pool; the underlying driver.Conn stays open for the next calls.
+// Safe to call more than once.
+func (conn *ClickHouseConnection) Close() error {
+	conn.releaseOnce.Do(func() {
+		if conn.poolEntry != nil {
+			pool.release(conn.poolEntry)
+		}
+	})
+	return nil
+}
 
-	settings := clickhouse.Settings{
+// openClickHouseConn creates a new driver.Conn for the pool; the connection itself is established lazily.
+func openClickHouseConn(connConfig *config.Config) (driver.Conn, error) {
+	log.Info(fmt.Sprintf("Initializing ClickHouse connection to %s (protocol: %s, connection strategy: %s)",
+		strings.Join(connConfig.Addresses, ","), connConfig.Protocol, connConfig.ConnectionStrategy))
+	if connConfig.Cluster != nil {
+		log.Info(fmt.Sprintf("Using self-hosted cluster %s (distributed: %t)",
+			connConfig.Cluster.Name, connConfig.Cluster.Distributed))
+	}
+	if connConfig.TLS != nil {
+		log.Info(fmt.Sprintf("Using custom TLS options (CA certificates: %d, client certificate: %t, server name: %q, min version: %s)",
+			len(connConfig.TLS.CACertificates), connConfig.TLS.ClientCertificate != nil,
+			connConfig.TLS.ServerName, tls.VersionName(connConfig.TLS.MinVersion)))
+	}
+
+	chSettings := clickhouse.Settings{
 		// support ISO DateTime formats from CSV
 		// https://clickhouse.com/docs/en/operations/settings/formats#date_time_input_format
 		"date_time_input_format": "best_effort",
@@ -84,23 +160,33 @@ func GetClickHouseConnection(ctx context.Context, connConfig *config.Config) (*C
 		// https://clickhouse.com/docs/en/operations/settings/settings#lightweight_deletes_sync
 		"lightweight_deletes_sync": 3,
 	}
-	var tlsConfig *tls.Config = nil
-	if !connConfig.Local {
-		tlsConfig = &tls.Config{InsecureSkipVerify: false}
-
+	if !connConfig.Local && connConfig.Cluster == nil {
 		// https://clickhouse.com/docs/en/operations/settings/settings#select_sequential_consistency
-		settings["select_sequential_consistency"] = 1
+		// Not set on self-hosted clusters: with ReplicatedMergeTree, it hides the data that was not inserted with insert_quorum.
+		chSettings["select_sequential_consistency"] = 1
+	}
+	if connConfig.Cluster.IsDistributed() {
+		// https://clickhouse.com/docs/en/operations/settings/settings#insert_distributed_sync
+		// the inserted data has to be visible on the shards before we run the mutations or FINAL selects.
+		chSettings["insert_distributed_sync"] = 1
+	}
+	protocol := clickhouse.Native
+	if connConfig.Protocol == config.ProtocolHTTP {
+		// HTTP(S) can pass through the proxies and load balancers that only allow HTTP traffic;
+		// the driver still uses the Native format for the data, so the scan types are the same.
+		protocol = clickhouse.HTTP
 	}
-	addr := fmt.Sprintf("%s:%d", connConfig.Host, connConfig.Port)
 	options := &clickhouse.Options{
-		Addr: []string{addr},
+		Addr:             connConfig.Addresses,
+		ConnOpenStrategy: toConnOpenStrategy(connConfig.ConnectionStrategy),
+		DialStrategy:     healthAwareDialStrategy,
 		Auth: clickhouse.Auth{
 			Username: connConfig.Username,
 			Password: connConfig.Password,
 			Database: "system",
 		},
-		Protocol:     clickhouse.Native,
-		Settings:     settings,
+		Protocol:     protocol,
+		Settings:     chSettings,
 		MaxOpenConns: int(*flags.MaxOpenConnections),
 		MaxIdleConns: int(*flags.MaxIdleConnections),
 		ReadTimeout:  *flags.RequestTimeoutDuration,
@@ -112,20 +198,9 @@ func GetClickHouseConnection(ctx context.Context, connConfig *config.Config) (*C
 				{Name: "fivetran-destination", Version: common.Version},
 			},
 		},
-		TLS: tlsConfig,
+		TLS: connConfig.TLSConfig(),
 	}
-	conn, err := clickhouse.Open(options)
-	if err != nil {
-		return nil, fmt.Errorf("error while opening a connection to ClickHouse: %w", err)
-	}
-	err = retry.OnNetError(func() error {
-		return conn.Ping(ctx)
-	}, ctx, "ping", false)
-	if err != nil {
-		return nil, fmt.Errorf("ClickHouse connection error: %w", err)
-	}
-	log.Info("ClickHouse connection established successfully")
-	return &ClickHouseConnection{Conn: conn, username: connConfig.Username, isLocal: connConfig.Local}, nil
+	return clickhouse.Open(options)
 }
 
 func (conn *ClickHouseConnection) ExecStatement(
@@ -151,7 +226,7 @@ func (conn *ClickHouseConnection) ExecStatement(
 	log.Info(fmt.Sprintf("Executing %s [query_id=%s]: %s", op, queryID, logQuery))
 	err := retry.OnNetError(func() error {
 		return conn.Exec(ctx, statementWithComment)
-	}, ctx, string(op), benchmark)
+	}, ctx, conn.settings.Retry, string(op), benchmark)
 
 	// Calculate duration once for consistent reporting
 	duration := time.Since(startTime)
@@ -164,6 +239,49 @@ func (conn *ClickHouseConnection) ExecStatement(
 	return nil
 }
 
+// ExecInsert executes an INSERT statement with an input format, such as INSERT ... FORMAT CSVWithNames,
+// followed by the data in that format, which is how the native protocol sends the data of such an insert.
+// Unlike ExecStatement, only the statement is logged and included in the errors, so that the inserted values
+// never reach the logs; ClickHouse doesn't keep the data in system.query_log either.
+// The data chunks are copied once, to build the query sent to ClickHouse.
+func (conn *ClickHouseConnection) ExecInsert(
+	ctx context.Context,
+	statement string,
+	op connectionOpType,
+	benchmark bool,
+	data ...[]byte,
+) error {
+	queryID := uuid.New().String()
+	ctx = clickhouse.Context(ctx, clickhouse.WithQueryID(queryID))
+
+	prefix := fmt.Sprintf("-- query_id: %s, operation: %s\n%s\n", queryID, op, statement)
+	size := len(prefix)
+	for _, chunk := range data {
+		size += len(chunk)
+	}
+	var query strings.Builder
+	query.Grow(size)
+	query.WriteString(prefix)
+	for _, chunk := range data {
+		query.Write(chunk)
+	}
+
+	startTime := time.Now()
+	log.Info(fmt.Sprintf("Executing %s [query_id=%s]: %s", op, queryID, statement))
+	err := retry.OnNetError(func() error {
+		return conn.Exec(ctx, query.String())
+	}, ctx, conn.settings.Retry, string(op), benchmark)
+
+	duration := time.Since(startTime)
+	conn.recordQuery(duration, err == nil)
+
+	if err != nil {
+		return fmt.Errorf("error while executing %s [query_id=%s]: %w", statement, queryID, err)
+	}
+	log.Info(fmt.Sprintf("Successfully executed %s [query_id=%s] in %v", op, queryID, duration))
+	return nil
+}
+
 func (conn *ClickHouseConnection) ExecQuery(
 	ctx context.Context,
 	query string,
@@ -187,7 +305,7 @@ func (conn *ClickHouseConnection) ExecQuery(
 	log.Info(fmt.Sprintf("Executing query %s [query_id=%s]: %s", op, queryID, logQuery))
 	rows, err := retry.OnNetErrorWithData(func() (driver.Rows, error) {
 		return conn.Query(ctx, queryWithID)
-	}, ctx, string(op), benchmark)
+	}, ctx, conn.settings.Retry, string(op), benchmark)
 
 	// Calculate duration once for consistent reporting
 	duration := time.Since(startTime)
@@ -257,11 +375,35 @@ func (conn *ClickHouseConnection) execAlterTableOps(
 	ops []*types.AlterTableOp,
 	op connectionOpType,
 ) error {
-	stmt, err := sql.GetAlterTableStatement(schemaName, tableName, ops)
-	if err != nil {
-		return err
+	for _, name := range conn.alterTableNames(tableName) {
+		stmt, err := sql.GetAlterTableStatement(schemaName, name, ops, conn.cluster)
+		if err != nil {
+			return err
+		}
+		if err = conn.ExecStatement(ctx, stmt, op, false); err != nil {
+			return err
+		}
 	}
-	return conn.ExecStatement(ctx, stmt, op, false)
+	return nil
+}
+
+// storageTableName returns the name of the table that physically stores the data:
+// the shard-local table if the cluster uses Distributed tables, or the table itself otherwise.
+// Mutations, lightweight deletes and system tables checks should use this name.
+func (conn *ClickHouseConnection) storageTableName(tableName string) string {
+	if conn.cluster.IsDistributed() {
+		return sql.LocalTableName(tableName)
+	}
+	return tableName
+}
+
+// alterTableNames returns the tables that should receive the same ALTER TABLE statement
+// to keep the table structure in sync: the shard-local table first, and then the Distributed table.
+func (conn *ClickHouseConnection) alterTableNames(tableName string) []string {
+	if conn.cluster.IsDistributed() {
+		return []string{sql.LocalTableName(tableName), tableName}
+	}
+	return []string{tableName}
 }
 
 // execInsertFromSelect runs INSERT INTO toTable SELECT cols FROM fromTable.
@@ -285,7 +427,8 @@ func (conn *ClickHouseConnection) DescribeTable(
 	schemaName string,
 	tableName string,
 ) (*types.TableDescription, error) {
-	query, err := sql.GetDescribeTableQuery(schemaName, tableName)
+	// Distributed tables have no primary key, so the shard-local table is described instead
+	query, err := sql.GetDescribeTableQuery(schemaName, conn.storageTableName(tableName))
 	if err != nil {
 		return nil, err
 	}
@@ -295,18 +438,32 @@ func (conn *ClickHouseConnection) DescribeTable(
 	}
 	defer rows.Close() //nolint:errcheck
 	var (
-		colName      string
-		colType      string
-		colComment   string
-		isPrimaryKey uint8
-		precision    *uint64
-		scale        *uint64
+		colName           string
+		colType           string
+		colComment        string
+		isPrimaryKey      uint8
+		precision         *uint64
+		scale             *uint64
+		defaultKind       string
+		defaultExpression string
 	)
-	var columns []*types.ColumnDefinition
+	ignoreDefaultColumns := conn.ignoreDefaultColumns(schemaName, tableName)
+	var columns, userColumns []*types.ColumnDefinition
 	for rows.Next() {
-		if err = rows.Scan(&colName, &colType, &colComment, &isPrimaryKey, &precision, &scale); err != nil {
+		if err = rows.Scan(&colName, &colType, &colComment, &isPrimaryKey, &precision, &scale,
+			&defaultKind, &defaultExpression); err != nil {
 			return nil, err
 		}
+		if isUserColumn(defaultKind, ignoreDefaultColumns) {
+			userColumns = append(userColumns, &types.ColumnDefinition{
+				Name:              colName,
+				Type:              colType,
+				Comment:           colComment,
+				DefaultKind:       defaultKind,
+				DefaultExpression: defaultExpression,
+			})
+			continue
+		}
 		var decimalParams *pb.DecimalParams = nil
 		if hasDecimalPrefix(colType) && precision != nil && scale != nil {
 			decimalParams = &pb.DecimalParams{Precision: uint32(*precision), Scale: uint32(*scale)}
@@ -319,13 +476,118 @@ func (conn *ClickHouseConnection) DescribeTable(
 			DecimalParams: decimalParams,
 		})
 	}
-	return types.MakeTableDescription(columns), nil
+	description := types.MakeTableDescription(columns)
+	description.UserColumns = userColumns
+	return description, nil
+}
+
+// DescribeTableCached is DescribeTable, but the result is taken from the process-wide metadata cache if possible.
+// The result is shared with the other calls and must not be modified. Tables that do not exist are not cached.
+func (conn *ClickHouseConnection) DescribeTableCached(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+) (*types.TableDescription, error) {
+	key := conn.tableMetadataKey(schemaName, tableName)
+	if description := metadataCache.getDescription(key); description != nil {
+		return description, nil
+	}
+	description, err := conn.DescribeTable(ctx, schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	if description != nil && len(description.Columns) > 0 {
+		metadataCache.putDescription(key, description)
+	}
+	return description, nil
+}
+
+// GetColumnTypesCached is GetColumnTypes, but the result is taken from the process-wide metadata cache if possible.
+// The result is shared with the other calls and must not be modified.
+func (conn *ClickHouseConnection) GetColumnTypesCached(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+) ([]driver.ColumnType, error) {
+	key := conn.tableMetadataKey(schemaName, tableName)
+	if columnTypes := metadataCache.getColumnTypes(key); columnTypes != nil {
+		return columnTypes, nil
+	}
+	columnTypes, err := conn.GetColumnTypes(ctx, schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	metadataCache.putColumnTypes(key, columnTypes)
+	return columnTypes, nil
+}
+
+// InvalidateTableMetadata removes the cached metadata of the table; should be called after any DDL on the table.
+func (conn *ClickHouseConnection) InvalidateTableMetadata(schemaName string, tableName string) {
+	metadataCache.invalidateTable(conn.hosts, schemaName, tableName)
+}
+
+// InvalidateSchemaMetadata removes the cached metadata of all the tables in the schema;
+// used after migrations, which can affect multiple tables (e.g. rename or copy).
+func (conn *ClickHouseConnection) InvalidateSchemaMetadata(schemaName string) {
+	metadataCache.invalidateSchema(conn.hosts, schemaName)
+}
+
+func (conn *ClickHouseConnection) tableMetadataKey(schemaName string, tableName string) tableMetadataKey {
+	return tableMetadataKey{
+		hosts:                conn.hosts,
+		identity:             conn.identity,
+		schemaName:           schemaName,
+		tableName:            tableName,
+		ignoreDefaultColumns: conn.ignoreDefaultColumns(schemaName, tableName),
+	}
+}
+
+// ignoreDefaultColumns returns true if the columns with a DEFAULT expression are user-owned in the table,
+// see types.TableEngineOptions.IgnoreDefaultColumns.
+func (conn *ClickHouseConnection) ignoreDefaultColumns(schemaName string, tableName string) bool {
+	options := conn.tableEngines.ForTable(schemaName, tableName)
+	return options != nil && options.IgnoreDefaultColumns
+}
+
+// GetDriverColumns returns the driver columns of the table (see GetColumnTypesCached) without the user-owned columns
+// (see types.TableDescription.UserColumns), with the binary encoding set for the columns with the decoded BINARY values,
+// which are distinguished by their comment (see DescribeTableCached). A FixedString column without the comment
+// is only decoded if binary decoding is enabled for it, as it is a pre-existing STRING column otherwise
+// (see dt.ClickHouseCompatibleToFivetranType).
+// The result also holds new coercion counters (see types.Coercions), so it should be requested once per WriteBatch.
+func (conn *ClickHouseConnection) GetDriverColumns(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+) (*types.DriverColumns, error) {
+	columnTypes, err := conn.GetColumnTypesCached(ctx, schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	description, err := conn.DescribeTableCached(ctx, schemaName, tableName)
+	if err != nil {
+		return nil, err
+	}
+	driverColumns := types.MakeDriverColumns(withoutUserColumns(columnTypes, description))
+	driverColumns.Coercions = types.NewCoercions(conn.coercions)
+	for _, col := range driverColumns.Columns {
+		isRawBinary := false
+		if colDef, ok := description.Mapping[col.Name]; ok {
+			isRawBinary = colDef.Comment == constants.RawBinaryColumnComment
+		}
+		_, isFixedString := dt.FixedStringLength(col.DatabaseType)
+		if isRawBinary || isFixedString && conn.nativeTypes.binary.ForColumn(schemaName, tableName, col.Name) != "" {
+			col.BinaryEncoding = conn.nativeTypes.binaryEncoding
+		}
+	}
+	return driverColumns, nil
 }
 
 // GetColumnTypes returns the information about the table columns as reported by the driver;
 // columns have the same order as in the ClickHouse table definition.
 // It is used to determine the scan types of the rows that we will insert into the table,
 // as well as validate the CSV header and build a proper mapping of CSV -> database columns indices.
+// If the cluster uses Distributed tables, the Distributed table is queried, as it is the one receiving the inserts.
 func (conn *ClickHouseConnection) GetColumnTypes(
 	ctx context.Context,
 	schemaName string,
@@ -340,7 +602,12 @@ func (conn *ClickHouseConnection) GetColumnTypes(
 		return nil, err
 	}
 	defer rows.Close() //nolint:errcheck
-	return rows.ColumnTypes(), nil
+	columnTypes := rows.ColumnTypes()
+	if len(columnTypes) == 0 {
+		// should not happen, but the HTTP driver returns no columns for an empty response body
+		return nil, fmt.Errorf("no column types returned for table %s.%s", schemaName, tableName)
+	}
+	return columnTypes, nil
 }
 
 func (conn *ClickHouseConnection) GetUserGrants(ctx context.Context) ([]*types.UserGrant, error) {
@@ -425,7 +692,7 @@ func (conn *ClickHouseConnection) CreateDatabase(
 	ctx context.Context,
 	schemaName string,
 ) error {
-	statement, err := sql.GetCreateDatabaseStatement(schemaName)
+	statement, err := sql.GetCreateDatabaseStatement(schemaName, conn.cluster)
 	if err != nil {
 		return err
 	}
@@ -442,12 +709,21 @@ func (conn *ClickHouseConnection) CreateDatabase(
 
 // CreateTable will additionally create a database if it does not exist yet.
 // It is done since we don't always know the name of the "schema" that a particular connector might use.
+// The native types and the configured column options are applied to the table description,
+// see applyNativeTypes and applyColumnOptions.
 func (conn *ClickHouseConnection) CreateTable(
 	ctx context.Context,
 	schemaName string,
 	tableName string,
 	tableDescription *types.TableDescription,
 ) error {
+	defer conn.InvalidateTableMetadata(schemaName, tableName)
+	ctx = conn.nativeTypes.withSettings(ctx)
+	tableDescription = applyNativeTypes(schemaName, tableName, nil, tableDescription, conn.nativeTypes)
+	tableDescription, err := applyColumnOptions(schemaName, tableName, tableDescription, conn.columnOptions, false)
+	if err != nil {
+		return err
+	}
 	databaseExists, err := conn.CheckDatabaseExists(ctx, schemaName)
 	if err != nil {
 		return err
@@ -458,14 +734,55 @@ func (conn *ClickHouseConnection) CreateTable(
 			return err
 		}
 	}
-	statement, err := sql.GetCreateTableStatement(schemaName, tableName, tableDescription)
+	options := conn.tableEngines.ForTable(schemaName, tableName)
+	return conn.createTable(ctx, schemaName, tableName, tableDescription, options, createTable)
+}
+
+// createTable creates a table without checking the database existence.
+// If the cluster uses Distributed tables, it creates the shard-local table and the Distributed table on top of it;
+// the table engine options only apply to the shard-local table.
+func (conn *ClickHouseConnection) createTable(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+	tableDescription *types.TableDescription,
+	options *types.TableEngineOptions,
+	op connectionOpType,
+) error {
+	statement, err := sql.GetCreateTableStatement(
+		schemaName, conn.storageTableName(tableName), tableDescription, conn.cluster, conn.withDeduplicationWindow(options))
+	if err != nil {
+		return err
+	}
+	if err = conn.ExecStatement(ctx, statement, op, false); err != nil {
+		return err
+	}
+	if !conn.cluster.IsDistributed() {
+		return nil
+	}
+	return conn.createDistributedTable(ctx, schemaName, tableName, tableDescription, op)
+}
+
+func (conn *ClickHouseConnection) createDistributedTable(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+	tableDescription *types.TableDescription,
+	op connectionOpType,
+) error {
+	statement, err := sql.GetCreateDistributedTableStatement(schemaName, tableName, tableDescription, conn.cluster)
 	if err != nil {
 		return err
 	}
-	return conn.ExecStatement(ctx, statement, createTable, false)
+	return conn.ExecStatement(ctx, statement, op, false)
 }
 
 // AlterTable will not execute any statements if both table definitions are identical.
+// The user-owned columns (see types.TableDescription.UserColumns) are not a part of either definition,
+// and are kept as is; if the table is recreated to change the primary key, they are recreated as well.
+// The native types and the configured column options are applied to the new table definition,
+// see applyNativeTypes and applyColumnOptions. The existing String columns that are modified to a native type
+// are prepared in advance, see alterColumns.
 func (conn *ClickHouseConnection) AlterTable(
 	ctx context.Context,
 	schemaName string,
@@ -473,6 +790,16 @@ func (conn *ClickHouseConnection) AlterTable(
 	from *types.TableDescription,
 	to *types.TableDescription,
 ) (wasExecuted bool, err error) {
+	defer conn.InvalidateTableMetadata(schemaName, tableName)
+	userColumns := from.UserColumns
+	if from, err = applyColumnOptions(schemaName, tableName, from, conn.columnOptions, true); err != nil {
+		return false, err
+	}
+	ctx = conn.nativeTypes.withSettings(ctx)
+	to = applyNativeTypes(schemaName, tableName, from, to, conn.nativeTypes)
+	if to, err = applyColumnOptions(schemaName, tableName, to, conn.columnOptions, false); err != nil {
+		return false, err
+	}
 	ops, hasChangedPK, unchangedColNames, err := GetAlterTableOps(from, to)
 	if err != nil {
 		return false, err
@@ -483,13 +810,19 @@ func (conn *ClickHouseConnection) AlterTable(
 		backupTableName := fmt.Sprintf("%s_backup_%d", tableName, unixMilli)
 		log.Info(fmt.Sprintf("AlterTable with PK change detected; backup table name: %s, new table name: %s",
 			backupTableName, newTableName))
-		createTableStmt, err := sql.GetCreateTableStatement(schemaName, newTableName, to)
+		// the options are configured for the original table name, not the temporary one
+		options := conn.tableEngines.ForTable(schemaName, tableName)
+		err = conn.createTable(ctx, schemaName, newTableName, to, options, alterTablePKCreateTable)
 		if err != nil {
 			return false, err
 		}
-		err = conn.ExecStatement(ctx, createTableStmt, alterTablePKCreateTable, false)
-		if err != nil {
-			return false, err
+		// the user-owned columns are never dropped, so they are added to the new table as well
+		if len(userColumns) > 0 {
+			if err = conn.alterColumns(
+				ctx, schemaName, newTableName, to, userColumnOps(userColumns), alterTablePKAddUserColumns); err != nil {
+				return false, err
+			}
+			unchangedColNames = append(unchangedColNames, storedUserColumnNames(userColumns)...)
 		}
 		if len(unchangedColNames) > 0 {
 			if err := conn.execInsertFromSelect(
@@ -513,24 +846,179 @@ func (conn *ClickHouseConnection) AlterTable(
 		if len(ops) == 0 {
 			return false, nil
 		}
-		statement, err := sql.GetAlterTableStatement(schemaName, tableName, ops)
-		if err != nil {
-			return false, err
-		}
-		if err := conn.execMutation(ctx, statement, schemaName, tableName, alterTable); err != nil {
+		if err = conn.alterColumns(ctx, schemaName, tableName, from, ops, alterTable); err != nil {
 			return false, err
 		}
 	}
 	return true, nil
 }
 
+// alterColumns executes the ALTER TABLE operations that don't change the primary key.
+// The existing String columns that are modified to a native type are prepared first,
+// see prepareNativeJSONMigration and prepareNativeTimeMigration.
+func (conn *ClickHouseConnection) alterColumns(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+	from *types.TableDescription,
+	ops []*types.AlterTableOp,
+	op connectionOpType,
+) error {
+	for _, migration := range nativeTypeMigrations(from, ops) {
+		var err error
+		if dt.IsJSONType(*migration.Type) {
+			err = conn.prepareNativeJSONMigration(ctx, schemaName, tableName, migration.Column)
+		} else {
+			err = conn.prepareNativeTimeMigration(ctx, schemaName, tableName, migration.Column)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	for i, name := range conn.alterTableNames(tableName) {
+		if i > 0 {
+			// Distributed tables do not support skip indexes
+			if ops = withoutIndexOps(ops); len(ops) == 0 {
+				break
+			}
+		}
+		statement, err := sql.GetAlterTableStatement(schemaName, name, ops, conn.cluster)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			err = conn.execMutation(ctx, statement, schemaName, tableName, op)
+		} else {
+			// Distributed table: metadata-only change
+			err = conn.ExecStatement(ctx, statement, op, false)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepareNativeJSONMigration checks that all values of a String column are JSON objects,
+// and replaces NULLs and empty strings with empty JSON objects, as the native JSON type can't be Nullable.
+// Otherwise, modifying the column to the native JSON type would fail, leaving a failed mutation behind.
+func (conn *ClickHouseConnection) prepareNativeJSONMigration(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+	columnName string,
+) error {
+	query, err := sql.GetNativeJSONCheckQuery(schemaName, tableName, columnName)
+	if err != nil {
+		return err
+	}
+	onlyObjects, err := conn.ExecBoolQuery(ctx, query, checkNativeJSON, false)
+	if err != nil {
+		return err
+	}
+	if !onlyObjects {
+		return fmt.Errorf("column %s of table %s.%s contains values that are not JSON objects, "+
+			"and can't be migrated to the native JSON type", columnName, schemaName, tableName)
+	}
+	statement, err := sql.GetNativeJSONReplaceNullsStatement(
+		schemaName, conn.storageTableName(tableName), columnName, conn.cluster)
+	if err != nil {
+		return err
+	}
+	return conn.execMutation(ctx, statement, schemaName, tableName, replaceNativeJSONNulls)
+}
+
+// prepareNativeTimeMigration normalizes the short time values (HH:MM) of a String column to HH:MM:SS,
+// and checks that all values can be converted to the native time type.
+// Otherwise, modifying the column to the native time type would fail, leaving a failed mutation behind.
+func (conn *ClickHouseConnection) prepareNativeTimeMigration(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+	columnName string,
+) error {
+	statement, err := sql.GetNativeTimeNormalizeStatement(
+		schemaName, conn.storageTableName(tableName), columnName, conn.cluster)
+	if err != nil {
+		return err
+	}
+	if err = conn.execMutation(ctx, statement, schemaName, tableName, normalizeNativeTime); err != nil {
+		return err
+	}
+	query, err := sql.GetCastCheckQuery(schemaName, tableName, columnName, conn.nativeTypes.naiveTime)
+	if err != nil {
+		return err
+	}
+	canCast, err := conn.ExecBoolQuery(ctx, query, checkNativeTime, false)
+	if err != nil {
+		return err
+	}
+	if !canCast {
+		return fmt.Errorf("column %s of table %s.%s contains values that are not valid times, "+
+			"and can't be migrated to the native %s type", columnName, schemaName, tableName, conn.nativeTypes.naiveTime)
+	}
+	return nil
+}
+
+// migrateToNativeType modifies a String column added by a migration to its native type, if it is enabled.
+// The column is added as String first, so the default values are set in the same way as without the native types.
+// BINARY columns keep the encoded default value, as the existing BINARY columns are never decoded (see nativeTypes.apply).
+func (conn *ClickHouseConnection) migrateToNativeType(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+	column string,
+	chType string,
+	comment string,
+) error {
+	if !isStringType(chType) || comment == constants.BinaryColumnComment {
+		return nil
+	}
+	col := &types.ColumnDefinition{Name: column, Type: chType, Comment: comment}
+	nativeType := conn.nativeTypes.forColumn(schemaName, tableName, col)
+	if nativeType == "" {
+		return nil
+	}
+	ops := []*types.AlterTableOp{{Op: types.AlterTableModify, Column: column, Type: &nativeType, Comment: &comment}}
+	from := types.MakeTableDescription([]*types.ColumnDefinition{col})
+	ctx = conn.nativeTypes.withSettings(ctx)
+	return conn.alterColumns(ctx, schemaName, tableName, from, ops, migrateNativeType)
+}
+
+// RenameTable renames a table. If the cluster uses Distributed tables, the shard-local table is renamed,
+// and the Distributed table is re-created with the new name, as it refers to the shard-local table by name.
 func (conn *ClickHouseConnection) RenameTable(
 	ctx context.Context,
 	schemaName string,
 	fromTableName string,
 	toTableName string,
 ) error {
-	renameStmt, err := sql.GetRenameTableStatement(schemaName, fromTableName, toTableName)
+	defer conn.InvalidateTableMetadata(schemaName, fromTableName)
+	defer conn.InvalidateTableMetadata(schemaName, toTableName)
+	if !conn.cluster.IsDistributed() {
+		return conn.renameTable(ctx, schemaName, fromTableName, toTableName)
+	}
+	err := conn.renameTable(ctx, schemaName, sql.LocalTableName(fromTableName), sql.LocalTableName(toTableName))
+	if err != nil {
+		return err
+	}
+	if err = conn.dropTable(ctx, schemaName, fromTableName); err != nil {
+		return err
+	}
+	tableDescription, err := conn.DescribeTable(ctx, schemaName, toTableName)
+	if err != nil {
+		return err
+	}
+	return conn.createDistributedTable(ctx, schemaName, toTableName, tableDescription, renameTable)
+}
+
+func (conn *ClickHouseConnection) renameTable(
+	ctx context.Context,
+	schemaName string,
+	fromTableName string,
+	toTableName string,
+) error {
+	renameStmt, err := sql.GetRenameTableStatement(schemaName, fromTableName, toTableName, conn.cluster)
 	if err != nil {
 		return err
 	}
@@ -572,7 +1060,7 @@ func (conn *ClickHouseConnection) TruncateTable(
 	truncateBefore time.Time,
 	softDeletedColumn *string,
 ) error {
-	statement, err := sql.GetTruncateTableStatement(schemaName, tableName, syncedColumn, truncateBefore, softDeletedColumn)
+	statement, err := sql.GetTruncateTableStatement(schemaName, conn.storageTableName(tableName), syncedColumn, truncateBefore, softDeletedColumn, conn.cluster)
 	if err != nil {
 		return err
 	}
@@ -585,20 +1073,44 @@ func (conn *ClickHouseConnection) TruncateTable(
 	return conn.execMutation(ctx, statement, schemaName, tableName, op)
 }
 
+// DropTable drops a table if it exists. If the cluster uses Distributed tables,
+// the Distributed table is dropped first, and then the shard-local table.
 func (conn *ClickHouseConnection) DropTable(
 	ctx context.Context,
-	qualifiedTableName sql.QualifiedTableName,
+	schemaName string,
+	tableName string,
 ) error {
-	statement, err := sql.GetDropTableStatement(qualifiedTableName)
+	defer conn.InvalidateTableMetadata(schemaName, tableName)
+	if err := conn.dropTable(ctx, schemaName, tableName); err != nil {
+		return err
+	}
+	if !conn.cluster.IsDistributed() {
+		return nil
+	}
+	return conn.dropTable(ctx, schemaName, sql.LocalTableName(tableName))
+}
+
+func (conn *ClickHouseConnection) dropTable(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+) error {
+	qualifiedTableName, err := sql.GetQualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return err
+	}
+	statement, err := sql.GetDropTableStatement(qualifiedTableName, conn.cluster)
 	if err != nil {
 		return err
 	}
 	return conn.ExecStatement(ctx, statement, dropTable, false)
 }
 
+// InsertBatch inserts the rows into the listed columns (see sql.GetInsertStatement); the rows in skipIdx are skipped.
 func (conn *ClickHouseConnection) InsertBatch(
 	ctx context.Context,
 	qualifiedTableName sql.QualifiedTableName,
+	columnNames []string,
 	rows [][]interface{},
 	skipIdx map[int]bool,
 	opName string,
@@ -607,43 +1119,78 @@ func (conn *ClickHouseConnection) InsertBatch(
 		log.Warn(fmt.Sprintf("[%s] All rows are skipped for %s", opName, qualifiedTableName))
 		return nil
 	}
-	return retry.OnNetError(func() error {
-		batch, err := conn.PrepareBatch(ctx, fmt.Sprintf("INSERT INTO %s", qualifiedTableName))
-		if err != nil {
-			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-				// ctx.Err() is diagnostic context, not the primary error; %v is nil-safe.
-				return fmt.Errorf("error while preparing batch for %s: %w (context state: %v)", qualifiedTableName, err, ctx.Err()) //nolint:errorlint
-			}
-			return fmt.Errorf("error while preparing batch for %s: %w", qualifiedTableName, err)
-		}
+	statement, err := sql.GetInsertStatement(qualifiedTableName, columnNames)
+	if err != nil {
+		return err
+	}
+	return conn.sendBatch(ctx, qualifiedTableName, statement, opName, func(batch driver.Batch) error {
 		for i, row := range rows {
 			if skipIdx[i] {
 				continue
 			}
-			err = batch.Append(row...)
-			if err != nil {
-				if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-					// ctx.Err() is diagnostic context, not the primary error; %v is nil-safe.
-					return fmt.Errorf("error appending row to a batch for %s: %w (context state: %v)", qualifiedTableName, err, ctx.Err()) //nolint:errorlint
-				}
-				return fmt.Errorf("error appending row to a batch for %s: %w", qualifiedTableName, err)
+			if err := batch.Append(row...); err != nil {
+				return err
 			}
 		}
-		err = batch.Send()
+		return nil
+	})
+}
+
+// InsertColumns is InsertBatch for the rows parsed column by column, see InsertColumns.
+func (conn *ClickHouseConnection) InsertColumns(
+	ctx context.Context,
+	qualifiedTableName sql.QualifiedTableName,
+	columnNames []string,
+	columns *InsertColumns,
+	opName string,
+) error {
+	if columns.Rows() == 0 {
+		log.Warn(fmt.Sprintf("[%s] No rows to insert for %s", opName, qualifiedTableName))
+		return nil
+	}
+	statement, err := sql.GetInsertStatement(qualifiedTableName, columnNames)
+	if err != nil {
+		return err
+	}
+	return conn.sendBatch(ctx, qualifiedTableName, statement, opName, columns.appendTo)
+}
+
+// sendBatch prepares a batch for the INSERT statement, fills it with appendRows, and sends it;
+// the whole batch is prepared and filled again on a retry.
+func (conn *ClickHouseConnection) sendBatch(
+	ctx context.Context,
+	qualifiedTableName sql.QualifiedTableName,
+	statement string,
+	opName string,
+	appendRows func(batch driver.Batch) error,
+) error {
+	// ctx.Err() is diagnostic context, not the primary error; %v is nil-safe.
+	wrap := func(err error, action string) error {
+		if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return fmt.Errorf("error %s for %s: %w (context state: %v)", action, qualifiedTableName, err, ctx.Err()) //nolint:errorlint
+		}
+		return fmt.Errorf("error %s for %s: %w", action, qualifiedTableName, err)
+	}
+	return retry.OnNetError(func() error {
+		batch, err := conn.PrepareBatch(ctx, statement)
 		if err != nil {
-			if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
-				// ctx.Err() is diagnostic context, not the primary error; %v is nil-safe.
-				return fmt.Errorf("error while sending batch for %s: %w (context state: %v)", qualifiedTableName, err, ctx.Err()) //nolint:errorlint
-			}
-			return fmt.Errorf("error while sending batch for %s: %w", qualifiedTableName, err)
+			return wrap(err, "while preparing batch")
+		}
+		if err = appendRows(batch); err != nil {
+			return wrap(err, "appending rows to a batch")
+		}
+		if err = batch.Send(); err != nil {
+			return wrap(err, "while sending batch")
 		}
 		return nil
-	}, ctx, opName, true)
+	}, ctx, conn.settings.Retry, opName, true)
 }
 
 // SelectByPrimaryKeys selects rows from the table by primary keys found in the CSV.
 // The CSV is split into groups, and each group is processed in parallel.
 // The results are merged into a map of primary key values to the rows.
+// If the cluster uses Distributed tables, the Distributed table is queried; FINAL is then applied on each shard,
+// which is correct as long as all versions of a row are stored on the same shard (see sql.GetCreateDistributedTableStatement).
 func (conn *ClickHouseConnection) SelectByPrimaryKeys(
 	ctx context.Context,
 	qualifiedTableName sql.QualifiedTableName,
@@ -654,7 +1201,13 @@ func (conn *ClickHouseConnection) SelectByPrimaryKeys(
 ) (RowsByPrimaryKeyValue, error) {
 	return benchmark.RunAndNoticeWithData(func() (RowsByPrimaryKeyValue, error) {
 		scanRows := ColumnTypesToEmptyScanRows(driverColumns, uint(len(csv)))
-		groups, err := GroupSlices(uint(len(csv)), *flags.SelectBatchSize, *flags.MaxParallelSelects)
+		if driverColumns.HasNativeJSON() {
+			// the native JSON columns can only be scanned as strings with this setting, see types.MakeDriverColumns
+			ctx = clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+				"output_format_native_write_json_as_string": 1,
+			}))
+		}
+		groups, err := GroupSlices(uint(len(csv)), conn.settings.SelectBatchSize, conn.settings.MaxParallelSelects)
 		if err != nil {
 			return nil, err
 		}
@@ -667,7 +1220,7 @@ func (conn *ClickHouseConnection) SelectByPrimaryKeys(
 				s := slice
 				eg.Go(func() error {
 					batch := csv[s.Start:s.End]
-					query, err := sql.GetSelectByPrimaryKeysQuery(batch, csvCols, qualifiedTableName, isHistoryMode)
+					query, err := sql.GetSelectByPrimaryKeysQuery(batch, csvCols, driverColumns.Names(), qualifiedTableName, isHistoryMode)
 					if err != nil {
 						return err
 					}
@@ -678,7 +1231,7 @@ func (conn *ClickHouseConnection) SelectByPrimaryKeys(
 					defer rows.Close() //nolint:errcheck
 					mutex.Lock()
 					defer mutex.Unlock()
-					for i := s.Num * (*flags.SelectBatchSize); rows.Next(); i++ {
+					for i := s.Num * conn.settings.SelectBatchSize; rows.Next(); i++ {
 						if err = rows.Scan(scanRows[i]...); err != nil {
 							return err
 						}
@@ -709,16 +1262,24 @@ func (conn *ClickHouseConnection) SelectByPrimaryKeys(
 // Inserts are done in sequence, `replaceBatchSize` records at a time,
 // and the batch size should be relatively high, up to 100K+ records at a time,
 // as we don't do any SELECT queries in advance, and we also don't use async_insert feature here.
+// The next batches are read while the previous one is inserted, see pipeline and config.Settings.WriteQueueDepth.
 //
 // Any duplicates are handled by ReplacingMergeTree itself (during merges or when using SELECT FINAL),
-// so it's safe to retry and not care about inserting the same record several times.
+// so it's safe to retry and not care about inserting the same record several times. If insert deduplication
+// is enabled, every batch is inserted with an insert_deduplication_token derived from the batch rows
+// (see deduplicationTokens), so that the batches that were already inserted are skipped by ClickHouse instead.
+//
+// If the file is a typed batch file (e.g., Parquet), its values are used as-is instead of being parsed from strings.
+// If server-side CSV parsing is enabled, the CSV rows are sent as they are, and parsed by ClickHouse
+// (see replaceBatchCSV and isServerSideCSVSupported). Otherwise, the CSV rows are parsed column by column,
+// see replaceBatchColumns.
 //
-// NB: retries are handled by InsertBatch
+// NB: retries are handled by InsertBatch and InsertColumns
 func (conn *ClickHouseConnection) ReplaceBatch(
 	ctx context.Context,
 	schemaName string,
 	table *pb.Table,
-	reader *csvfile.CSVFileReader,
+	reader files.BatchFileReader,
 	csvColumns *types.CSVColumns,
 	nullStr string,
 ) (int, error) {
@@ -727,34 +1288,92 @@ func (conn *ClickHouseConnection) ReplaceBatch(
 		if err != nil {
 			return 0, err
 		}
+		tokens := conn.newDeduplicationTokens(reader.Header())
+		if rawReader, isRaw := reader.(files.RawBatchFileReader); isRaw && conn.isServerSideCSVSupported(csvColumns) {
+			return conn.replaceBatchCSV(ctx, qualifiedTableName, rawReader, nullStr, tokens)
+		}
+		typedReader, isTyped := reader.(files.TypedBatchFileReader)
+		if recordReader, isRecord := reader.(files.RecordBatchFileReader); isRecord && !isTyped {
+			return conn.replaceBatchColumns(ctx, qualifiedTableName, recordReader, csvColumns, nullStr, tokens)
+		}
+		columnNames := csvColumns.TableColumnNames()
 		totalRows := 0
-		for {
-			batch, err := reader.ReadBatch(*flags.WriteBatchSize)
-			if err != nil {
-				return totalRows, err
-			}
-			if batch == nil {
-				break
-			}
-			totalRows += len(batch)
-			log.Notice(fmt.Sprintf("[%s] Read batch of %d rows (total so far: %d)", insertBatchReplace, len(batch), totalRows))
-			insertRows := make([][]interface{}, len(batch))
-			for j, csvRow := range batch {
-				insertRow, err := ToInsertRow(csvRow, csvColumns, nullStr)
-				if err != nil {
-					return totalRows, err
+		err = pipeline(ctx, conn.settings.WriteQueueDepth,
+			func(ctx context.Context) (tokenBatch[[][]interface{}], bool, error) {
+				var (
+					insertRows [][]interface{}
+					err        error
+				)
+				if isTyped {
+					insertRows, err = readTypedInsertRows(typedReader, conn.settings.WriteBatchSize, csvColumns, tokens)
+				} else {
+					insertRows, err = readInsertRows(reader, conn.settings.WriteBatchSize, csvColumns, nullStr, tokens)
 				}
-				insertRows[j] = insertRow
-			}
-			err = conn.InsertBatch(ctx, qualifiedTableName, insertRows, nil, string(insertBatchReplaceTask))
-			if err != nil {
-				return totalRows, err
-			}
-		}
-		return totalRows, nil
+				if err != nil || insertRows == nil {
+					return tokenBatch[[][]interface{}]{}, false, err
+				}
+				totalRows += len(insertRows)
+				log.Notice(fmt.Sprintf("[%s] Read batch of %d rows (total so far: %d)", insertBatchReplace, len(insertRows), totalRows))
+				return tokenBatch[[][]interface{}]{rows: insertRows, token: tokens.next()}, true, nil
+			},
+			func(ctx context.Context, batch tokenBatch[[][]interface{}]) error {
+				return conn.InsertBatch(withDeduplicationToken(ctx, nil, batch.token), qualifiedTableName, columnNames,
+					batch.rows, nil, string(insertBatchReplaceTask))
+			})
+		return totalRows, err
 	}, string(insertBatchReplace))
 }
 
+// readInsertRows reads the next batch of rows from the file and converts them with ToInsertRow;
+// the rows are also added to the deduplication tokens.
+// Returns (nil, nil) when there are no more rows to read.
+func readInsertRows(
+	reader files.BatchFileReader,
+	batchSize uint,
+	csvColumns *types.CSVColumns,
+	nullStr string,
+	tokens *deduplicationTokens,
+) ([][]interface{}, error) {
+	batch, err := reader.ReadBatch(batchSize)
+	if err != nil || batch == nil {
+		return nil, err
+	}
+	insertRows := make([][]interface{}, len(batch))
+	for j, csvRow := range batch {
+		tokens.addRow(csvRow)
+		insertRow, err := ToInsertRow(csvRow, csvColumns, nullStr)
+		if err != nil {
+			return nil, err
+		}
+		insertRows[j] = insertRow
+	}
+	return insertRows, nil
+}
+
+// readTypedInsertRows is similar to readInsertRows, but uses the already typed values
+// from the file, avoiding parsing them from strings (see ToInsertRowFromTyped).
+func readTypedInsertRows(
+	reader files.TypedBatchFileReader,
+	batchSize uint,
+	csvColumns *types.CSVColumns,
+	tokens *deduplicationTokens,
+) ([][]interface{}, error) {
+	batch, err := reader.ReadTypedBatch(batchSize)
+	if err != nil || batch == nil {
+		return nil, err
+	}
+	insertRows := make([][]interface{}, len(batch))
+	for j, typedRow := range batch {
+		tokens.addTypedRow(typedRow)
+		insertRow, err := ToInsertRowFromTyped(typedRow, csvColumns)
+		if err != nil {
+			return nil, err
+		}
+		insertRows[j] = insertRow
+	}
+	return insertRows, nil
+}
+
 // UpdateBatch uses one of "update" CSV to insert the updated versions of the records into the table.
 //
 // Selects rows by PK found in CSV, merges these rows with the CSV values, and inserts them back.
@@ -762,6 +1381,7 @@ func (conn *ClickHouseConnection) ReplaceBatch(
 // If a record is not found in the table, it is skipped (though it should not usually happen).
 // If a CSV column value equals to `unmodifiedStr`, that means that the original value should be preserved.
 // If a CSV column value equals to `nullStr`, that means that the column value should be set to NULL.
+// The next batches are read while the previous one is updated, see pipeline and config.Settings.WriteQueueDepth.
 //
 // In the end, ReplacingMergeTree handles the merging of the updated records with their previous versions.
 // Any duplicates are also handled by ReplacingMergeTree itself (during merges or when using SELECT FINAL),
@@ -774,7 +1394,7 @@ func (conn *ClickHouseConnection) UpdateBatch(
 	table *pb.Table,
 	driverColumns *types.DriverColumns,
 	csvColumns *types.CSVColumns,
-	reader *csvfile.CSVFileReader,
+	reader files.BatchFileReader,
 	nullStr string,
 	unmodifiedStr string,
 	isHistoryMode bool,
@@ -784,46 +1404,48 @@ func (conn *ClickHouseConnection) UpdateBatch(
 		if err != nil {
 			return 0, err
 		}
+		columnNames := csvColumns.TableColumnNames()
 		totalRows := 0
-		for {
-			batch, err := reader.ReadBatch(*flags.WriteBatchSize)
-			if err != nil {
-				return totalRows, err
-			}
-			if batch == nil {
-				break
-			}
-			totalRows += len(batch)
-			log.Notice(fmt.Sprintf("[%s] Read batch of %d rows (total so far: %d)", insertBatchUpdate, len(batch), totalRows))
-			selectRows, err := conn.SelectByPrimaryKeys(ctx, qualifiedTableName, driverColumns, csvColumns, batch, isHistoryMode)
-			if err != nil {
-				return totalRows, err
-			}
-			insertRows, skipIdx, err := MergeUpdatedRows(batch, selectRows, csvColumns, nullStr, unmodifiedStr, isHistoryMode)
-			if err != nil {
-				return totalRows, err
-			}
-			err = conn.InsertBatch(ctx, qualifiedTableName, insertRows, skipIdx, string(insertBatchUpdateTask))
-			if err != nil {
-				return totalRows, err
-			}
-		}
-		return totalRows, nil
+		err = pipeline(ctx, conn.settings.WriteQueueDepth,
+			func(ctx context.Context) ([][]string, bool, error) {
+				batch, err := reader.ReadBatch(conn.settings.WriteBatchSize)
+				if err != nil || batch == nil {
+					return nil, false, err
+				}
+				totalRows += len(batch)
+				log.Notice(fmt.Sprintf("[%s] Read batch of %d rows (total so far: %d)", insertBatchUpdate, len(batch), totalRows))
+				return batch, true, nil
+			},
+			func(ctx context.Context, batch [][]string) error {
+				// the batches are selected, merged and inserted in sequence, so that the rows updated
+				// by the previous batches are selected with their latest values
+				selectRows, err := conn.SelectByPrimaryKeys(ctx, qualifiedTableName, driverColumns, csvColumns, batch, isHistoryMode)
+				if err != nil {
+					return err
+				}
+				insertRows, skipIdx, err := MergeUpdatedRows(batch, selectRows, csvColumns, nullStr, unmodifiedStr, isHistoryMode)
+				if err != nil {
+					return err
+				}
+				return conn.InsertBatch(ctx, qualifiedTableName, columnNames, insertRows, skipIdx,
+					string(insertBatchUpdateTask))
+			})
+		return totalRows, err
 	}, string(insertBatchUpdate))
 }
 
 // HardDelete is called when processing "delete" CSVs.
-// Uses lightweight deletes to remove records from the table.
+// Uses lightweight deletes to remove records from the table (or from the shard-local tables, if the cluster uses Distributed tables).
 // See also: sql.GetHardDeleteStatement
 func (conn *ClickHouseConnection) HardDelete(
 	ctx context.Context,
 	schemaName string,
 	table *pb.Table,
-	reader *csvfile.CSVFileReader,
+	reader files.BatchFileReader,
 	csvColumns *types.CSVColumns,
 ) (int, error) {
 	return benchmark.RunAndNoticeWithData(func() (int, error) {
-		qualifiedTableName, err := sql.GetQualifiedTableName(schemaName, table.Name)
+		qualifiedTableName, err := sql.GetQualifiedTableName(schemaName, conn.storageTableName(table.Name))
 		if err != nil {
 			return 0, err
 		}
@@ -833,7 +1455,7 @@ func (conn *ClickHouseConnection) HardDelete(
 		}
 		totalRows := 0
 		for {
-			batch, err := reader.ReadBatch(*flags.HardDeleteBatchSize)
+			batch, err := reader.ReadBatch(conn.settings.HardDeleteBatchSize)
 			if err != nil {
 				return totalRows, err
 			}
@@ -842,7 +1464,7 @@ func (conn *ClickHouseConnection) HardDelete(
 			}
 			totalRows += len(batch)
 			log.Notice(fmt.Sprintf("[%s] Read batch of %d rows (total so far: %d)", insertBatchHardDelete, len(batch), totalRows))
-			statement, err := sql.GetHardDeleteStatement(batch, csvColumns, qualifiedTableName)
+			statement, err := sql.GetHardDeleteStatement(batch, csvColumns, qualifiedTableName, conn.cluster)
 			if err != nil {
 				return totalRows, err
 			}
@@ -867,11 +1489,11 @@ func (conn *ClickHouseConnection) HardDeleteForEarliestStartHistory(
 	ctx context.Context,
 	schemaName string,
 	table *pb.Table,
-	reader *csvfile.CSVFileReader,
+	reader files.BatchFileReader,
 	csvColumns *types.CSVColumns,
 ) (int, error) {
 	return benchmark.RunAndNoticeWithData(func() (int, error) {
-		qualifiedTableName, err := sql.GetQualifiedTableName(schemaName, table.Name)
+		qualifiedTableName, err := sql.GetQualifiedTableName(schemaName, conn.storageTableName(table.Name))
 		if err != nil {
 			return 0, err
 		}
@@ -888,7 +1510,7 @@ func (conn *ClickHouseConnection) HardDeleteForEarliestStartHistory(
 
 		totalRows := 0
 		for {
-			batch, err := reader.ReadBatch(*flags.HardDeleteBatchSize)
+			batch, err := reader.ReadBatch(conn.settings.HardDeleteBatchSize)
 			if err != nil {
 				return totalRows, err
 			}
@@ -904,6 +1526,7 @@ func (conn *ClickHouseConnection) HardDeleteForEarliestStartHistory(
 				constants.FivetranStart,
 				fivetranStartIndex,
 				fivetranStartType,
+				conn.cluster,
 			)
 			if err != nil {
 				return totalRows, err
@@ -944,12 +1567,12 @@ func (conn *ClickHouseConnection) UpdateForEarliestStartHistory(
 	ctx context.Context,
 	schemaName string,
 	table *pb.Table,
-	reader *csvfile.CSVFileReader,
+	reader files.BatchFileReader,
 	csvColumns *types.CSVColumns,
 	fivetranStartColumnName string,
 ) (int, error) {
 	return benchmark.RunAndNoticeWithData(func() (int, error) {
-		qualifiedTableName, err := sql.GetQualifiedTableName(schemaName, table.Name)
+		qualifiedTableName, err := sql.GetQualifiedTableName(schemaName, conn.storageTableName(table.Name))
 		if err != nil {
 			return 0, err
 		}
@@ -970,7 +1593,7 @@ func (conn *ClickHouseConnection) UpdateForEarliestStartHistory(
 		for {
 			// Use MutationBatchSize for ALTER TABLE UPDATE mutations to avoid generating
 			// extremely large SQL statements that can cause ClickHouse OOM during AST parsing
-			batch, err := reader.ReadBatch(*flags.MutationBatchSize)
+			batch, err := reader.ReadBatch(conn.settings.MutationBatchSize)
 			if err != nil {
 				return totalRows, err
 			}
@@ -985,6 +1608,7 @@ func (conn *ClickHouseConnection) UpdateForEarliestStartHistory(
 				qualifiedTableName,
 				fivetranStartColumnIndex,
 				fivetranStartColumnType,
+				conn.cluster,
 			)
 			if err != nil {
 				return totalRows, err
@@ -1031,11 +1655,11 @@ func (conn *ClickHouseConnection) WaitAllNodesAvailable(
 	tableName string,
 ) error {
 	// disable this check with the local ClickHouse in a Docker; the result will be always empty there
-	if conn.isLocal {
+	if conn.isLocal && conn.cluster == nil {
 		return nil
 	}
 
-	query, err := sql.GetAllReplicasActiveQuery(schemaName, tableName)
+	query, err := sql.GetAllReplicasActiveQuery(schemaName, conn.storageTableName(tableName), conn.cluster)
 	if err != nil {
 		return err
 	}
@@ -1069,7 +1693,7 @@ func (conn *ClickHouseConnection) WaitAllMutationsCompleted(
 	tableName string,
 ) error {
 	// disable this check with the local ClickHouse in a Docker; the result will be always empty there
-	if conn.isLocal || !isIncompleteMutationErr(mutationError) {
+	if (conn.isLocal && conn.cluster == nil) || !isIncompleteMutationErr(mutationError) {
 		return mutationError
 	}
 	// even though we set alter/mutations_sync=3, we check for all nodes availability and log warning if not all nodes are available
@@ -1078,7 +1702,7 @@ func (conn *ClickHouseConnection) WaitAllMutationsCompleted(
 		log.Warn(fmt.Sprintf("It seems like not all nodes are available: %v. We strongly recommend to check the cluster health and availability to avoid inconsistency between replicas", err))
 	}
 
-	query, err := sql.GetAllMutationsCompletedQuery(schemaName, tableName)
+	query, err := sql.GetAllMutationsCompletedQuery(schemaName, conn.storageTableName(tableName), conn.cluster)
 	if err != nil {
 		return fmt.Errorf("error while generating the mutations status query: %w; initial cause: %w", err, mutationError)
 	}
@@ -1157,7 +1781,8 @@ func (conn *ClickHouseConnection) ConnectionTest(ctx context.Context) error {
 	return nil
 }
 
-func (conn *ClickHouseConnection) GrantsTest(ctx context.Context) error {
+// GrantsTest verifies that the connection user has the privileges on *.* required by the given scope.
+func (conn *ClickHouseConnection) GrantsTest(ctx context.Context, scope GrantsScope) error {
 	// assuming that the default user should always have all grants
 	if conn.username == "default" {
 		return nil
@@ -1166,20 +1791,21 @@ func (conn *ClickHouseConnection) GrantsTest(ctx context.Context) error {
 	if err != nil {
 		return err
 	}
-	verifiedGrants := map[grantType]bool{
-		createDatabaseGrant: false,
-		createTableGrant:    false,
-		insertGrant:         false,
-		selectGrant:         false,
-		alterGrant:          false,
+	verifiedGrants := make(map[grantType]bool)
+	for _, grant := range requiredGrants[scope] {
+		verifiedGrants[grant] = false
 	}
 	if len(grants) == 0 {
 		return fmt.Errorf("user is missing the required grants on *.*: %s", joinMissingGrants(verifiedGrants))
 	}
 	for _, grant := range grants {
-		_, ok := verifiedGrants[grant.AccessType]
-		if ok && grant.Database == nil && grant.Table == nil && grant.Column == nil {
-			verifiedGrants[grant.AccessType] = true
+		if grant.Database != nil || grant.Table != nil || grant.Column != nil {
+			continue
+		}
+		for required := range verifiedGrants {
+			if grant.AccessType == required || slices.Contains(parentGrants[required], grant.AccessType) {
+				verifiedGrants[required] = true
+			}
 		}
 	}
 	joinedMissingGrants := joinMissingGrants(verifiedGrants)
@@ -1209,32 +1835,20 @@ func hasDecimalPrefix(colType string) bool {
 
 // A sample exception: code: 341, message: Mutation is not finished because some replicas are inactive right now
 func isIncompleteMutationErr(err error) bool {
-	var exception *clickhouse.Exception
-	ok := errors.As(err, &exception)
-	if !ok || exception.Code != 341 {
-		return false
-	}
-	return true
+	code, ok := retry.ExceptionCode(err)
+	return ok && code == 341
 }
 
 func isDatabaseBeingCreatedErr(err error) bool {
-	var exception *clickhouse.Exception
-	ok := errors.As(err, &exception)
-	if !ok || exception.Code != 81 {
-		return false
-	}
-	return true
+	code, ok := retry.ExceptionCode(err)
+	return ok && code == 81
 }
 
 // isTableAlreadyExistsErr reports whether err is (or wraps) a ClickHouse
 // server exception with code 57 (TABLE_ALREADY_EXISTS).
 func isTableAlreadyExistsErr(err error) bool {
-	var exception *clickhouse.Exception
-	ok := errors.As(err, &exception)
-	if !ok || exception.Code != 57 {
-		return false
-	}
-	return true
+	code, ok := retry.ExceptionCode(err)
+	return ok && code == 57
 }
 
 type connectionOpType string
@@ -1248,12 +1862,18 @@ const (
 	alterTable                 connectionOpType = "AlterTable"
 	alterTablePKCreateTable    connectionOpType = "AlterTable(PK, Create table)"
 	alterTablePKInsert         connectionOpType = "AlterTable(PK, Insert from select)"
+	alterTablePKAddUserColumns connectionOpType = "AlterTable(PK, Add user columns)"
+	checkNativeJSON            connectionOpType = "AlterTable(Native JSON, Check values)"
+	replaceNativeJSONNulls     connectionOpType = "AlterTable(Native JSON, Replace NULLs)"
+	normalizeNativeTime        connectionOpType = "AlterTable(Native time, Normalize values)"
+	checkNativeTime            connectionOpType = "AlterTable(Native time, Check values)"
 	renameTable                connectionOpType = "RenameTable"
 	softTruncateTable          connectionOpType = "SoftTruncateTable"
 	hardTruncateTable          connectionOpType = "HardTruncateTable"
 	dropTable                  connectionOpType = "DropTable"
 	insertBatchReplace         connectionOpType = "InsertBatch(Replace)"
 	insertBatchReplaceTask     connectionOpType = "InsertBatch(Replace task)"
+	insertBatchReplaceCSV      connectionOpType = "InsertBatch(Replace CSV)"
 	insertBatchUpdate          connectionOpType = "InsertBatch(Update)"
 	insertBatchUpdateTask      connectionOpType = "InsertBatch(Update task)"
 	insertBatchHardDelete      connectionOpType = "InsertBatch(Hard delete)"
@@ -1276,4 +1896,37 @@ const (
 	insertGrant         grantType = "INSERT"
 	selectGrant         grantType = "SELECT"
 	alterGrant          grantType = "ALTER"
+	alterTableGrant     grantType = "ALTER TABLE"
+	alterUpdateGrant    grantType = "ALTER UPDATE"
+	alterDeleteGrant    grantType = "ALTER DELETE"
+	dropGrant           grantType = "DROP"
+	dropTableGrant      grantType = "DROP TABLE"
 )
+
+// parentGrants lists the privileges that include a required privilege, as system.grants
+// only contains the privilege that was actually granted (e.g. ALTER, not ALTER UPDATE).
+var parentGrants = map[grantType][]grantType{
+	alterUpdateGrant: {alterTableGrant, alterGrant},
+	alterDeleteGrant: {alterTableGrant, alterGrant},
+	dropTableGrant:   {dropGrant},
+}
+
+// GrantsScope selects the privileges verified by GrantsTest.
+type GrantsScope int
+
+const (
+	// AllGrants are required if the same user runs both DDL and DML statements.
+	AllGrants GrantsScope = iota
+	// DDLGrants are required for a separate DDL user (see config.Config.ForDDL);
+	// migrations copy the data between tables, so INSERT and SELECT are required as well.
+	DDLGrants
+	// DMLGrants are required for the main user if a separate DDL user is configured:
+	// inserts, selects, soft deletes and history updates (ALTER UPDATE), and hard deletes (ALTER DELETE).
+	DMLGrants
+)
+
+var requiredGrants = map[GrantsScope][]grantType{
+	AllGrants: {createDatabaseGrant, createTableGrant, insertGrant, selectGrant, alterGrant},
+	DDLGrants: {createDatabaseGrant, createTableGrant, insertGrant, selectGrant, alterGrant, dropTableGrant},
+	DMLGrants: {insertGrant, selectGrant, alterUpdateGrant, alterDeleteGrant},
+}
@@ -3,12 +3,18 @@ package db
 import (
 	"context"
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
-	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+	csvfile "fivetran.com/fivetran_sdk/destination/common/csv"
+	"fivetran.com/fivetran_sdk/destination/common/retry"
 	"fivetran.com/fivetran_sdk/destination/common/types"
 	"fivetran.com/fivetran_sdk/destination/db/config"
+	"fivetran.com/fivetran_sdk/destination/db/values"
 	pb "fivetran.com/fivetran_sdk/proto"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
@@ -16,50 +22,49 @@ import (
 )
 
 func TestGetConnectionFailureAfterMaxRetries(t *testing.T) {
-	currentMaxRetries := *flags.MaxRetries
-	currentMaxDelayMs := *flags.InitialRetryDelayMilliseconds
-	*flags.MaxRetries = 3
-	*flags.InitialRetryDelayMilliseconds = 10
-	defer func() {
-		*flags.MaxRetries = currentMaxRetries
-		*flags.InitialRetryDelayMilliseconds = currentMaxDelayMs
-	}()
+	settings := config.DefaultSettings()
+	settings.Retry = retry.Settings{MaxRetries: 3, InitialRetryDelayMilliseconds: 10}
 
 	ctx := context.Background()
-	connConfig, err := config.Parse(map[string]string{
+	connConfig, err := config.Parse(withTestProtocol(map[string]string{
 		"host":     "localhost",
 		"port":     "9999",
 		"username": "default",
 		"local":    "true",
-	})
+	}))
 	require.NoError(t, err)
-	conn, err := GetClickHouseConnection(ctx, connConfig)
-	assert.ErrorContains(t, err, "ClickHouse connection error: ping failed after 3 attempts: dial tcp [::1]:9999: connect: connection refused")
+	conn, err := GetClickHouseConnection(ctx, connConfig, settings)
+	assert.ErrorContains(t, err, "ClickHouse connection error: ping failed after 3 attempts: ")
+	assert.ErrorContains(t, err, "dial tcp [::1]:9999: connect: connection refused")
 	assert.Nil(t, conn)
 }
 
 func TestGetConnectionInvalidUsername(t *testing.T) {
 	ctx := context.Background()
-	connConfig, err := config.Parse(map[string]string{
+	connConfig, err := config.Parse(withTestProtocol(map[string]string{
 		"host":     "localhost",
 		"port":     "9000",
 		"username": "invalid-user",
 		"local":    "true",
-	})
+	}))
 	require.NoError(t, err)
-	conn, err := GetClickHouseConnection(ctx, connConfig)
-	assert.ErrorContains(t, err, "ClickHouse connection error: code: 516, message: invalid-user: Authentication failed")
+	conn, err := GetClickHouseConnection(ctx, connConfig, config.DefaultSettings())
+	assert.ErrorContains(t, err, "ClickHouse connection error: ")
+	assert.ErrorContains(t, err, "invalid-user: Authentication failed")
+	code, isException := retry.ExceptionCode(err)
+	assert.True(t, isException)
+	assert.Equal(t, int32(516), code)
 	assert.Nil(t, conn)
 
-	connConfig, err = config.Parse(map[string]string{
+	connConfig, err = config.Parse(withTestProtocol(map[string]string{
 		"host":     "localhost",
 		"port":     "9000",
 		"username": "default",
 		"password": "invalid-password",
 		"local":    "true",
-	})
+	}))
 	require.NoError(t, err)
-	conn, err = GetClickHouseConnection(ctx, connConfig)
+	conn, err = GetClickHouseConnection(ctx, connConfig, config.DefaultSettings())
 	assert.ErrorContains(t, err, "ClickHouse connection error")
 	assert.Nil(t, conn)
 }
@@ -125,32 +130,94 @@ func TestGrants(t *testing.T) {
 	missingPart := "user is missing the required grants on *.*: "
 
 	// users start with zero privileges and the first check immediately fails
-	err = conn.GrantsTest(ctx)
+	err = conn.GrantsTest(ctx, AllGrants)
 	assert.ErrorContains(t, err, "it's necessary to have the grant SELECT")
 
 	// gradually add more privileges
 	addGrant("SELECT ON system.grants")
-	err = conn.GrantsTest(ctx)
+	err = conn.GrantsTest(ctx, AllGrants)
 	assert.ErrorContains(t, err, missingPart+"ALTER, CREATE DATABASE, CREATE TABLE, INSERT, SELECT")
 
 	addGrant("ALTER ON *.*")
-	err = conn.GrantsTest(ctx)
+	err = conn.GrantsTest(ctx, AllGrants)
 	assert.ErrorContains(t, err, missingPart+"CREATE DATABASE, CREATE TABLE, INSERT, SELECT")
 
 	addGrant("CREATE DATABASE ON *.*")
-	err = conn.GrantsTest(ctx)
+	err = conn.GrantsTest(ctx, AllGrants)
 	assert.ErrorContains(t, err, missingPart+"CREATE TABLE, INSERT, SELECT")
 
 	addGrant("CREATE TABLE ON *.*")
-	err = conn.GrantsTest(ctx)
+	err = conn.GrantsTest(ctx, AllGrants)
 	assert.ErrorContains(t, err, missingPart+"INSERT, SELECT")
 
 	addGrant("INSERT ON *.*")
-	err = conn.GrantsTest(ctx)
+	err = conn.GrantsTest(ctx, AllGrants)
 	assert.ErrorContains(t, err, missingPart+"SELECT")
 
 	addGrant("SELECT ON *.*")
-	err = conn.GrantsTest(ctx)
+	err = conn.GrantsTest(ctx, AllGrants)
+	require.NoError(t, err)
+}
+
+func TestGrantsDMLAndDDL(t *testing.T) {
+	guid := func() string {
+		return strings.ReplaceAll(uuid.New().String(), "-", "")
+	}
+
+	var err error
+	ctx := context.Background()
+	defaultConn := getTestConnection(t, ctx, map[string]string{
+		"host":     "localhost",
+		"port":     "9000",
+		"username": "default",
+		"local":    "true",
+	})
+	defer defaultConn.Close() //nolint:errcheck
+
+	username := fmt.Sprintf("test_grants_dml_user_%s", guid())
+	password := fmt.Sprintf("secret_%s", guid())
+	defer func() {
+		err = defaultConn.ExecStatement(ctx, fmt.Sprintf("DROP USER IF EXISTS %s", username), "[TestGrantsDMLAndDDL] DropUser", false)
+		assert.NoError(t, err)
+	}()
+	err = defaultConn.ExecStatement(ctx, fmt.Sprintf("CREATE USER %s IDENTIFIED BY '%s'", username, password), "[TestGrantsDMLAndDDL] CreateUser", false)
+	require.NoError(t, err)
+
+	conn := getTestConnection(t, ctx, map[string]string{
+		"host":     "localhost",
+		"port":     "9000",
+		"username": username,
+		"password": password,
+		"local":    "true",
+	})
+	defer conn.Close() //nolint:errcheck
+
+	addGrant := func(grant string) {
+		err = defaultConn.ExecStatement(ctx, fmt.Sprintf("GRANT %s TO %s", grant, username), "", false)
+		require.NoError(t, err)
+	}
+
+	missingPart := "user is missing the required grants on *.*: "
+
+	addGrant("SELECT ON system.grants")
+	err = conn.GrantsTest(ctx, DMLGrants)
+	assert.ErrorContains(t, err, missingPart+"ALTER DELETE, ALTER UPDATE, INSERT, SELECT")
+
+	addGrant("INSERT, SELECT, ALTER UPDATE ON *.*")
+	err = conn.GrantsTest(ctx, DMLGrants)
+	assert.ErrorContains(t, err, missingPart+"ALTER DELETE")
+
+	addGrant("ALTER DELETE ON *.*")
+	err = conn.GrantsTest(ctx, DMLGrants)
+	require.NoError(t, err)
+
+	// the DML privileges are not enough for DDL
+	err = conn.GrantsTest(ctx, DDLGrants)
+	assert.ErrorContains(t, err, missingPart+"ALTER, CREATE DATABASE, CREATE TABLE, DROP TABLE")
+
+	// the parent privileges include ALTER UPDATE, ALTER DELETE and DROP TABLE
+	addGrant("ALTER, CREATE DATABASE, CREATE TABLE, DROP ON *.*")
+	err = conn.GrantsTest(ctx, DDLGrants)
 	require.NoError(t, err)
 }
 
@@ -258,7 +325,8 @@ func TestRenameTableIdempotentOnRetry(t *testing.T) {
 
 		err := conn.RenameTable(ctx, dbName, missingSource, missingDest)
 		require.Error(t, err)
-		assert.Contains(t, err.Error(), "code: 60")
+		code, _ := retry.ExceptionCode(err)
+		assert.Equal(t, int32(60), code)
 	})
 
 	t.Run("source_and_destination_both_exist_returns_error", func(t *testing.T) {
@@ -274,7 +342,8 @@ func TestRenameTableIdempotentOnRetry(t *testing.T) {
 
 		err := conn.RenameTable(ctx, dbName, sourceTable, destTable)
 		require.Error(t, err)
-		assert.Contains(t, err.Error(), "code: 57")
+		code, _ := retry.ExceptionCode(err)
+		assert.Equal(t, int32(57), code)
 	})
 }
 
@@ -385,3 +454,134 @@ func TestDescribeTable(t *testing.T) {
 		PrimaryKeys: []string{"b", "i16", "i32", "i64", "f32", "f64", "dd", "d", "dt", "dt64", "s", "xml", "json", "bin"},
 	})
 }
+
+func TestReplaceBatchInsertDeduplication(t *testing.T) {
+	ctx := context.Background()
+	conn := getTestConnection(t, ctx, map[string]string{
+		"host":     "localhost",
+		"port":     "9000",
+		"username": "default",
+		"local":    "true",
+	})
+	defer conn.Close() //nolint:errcheck
+
+	dbName := "fivetran_test"
+	err := conn.Exec(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", dbName))
+	require.NoError(t, err)
+
+	for _, serverSideCSVParsing := range []bool{false, true} {
+		conn.settings = config.DefaultSettings()
+		conn.settings.WriteBatchSize = 2
+		conn.settings.ServerSideCSVParsing = serverSideCSVParsing
+		conn.settings.InsertDeduplication = true
+		tableName := fmt.Sprintf("test_insert_deduplication_%s", strings.ReplaceAll(uuid.New().String(), "-", "_"))
+		// the table is created with non_replicated_deduplication_window, see withDeduplicationWindow
+		err = conn.CreateTable(ctx, dbName, tableName, types.MakeTableDescription([]*types.ColumnDefinition{
+			{Name: "id", Type: "Int32", IsPrimaryKey: true},
+			{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+		}))
+		require.NoError(t, err)
+
+		csvColumns := syncedCSVColumns()
+		csvColumns.All[1].TableIndex = 1
+		// the second time, every batch of the file is sent again, as if the first attempt failed after reaching ClickHouse
+		for range 2 {
+			reader := openSyncedReader(t)
+			totalRows, err := conn.ReplaceBatch(ctx, dbName, &pb.Table{Name: tableName}, reader, csvColumns, "my-null-str")
+			reader.Close()
+			require.NoError(t, err, "server-side CSV parsing: %t", serverSideCSVParsing)
+			assert.Equal(t, 5, totalRows)
+		}
+
+		// without FINAL, so that the rows are not deduplicated by ReplacingMergeTree
+		rows, err := conn.Query(ctx, fmt.Sprintf("SELECT count() FROM %s.%s", dbName, tableName))
+		require.NoError(t, err)
+		var count uint64
+		require.True(t, rows.Next())
+		require.NoError(t, rows.Scan(&count))
+		require.NoError(t, rows.Close())
+		assert.Equal(t, uint64(5), count, "server-side CSV parsing: %t", serverSideCSVParsing)
+
+		err = conn.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", dbName, tableName))
+		assert.NoError(t, err)
+	}
+}
+
+func TestReplaceBatchOutOfRangeDates(t *testing.T) {
+	ctx := context.Background()
+	conn := getTestConnection(t, ctx, map[string]string{
+		"host":     "localhost",
+		"port":     "9000",
+		"username": "default",
+		"local":    "true",
+	})
+	defer conn.Close() //nolint:errcheck
+
+	dbName := "fivetran_test"
+	err := conn.Exec(ctx, fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", dbName))
+	require.NoError(t, err)
+
+	fileName := filepath.Join(t.TempDir(), "out_of_range_dates.csv")
+	err = os.WriteFile(fileName, []byte(`"id","day","opened_at","created_at","updated_at"
+"1","1850-07-20","1850-07-20T12:00:00","1850-07-20T12:00:00Z","1850-07-20T12:00:00Z"
+"2","2350-07-20","2290-07-20T12:00:00","9999-12-31T23:59:59.999Z","2290-07-20T12:00:00.123Z"
+`), 0o600)
+	require.NoError(t, err)
+
+	precision := uint(3)
+	idCol := &types.CSVColumn{Index: 0, TableIndex: 0, Name: "id", Type: pb.DataType_INT, IsPrimaryKey: true}
+	csvColumns := &types.CSVColumns{
+		All: []*types.CSVColumn{
+			idCol,
+			{Index: 1, TableIndex: 1, Name: "day", Type: pb.DataType_NAIVE_DATE},
+			{Index: 2, TableIndex: 2, Name: "opened_at", Type: pb.DataType_NAIVE_DATETIME},
+			{Index: 3, TableIndex: 3, Name: "created_at", Type: pb.DataType_UTC_DATETIME},
+			{Index: 4, TableIndex: 4, Name: "updated_at", Type: pb.DataType_UTC_DATETIME, UTCPrecision: &precision},
+		},
+		PrimaryKeys: []*types.CSVColumn{idCol},
+	}
+
+	// the values out of the supported range are clamped the same way with and without server-side CSV parsing
+	minDate := time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	expected := [][]any{
+		{int32(1), minDate, minDate, minDate, minDate},
+		{int32(2), time.Date(2299, time.December, 31, 0, 0, 0, 0, time.UTC),
+			values.MaxDateTime64, values.MaxDateTime64, values.MaxDateTime64},
+	}
+	for _, serverSideCSVParsing := range []bool{false, true} {
+		conn.settings = config.DefaultSettings()
+		conn.settings.ServerSideCSVParsing = serverSideCSVParsing
+		tableName := fmt.Sprintf("test_out_of_range_dates_%s", strings.ReplaceAll(uuid.New().String(), "-", "_"))
+		err = conn.CreateTable(ctx, dbName, tableName, types.MakeTableDescription([]*types.ColumnDefinition{
+			{Name: "id", Type: "Int32", IsPrimaryKey: true},
+			{Name: "day", Type: constants.Date},
+			{Name: "opened_at", Type: constants.DateTime},
+			{Name: "created_at", Type: constants.DateTimeUTC},
+			{Name: "updated_at", Type: "DateTime64(3, 'UTC')"},
+		}))
+		require.NoError(t, err)
+
+		reader, err := csvfile.NewCSVFileReader(fileName, map[string][]byte{fileName: nil}, pb.Compression_OFF, pb.Encryption_NONE)
+		require.NoError(t, err)
+		totalRows, err := conn.ReplaceBatch(ctx, dbName, &pb.Table{Name: tableName}, reader, csvColumns, "my-null-str")
+		reader.Close()
+		require.NoError(t, err, "server-side CSV parsing: %t", serverSideCSVParsing)
+		assert.Equal(t, 2, totalRows)
+
+		rows, err := conn.Query(ctx, fmt.Sprintf(
+			"SELECT id, day, opened_at, created_at, updated_at FROM %s.%s ORDER BY id", dbName, tableName))
+		require.NoError(t, err)
+		var stored [][]any
+		for rows.Next() {
+			var id int32
+			var day, openedAt, createdAt, updatedAt time.Time
+			require.NoError(t, rows.Scan(&id, &day, &openedAt, &createdAt, &updatedAt))
+			stored = append(stored, []any{id, day.UTC(), openedAt.UTC(), createdAt.UTC(), updatedAt.UTC()})
+		}
+		require.NoError(t, rows.Close())
+		assert.Equal(t, expected, stored, "server-side CSV parsing: %t", serverSideCSVParsing)
+
+		err = conn.Exec(ctx, fmt.Sprintf("DROP TABLE IF EXISTS %s.%s", dbName, tableName))
+		assert.NoError(t, err)
+	}
+}
@@ -25,6 +25,7 @@ const (
 	migrateHistoryUpdate     connectionOpType = "Migrate(History, Update)"
 	migrateHistoryClose      connectionOpType = "Migrate(History, Close)"
 	migrateSyncModeInsert    connectionOpType = "Migrate(SyncMode, Insert)"
+	migrateNativeType        connectionOpType = "Migrate(NativeType)"
 )
 
 // execInsertNewActiveVersions runs the "insert new active history rows" INSERT used by the
@@ -75,7 +76,7 @@ func (conn *ClickHouseConnection) closeActiveRows(
 	operationTimestampNanos string,
 	column string,
 ) error {
-	stmt, err := sql.GetCloseActiveRowsStatement(schemaName, tableName, operationTimestampNanos, column)
+	stmt, err := sql.GetCloseActiveRowsStatement(schemaName, conn.storageTableName(tableName), operationTimestampNanos, column, conn.cluster)
 	if err != nil {
 		return err
 	}
@@ -89,11 +90,16 @@ func (conn *ClickHouseConnection) RenameColumn(
 	fromColumn string,
 	toColumn string,
 ) error {
-	statement, err := sql.GetRenameColumnStatement(schemaName, tableName, fromColumn, toColumn)
-	if err != nil {
-		return err
+	for _, name := range conn.alterTableNames(tableName) {
+		statement, err := sql.GetRenameColumnStatement(schemaName, name, fromColumn, toColumn, conn.cluster)
+		if err != nil {
+			return err
+		}
+		if err = conn.ExecStatement(ctx, statement, migrateRenameColumn, false); err != nil {
+			return err
+		}
 	}
-	return conn.ExecStatement(ctx, statement, migrateRenameColumn, false)
+	return nil
 }
 
 // UpdateColumnValue updates all rows in a column to the given value (which may be SQL NULL).
@@ -104,7 +110,7 @@ func (conn *ClickHouseConnection) UpdateColumnValue(
 	column string,
 	value values.MigrateValue,
 ) error {
-	statement, err := sql.GetUpdateColumnValueStatement(schemaName, tableName, column, value)
+	statement, err := sql.GetUpdateColumnValueStatement(schemaName, conn.storageTableName(tableName), column, value, conn.cluster)
 	if err != nil {
 		return err
 	}
@@ -118,7 +124,7 @@ func (conn *ClickHouseConnection) CopyColumnData(
 	fromColumn string,
 	toColumn string,
 ) error {
-	statement, err := sql.GetCopyColumnUpdateStatement(schemaName, tableName, toColumn, fromColumn)
+	statement, err := sql.GetCopyColumnUpdateStatement(schemaName, conn.storageTableName(tableName), toColumn, fromColumn, conn.cluster)
 	if err != nil {
 		return err
 	}
@@ -143,20 +149,22 @@ func (conn *ClickHouseConnection) MigrateCopyTable(
 	for i, col := range tableDesc.Columns {
 		colNames[i] = col.Name
 	}
-	toTableQualified, err := sql.GetQualifiedTableName(schemaName, toTable)
-	if err != nil {
-		return err
-	}
-	if err = conn.DropTable(ctx, toTableQualified); err != nil {
+	if err = conn.DropTable(ctx, schemaName, toTable); err != nil {
 		return err
 	}
-	createStmt, err := sql.GetCreateTableAsStatement(schemaName, fromTable, toTable)
+	createStmt, err := sql.GetCreateTableAsStatement(
+		schemaName, conn.storageTableName(fromTable), conn.storageTableName(toTable), conn.cluster)
 	if err != nil {
 		return err
 	}
 	if err = conn.ExecStatement(ctx, createStmt, migrateCopyTableCreate, false); err != nil {
 		return err
 	}
+	if conn.cluster.IsDistributed() {
+		if err = conn.createDistributedTable(ctx, schemaName, toTable, tableDesc, migrateCopyTableCreate); err != nil {
+			return err
+		}
+	}
 	return conn.execInsertFromSelect(ctx, schemaName, fromTable, toTable, colNames, migrateCopyTableInsert)
 }
 
@@ -216,7 +224,11 @@ func (conn *ClickHouseConnection) MigrateAddColumnWithDefault(
 		return err
 	}
 	// Step 2: Set default value
-	return conn.UpdateColumnValue(ctx, schemaName, tableName, column, defaultValue)
+	if err := conn.UpdateColumnValue(ctx, schemaName, tableName, column, defaultValue); err != nil {
+		return err
+	}
+	// Step 3: Modify the column to its native type, if it is enabled
+	return conn.migrateToNativeType(ctx, schemaName, tableName, column, chType, comment)
 }
 
 func (conn *ClickHouseConnection) MigrateUpdateRowsAtOperationTimestamp(
@@ -228,7 +240,7 @@ func (conn *ClickHouseConnection) MigrateUpdateRowsAtOperationTimestamp(
 	operationTimestampNanos string,
 ) error {
 	statement, err := sql.GetUpdateRowsAtOperationTimestampStatement(
-		schemaName, tableName, column, value, operationTimestampNanos)
+		schemaName, conn.storageTableName(tableName), column, value, operationTimestampNanos, conn.cluster)
 	if err != nil {
 		return err
 	}
@@ -309,7 +321,7 @@ func (conn *ClickHouseConnection) validateHistoryModeTable(
 
 // MigrateAddColumnInHistoryMode adds a column to a history-mode table and, when the table has
 // existing active rows, closes them off and opens new versions carrying the default value so
-// the column addition is reflected in the history.
+// the column addition is reflected in the history. Finally, the column is modified to its native type, if it is enabled.
 func (conn *ClickHouseConnection) MigrateAddColumnInHistoryMode(
 	ctx context.Context,
 	schemaName string,
@@ -319,6 +331,24 @@ func (conn *ClickHouseConnection) MigrateAddColumnInHistoryMode(
 	comment string,
 	defaultValue values.MigrateValue,
 	operationTimestampNanos string,
+) error {
+	err := conn.addColumnInHistoryMode(
+		ctx, schemaName, tableName, column, chType, comment, defaultValue, operationTimestampNanos)
+	if err != nil {
+		return err
+	}
+	return conn.migrateToNativeType(ctx, schemaName, tableName, column, chType, comment)
+}
+
+func (conn *ClickHouseConnection) addColumnInHistoryMode(
+	ctx context.Context,
+	schemaName string,
+	tableName string,
+	column string,
+	chType string,
+	comment string,
+	defaultValue values.MigrateValue,
+	operationTimestampNanos string,
 ) error {
 	// Step 1: Add the column. This must happen regardless of whether the table has data.
 	addOp := &types.AlterTableOp{
@@ -444,11 +474,7 @@ func (conn *ClickHouseConnection) MigrateCopyTableToHistoryMode(
 	)
 	newTableDesc := types.MakeTableDescription(newCols)
 	// Step 3: Pre-drop any leftover and create the target table.
-	toTableQualified, err := sql.GetQualifiedTableName(schemaName, toTable)
-	if err != nil {
-		return err
-	}
-	if err = conn.DropTable(ctx, toTableQualified); err != nil {
+	if err = conn.DropTable(ctx, schemaName, toTable); err != nil {
 		return err
 	}
 	err = conn.CreateTable(ctx, schemaName, toTable, newTableDesc)
@@ -7,8 +7,8 @@ import (
 
 	"fivetran.com/fivetran_sdk/destination/common/constants"
 	csvfile "fivetran.com/fivetran_sdk/destination/common/csv"
-	"fivetran.com/fivetran_sdk/destination/common/flags"
 	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
 	pb "fivetran.com/fivetran_sdk/proto"
 	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
 	"github.com/stretchr/testify/assert"
@@ -54,12 +54,8 @@ func openHistoryModeReader(t *testing.T) *csvfile.CSVFileReader {
 }
 
 func TestUpdateForEarliestStartHistoryBatchesExec(t *testing.T) {
-	original := *flags.MutationBatchSize
-	defer func() { *flags.MutationBatchSize = original }()
-	*flags.MutationBatchSize = 2
-
 	mock := &mockConn{}
-	conn := &ClickHouseConnection{Conn: mock, isLocal: true}
+	conn := &ClickHouseConnection{Conn: mock, isLocal: true, settings: &config.Settings{MutationBatchSize: 2}}
 
 	reader := openHistoryModeReader(t)
 	defer reader.Close()
@@ -75,12 +71,8 @@ func TestUpdateForEarliestStartHistoryBatchesExec(t *testing.T) {
 }
 
 func TestUpdateForEarliestStartHistorySingleBatch(t *testing.T) {
-	original := *flags.MutationBatchSize
-	defer func() { *flags.MutationBatchSize = original }()
-	*flags.MutationBatchSize = 1500
-
 	mock := &mockConn{}
-	conn := &ClickHouseConnection{Conn: mock, isLocal: true}
+	conn := &ClickHouseConnection{Conn: mock, isLocal: true, settings: &config.Settings{MutationBatchSize: 1500}}
 
 	reader := openHistoryModeReader(t)
 	defer reader.Close()
@@ -0,0 +1,47 @@
+package db
+
+import (
+	"fmt"
+
+	dt "fivetran.com/fivetran_sdk/destination/common/data_types"
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+)
+
+// applyColumnOptions returns a copy of the table description with the configured column options:
+// LowCardinality types, codecs and skip indexes. With indexesOnly, only the skip indexes are set;
+// this is used for the current table definition, so the skip indexes of the dropped columns are dropped as well.
+// The description itself is never modified, as it can be shared via the metadata cache.
+func applyColumnOptions(
+	schemaName string,
+	tableName string,
+	description *types.TableDescription,
+	columnOptions config.ColumnOptions,
+	indexesOnly bool,
+) (*types.TableDescription, error) {
+	if len(columnOptions) == 0 || description == nil || len(description.Columns) == 0 {
+		return description, nil
+	}
+	columns := make([]*types.ColumnDefinition, len(description.Columns))
+	for i, col := range description.Columns {
+		options := columnOptions.ForColumn(schemaName, tableName, col.Name)
+		if options == nil {
+			columns[i] = col
+			continue
+		}
+		colCopy := *col
+		colCopy.SkipIndexes = options.SkipIndexes
+		if !indexesOnly {
+			colCopy.Codec = options.Codec
+			if options.LowCardinality {
+				lowCardinalityType, err := dt.ToLowCardinalityType(col.Type)
+				if err != nil {
+					return nil, fmt.Errorf("column %s of table %s.%s: %w", col.Name, schemaName, tableName, err)
+				}
+				colCopy.Type = lowCardinalityType
+			}
+		}
+		columns[i] = &colCopy
+	}
+	return types.MakeTableDescription(columns), nil
+}
@@ -0,0 +1,59 @@
+package db
+
+import (
+	"testing"
+
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyColumnOptions(t *testing.T) {
+	index := &types.SkipIndex{Name: "email_bloom_filter_idx", Type: "bloom_filter", Granularity: 1}
+	columnOptions := config.ColumnOptions{
+		"foo.bar.id":      {Codec: "Delta, ZSTD"},
+		"foo.bar.email":   {LowCardinality: true, SkipIndexes: []*types.SkipIndex{index}},
+		"foo.bar.country": {LowCardinality: true},
+		"foo.qaz.id":      {Codec: "LZ4HC(9)"},
+	}
+	description := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Int64", IsPrimaryKey: true},
+		{Name: "email", Type: "Nullable(String)", Comment: "XML"},
+		{Name: "country", Type: "LowCardinality(Nullable(String))"},
+		{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+	})
+
+	result, err := applyColumnOptions("foo", "bar", description, columnOptions, false)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Int64", IsPrimaryKey: true, Codec: "Delta, ZSTD"},
+		{Name: "email", Type: "LowCardinality(Nullable(String))", Comment: "XML", SkipIndexes: []*types.SkipIndex{index}},
+		{Name: "country", Type: "LowCardinality(Nullable(String))"},
+		{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+	}), result)
+	// the original description is not modified
+	assert.Equal(t, "Nullable(String)", description.Mapping["email"].Type)
+	assert.Empty(t, description.Mapping["id"].Codec)
+
+	result, err = applyColumnOptions("foo", "bar", description, columnOptions, true)
+	assert.NoError(t, err)
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Int64", IsPrimaryKey: true},
+		{Name: "email", Type: "Nullable(String)", Comment: "XML", SkipIndexes: []*types.SkipIndex{index}},
+		{Name: "country", Type: "LowCardinality(Nullable(String))"},
+		{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+	}), result)
+
+	// no options for the table
+	result, err = applyColumnOptions("foo", "other", description, columnOptions, false)
+	assert.NoError(t, err)
+	assert.Equal(t, description, result)
+	result, err = applyColumnOptions("foo", "bar", description, nil, false)
+	assert.NoError(t, err)
+	assert.Same(t, description, result)
+
+	_, err = applyColumnOptions("foo", "bar", types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "email", Type: "Nullable(Int32)"},
+	}), columnOptions, false)
+	assert.ErrorContains(t, err, "column email of table foo.bar: LowCardinality is only supported for String columns")
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"fivetran.com/fivetran_sdk/destination/common/types"
+)
+
+// Skip index types supported in the column configurations.
+const (
+	SkipIndexBloomFilter = "bloom_filter"
+	SkipIndexMinMax      = "minmax"
+	SkipIndexSet         = "set"
+)
+
+// codecRegex allows a comma-separated list of codecs with numeric arguments, e.g. "Delta(4), ZSTD(3)".
+var codecRegex = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9]*(\(\d+(,\s*\d+)*\))?(,\s*[A-Za-z][A-Za-z0-9]*(\(\d+(,\s*\d+)*\))?)*$`)
+
+// ColumnConfiguration holds the physical hints for a single column, see types.ColumnOptions.
+// The column configurations are keyed by "schema.table.column".
+type ColumnConfiguration struct {
+	Codec          *string                   `json:"codec,omitempty"`
+	LowCardinality *bool                     `json:"low_cardinality,omitempty"`
+	SkipIndexes    []*SkipIndexConfiguration `json:"skip_indexes,omitempty"`
+}
+
+// SkipIndexConfiguration is a data-skipping index on the configured column.
+// Type is one of SkipIndexBloomFilter, SkipIndexMinMax or SkipIndexSet.
+// FalsePositiveRate is an optional bloom_filter argument; MaxRows is an optional set argument (0 = unlimited).
+// Granularity defaults to 1.
+type SkipIndexConfiguration struct {
+	Type              string   `json:"type"`
+	FalsePositiveRate *float64 `json:"false_positive_rate,omitempty"`
+	MaxRows           *uint    `json:"max_rows,omitempty"`
+	Granularity       *uint    `json:"granularity,omitempty"`
+}
+
+// ColumnOptions holds the validated column configurations, keyed by "schema.table.column".
+type ColumnOptions map[string]*types.ColumnOptions
+
+// ForColumn returns the options for the column; nil if there are none. Safe to call on nil.
+func (o ColumnOptions) ForColumn(schemaName string, tableName string, columnName string) *types.ColumnOptions {
+	return o[schemaName+"."+tableName+"."+columnName]
+}
+
+func parseColumnConfigurations(cc map[string]*ColumnConfiguration) (ColumnOptions, error) {
+	if len(cc) == 0 {
+		return nil, nil
+	}
+	result := make(ColumnOptions, len(cc))
+	for key, column := range cc {
+		parts := strings.SplitN(key, ".", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("column key %s should be in the schema.table.column format", key)
+		}
+		if column == nil {
+			return nil, fmt.Errorf("column %s: configuration is empty", key)
+		}
+		options, err := toColumnOptions(parts[2], column)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %w", key, err)
+		}
+		result[key] = options
+	}
+	return result, nil
+}
+
+func toColumnOptions(columnName string, cc *ColumnConfiguration) (*types.ColumnOptions, error) {
+	options := &types.ColumnOptions{}
+	if cc.Codec != nil {
+		codec := strings.TrimSpace(*cc.Codec)
+		if !codecRegex.MatchString(codec) {
+			return nil, fmt.Errorf("codec %s is not valid, expected a list of codecs such as Delta, ZSTD(3)", codec)
+		}
+		options.Codec = codec
+	}
+	if cc.LowCardinality != nil {
+		options.LowCardinality = *cc.LowCardinality
+	}
+	seen := make(map[string]bool, len(cc.SkipIndexes))
+	for _, index := range cc.SkipIndexes {
+		if index == nil {
+			return nil, fmt.Errorf("skip index configuration is empty")
+		}
+		skipIndex, err := toSkipIndex(columnName, index)
+		if err != nil {
+			return nil, err
+		}
+		if seen[index.Type] {
+			return nil, fmt.Errorf("skip index of type %s is configured more than once", index.Type)
+		}
+		seen[index.Type] = true
+		options.SkipIndexes = append(options.SkipIndexes, skipIndex)
+	}
+	return options, nil
+}
+
+func toSkipIndex(columnName string, index *SkipIndexConfiguration) (*types.SkipIndex, error) {
+	indexType := index.Type
+	switch index.Type {
+	case SkipIndexBloomFilter:
+		if index.MaxRows != nil {
+			return nil, fmt.Errorf("max_rows is only supported for the %s skip index", SkipIndexSet)
+		}
+		if rate := index.FalsePositiveRate; rate != nil {
+			if *rate <= 0 || *rate >= 1 {
+				return nil, fmt.Errorf("false_positive_rate should be between 0 and 1")
+			}
+			indexType = fmt.Sprintf("%s(%s)", SkipIndexBloomFilter, strconv.FormatFloat(*rate, 'f', -1, 64))
+		}
+	case SkipIndexSet:
+		if index.FalsePositiveRate != nil {
+			return nil, fmt.Errorf("false_positive_rate is only supported for the %s skip index", SkipIndexBloomFilter)
+		}
+		maxRows := uint(0)
+		if index.MaxRows != nil {
+			maxRows = *index.MaxRows
+		}
+		indexType = fmt.Sprintf("%s(%d)", SkipIndexSet, maxRows)
+	case SkipIndexMinMax:
+		if index.FalsePositiveRate != nil || index.MaxRows != nil {
+			return nil, fmt.Errorf("the %s skip index has no arguments", SkipIndexMinMax)
+		}
+	default:
+		return nil, fmt.Errorf("skip index type %s is not supported, expected one of: %s, %s, %s",
+			index.Type, SkipIndexBloomFilter, SkipIndexMinMax, SkipIndexSet)
+	}
+	granularity := uint(1)
+	if index.Granularity != nil {
+		if *index.Granularity == 0 {
+			return nil, fmt.Errorf("skip index granularity should be greater than 0")
+		}
+		granularity = *index.Granularity
+	}
+	return &types.SkipIndex{
+		Name:        fmt.Sprintf("%s_%s_idx", columnName, index.Type),
+		Type:        indexType,
+		Granularity: granularity,
+	}, nil
+}
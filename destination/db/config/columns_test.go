@@ -0,0 +1,127 @@
+package config
+
+import (
+	"testing"
+
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllWithColumnConfigurations(t *testing.T) {
+	input := configWithAdvancedJSON(
+		map[string]string{"host": "my.host"},
+		`{"column_configurations": {
+			"analytics.events.payload": {"codec": " ZSTD(3) "},
+			"analytics.events.country": {"low_cardinality": true, "skip_indexes": [{"type": "set", "max_rows": 100}]},
+			"analytics.events.email": {"skip_indexes": [{"type": "bloom_filter", "false_positive_rate": 0.01}, {"type": "minmax", "granularity": 4}]},
+			"analytics.events.created_at": {"codec": "Delta(8), LZ4", "skip_indexes": [{"type": "bloom_filter"}, {"type": "set"}]}
+		}}`,
+	)
+	cfg, _, err := ParseAll(input)
+	require.NoError(t, err)
+
+	assert.Equal(t, &types.ColumnOptions{Codec: "ZSTD(3)"}, cfg.ColumnOptions.ForColumn("analytics", "events", "payload"))
+	assert.Equal(t, &types.ColumnOptions{
+		LowCardinality: true,
+		SkipIndexes:    []*types.SkipIndex{{Name: "country_set_idx", Type: "set(100)", Granularity: 1}},
+	}, cfg.ColumnOptions.ForColumn("analytics", "events", "country"))
+	assert.Equal(t, &types.ColumnOptions{
+		SkipIndexes: []*types.SkipIndex{
+			{Name: "email_bloom_filter_idx", Type: "bloom_filter(0.01)", Granularity: 1},
+			{Name: "email_minmax_idx", Type: "minmax", Granularity: 4},
+		},
+	}, cfg.ColumnOptions.ForColumn("analytics", "events", "email"))
+	assert.Equal(t, &types.ColumnOptions{
+		Codec: "Delta(8), LZ4",
+		SkipIndexes: []*types.SkipIndex{
+			{Name: "created_at_bloom_filter_idx", Type: "bloom_filter", Granularity: 1},
+			{Name: "created_at_set_idx", Type: "set(0)", Granularity: 1},
+		},
+	}, cfg.ColumnOptions.ForColumn("analytics", "events", "created_at"))
+	assert.Nil(t, cfg.ColumnOptions.ForColumn("analytics", "events", "id"))
+	assert.Nil(t, cfg.ColumnOptions.ForColumn("analytics", "users", "email"))
+
+	// no column configurations at all
+	cfg, _, err = ParseAll(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+	assert.Nil(t, cfg.ColumnOptions)
+	assert.Nil(t, cfg.ColumnOptions.ForColumn("analytics", "events", "email"))
+}
+
+func TestParseAllInvalidColumnConfigurations(t *testing.T) {
+	tests := []struct {
+		name          string
+		json          string
+		expectedError string
+	}{
+		{
+			name:          "column key without table",
+			json:          `{"analytics.email": {"codec": "ZSTD"}}`,
+			expectedError: "column key analytics.email should be in the schema.table.column format",
+		},
+		{
+			name:          "empty column configuration",
+			json:          `{"analytics.events.email": null}`,
+			expectedError: "column analytics.events.email: configuration is empty",
+		},
+		{
+			name:          "empty codec",
+			json:          `{"analytics.events.email": {"codec": ""}}`,
+			expectedError: "codec  is not valid",
+		},
+		{
+			name:          "codec with an expression",
+			json:          `{"analytics.events.email": {"codec": "ZSTD) DEFAULT (1"}}`,
+			expectedError: "codec ZSTD) DEFAULT (1 is not valid",
+		},
+		{
+			name:          "unknown skip index type",
+			json:          `{"analytics.events.email": {"skip_indexes": [{"type": "ngrambf_v1"}]}}`,
+			expectedError: "skip index type ngrambf_v1 is not supported, expected one of: bloom_filter, minmax, set",
+		},
+		{
+			name:          "empty skip index",
+			json:          `{"analytics.events.email": {"skip_indexes": [null]}}`,
+			expectedError: "skip index configuration is empty",
+		},
+		{
+			name:          "duplicate skip index type",
+			json:          `{"analytics.events.email": {"skip_indexes": [{"type": "minmax"}, {"type": "minmax", "granularity": 2}]}}`,
+			expectedError: "skip index of type minmax is configured more than once",
+		},
+		{
+			name:          "bloom filter false positive rate out of range",
+			json:          `{"analytics.events.email": {"skip_indexes": [{"type": "bloom_filter", "false_positive_rate": 1}]}}`,
+			expectedError: "false_positive_rate should be between 0 and 1",
+		},
+		{
+			name:          "bloom filter with max rows",
+			json:          `{"analytics.events.email": {"skip_indexes": [{"type": "bloom_filter", "max_rows": 1}]}}`,
+			expectedError: "max_rows is only supported for the set skip index",
+		},
+		{
+			name:          "set with false positive rate",
+			json:          `{"analytics.events.email": {"skip_indexes": [{"type": "set", "false_positive_rate": 0.1}]}}`,
+			expectedError: "false_positive_rate is only supported for the bloom_filter skip index",
+		},
+		{
+			name:          "minmax with arguments",
+			json:          `{"analytics.events.email": {"skip_indexes": [{"type": "minmax", "max_rows": 1}]}}`,
+			expectedError: "the minmax skip index has no arguments",
+		},
+		{
+			name:          "zero granularity",
+			json:          `{"analytics.events.email": {"skip_indexes": [{"type": "minmax", "granularity": 0}]}}`,
+			expectedError: "skip index granularity should be greater than 0",
+		},
+	}
+	for _, test := range tests {
+		input := configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"column_configurations": `+test.json+`}`)
+		cfg, settings, err := ParseAll(input)
+		assert.Nil(t, cfg, "Test %s", test.name)
+		assert.Nil(t, settings, "Test %s", test.name)
+		assert.ErrorContains(t, err, "invalid column configurations", "Test %s", test.name)
+		assert.ErrorContains(t, err, test.expectedError, "Test %s", test.name)
+	}
+}
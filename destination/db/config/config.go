@@ -5,11 +5,12 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net"
 	"strconv"
 	"strings"
 
-	"fivetran.com/fivetran_sdk/destination/common/flags"
 	"fivetran.com/fivetran_sdk/destination/common/log"
+	"fivetran.com/fivetran_sdk/destination/common/types"
 )
 
 const (
@@ -17,35 +18,143 @@ const (
 	PortKey     = "port"
 	UsernameKey = "username"
 	PasswordKey = "password"
+	ClusterKey  = "cluster"
+	ProtocolKey = "protocol"
+	// DDLUsernameKey and DDLPasswordKey are the optional credentials of a separate user for the DDL operations.
+	DDLUsernameKey = "ddl_username"
+	DDLPasswordKey = "ddl_password"
+	// ConnectionStrategyKey selects the order in which the hosts are tried when opening new connections.
+	ConnectionStrategyKey = "connection_strategy"
+)
+
+// Supported values of the ConnectionStrategyKey field.
+const (
+	ConnectionStrategyInOrder    = "in_order"
+	ConnectionStrategyRoundRobin = "round_robin"
+	ConnectionStrategyRandom     = "random"
+)
+
+// Supported values of the ProtocolKey field.
+const (
+	ProtocolNative = "native"
+	ProtocolHTTP   = "http"
+)
+
+// Default ports for ClickHouse Cloud (SSL/TLS) per protocol.
+const (
+	defaultNativePort = "9440"
+	defaultHTTPPort   = "8443"
 )
 
 type Config struct {
-	Host     string
-	Port     uint
-	Username string
-	Password string
-	Local    bool
+	// Addresses are the ClickHouse endpoints as host:port; there is at least one.
+	// The host field may contain a comma-separated list of hosts, each with an optional port;
+	// the port field is used for the hosts without an explicit port.
+	Addresses          []string
+	ConnectionStrategy string
+	Username           string
+	Password           string
+	Protocol           string // ProtocolNative or ProtocolHTTP
+	Local              bool
+	// DDLUsername and DDLPassword are empty if the same user runs both DDL and DML statements; see ForDDL.
+	DDLUsername string
+	DDLPassword string
+	// Cluster is nil for ClickHouse Cloud; set for self-hosted clusters, see types.Cluster.
+	Cluster *types.Cluster
+	// TLS is nil if none of the TLS fields are set; see TLSConfig.
+	TLS *TLSOptions
+	// TableEngines is nil if there are no table configurations in the advanced config; see TableEngines.ForTable.
+	TableEngines *TableEngines
+	// ColumnOptions is nil if there are no column configurations in the advanced config; see ColumnOptions.ForColumn.
+	ColumnOptions ColumnOptions
+	// JSONTypes is nil unless the native JSON type is enabled in the advanced config; see JSONTypes.ForColumn.
+	JSONTypes *JSONTypes
+	// NaiveTimeType is the ClickHouse type of the NAIVE_TIME columns, e.g. Time64(6);
+	// empty unless enabled in the advanced config, as such columns are String by default.
+	NaiveTimeType string
+	// BinaryTypes is nil unless decoding the BINARY values is enabled in the advanced config; see BinaryTypes.ForColumn.
+	BinaryTypes *BinaryTypes
+	// BinaryEncoding is the encoding of the BINARY values in the batch files, base64 (also if empty) or hex.
+	// It is used for the columns with the decoded values, even if decoding is no longer enabled for the new columns.
+	BinaryEncoding string
+	// DecimalOverflow is the policy for the DECIMAL columns with a precision above 76 or a scale above the precision:
+	// clamp (also if empty), string or decimal256; see constants.DecimalOverflowClamp.
+	DecimalOverflow string
+	// CoercionPolicies are keyed by the lowercase Fivetran type name, e.g. naive_date -> null;
+	// nil if not configured, which means the clamp policy for all types. See types.Coercions.
+	CoercionPolicies map[string]string
+	// DateTimeTypes is nil unless the datetime types are configured in the advanced config;
+	// see DateTimeTypes.ForUTCColumn and DateTimeTypes.ForNaiveColumn.
+	DateTimeTypes *DateTimeTypes
 }
 
 // Parse ClickHouse connection config from a Fivetran config map that we receive on every GRPC call.
 func Parse(configuration map[string]string) (*Config, error) {
-	host, err := validateHost(getWithDefault(configuration, HostKey, "localhost", true))
+	protocol, err := validateProtocol(getWithDefault(configuration, ProtocolKey, ProtocolNative, true))
+	if err != nil {
+		return nil, err
+	}
+	defaultPort := defaultNativePort
+	if protocol == ProtocolHTTP {
+		defaultPort = defaultHTTPPort
+	}
+	port, err := validatePort(getWithDefault(configuration, PortKey, defaultPort, true))
+	if err != nil {
+		return nil, err
+	}
+	addresses, err := validateHosts(getWithDefault(configuration, HostKey, "localhost", true), port)
+	if err != nil {
+		return nil, err
+	}
+	connectionStrategy, err := validateConnectionStrategy(
+		getWithDefault(configuration, ConnectionStrategyKey, ConnectionStrategyInOrder, true))
 	if err != nil {
 		return nil, err
 	}
-	port, err := validatePort(getWithDefault(configuration, PortKey, "9440", true))
+	cluster, err := validateCluster(getWithDefault(configuration, ClusterKey, "", true))
 	if err != nil {
 		return nil, err
 	}
+	tlsOptions, err := parseTLSOptions(configuration)
+	if err != nil {
+		return nil, err
+	}
+	ddlUsername := getWithDefault(configuration, DDLUsernameKey, "", true)
+	ddlPassword := getWithDefault(configuration, DDLPasswordKey, "", false)
+	if ddlUsername == "" && ddlPassword != "" {
+		return nil, fmt.Errorf("%s requires %s to be set", DDLPasswordKey, DDLUsernameKey)
+	}
 	return &Config{
-		Host:     host,
-		Port:     port,
-		Username: getWithDefault(configuration, UsernameKey, "default", true),
-		Password: getWithDefault(configuration, PasswordKey, "", false),
-		Local:    getWithDefault(configuration, "local", "false", true) == "true",
+		Addresses:          addresses,
+		ConnectionStrategy: connectionStrategy,
+		Username:           getWithDefault(configuration, UsernameKey, "default", true),
+		Password:           getWithDefault(configuration, PasswordKey, "", false),
+		DDLUsername:        ddlUsername,
+		DDLPassword:        ddlPassword,
+		Protocol:           protocol,
+		Local:              getWithDefault(configuration, "local", "false", true) == "true",
+		Cluster:            cluster,
+		TLS:                tlsOptions,
 	}, nil
 }
 
+// HasDDLCredentials returns true if a separate user is configured for the DDL operations.
+func (c *Config) HasDDLCredentials() bool {
+	return c.DDLUsername != ""
+}
+
+// ForDDL returns the config to connect as the user that creates and alters databases and tables
+// (CreateTable, AlterTable and Migrate). If no separate DDL user is configured, the config is returned as is.
+func (c *Config) ForDDL() *Config {
+	if !c.HasDDLCredentials() {
+		return c
+	}
+	ddlConfig := *c
+	ddlConfig.Username = c.DDLUsername
+	ddlConfig.Password = c.DDLPassword
+	return &ddlConfig
+}
+
 func getWithDefault(configuration map[string]string, key string, defaultValue string, trim bool) string {
 	value, ok := configuration[key]
 	if !ok || value == "" {
@@ -57,14 +166,63 @@ func getWithDefault(configuration map[string]string, key string, defaultValue st
 	return value
 }
 
-func validateHost(host string) (string, error) {
-	if strings.Contains(host, ":") {
-		return "", fmt.Errorf("host %s should not contain protocol or port", host)
+// validateHosts parses a comma-separated list of hosts, each with an optional port (host:port),
+// and returns the list of host:port addresses; defaultPort is used for the hosts without an explicit port.
+func validateHosts(hosts string, defaultPort uint) ([]string, error) {
+	var addresses []string
+	for _, host := range strings.Split(hosts, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			return nil, fmt.Errorf("host list %s should not contain empty hosts", hosts)
+		}
+		if strings.Contains(host, "://") {
+			return nil, fmt.Errorf("host %s should not contain protocol", host)
+		}
+		if strings.Contains(host, "/") {
+			return nil, fmt.Errorf("host %s should not contain path", host)
+		}
+		port := defaultPort
+		if strings.Contains(host, ":") {
+			hostOnly, portStr, err := net.SplitHostPort(host)
+			if err != nil || hostOnly == "" {
+				return nil, fmt.Errorf("host %s should be either a host name or host:port", host)
+			}
+			if port, err = validatePort(portStr); err != nil {
+				return nil, err
+			}
+			host = hostOnly
+		}
+		addresses = append(addresses, net.JoinHostPort(host, strconv.Itoa(int(port))))
 	}
-	if strings.Contains(host, "/") {
-		return "", fmt.Errorf("host %s should not contain path", host)
+	return addresses, nil
+}
+
+func validateConnectionStrategy(strategy string) (string, error) {
+	strategy = strings.ToLower(strategy)
+	switch strategy {
+	case ConnectionStrategyInOrder, ConnectionStrategyRoundRobin, ConnectionStrategyRandom:
+		return strategy, nil
+	}
+	return "", fmt.Errorf("connection strategy %s is not supported, expected %s, %s or %s",
+		strategy, ConnectionStrategyInOrder, ConnectionStrategyRoundRobin, ConnectionStrategyRandom)
+}
+
+func validateCluster(name string) (*types.Cluster, error) {
+	if name == "" {
+		return nil, nil
+	}
+	if strings.ContainsAny(name, "`'\\") {
+		return nil, fmt.Errorf("cluster name %s should not contain quotes or backslashes", name)
 	}
-	return host, nil
+	return &types.Cluster{Name: name}, nil
+}
+
+func validateProtocol(protocol string) (string, error) {
+	protocol = strings.ToLower(protocol)
+	if protocol != ProtocolNative && protocol != ProtocolHTTP {
+		return "", fmt.Errorf("protocol %s is not supported, expected %s or %s", protocol, ProtocolNative, ProtocolHTTP)
+	}
+	return protocol, nil
 }
 
 func validatePort(port string) (uint, error) {
@@ -78,20 +236,75 @@ func validatePort(port string) (uint, error) {
 	return uint(portInt), nil
 }
 
-// ParseAll parses both the connection config and the optional advanced config
-// from the Fivetran configuration map. If destination configurations are present,
-// they are validated and applied to the global flags.
-func ParseAll(configuration map[string]string) (*Config, error) {
+// ParseAll parses the connection config, the optional advanced config, and the request-scoped Settings
+// from the Fivetran configuration map. Settings are built from the process flags, overridden by the
+// destination configurations if present; global flags are never modified.
+func ParseAll(configuration map[string]string) (*Config, *Settings, error) {
 	advancedCfg, err := ParseAdvancedConfig(configuration)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse advanced config: %w", err)
+		return nil, nil, fmt.Errorf("failed to parse advanced config: %w", err)
 	}
-	if err := ValidateAndOverwriteFlags(advancedCfg.DestinationConfigurations); err != nil {
-		return nil, fmt.Errorf("invalid destination configurations: %w", err)
+	settings, err := NewSettings(advancedCfg.DestinationConfigurations)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid destination configurations: %w", err)
 	}
 	jsonBytes, _ := json.Marshal(advancedCfg)
 	log.Info(fmt.Sprintf("Destination configurations applied: %s", string(jsonBytes)))
-	return Parse(configuration)
+	connConfig, err := Parse(configuration)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = applyClusterConfigurations(connConfig, advancedCfg.ClusterConfigurations); err != nil {
+		return nil, nil, fmt.Errorf("invalid cluster configurations: %w", err)
+	}
+	if connConfig.TableEngines, err = parseTableConfigurations(advancedCfg.TableConfigurations); err != nil {
+		return nil, nil, fmt.Errorf("invalid table configurations: %w", err)
+	}
+	if connConfig.ColumnOptions, err = parseColumnConfigurations(advancedCfg.ColumnConfigurations); err != nil {
+		return nil, nil, fmt.Errorf("invalid column configurations: %w", err)
+	}
+	if connConfig.JSONTypes, err = parseJSONConfigurations(advancedCfg.JSONConfigurations); err != nil {
+		return nil, nil, fmt.Errorf("invalid JSON configurations: %w", err)
+	}
+	if err = applyTypeConfigurations(connConfig, advancedCfg.TypeConfigurations); err != nil {
+		return nil, nil, fmt.Errorf("invalid type configurations: %w", err)
+	}
+	return connConfig, settings, nil
+}
+
+// applyClusterConfigurations sets the Replicated engine parameters from the advanced config.
+// These are only meaningful for self-hosted clusters, so the cluster name is required.
+func applyClusterConfigurations(connConfig *Config, cc *ClusterConfigurations) error {
+	if cc == nil {
+		return nil
+	}
+	if connConfig.Cluster == nil {
+		return fmt.Errorf("cluster configurations require the %s field to be set", ClusterKey)
+	}
+	if cc.KeeperPath != nil {
+		connConfig.Cluster.KeeperPath = strings.TrimSpace(*cc.KeeperPath)
+	}
+	if cc.ReplicaName != nil {
+		connConfig.Cluster.ReplicaName = strings.TrimSpace(*cc.ReplicaName)
+	}
+	if cc.Distributed != nil {
+		connConfig.Cluster.Distributed = *cc.Distributed
+	}
+	if cc.ShardingKey != nil {
+		connConfig.Cluster.ShardingKey = strings.TrimSpace(*cc.ShardingKey)
+	}
+	if connConfig.Cluster.ReplicaName != "" && connConfig.Cluster.KeeperPath == "" {
+		return fmt.Errorf("replica_name requires keeper_path to be set")
+	}
+	if connConfig.Cluster.ShardingKey != "" && !connConfig.Cluster.Distributed {
+		return fmt.Errorf("sharding_key requires distributed to be enabled")
+	}
+	for _, value := range []string{connConfig.Cluster.KeeperPath, connConfig.Cluster.ReplicaName} {
+		if strings.ContainsAny(value, "'\\") {
+			return fmt.Errorf("value %s should not contain quotes or backslashes", value)
+		}
+	}
+	return nil
 }
 
 // --- Advanced Configuration ---
@@ -101,15 +314,39 @@ const AdvancedConfigKey = "advanced_config"
 // AdvancedConfig is the top-level structure of the optional JSON configuration file
 // uploaded via the Fivetran setup form.
 type AdvancedConfig struct {
-	DestinationConfigurations *DestinationConfigurations `json:"destination_configurations,omitempty"`
+	DestinationConfigurations *DestinationConfigurations      `json:"destination_configurations,omitempty"`
+	ClusterConfigurations     *ClusterConfigurations          `json:"cluster_configurations,omitempty"`
+	TableConfigurations       *TableConfigurations            `json:"table_configurations,omitempty"`
+	ColumnConfigurations      map[string]*ColumnConfiguration `json:"column_configurations,omitempty"`
+	JSONConfigurations        *JSONConfigurations             `json:"json_configurations,omitempty"`
+	TypeConfigurations        *TypeConfigurations             `json:"type_configurations,omitempty"`
 }
 
 // DestinationConfigurations controls the internal behavior of the destination connector.
 type DestinationConfigurations struct {
-	WriteBatchSize      *uint `json:"write_batch_size,omitempty"`
-	SelectBatchSize     *uint `json:"select_batch_size,omitempty"`
-	MutationBatchSize   *uint `json:"mutation_batch_size,omitempty"`
-	HardDeleteBatchSize *uint `json:"hard_delete_batch_size,omitempty"`
+	WriteBatchSize       *uint `json:"write_batch_size,omitempty"`
+	SelectBatchSize      *uint `json:"select_batch_size,omitempty"`
+	MutationBatchSize    *uint `json:"mutation_batch_size,omitempty"`
+	HardDeleteBatchSize  *uint `json:"hard_delete_batch_size,omitempty"`
+	WriteQueueDepth      *uint `json:"write_queue_depth,omitempty"`
+	ParallelReplaceFiles *uint `json:"parallel_replace_files,omitempty"`
+	ServerSideCSVParsing *bool `json:"server_side_csv_parsing,omitempty"`
+	InsertDeduplication  *bool `json:"insert_deduplication,omitempty"`
+}
+
+// ClusterConfigurations controls how tables are created on a self-hosted cluster.
+// KeeperPath and ReplicaName are the ReplicatedReplacingMergeTree engine arguments, and may contain macros,
+// for example: "/clickhouse/tables/{shard}/{database}/{table}" and "{replica}".
+// If omitted, the server defaults (default_replica_path, default_replica_name) are used.
+// Distributed enables sharding: each table is created as a shard-local table with the "_local" suffix,
+// plus a Distributed table with the Fivetran table name. ShardingKey is the Distributed engine sharding expression,
+// defaulting to a hash of the primary key columns; it should depend on the primary key columns only,
+// otherwise different versions of the same row can end up on different shards.
+type ClusterConfigurations struct {
+	KeeperPath  *string `json:"keeper_path,omitempty"`
+	ReplicaName *string `json:"replica_name,omitempty"`
+	Distributed *bool   `json:"distributed,omitempty"`
+	ShardingKey *string `json:"sharding_key,omitempty"`
 }
 
 // ParseAdvancedConfig decodes and parses the optional JSON configuration file
@@ -144,37 +381,3 @@ func warnOnUnknownFields(jsonBytes []byte) {
 		log.Warn(fmt.Sprintf("Advanced config contains unknown fields (they will be ignored): %v", err))
 	}
 }
-
-// ValidateAndOverwriteFlags overrides the global flag values with values from the
-// parsed DestinationConfigurations. Nil fields are left at their flag defaults.
-// Returns an error if any value is outside its allowed range.
-func ValidateAndOverwriteFlags(ds *DestinationConfigurations) error {
-	if ds == nil {
-		return nil
-	}
-	if err := applySetting(&flags.WriteBatchSizeSetting, ds.WriteBatchSize); err != nil {
-		return err
-	}
-	if err := applySetting(&flags.SelectBatchSizeSetting, ds.SelectBatchSize); err != nil {
-		return err
-	}
-	if err := applySetting(&flags.MutationBatchSizeSetting, ds.MutationBatchSize); err != nil {
-		return err
-	}
-	if err := applySetting(&flags.HardDeleteBatchSizeSetting, ds.HardDeleteBatchSize); err != nil {
-		return err
-	}
-	return nil
-}
-
-func applySetting(setting *flags.ConfigDefinition, val *uint) error {
-	if val == nil {
-		*setting.Flag = setting.DefaultValue
-		return nil
-	}
-	if *val < setting.MinValue || *val > setting.MaxValue {
-		return fmt.Errorf("%s: value %d out of allowed range [%d, %d]", setting.Name, *val, setting.MinValue, setting.MaxValue)
-	}
-	*setting.Flag = *val
-	return nil
-}
@@ -2,9 +2,11 @@ package config
 
 import (
 	"encoding/base64"
+	"strings"
 	"testing"
 
 	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"fivetran.com/fivetran_sdk/destination/common/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -24,20 +26,24 @@ func TestGetWithDefaultTrim(t *testing.T) {
 
 func TestParseConfig(t *testing.T) {
 	defaultConfig := Config{
-		Host:     "localhost",
-		Port:     9440,
-		Username: "default",
-		Password: "",
-		Local:    false,
+		Addresses:          []string{"localhost:9440"},
+		ConnectionStrategy: "in_order",
+		Username:           "default",
+		Password:           "",
+		Protocol:           "native",
+		Local:              false,
 	}
 	withHostOnly := defaultConfig
-	withHostOnly.Host = "my.host"
+	withHostOnly.Addresses = []string{"my.host:9440"}
 	withPortOnly := defaultConfig
-	withPortOnly.Port = 9441
+	withPortOnly.Addresses = []string{"localhost:9441"}
 	withUsernameOnly := defaultConfig
 	withUsernameOnly.Username = "5t"
 	withPasswordOnly := defaultConfig
 	withPasswordOnly.Password = " foo_bar "
+	withHTTPOnly := defaultConfig
+	withHTTPOnly.Protocol = "http"
+	withHTTPOnly.Addresses = []string{"localhost:8443"}
 	tests := []struct {
 		name          string
 		configuration map[string]string
@@ -52,22 +58,24 @@ func TestParseConfig(t *testing.T) {
 				"password": " foo_bar ",
 			},
 			expected: &Config{
-				Host:     "my.host",
-				Port:     9441,
-				Username: "5t",
-				Password: " foo_bar ",
-				Local:    false,
+				Addresses:          []string{"my.host:9441"},
+				ConnectionStrategy: "in_order",
+				Username:           "5t",
+				Password:           " foo_bar ",
+				Protocol:           "native",
+				Local:              false,
 			},
 		},
 		{
 			name:          "valid config (all defaults)",
 			configuration: map[string]string{},
 			expected: &Config{
-				Host:     "localhost",
-				Port:     9440,
-				Username: "default",
-				Password: "",
-				Local:    false,
+				Addresses:          []string{"localhost:9440"},
+				ConnectionStrategy: "in_order",
+				Username:           "default",
+				Password:           "",
+				Protocol:           "native",
+				Local:              false,
 			},
 		},
 		{
@@ -90,6 +98,23 @@ func TestParseConfig(t *testing.T) {
 			configuration: map[string]string{"password": " foo_bar "},
 			expected:      &withPasswordOnly,
 		},
+		{
+			name:          "valid config (HTTP protocol with the default port)",
+			configuration: map[string]string{"protocol": " HTTP "},
+			expected:      &withHTTPOnly,
+		},
+		{
+			name:          "valid config (HTTP protocol with an explicit port)",
+			configuration: map[string]string{"protocol": "http", "port": "8123", "local": "true"},
+			expected: &Config{
+				Addresses:          []string{"localhost:8123"},
+				ConnectionStrategy: "in_order",
+				Username:           "default",
+				Password:           "",
+				Protocol:           "http",
+				Local:              true,
+			},
+		},
 	}
 	for _, test := range tests {
 		actual, err := Parse(test.configuration)
@@ -124,6 +149,11 @@ func TestParseConfigErrors(t *testing.T) {
 			configuration: map[string]string{"port": "-1"},
 			expectedError: "port -1 must be in range [1, 65535]",
 		},
+		{
+			name:          "unsupported protocol",
+			configuration: map[string]string{"protocol": "grpc"},
+			expectedError: "protocol grpc is not supported, expected native or http",
+		},
 	}
 	for _, test := range tests {
 		actual, err := Parse(test.configuration)
@@ -135,16 +165,85 @@ func TestParseConfigErrors(t *testing.T) {
 func TestConfigHostValidation(t *testing.T) {
 	parsed, err := Parse(map[string]string{"host": "my.host"})
 	assert.NoError(t, err)
-	assert.Equal(t, "my.host", parsed.Host)
+	assert.Equal(t, []string{"my.host:9440"}, parsed.Addresses)
+
+	// a host can specify its own port
+	parsed, err = Parse(map[string]string{"host": "my.host:9000"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"my.host:9000"}, parsed.Addresses)
 
-	_, err = Parse(map[string]string{"host": "my.host:9000"})
-	assert.ErrorContains(t, err, "host my.host:9000 should not contain protocol or port")
+	// multiple hosts, the port field is used as the default
+	parsed, err = Parse(map[string]string{"host": "host1, host2:9001 ,10.0.0.1,[::1]:9002", "port": "9000"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"host1:9000", "host2:9001", "10.0.0.1:9000", "[::1]:9002"}, parsed.Addresses)
 
 	_, err = Parse(map[string]string{"host": "my.host/path"})
 	assert.ErrorContains(t, err, "host my.host/path should not contain path")
 
 	_, err = Parse(map[string]string{"host": "tcp://my.host"})
-	assert.ErrorContains(t, err, "host tcp://my.host should not contain protocol or port")
+	assert.ErrorContains(t, err, "host tcp://my.host should not contain protocol")
+
+	_, err = Parse(map[string]string{"host": "my.host:foo"})
+	assert.ErrorContains(t, err, "port foo must be a number in range [1, 65535]")
+
+	_, err = Parse(map[string]string{"host": "my.host:9000:9001"})
+	assert.ErrorContains(t, err, "host my.host:9000:9001 should be either a host name or host:port")
+
+	_, err = Parse(map[string]string{"host": ":9000"})
+	assert.ErrorContains(t, err, "host :9000 should be either a host name or host:port")
+
+	_, err = Parse(map[string]string{"host": "host1,,host2"})
+	assert.ErrorContains(t, err, "host list host1,,host2 should not contain empty hosts")
+}
+
+func TestConfigConnectionStrategyValidation(t *testing.T) {
+	for _, strategy := range []string{"in_order", "round_robin", " Random "} {
+		parsed, err := Parse(map[string]string{"host": "host1,host2", "connection_strategy": strategy})
+		assert.NoError(t, err, "Strategy %s", strategy)
+		assert.Equal(t, strings.ToLower(strings.TrimSpace(strategy)), parsed.ConnectionStrategy)
+	}
+
+	_, err := Parse(map[string]string{"host": "host1,host2", "connection_strategy": "fastest"})
+	assert.ErrorContains(t, err, "connection strategy fastest is not supported, expected in_order, round_robin or random")
+}
+
+func TestConfigDDLCredentials(t *testing.T) {
+	parsed, err := Parse(map[string]string{"username": "fivetran", "password": "secret"})
+	assert.NoError(t, err)
+	assert.False(t, parsed.HasDDLCredentials())
+	assert.Same(t, parsed, parsed.ForDDL())
+
+	parsed, err = Parse(map[string]string{
+		"username":     "fivetran",
+		"password":     "secret",
+		"ddl_username": " fivetran_ddl ",
+		"ddl_password": " ddl_secret ",
+	})
+	assert.NoError(t, err)
+	assert.True(t, parsed.HasDDLCredentials())
+	ddlConfig := parsed.ForDDL()
+	assert.Equal(t, "fivetran_ddl", ddlConfig.Username)
+	assert.Equal(t, " ddl_secret ", ddlConfig.Password)
+	assert.Equal(t, parsed.Addresses, ddlConfig.Addresses)
+	// the original config still uses the DML user
+	assert.Equal(t, "fivetran", parsed.Username)
+	assert.Equal(t, "secret", parsed.Password)
+
+	_, err = Parse(map[string]string{"username": "fivetran", "ddl_password": "ddl_secret"})
+	assert.ErrorContains(t, err, "ddl_password requires ddl_username to be set")
+}
+
+func TestConfigClusterValidation(t *testing.T) {
+	parsed, err := Parse(map[string]string{"host": "my.host"})
+	assert.NoError(t, err)
+	assert.Nil(t, parsed.Cluster)
+
+	parsed, err = Parse(map[string]string{"host": "my.host", "cluster": " my_cluster "})
+	assert.NoError(t, err)
+	assert.Equal(t, &types.Cluster{Name: "my_cluster"}, parsed.Cluster)
+
+	_, err = Parse(map[string]string{"host": "my.host", "cluster": "my`cluster"})
+	assert.ErrorContains(t, err, "cluster name my`cluster should not contain quotes or backslashes")
 }
 
 func encodeJSON(json string) string {
@@ -259,115 +358,6 @@ func TestParseAdvancedConfigFloatValue(t *testing.T) {
 	assert.ErrorContains(t, err, "failed to parse advanced config JSON")
 }
 
-func TestValidateAndOverwriteFlagsNilLeftsFlagsUnchanged(t *testing.T) {
-	originalWriteBatch := *flags.WriteBatchSize
-	assert.NoError(t, ValidateAndOverwriteFlags(nil))
-	assert.Equal(t, originalWriteBatch, *flags.WriteBatchSize)
-}
-
-func TestValidateAndOverwriteFlagsOverridesFlags(t *testing.T) {
-	originalWriteBatch := *flags.WriteBatchSize
-	originalSelectBatch := *flags.SelectBatchSize
-	originalMutationBatch := *flags.MutationBatchSize
-	originalHardDeleteBatch := *flags.HardDeleteBatchSize
-	defer func() {
-		*flags.WriteBatchSize = originalWriteBatch
-		*flags.SelectBatchSize = originalSelectBatch
-		*flags.MutationBatchSize = originalMutationBatch
-		*flags.HardDeleteBatchSize = originalHardDeleteBatch
-	}()
-
-	writeBatch := flags.WriteBatchSizeSetting.MinValue + 1
-	selectBatch := flags.SelectBatchSizeSetting.MinValue + 1
-	mutationBatch := flags.MutationBatchSizeSetting.MinValue + 1
-	hardDelete := flags.HardDeleteBatchSizeSetting.MinValue + 1
-
-	ds := &DestinationConfigurations{
-		WriteBatchSize:      &writeBatch,
-		SelectBatchSize:     &selectBatch,
-		MutationBatchSize:   &mutationBatch,
-		HardDeleteBatchSize: &hardDelete,
-	}
-	assert.NoError(t, ValidateAndOverwriteFlags(ds))
-
-	assert.Equal(t, writeBatch, *flags.WriteBatchSize)
-	assert.Equal(t, selectBatch, *flags.SelectBatchSize)
-	assert.Equal(t, mutationBatch, *flags.MutationBatchSize)
-	assert.Equal(t, hardDelete, *flags.HardDeleteBatchSize)
-}
-
-func TestValidateAndOverwriteFlagsPartialOverride(t *testing.T) {
-	originalWriteBatch := *flags.WriteBatchSize
-	originalSelectBatch := *flags.SelectBatchSize
-	defer func() {
-		*flags.WriteBatchSize = originalWriteBatch
-		*flags.SelectBatchSize = originalSelectBatch
-	}()
-
-	writeBatch := flags.WriteBatchSizeSetting.MinValue + 1
-	ds := &DestinationConfigurations{
-		WriteBatchSize: &writeBatch,
-	}
-	assert.NoError(t, ValidateAndOverwriteFlags(ds))
-
-	assert.Equal(t, writeBatch, *flags.WriteBatchSize)
-	assert.Equal(t, flags.SelectBatchSizeSetting.DefaultValue, *flags.SelectBatchSize)
-}
-
-func uintPtr(v uint) *uint { return &v }
-
-func TestValidateAndOverwriteFlagsRejectsOutOfRange(t *testing.T) {
-	belowMin := flags.WriteBatchSizeSetting.MinValue - 1
-	err := ValidateAndOverwriteFlags(&DestinationConfigurations{WriteBatchSize: &belowMin})
-	assert.ErrorContains(t, err, "out of allowed range")
-
-	aboveMax := flags.WriteBatchSizeSetting.MaxValue + 1
-	err = ValidateAndOverwriteFlags(&DestinationConfigurations{WriteBatchSize: &aboveMax})
-	assert.ErrorContains(t, err, "out of allowed range")
-}
-
-func TestValidateAndOverwriteFlagsAcceptsBoundaryValues(t *testing.T) {
-	originalWriteBatch := *flags.WriteBatchSize
-	originalSelectBatch := *flags.SelectBatchSize
-	originalHardDeleteBatch := *flags.HardDeleteBatchSize
-	defer func() {
-		*flags.WriteBatchSize = originalWriteBatch
-		*flags.SelectBatchSize = originalSelectBatch
-		*flags.HardDeleteBatchSize = originalHardDeleteBatch
-	}()
-
-	ds := &DestinationConfigurations{
-		WriteBatchSize:      uintPtr(flags.WriteBatchSizeSetting.MinValue),
-		SelectBatchSize:     uintPtr(flags.SelectBatchSizeSetting.MaxValue),
-		HardDeleteBatchSize: uintPtr(flags.HardDeleteBatchSizeSetting.MaxValue),
-	}
-	assert.NoError(t, ValidateAndOverwriteFlags(ds))
-	assert.Equal(t, flags.WriteBatchSizeSetting.MinValue, *flags.WriteBatchSize)
-	assert.Equal(t, flags.SelectBatchSizeSetting.MaxValue, *flags.SelectBatchSize)
-	assert.Equal(t, flags.HardDeleteBatchSizeSetting.MaxValue, *flags.HardDeleteBatchSize)
-}
-
-func TestValidateAndOverwriteFlagsDoesNotModifyFlagsOnError(t *testing.T) {
-	originalWriteBatch := *flags.WriteBatchSize
-	originalSelectBatch := *flags.SelectBatchSize
-	defer func() {
-		*flags.WriteBatchSize = originalWriteBatch
-		*flags.SelectBatchSize = originalSelectBatch
-	}()
-
-	validWrite := flags.WriteBatchSizeSetting.MinValue + 1
-	invalidSelect := flags.SelectBatchSizeSetting.MaxValue + 1
-	ds := &DestinationConfigurations{
-		WriteBatchSize:  &validWrite,
-		SelectBatchSize: &invalidSelect,
-	}
-	err := ValidateAndOverwriteFlags(ds)
-	assert.Error(t, err)
-
-	assert.Equal(t, validWrite, *flags.WriteBatchSize)
-	assert.Equal(t, originalSelectBatch, *flags.SelectBatchSize)
-}
-
 // --- ParseAll tests ---
 
 func configWithAdvancedJSON(base map[string]string, json string) map[string]string {
@@ -380,37 +370,30 @@ func configWithAdvancedJSON(base map[string]string, json string) map[string]stri
 }
 
 func TestParseAllNoAdvancedConfig(t *testing.T) {
-	originalWriteBatch := *flags.WriteBatchSize
-	defer func() { *flags.WriteBatchSize = originalWriteBatch }()
-
-	cfg, err := ParseAll(map[string]string{
+	cfg, settings, err := ParseAll(map[string]string{
 		"host": "my.host",
 		"port": "9441",
 	})
 	assert.NoError(t, err)
-	assert.Equal(t, "my.host", cfg.Host)
-	assert.Equal(t, uint(9441), cfg.Port)
-	assert.Equal(t, flags.WriteBatchSizeSetting.DefaultValue, *flags.WriteBatchSize)
+	assert.Equal(t, []string{"my.host:9441"}, cfg.Addresses)
+	assert.Equal(t, DefaultSettings(), settings)
 }
 
 func TestParseAllWithAdvancedConfig(t *testing.T) {
 	originalWriteBatch := *flags.WriteBatchSize
 	originalSelectBatch := *flags.SelectBatchSize
-	defer func() {
-		*flags.WriteBatchSize = originalWriteBatch
-		*flags.SelectBatchSize = originalSelectBatch
-	}()
 
 	input := configWithAdvancedJSON(
 		map[string]string{"host": "my.host", "port": "9441"},
 		`{"destination_configurations": {"write_batch_size": 10000, "select_batch_size": 500}}`,
 	)
-	cfg, err := ParseAll(input)
+	cfg, settings, err := ParseAll(input)
 	assert.NoError(t, err)
-	assert.Equal(t, "my.host", cfg.Host)
-	assert.Equal(t, uint(9441), cfg.Port)
-	assert.Equal(t, uint(10000), *flags.WriteBatchSize)
-	assert.Equal(t, uint(500), *flags.SelectBatchSize)
+	assert.Equal(t, []string{"my.host:9441"}, cfg.Addresses)
+	assert.Equal(t, uint(10000), settings.WriteBatchSize)
+	assert.Equal(t, uint(500), settings.SelectBatchSize)
+	assert.Equal(t, originalWriteBatch, *flags.WriteBatchSize)
+	assert.Equal(t, originalSelectBatch, *flags.SelectBatchSize)
 }
 
 func TestParseAllInvalidAdvancedConfigJSON(t *testing.T) {
@@ -418,8 +401,9 @@ func TestParseAllInvalidAdvancedConfigJSON(t *testing.T) {
 		map[string]string{"host": "my.host"},
 		`{invalid}`,
 	)
-	cfg, err := ParseAll(input)
+	cfg, settings, err := ParseAll(input)
 	assert.Nil(t, cfg)
+	assert.Nil(t, settings)
 	assert.ErrorContains(t, err, "failed to parse advanced config")
 }
 
@@ -428,8 +412,9 @@ func TestParseAllOutOfRangeFlag(t *testing.T) {
 		map[string]string{"host": "my.host"},
 		`{"destination_configurations": {"write_batch_size": 1}}`,
 	)
-	cfg, err := ParseAll(input)
+	cfg, settings, err := ParseAll(input)
 	assert.Nil(t, cfg)
+	assert.Nil(t, settings)
 	assert.ErrorContains(t, err, "invalid destination configurations")
 	assert.ErrorContains(t, err, "out of allowed range")
 }
@@ -439,7 +424,77 @@ func TestParseAllInvalidConnectionConfig(t *testing.T) {
 		map[string]string{"host": "my.host", "port": "invalid"},
 		`{"destination_configurations": {"write_batch_size": 10000}}`,
 	)
-	cfg, err := ParseAll(input)
+	cfg, settings, err := ParseAll(input)
 	assert.Nil(t, cfg)
+	assert.Nil(t, settings)
 	assert.ErrorContains(t, err, "port invalid must be a number")
 }
+
+func TestParseAllWithClusterConfigurations(t *testing.T) {
+	input := configWithAdvancedJSON(
+		map[string]string{"host": "my.host", "cluster": "my_cluster"},
+		`{"cluster_configurations": {"keeper_path": "/clickhouse/tables/{shard}/{uuid}", "replica_name": "{replica}"}}`,
+	)
+	cfg, _, err := ParseAll(input)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.Cluster{
+		Name:        "my_cluster",
+		KeeperPath:  "/clickhouse/tables/{shard}/{uuid}",
+		ReplicaName: "{replica}",
+	}, cfg.Cluster)
+
+	// cluster without advanced config uses the server defaults
+	cfg, _, err = ParseAll(map[string]string{"host": "my.host", "cluster": "my_cluster"})
+	assert.NoError(t, err)
+	assert.Equal(t, &types.Cluster{Name: "my_cluster"}, cfg.Cluster)
+
+	input = configWithAdvancedJSON(
+		map[string]string{"host": "my.host", "cluster": "my_cluster"},
+		`{"cluster_configurations": {"distributed": true, "sharding_key": " rand() "}}`,
+	)
+	cfg, _, err = ParseAll(input)
+	assert.NoError(t, err)
+	assert.Equal(t, &types.Cluster{Name: "my_cluster", Distributed: true, ShardingKey: "rand()"}, cfg.Cluster)
+	assert.True(t, cfg.Cluster.IsDistributed())
+}
+
+func TestParseAllInvalidClusterConfigurations(t *testing.T) {
+	tests := []struct {
+		name          string
+		configuration map[string]string
+		json          string
+		expectedError string
+	}{
+		{
+			name:          "no cluster name",
+			configuration: map[string]string{"host": "my.host"},
+			json:          `{"cluster_configurations": {"keeper_path": "/clickhouse/tables/{shard}/{uuid}"}}`,
+			expectedError: "cluster configurations require the cluster field to be set",
+		},
+		{
+			name:          "replica name without Keeper path",
+			configuration: map[string]string{"host": "my.host", "cluster": "my_cluster"},
+			json:          `{"cluster_configurations": {"replica_name": "{replica}"}}`,
+			expectedError: "replica_name requires keeper_path to be set",
+		},
+		{
+			name:          "quotes in Keeper path",
+			configuration: map[string]string{"host": "my.host", "cluster": "my_cluster"},
+			json:          `{"cluster_configurations": {"keeper_path": "/clickhouse/tables/'"}}`,
+			expectedError: "value /clickhouse/tables/' should not contain quotes or backslashes",
+		},
+		{
+			name:          "sharding key without distributed",
+			configuration: map[string]string{"host": "my.host", "cluster": "my_cluster"},
+			json:          `{"cluster_configurations": {"sharding_key": "rand()"}}`,
+			expectedError: "sharding_key requires distributed to be enabled",
+		},
+	}
+	for _, test := range tests {
+		cfg, settings, err := ParseAll(configWithAdvancedJSON(test.configuration, test.json))
+		assert.Nil(t, cfg, "Test %s", test.name)
+		assert.Nil(t, settings, "Test %s", test.name)
+		assert.ErrorContains(t, err, "invalid cluster configurations", "Test %s", test.name)
+		assert.ErrorContains(t, err, test.expectedError, "Test %s", test.name)
+	}
+}
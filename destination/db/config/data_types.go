@@ -0,0 +1,287 @@
+package config
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+)
+
+const defaultNaiveTimePrecision = 6
+
+// TypeConfigurations enables the opt-in ClickHouse types for the Fivetran data types
+// that are otherwise stored as String with a type comment.
+type TypeConfigurations struct {
+	NaiveTime *NaiveTimeConfiguration `json:"naive_time,omitempty"`
+	Binary    *BinaryConfiguration    `json:"binary,omitempty"`
+	Decimal   *DecimalConfiguration   `json:"decimal,omitempty"`
+	Coercion  *CoercionConfiguration  `json:"coercion,omitempty"`
+	DateTime  *DateTimeConfiguration  `json:"datetime,omitempty"`
+}
+
+// NaiveTimeConfiguration enables the ClickHouse Time64 type for the Fivetran NAIVE_TIME columns (Time if Precision is 0).
+// Precision is the number of fractional second digits, from 0 to 9; defaults to 6 (microseconds).
+type NaiveTimeConfiguration struct {
+	Native    *bool `json:"native,omitempty"`
+	Precision *uint `json:"precision,omitempty"`
+}
+
+// BinaryConfiguration enables decoding the Fivetran BINARY values into raw bytes, instead of storing the encoded text.
+// Encoding is the encoding of the values in the batch files, base64 (default) or hex.
+// Columns are keyed by "schema.table.column", and set the FixedString length for a single column.
+type BinaryConfiguration struct {
+	Decode   *bool                                 `json:"decode,omitempty"`
+	Encoding *string                               `json:"encoding,omitempty"`
+	Columns  map[string]*BinaryColumnConfiguration `json:"columns,omitempty"`
+}
+
+// BinaryColumnConfiguration is a single entry of BinaryConfiguration.Columns.
+// FixedLength is the length in bytes of all values of the column, which is then created as FixedString(FixedLength).
+type BinaryColumnConfiguration struct {
+	FixedLength *uint `json:"fixed_length,omitempty"`
+}
+
+// DecimalConfiguration sets how the Fivetran DECIMAL columns that don't fit the ClickHouse Decimal type are stored,
+// i.e. the ones with a precision above 76 or a scale above the precision. Overflow is one of:
+//   - clamp (default): Decimal with the precision and the scale clamped to 76 and to the precision respectively;
+//   - string: String with the exact values;
+//   - decimal256: Decimal(76, S), and the values that don't fit are rejected on insert.
+//
+// With string and decimal256, the original precision and scale are kept in the column comment.
+type DecimalConfiguration struct {
+	Overflow *string `json:"overflow,omitempty"`
+}
+
+// CoercionConfiguration sets how the values out of the ClickHouse range are written:
+// dates and timestamps outside of the supported range, NaN or infinite floats,
+// decimals with more digits than the column type allows, and integers out of the range of a pre-existing column type.
+// Policies are keyed by the lowercase Fivetran type name (see coercionTypes), and are one of clamp (default), null or fail.
+type CoercionConfiguration struct {
+	Policies map[string]string `json:"policies,omitempty"`
+}
+
+// DateTimeConfiguration sets the ClickHouse types of the new datetime columns.
+// UTCPrecision is the DateTime64 precision of the UTC_DATETIME columns, 3, 6 or 9 (default);
+// Tables are keyed by "schema.table", and override it for a single table.
+// NaiveTimezone is the DateTime64 timezone of the NAIVE_DATETIME columns, e.g. Europe/Berlin; defaults to UTC.
+// The values are written as is, i.e. as the wall clock time in that timezone; the timezone only affects how ClickHouse
+// converts them to Unix timestamps and to the other timezones.
+type DateTimeConfiguration struct {
+	UTCPrecision  *uint                                  `json:"utc_precision,omitempty"`
+	NaiveTimezone *string                                `json:"naive_timezone,omitempty"`
+	Tables        map[string]*DateTimeTableConfiguration `json:"tables,omitempty"`
+}
+
+// DateTimeTableConfiguration is a single entry of DateTimeConfiguration.Tables.
+type DateTimeTableConfiguration struct {
+	UTCPrecision *uint `json:"utc_precision,omitempty"`
+}
+
+// utcDateTimePrecisions are the supported DateTime64 precisions of the UTC_DATETIME columns.
+var utcDateTimePrecisions = []uint{3, 6, 9}
+
+// coercionTypes are the Fivetran types with the values that can be out of the ClickHouse range.
+var coercionTypes = []string{"short", "int", "long", "naive_date", "naive_datetime", "utc_datetime", "float", "double", "decimal"}
+
+// BinaryTypes holds the validated BinaryConfiguration, resolved to the ClickHouse column types.
+type BinaryTypes struct {
+	columns map[string]string
+}
+
+// ForColumn returns the ClickHouse type for the Fivetran BINARY column with the decoded bytes, String or FixedString(N);
+// empty if decoding is not enabled. Safe to call on nil.
+func (t *BinaryTypes) ForColumn(schemaName string, tableName string, columnName string) string {
+	if t == nil {
+		return ""
+	}
+	if columnType, ok := t.columns[schemaName+"."+tableName+"."+columnName]; ok {
+		return columnType
+	}
+	return constants.String
+}
+
+// DateTimeTypes holds the validated DateTimeConfiguration, resolved to the ClickHouse column types.
+type DateTimeTypes struct {
+	utcType   string
+	tables    map[string]string
+	naiveType string
+}
+
+// ForUTCColumn returns the ClickHouse type of the new UTC_DATETIME columns of the table,
+// e.g. DateTime64(6, 'UTC'). Safe to call on nil, which means constants.DateTimeUTC.
+func (t *DateTimeTypes) ForUTCColumn(schemaName string, tableName string) string {
+	if t == nil {
+		return constants.DateTimeUTC
+	}
+	if utcType, ok := t.tables[schemaName+"."+tableName]; ok {
+		return utcType
+	}
+	return t.utcType
+}
+
+// ForNaiveColumn returns the ClickHouse type of the new NAIVE_DATETIME columns, e.g. DateTime64(0, 'Europe/Berlin').
+// Safe to call on nil, which means constants.DateTime.
+func (t *DateTimeTypes) ForNaiveColumn() string {
+	if t == nil {
+		return constants.DateTime
+	}
+	return t.naiveType
+}
+
+// applyTypeConfigurations sets the opt-in ClickHouse types of the connection config.
+func applyTypeConfigurations(connConfig *Config, tc *TypeConfigurations) error {
+	if tc == nil {
+		return nil
+	}
+	if tc.NaiveTime != nil {
+		naiveTimeType, err := toNaiveTimeType(tc.NaiveTime)
+		if err != nil {
+			return fmt.Errorf("naive_time: %w", err)
+		}
+		connConfig.NaiveTimeType = naiveTimeType
+	}
+	if tc.Binary != nil {
+		binaryTypes, encoding, err := parseBinaryConfiguration(tc.Binary)
+		if err != nil {
+			return fmt.Errorf("binary: %w", err)
+		}
+		connConfig.BinaryTypes = binaryTypes
+		connConfig.BinaryEncoding = encoding
+	}
+	if tc.Decimal != nil && tc.Decimal.Overflow != nil {
+		switch overflow := *tc.Decimal.Overflow; overflow {
+		case constants.DecimalOverflowClamp, constants.DecimalOverflowString, constants.DecimalOverflowDecimal256:
+			connConfig.DecimalOverflow = overflow
+		default:
+			return fmt.Errorf("decimal: overflow %s is not supported, expected one of: %s, %s, %s", overflow,
+				constants.DecimalOverflowClamp, constants.DecimalOverflowString, constants.DecimalOverflowDecimal256)
+		}
+	}
+	if tc.Coercion != nil {
+		if err := validateCoercionPolicies(tc.Coercion.Policies); err != nil {
+			return fmt.Errorf("coercion: %w", err)
+		}
+		connConfig.CoercionPolicies = tc.Coercion.Policies
+	}
+	if tc.DateTime != nil {
+		dateTimeTypes, err := parseDateTimeConfiguration(tc.DateTime)
+		if err != nil {
+			return fmt.Errorf("datetime: %w", err)
+		}
+		connConfig.DateTimeTypes = dateTimeTypes
+	}
+	return nil
+}
+
+func validateCoercionPolicies(policies map[string]string) error {
+	for dataType, policy := range policies {
+		if !slices.Contains(coercionTypes, dataType) {
+			return fmt.Errorf("type %s is not supported, expected one of: %s", dataType, strings.Join(coercionTypes, ", "))
+		}
+		switch policy {
+		case constants.CoercionClamp, constants.CoercionNull, constants.CoercionFail:
+		default:
+			return fmt.Errorf("%s: policy %s is not supported, expected one of: %s, %s, %s", dataType, policy,
+				constants.CoercionClamp, constants.CoercionNull, constants.CoercionFail)
+		}
+	}
+	return nil
+}
+
+func toNaiveTimeType(nc *NaiveTimeConfiguration) (string, error) {
+	if nc.Native == nil || !*nc.Native {
+		if nc.Precision != nil {
+			return "", fmt.Errorf("native should be enabled to set the precision")
+		}
+		return "", nil
+	}
+	precision := uint(defaultNaiveTimePrecision)
+	if nc.Precision != nil {
+		if *nc.Precision > 9 {
+			return "", fmt.Errorf("precision should be between 0 and 9")
+		}
+		precision = *nc.Precision
+	}
+	if precision == 0 {
+		return constants.Time, nil
+	}
+	return fmt.Sprintf("%s(%d)", constants.Time64, precision), nil
+}
+
+func parseBinaryConfiguration(bc *BinaryConfiguration) (*BinaryTypes, string, error) {
+	encoding := constants.Base64Encoding
+	if bc.Encoding != nil {
+		encoding = *bc.Encoding
+		if encoding != constants.Base64Encoding && encoding != constants.HexEncoding {
+			return nil, "", fmt.Errorf("encoding %s is not supported, expected one of: %s, %s",
+				encoding, constants.Base64Encoding, constants.HexEncoding)
+		}
+	}
+	if bc.Decode == nil || !*bc.Decode {
+		if len(bc.Columns) > 0 {
+			return nil, "", fmt.Errorf("decode should be enabled to configure the binary columns")
+		}
+		// the encoding still applies to the existing columns with the decoded values
+		return nil, encoding, nil
+	}
+	binaryTypes := &BinaryTypes{columns: make(map[string]string, len(bc.Columns))}
+	for key, column := range bc.Columns {
+		parts := strings.SplitN(key, ".", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, "", fmt.Errorf("column key %s should be in the schema.table.column format", key)
+		}
+		if column == nil || column.FixedLength == nil {
+			return nil, "", fmt.Errorf("column %s: configuration is empty", key)
+		}
+		if *column.FixedLength == 0 {
+			return nil, "", fmt.Errorf("column %s: fixed_length should be greater than 0", key)
+		}
+		binaryTypes.columns[key] = fmt.Sprintf("%s(%d)", constants.FixedString, *column.FixedLength)
+	}
+	return binaryTypes, encoding, nil
+}
+
+func parseDateTimeConfiguration(dc *DateTimeConfiguration) (*DateTimeTypes, error) {
+	utcType, err := toUTCDateTimeType(dc.UTCPrecision)
+	if err != nil {
+		return nil, err
+	}
+	dateTimeTypes := &DateTimeTypes{
+		utcType:   utcType,
+		tables:    make(map[string]string, len(dc.Tables)),
+		naiveType: constants.DateTime,
+	}
+	if dc.NaiveTimezone != nil {
+		// LoadLocation also accepts "" and "Local", which ClickHouse doesn't
+		timezone := *dc.NaiveTimezone
+		if _, err = time.LoadLocation(timezone); err != nil || timezone == "" || timezone == "Local" {
+			return nil, fmt.Errorf("naive_timezone %s is not a valid timezone", timezone)
+		}
+		dateTimeTypes.naiveType = fmt.Sprintf("DateTime64(0, '%s')", timezone)
+	}
+	for key, table := range dc.Tables {
+		parts := strings.SplitN(key, ".", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("table key %s should be in the schema.table format", key)
+		}
+		if table == nil || table.UTCPrecision == nil {
+			return nil, fmt.Errorf("table %s: configuration is empty", key)
+		}
+		if dateTimeTypes.tables[key], err = toUTCDateTimeType(table.UTCPrecision); err != nil {
+			return nil, fmt.Errorf("table %s: %w", key, err)
+		}
+	}
+	return dateTimeTypes, nil
+}
+
+func toUTCDateTimeType(precision *uint) (string, error) {
+	if precision == nil {
+		return constants.DateTimeUTC, nil
+	}
+	if !slices.Contains(utcDateTimePrecisions, *precision) {
+		return "", fmt.Errorf("utc_precision %d is not supported, expected one of: 3, 6, 9", *precision)
+	}
+	return fmt.Sprintf("DateTime64(%d, 'UTC')", *precision), nil
+}
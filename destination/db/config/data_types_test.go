@@ -0,0 +1,215 @@
+package config
+
+import (
+	"testing"
+
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllWithTypeConfigurations(t *testing.T) {
+	tests := []struct {
+		json          string
+		naiveTimeType string
+	}{
+		{`{"naive_time": {"native": true}}`, "Time64(6)"},
+		{`{"naive_time": {"native": true, "precision": 3}}`, "Time64(3)"},
+		{`{"naive_time": {"native": true, "precision": 9}}`, "Time64(9)"},
+		{`{"naive_time": {"native": true, "precision": 0}}`, "Time"},
+		{`{"naive_time": {"native": false}}`, ""},
+		{`{}`, ""},
+	}
+	for _, test := range tests {
+		input := configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"type_configurations": `+test.json+`}`)
+		cfg, _, err := ParseAll(input)
+		require.NoError(t, err, "Test %s", test.json)
+		assert.Equal(t, test.naiveTimeType, cfg.NaiveTimeType, "Test %s", test.json)
+	}
+
+	// no type configurations at all
+	cfg, _, err := ParseAll(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+	assert.Empty(t, cfg.NaiveTimeType)
+}
+
+func TestParseAllWithBinaryConfiguration(t *testing.T) {
+	input := configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"type_configurations": {"binary": {
+		"decode": true,
+		"encoding": "hex",
+		"columns": {"analytics.events.hash": {"fixed_length": 32}}
+	}}}`)
+	cfg, _, err := ParseAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, "hex", cfg.BinaryEncoding)
+	assert.Equal(t, "FixedString(32)", cfg.BinaryTypes.ForColumn("analytics", "events", "hash"))
+	assert.Equal(t, "String", cfg.BinaryTypes.ForColumn("analytics", "events", "payload"))
+
+	// the encoding is kept for the existing decoded columns
+	input = configWithAdvancedJSON(map[string]string{"host": "my.host"},
+		`{"type_configurations": {"binary": {"decode": false, "encoding": "hex"}}}`)
+	cfg, _, err = ParseAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, "hex", cfg.BinaryEncoding)
+	assert.Nil(t, cfg.BinaryTypes)
+	assert.Empty(t, cfg.BinaryTypes.ForColumn("analytics", "events", "payload"))
+
+	input = configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"type_configurations": {"binary": {"decode": true}}}`)
+	cfg, _, err = ParseAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, "base64", cfg.BinaryEncoding)
+	assert.Equal(t, "String", cfg.BinaryTypes.ForColumn("analytics", "events", "payload"))
+}
+
+func TestParseAllWithDecimalConfiguration(t *testing.T) {
+	tests := []struct {
+		json     string
+		overflow string
+	}{
+		{`{"decimal": {"overflow": "string"}}`, "string"},
+		{`{"decimal": {"overflow": "decimal256"}}`, "decimal256"},
+		{`{"decimal": {"overflow": "clamp"}}`, "clamp"},
+		{`{"decimal": {}}`, ""},
+		{`{}`, ""},
+	}
+	for _, test := range tests {
+		input := configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"type_configurations": `+test.json+`}`)
+		cfg, _, err := ParseAll(input)
+		require.NoError(t, err, "Test %s", test.json)
+		assert.Equal(t, test.overflow, cfg.DecimalOverflow, "Test %s", test.json)
+	}
+}
+
+func TestParseAllWithCoercionConfiguration(t *testing.T) {
+	input := configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"type_configurations": {"coercion": {
+		"policies": {"naive_date": "null", "double": "fail", "decimal": "clamp"}}}}`)
+	cfg, _, err := ParseAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"naive_date": "null", "double": "fail", "decimal": "clamp"}, cfg.CoercionPolicies)
+
+	cfg, _, err = ParseAll(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+	assert.Nil(t, cfg.CoercionPolicies)
+}
+
+func TestParseAllWithDateTimeConfiguration(t *testing.T) {
+	input := configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"type_configurations": {"datetime": {
+		"utc_precision": 3, "naive_timezone": "Europe/Berlin", "tables": {"analytics.events": {"utc_precision": 6}}}}}`)
+	cfg, _, err := ParseAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, "DateTime64(3, 'UTC')", cfg.DateTimeTypes.ForUTCColumn("analytics", "users"))
+	assert.Equal(t, "DateTime64(6, 'UTC')", cfg.DateTimeTypes.ForUTCColumn("analytics", "events"))
+	assert.Equal(t, "DateTime64(0, 'Europe/Berlin')", cfg.DateTimeTypes.ForNaiveColumn())
+
+	input = configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"type_configurations": {"datetime": {
+		"tables": {"analytics.events": {"utc_precision": 3}}}}}`)
+	cfg, _, err = ParseAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, constants.DateTimeUTC, cfg.DateTimeTypes.ForUTCColumn("analytics", "users"))
+	assert.Equal(t, "DateTime64(3, 'UTC')", cfg.DateTimeTypes.ForUTCColumn("analytics", "events"))
+	assert.Equal(t, constants.DateTime, cfg.DateTimeTypes.ForNaiveColumn())
+
+	cfg, _, err = ParseAll(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+	assert.Nil(t, cfg.DateTimeTypes)
+	assert.Equal(t, constants.DateTimeUTC, cfg.DateTimeTypes.ForUTCColumn("analytics", "events"))
+	assert.Equal(t, constants.DateTime, cfg.DateTimeTypes.ForNaiveColumn())
+}
+
+func TestParseAllInvalidTypeConfigurations(t *testing.T) {
+	tests := []struct {
+		name          string
+		json          string
+		expectedError string
+	}{
+		{
+			name:          "precision without native",
+			json:          `{"naive_time": {"precision": 3}}`,
+			expectedError: "naive_time: native should be enabled to set the precision",
+		},
+		{
+			name:          "precision out of range",
+			json:          `{"naive_time": {"native": true, "precision": 10}}`,
+			expectedError: "naive_time: precision should be between 0 and 9",
+		},
+		{
+			name:          "unknown binary encoding",
+			json:          `{"binary": {"decode": true, "encoding": "base32"}}`,
+			expectedError: "binary: encoding base32 is not supported, expected one of: base64, hex",
+		},
+		{
+			name:          "binary columns without decode",
+			json:          `{"binary": {"columns": {"analytics.events.hash": {"fixed_length": 32}}}}`,
+			expectedError: "binary: decode should be enabled to configure the binary columns",
+		},
+		{
+			name:          "binary column key without table",
+			json:          `{"binary": {"decode": true, "columns": {"analytics.hash": {"fixed_length": 32}}}}`,
+			expectedError: "binary: column key analytics.hash should be in the schema.table.column format",
+		},
+		{
+			name:          "empty binary column configuration",
+			json:          `{"binary": {"decode": true, "columns": {"analytics.events.hash": {}}}}`,
+			expectedError: "binary: column analytics.events.hash: configuration is empty",
+		},
+		{
+			name:          "zero fixed length",
+			json:          `{"binary": {"decode": true, "columns": {"analytics.events.hash": {"fixed_length": 0}}}}`,
+			expectedError: "binary: column analytics.events.hash: fixed_length should be greater than 0",
+		},
+		{
+			name:          "unknown decimal overflow policy",
+			json:          `{"decimal": {"overflow": "float"}}`,
+			expectedError: "decimal: overflow float is not supported, expected one of: clamp, string, decimal256",
+		},
+		{
+			name:          "unknown coercion type",
+			json:          `{"coercion": {"policies": {"string": "null"}}}`,
+			expectedError: "coercion: type string is not supported, expected one of: short, int, long, naive_date, naive_datetime, utc_datetime, float, double, decimal",
+		},
+		{
+			name:          "unknown coercion policy",
+			json:          `{"coercion": {"policies": {"naive_date": "round"}}}`,
+			expectedError: "coercion: naive_date: policy round is not supported, expected one of: clamp, null, fail",
+		},
+		{
+			name:          "unsupported utc precision",
+			json:          `{"datetime": {"utc_precision": 0}}`,
+			expectedError: "datetime: utc_precision 0 is not supported, expected one of: 3, 6, 9",
+		},
+		{
+			name:          "unsupported table utc precision",
+			json:          `{"datetime": {"tables": {"analytics.events": {"utc_precision": 12}}}}`,
+			expectedError: "datetime: table analytics.events: utc_precision 12 is not supported, expected one of: 3, 6, 9",
+		},
+		{
+			name:          "table key without schema",
+			json:          `{"datetime": {"tables": {"events": {"utc_precision": 3}}}}`,
+			expectedError: "datetime: table key events should be in the schema.table format",
+		},
+		{
+			name:          "empty table configuration",
+			json:          `{"datetime": {"tables": {"analytics.events": {}}}}`,
+			expectedError: "datetime: table analytics.events: configuration is empty",
+		},
+		{
+			name:          "unknown naive timezone",
+			json:          `{"datetime": {"naive_timezone": "Mars/Olympus_Mons"}}`,
+			expectedError: "datetime: naive_timezone Mars/Olympus_Mons is not a valid timezone",
+		},
+		{
+			name:          "local naive timezone",
+			json:          `{"datetime": {"naive_timezone": "Local"}}`,
+			expectedError: "datetime: naive_timezone Local is not a valid timezone",
+		},
+	}
+	for _, test := range tests {
+		input := configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"type_configurations": `+test.json+`}`)
+		cfg, settings, err := ParseAll(input)
+		assert.Nil(t, cfg, "Test %s", test.name)
+		assert.Nil(t, settings, "Test %s", test.name)
+		assert.ErrorContains(t, err, "invalid type configurations", "Test %s", test.name)
+		assert.ErrorContains(t, err, test.expectedError, "Test %s", test.name)
+	}
+}
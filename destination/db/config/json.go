@@ -0,0 +1,111 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+)
+
+// jsonPathRegex allows a dot-separated list of identifiers, e.g. "user.address.city".
+var jsonPathRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// JSONConfigurations enables the native ClickHouse JSON type for the Fivetran JSON columns.
+// By default, the JSON columns are created as String with a JSON comment.
+// MaxDynamicPaths applies to all JSON columns; Columns are keyed by "schema.table.column",
+// and override MaxDynamicPaths and add the type hints for a single column.
+type JSONConfigurations struct {
+	Native          *bool                               `json:"native,omitempty"`
+	MaxDynamicPaths *uint                               `json:"max_dynamic_paths,omitempty"`
+	Columns         map[string]*JSONColumnConfiguration `json:"columns,omitempty"`
+}
+
+// JSONColumnConfiguration is a single entry of JSONConfigurations.Columns.
+// TypeHints map the JSON paths to ClickHouse types, e.g. {"user.id": "UInt64"}.
+type JSONColumnConfiguration struct {
+	MaxDynamicPaths *uint             `json:"max_dynamic_paths,omitempty"`
+	TypeHints       map[string]string `json:"type_hints,omitempty"`
+}
+
+// JSONTypes holds the validated JSONConfigurations, resolved to the ClickHouse column types.
+type JSONTypes struct {
+	defaultType string
+	columns     map[string]string
+}
+
+// ForColumn returns the ClickHouse type for the Fivetran JSON column, e.g. JSON(max_dynamic_paths=256, user.id UInt64);
+// empty if the native JSON type is not enabled. Safe to call on nil.
+func (t *JSONTypes) ForColumn(schemaName string, tableName string, columnName string) string {
+	if t == nil {
+		return ""
+	}
+	if columnType, ok := t.columns[schemaName+"."+tableName+"."+columnName]; ok {
+		return columnType
+	}
+	return t.defaultType
+}
+
+func parseJSONConfigurations(jc *JSONConfigurations) (*JSONTypes, error) {
+	if jc == nil {
+		return nil, nil
+	}
+	if jc.Native == nil || !*jc.Native {
+		if jc.MaxDynamicPaths != nil || len(jc.Columns) > 0 {
+			return nil, fmt.Errorf("native should be enabled to configure the JSON columns")
+		}
+		return nil, nil
+	}
+	defaultType, err := toJSONType(jc.MaxDynamicPaths, nil)
+	if err != nil {
+		return nil, err
+	}
+	jsonTypes := &JSONTypes{defaultType: defaultType, columns: make(map[string]string, len(jc.Columns))}
+	for key, column := range jc.Columns {
+		parts := strings.SplitN(key, ".", 3)
+		if len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("column key %s should be in the schema.table.column format", key)
+		}
+		if column == nil {
+			return nil, fmt.Errorf("column %s: configuration is empty", key)
+		}
+		maxDynamicPaths := jc.MaxDynamicPaths
+		if column.MaxDynamicPaths != nil {
+			maxDynamicPaths = column.MaxDynamicPaths
+		}
+		if jsonTypes.columns[key], err = toJSONType(maxDynamicPaths, column.TypeHints); err != nil {
+			return nil, fmt.Errorf("column %s: %w", key, err)
+		}
+	}
+	return jsonTypes, nil
+}
+
+func toJSONType(maxDynamicPaths *uint, typeHints map[string]string) (string, error) {
+	params := make([]string, 0, len(typeHints)+1)
+	if maxDynamicPaths != nil {
+		params = append(params, fmt.Sprintf("max_dynamic_paths=%d", *maxDynamicPaths))
+	}
+	paths := make([]string, 0, len(typeHints))
+	for path := range typeHints {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		if !jsonPathRegex.MatchString(path) {
+			return "", fmt.Errorf("type hint path %s is not valid, expected a dot-separated list of identifiers", path)
+		}
+		hintType := strings.TrimSpace(typeHints[path])
+		if hintType == "" {
+			return "", fmt.Errorf("type hint for path %s should not be empty", path)
+		}
+		if strings.ContainsAny(hintType, ";`") {
+			return "", fmt.Errorf("type hint %s for path %s should not contain semicolons or backticks", hintType, path)
+		}
+		params = append(params, fmt.Sprintf("%s %s", path, hintType))
+	}
+	if len(params) == 0 {
+		return constants.JSON, nil
+	}
+	return fmt.Sprintf("%s(%s)", constants.JSON, strings.Join(params, ", ")), nil
+}
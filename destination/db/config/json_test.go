@@ -0,0 +1,101 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllWithJSONConfigurations(t *testing.T) {
+	input := configWithAdvancedJSON(
+		map[string]string{"host": "my.host"},
+		`{"json_configurations": {
+			"native": true,
+			"max_dynamic_paths": 512,
+			"columns": {
+				"analytics.events.payload": {"type_hints": {"user.id": " UInt64 ", "created_at": "DateTime64(3)"}},
+				"analytics.events.metadata": {"max_dynamic_paths": 64},
+				"analytics.users.profile": {"max_dynamic_paths": 0, "type_hints": {"tags": "Array(String)"}}
+			}
+		}}`,
+	)
+	cfg, _, err := ParseAll(input)
+	require.NoError(t, err)
+
+	assert.Equal(t, "JSON(max_dynamic_paths=512, created_at DateTime64(3), user.id UInt64)",
+		cfg.JSONTypes.ForColumn("analytics", "events", "payload"))
+	assert.Equal(t, "JSON(max_dynamic_paths=64)", cfg.JSONTypes.ForColumn("analytics", "events", "metadata"))
+	assert.Equal(t, "JSON(max_dynamic_paths=0, tags Array(String))", cfg.JSONTypes.ForColumn("analytics", "users", "profile"))
+	assert.Equal(t, "JSON(max_dynamic_paths=512)", cfg.JSONTypes.ForColumn("analytics", "users", "payload"))
+
+	// no parameters
+	input = configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"json_configurations": {"native": true}}`)
+	cfg, _, err = ParseAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, "JSON", cfg.JSONTypes.ForColumn("analytics", "events", "payload"))
+
+	// not enabled
+	input = configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"json_configurations": {"native": false}}`)
+	cfg, _, err = ParseAll(input)
+	require.NoError(t, err)
+	assert.Nil(t, cfg.JSONTypes)
+	assert.Empty(t, cfg.JSONTypes.ForColumn("analytics", "events", "payload"))
+
+	// no JSON configurations at all
+	cfg, _, err = ParseAll(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+	assert.Nil(t, cfg.JSONTypes)
+}
+
+func TestParseAllInvalidJSONConfigurations(t *testing.T) {
+	tests := []struct {
+		name          string
+		json          string
+		expectedError string
+	}{
+		{
+			name:          "columns without native",
+			json:          `{"columns": {"analytics.events.payload": {"max_dynamic_paths": 64}}}`,
+			expectedError: "native should be enabled to configure the JSON columns",
+		},
+		{
+			name:          "max dynamic paths without native",
+			json:          `{"native": false, "max_dynamic_paths": 64}`,
+			expectedError: "native should be enabled to configure the JSON columns",
+		},
+		{
+			name:          "column key without table",
+			json:          `{"native": true, "columns": {"analytics.payload": {"max_dynamic_paths": 64}}}`,
+			expectedError: "column key analytics.payload should be in the schema.table.column format",
+		},
+		{
+			name:          "empty column configuration",
+			json:          `{"native": true, "columns": {"analytics.events.payload": null}}`,
+			expectedError: "column analytics.events.payload: configuration is empty",
+		},
+		{
+			name:          "invalid path",
+			json:          `{"native": true, "columns": {"analytics.events.payload": {"type_hints": {"user id": "UInt64"}}}}`,
+			expectedError: "type hint path user id is not valid",
+		},
+		{
+			name:          "empty type",
+			json:          `{"native": true, "columns": {"analytics.events.payload": {"type_hints": {"user.id": " "}}}}`,
+			expectedError: "type hint for path user.id should not be empty",
+		},
+		{
+			name:          "multiple statements in type",
+			json:          `{"native": true, "columns": {"analytics.events.payload": {"type_hints": {"user.id": "UInt64); DROP TABLE foo"}}}}`,
+			expectedError: "type hint UInt64); DROP TABLE foo for path user.id should not contain semicolons or backticks",
+		},
+	}
+	for _, test := range tests {
+		input := configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"json_configurations": `+test.json+`}`)
+		cfg, settings, err := ParseAll(input)
+		assert.Nil(t, cfg, "Test %s", test.name)
+		assert.Nil(t, settings, "Test %s", test.name)
+		assert.ErrorContains(t, err, "invalid JSON configurations", "Test %s", test.name)
+		assert.ErrorContains(t, err, test.expectedError, "Test %s", test.name)
+	}
+}
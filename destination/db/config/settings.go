@@ -0,0 +1,101 @@
+package config
+
+import (
+	"fmt"
+
+	"fivetran.com/fivetran_sdk/destination/common/files"
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"fivetran.com/fivetran_sdk/destination/common/retry"
+	pb "fivetran.com/fivetran_sdk/proto"
+)
+
+// Settings holds the tunables used while serving a single GRPC call.
+// It is built once per request from the process flags and the destination configurations,
+// and must not be modified afterward, so concurrent requests from different destinations
+// never observe each other's values.
+type Settings struct {
+	WriteBatchSize      uint
+	SelectBatchSize     uint
+	MutationBatchSize   uint
+	HardDeleteBatchSize uint
+	// WriteQueueDepth is the number of batches that ReplaceBatch and UpdateBatch read ahead
+	// while the previous batch is inserted, see db.pipeline.
+	WriteQueueDepth uint
+	// ParallelReplaceFiles is the number of replace files of a WriteBatch or WriteHistoryBatch request
+	// that are inserted at the same time.
+	ParallelReplaceFiles uint
+	MaxParallelSelects   uint
+	Retry                retry.Settings
+	// ServerSideCSVParsing makes ReplaceBatch send the CSV rows as they are, see db.ClickHouseConnection.ReplaceBatch.
+	ServerSideCSVParsing bool
+	// InsertDeduplication makes ReplaceBatch set insert_deduplication_token for every batch, see db.deduplicationTokens.
+	InsertDeduplication bool
+	// BatchFileFormat is the format of the batch files in the WriteBatch and WriteHistoryBatch requests.
+	// It can't be overridden per destination, as Capabilities requests it from Fivetran without the configuration.
+	BatchFileFormat pb.BatchFileFormat
+}
+
+// DefaultSettings returns Settings populated from the process flags only.
+func DefaultSettings() *Settings {
+	// the flag is validated on startup, see cmd.StartServer
+	batchFileFormat, _ := files.FormatFromFlags()
+	return &Settings{
+		WriteBatchSize:       *flags.WriteBatchSize,
+		SelectBatchSize:      *flags.SelectBatchSize,
+		MutationBatchSize:    *flags.MutationBatchSize,
+		HardDeleteBatchSize:  *flags.HardDeleteBatchSize,
+		WriteQueueDepth:      *flags.WriteQueueDepth,
+		ParallelReplaceFiles: *flags.ParallelReplaceFiles,
+		MaxParallelSelects:   *flags.MaxParallelSelects,
+		Retry:                retry.SettingsFromFlags(),
+		ServerSideCSVParsing: *flags.ServerSideCSVParsing,
+		InsertDeduplication:  *flags.InsertDeduplication,
+		BatchFileFormat:      batchFileFormat,
+	}
+}
+
+// NewSettings builds Settings from the process flags, overridden by the values from
+// the parsed DestinationConfigurations. Nil fields are left at their flag values.
+// Returns an error if any value is outside its allowed range.
+func NewSettings(ds *DestinationConfigurations) (*Settings, error) {
+	settings := DefaultSettings()
+	if ds == nil {
+		return settings, nil
+	}
+	if err := applySetting(&flags.WriteBatchSizeSetting, ds.WriteBatchSize, &settings.WriteBatchSize); err != nil {
+		return nil, err
+	}
+	if err := applySetting(&flags.SelectBatchSizeSetting, ds.SelectBatchSize, &settings.SelectBatchSize); err != nil {
+		return nil, err
+	}
+	if err := applySetting(&flags.MutationBatchSizeSetting, ds.MutationBatchSize, &settings.MutationBatchSize); err != nil {
+		return nil, err
+	}
+	if err := applySetting(&flags.HardDeleteBatchSizeSetting, ds.HardDeleteBatchSize, &settings.HardDeleteBatchSize); err != nil {
+		return nil, err
+	}
+	if err := applySetting(&flags.WriteQueueDepthSetting, ds.WriteQueueDepth, &settings.WriteQueueDepth); err != nil {
+		return nil, err
+	}
+	if err := applySetting(&flags.ParallelReplaceFilesSetting, ds.ParallelReplaceFiles, &settings.ParallelReplaceFiles); err != nil {
+		return nil, err
+	}
+	if ds.ServerSideCSVParsing != nil {
+		settings.ServerSideCSVParsing = *ds.ServerSideCSVParsing
+	}
+	if ds.InsertDeduplication != nil {
+		settings.InsertDeduplication = *ds.InsertDeduplication
+	}
+	return settings, nil
+}
+
+func applySetting(setting *flags.ConfigDefinition, val *uint, target *uint) error {
+	if val == nil {
+		return nil
+	}
+	if *val < setting.MinValue || *val > setting.MaxValue {
+		return fmt.Errorf("%s: value %d out of allowed range [%d, %d]", setting.Name, *val, setting.MinValue, setting.MaxValue)
+	}
+	*target = *val
+	return nil
+}
@@ -0,0 +1,179 @@
+package config
+
+import (
+	"testing"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewSettingsNilUsesFlags(t *testing.T) {
+	settings, err := NewSettings(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultSettings(), settings)
+	assert.Equal(t, *flags.WriteBatchSize, settings.WriteBatchSize)
+	assert.Equal(t, *flags.SelectBatchSize, settings.SelectBatchSize)
+	assert.Equal(t, *flags.MutationBatchSize, settings.MutationBatchSize)
+	assert.Equal(t, *flags.HardDeleteBatchSize, settings.HardDeleteBatchSize)
+	assert.Equal(t, *flags.WriteQueueDepth, settings.WriteQueueDepth)
+	assert.Equal(t, *flags.ParallelReplaceFiles, settings.ParallelReplaceFiles)
+	assert.Equal(t, *flags.MaxParallelSelects, settings.MaxParallelSelects)
+	assert.Equal(t, *flags.MaxRetries, settings.Retry.MaxRetries)
+	assert.Equal(t, pb.BatchFileFormat_CSV, settings.BatchFileFormat)
+}
+
+func TestNewSettingsBatchFileFormat(t *testing.T) {
+	original := *flags.BatchFileFormat
+	defer func() { *flags.BatchFileFormat = original }()
+
+	csvSettings, err := NewSettings(nil)
+	assert.NoError(t, err)
+
+	*flags.BatchFileFormat = "parquet"
+	parquetSettings, err := NewSettings(&DestinationConfigurations{})
+	assert.NoError(t, err)
+	assert.Equal(t, pb.BatchFileFormat_PARQUET, parquetSettings.BatchFileFormat)
+	// the settings of the earlier requests are not changed
+	assert.Equal(t, pb.BatchFileFormat_CSV, csvSettings.BatchFileFormat)
+}
+
+func TestNewSettingsOverridesFlags(t *testing.T) {
+	originalWriteBatch := *flags.WriteBatchSize
+	originalSelectBatch := *flags.SelectBatchSize
+	originalMutationBatch := *flags.MutationBatchSize
+	originalHardDeleteBatch := *flags.HardDeleteBatchSize
+
+	writeBatch := flags.WriteBatchSizeSetting.MinValue + 1
+	selectBatch := flags.SelectBatchSizeSetting.MinValue + 1
+	mutationBatch := flags.MutationBatchSizeSetting.MinValue + 1
+	hardDelete := flags.HardDeleteBatchSizeSetting.MinValue + 1
+
+	ds := &DestinationConfigurations{
+		WriteBatchSize:      &writeBatch,
+		SelectBatchSize:     &selectBatch,
+		MutationBatchSize:   &mutationBatch,
+		HardDeleteBatchSize: &hardDelete,
+	}
+	settings, err := NewSettings(ds)
+	assert.NoError(t, err)
+
+	assert.Equal(t, writeBatch, settings.WriteBatchSize)
+	assert.Equal(t, selectBatch, settings.SelectBatchSize)
+	assert.Equal(t, mutationBatch, settings.MutationBatchSize)
+	assert.Equal(t, hardDelete, settings.HardDeleteBatchSize)
+
+	// global flags are never modified
+	assert.Equal(t, originalWriteBatch, *flags.WriteBatchSize)
+	assert.Equal(t, originalSelectBatch, *flags.SelectBatchSize)
+	assert.Equal(t, originalMutationBatch, *flags.MutationBatchSize)
+	assert.Equal(t, originalHardDeleteBatch, *flags.HardDeleteBatchSize)
+}
+
+func TestNewSettingsPartialOverride(t *testing.T) {
+	originalSelectBatch := *flags.SelectBatchSize
+	defer func() { *flags.SelectBatchSize = originalSelectBatch }()
+	*flags.SelectBatchSize = flags.SelectBatchSizeSetting.MinValue + 10
+
+	writeBatch := flags.WriteBatchSizeSetting.MinValue + 1
+	ds := &DestinationConfigurations{
+		WriteBatchSize: &writeBatch,
+	}
+	settings, err := NewSettings(ds)
+	assert.NoError(t, err)
+
+	assert.Equal(t, writeBatch, settings.WriteBatchSize)
+	assert.Equal(t, flags.SelectBatchSizeSetting.MinValue+10, settings.SelectBatchSize)
+}
+
+func uintPtr(v uint) *uint { return &v }
+
+func TestNewSettingsRejectsOutOfRange(t *testing.T) {
+	belowMin := flags.WriteBatchSizeSetting.MinValue - 1
+	settings, err := NewSettings(&DestinationConfigurations{WriteBatchSize: &belowMin})
+	assert.Nil(t, settings)
+	assert.ErrorContains(t, err, "out of allowed range")
+
+	aboveMax := flags.WriteBatchSizeSetting.MaxValue + 1
+	settings, err = NewSettings(&DestinationConfigurations{WriteBatchSize: &aboveMax})
+	assert.Nil(t, settings)
+	assert.ErrorContains(t, err, "out of allowed range")
+}
+
+func TestNewSettingsAcceptsBoundaryValues(t *testing.T) {
+	ds := &DestinationConfigurations{
+		WriteBatchSize:      uintPtr(flags.WriteBatchSizeSetting.MinValue),
+		SelectBatchSize:     uintPtr(flags.SelectBatchSizeSetting.MaxValue),
+		HardDeleteBatchSize: uintPtr(flags.HardDeleteBatchSizeSetting.MaxValue),
+	}
+	settings, err := NewSettings(ds)
+	assert.NoError(t, err)
+	assert.Equal(t, flags.WriteBatchSizeSetting.MinValue, settings.WriteBatchSize)
+	assert.Equal(t, flags.SelectBatchSizeSetting.MaxValue, settings.SelectBatchSize)
+	assert.Equal(t, flags.HardDeleteBatchSizeSetting.MaxValue, settings.HardDeleteBatchSize)
+}
+
+func TestNewSettingsAreIsolated(t *testing.T) {
+	first, err := NewSettings(&DestinationConfigurations{WriteBatchSize: uintPtr(10_000)})
+	assert.NoError(t, err)
+	second, err := NewSettings(&DestinationConfigurations{WriteBatchSize: uintPtr(20_000)})
+	assert.NoError(t, err)
+	third, err := NewSettings(nil)
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint(10_000), first.WriteBatchSize)
+	assert.Equal(t, uint(20_000), second.WriteBatchSize)
+	assert.Equal(t, *flags.WriteBatchSize, third.WriteBatchSize)
+}
+
+func TestNewSettingsServerSideCSVParsing(t *testing.T) {
+	settings, err := NewSettings(&DestinationConfigurations{})
+	assert.NoError(t, err)
+	assert.Equal(t, *flags.ServerSideCSVParsing, settings.ServerSideCSVParsing)
+
+	enabled := true
+	settings, err = NewSettings(&DestinationConfigurations{ServerSideCSVParsing: &enabled})
+	assert.NoError(t, err)
+	assert.True(t, settings.ServerSideCSVParsing)
+	assert.False(t, *flags.ServerSideCSVParsing)
+}
+
+func TestNewSettingsInsertDeduplication(t *testing.T) {
+	settings, err := NewSettings(&DestinationConfigurations{})
+	assert.NoError(t, err)
+	assert.Equal(t, *flags.InsertDeduplication, settings.InsertDeduplication)
+
+	enabled := true
+	settings, err = NewSettings(&DestinationConfigurations{InsertDeduplication: &enabled})
+	assert.NoError(t, err)
+	assert.True(t, settings.InsertDeduplication)
+	assert.False(t, *flags.InsertDeduplication)
+}
+
+func TestNewSettingsWriteQueueDepth(t *testing.T) {
+	settings, err := NewSettings(&DestinationConfigurations{})
+	assert.NoError(t, err)
+	assert.Equal(t, *flags.WriteQueueDepth, settings.WriteQueueDepth)
+
+	settings, err = NewSettings(&DestinationConfigurations{WriteQueueDepth: uintPtr(0)})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(0), settings.WriteQueueDepth)
+
+	settings, err = NewSettings(&DestinationConfigurations{WriteQueueDepth: uintPtr(flags.WriteQueueDepthSetting.MaxValue + 1)})
+	assert.Nil(t, settings)
+	assert.ErrorContains(t, err, "write_queue_depth: value 5 out of allowed range [0, 4]")
+}
+
+func TestNewSettingsParallelReplaceFiles(t *testing.T) {
+	settings, err := NewSettings(&DestinationConfigurations{})
+	assert.NoError(t, err)
+	assert.Equal(t, *flags.ParallelReplaceFiles, settings.ParallelReplaceFiles)
+
+	settings, err = NewSettings(&DestinationConfigurations{ParallelReplaceFiles: uintPtr(8)})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(8), settings.ParallelReplaceFiles)
+
+	settings, err = NewSettings(&DestinationConfigurations{ParallelReplaceFiles: uintPtr(0)})
+	assert.Nil(t, settings)
+	assert.ErrorContains(t, err, "parallel_replace_files: value 0 out of allowed range [1, 16]")
+}
@@ -0,0 +1,222 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"sort"
+	"strings"
+
+	"fivetran.com/fivetran_sdk/destination/common/types"
+)
+
+const (
+	storagePolicySetting    = "storage_policy"
+	indexGranularitySetting = "index_granularity"
+)
+
+var settingNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// TableConfigurations controls the optional CREATE TABLE clauses (see types.TableEngineOptions).
+// Defaults apply to all tables; Tables are keyed by "schema.table", and override the defaults field by field
+// (the Settings maps are merged).
+type TableConfigurations struct {
+	Defaults *TableConfiguration            `json:"defaults,omitempty"`
+	Tables   map[string]*TableConfiguration `json:"tables,omitempty"`
+}
+
+// TableConfiguration is a single entry of TableConfigurations; see types.TableEngineOptions for the meaning of the fields.
+// Settings values can be strings, numbers or booleans.
+type TableConfiguration struct {
+	PartitionBy          *string        `json:"partition_by,omitempty"`
+	OrderByPrefix        []string       `json:"order_by_prefix,omitempty"`
+	OrderBySuffix        []string       `json:"order_by_suffix,omitempty"`
+	PrimaryKey           []string       `json:"primary_key,omitempty"`
+	TTL                  *string        `json:"ttl,omitempty"`
+	StoragePolicy        *string        `json:"storage_policy,omitempty"`
+	IndexGranularity     *uint          `json:"index_granularity,omitempty"`
+	Settings             map[string]any `json:"settings,omitempty"`
+	IgnoreDefaultColumns *bool          `json:"ignore_default_columns,omitempty"`
+}
+
+// TableEngines holds the validated TableConfigurations, resolved for each configured table.
+type TableEngines struct {
+	defaults *types.TableEngineOptions
+	tables   map[string]*types.TableEngineOptions
+}
+
+// ForTable returns the CREATE TABLE options for the Fivetran table; nil if there are none. Safe to call on nil.
+func (e *TableEngines) ForTable(schemaName string, tableName string) *types.TableEngineOptions {
+	if e == nil {
+		return nil
+	}
+	if options, ok := e.tables[schemaName+"."+tableName]; ok {
+		return options
+	}
+	return e.defaults
+}
+
+// parseTableConfigurations validates the table configurations, and merges each table entry with the defaults.
+func parseTableConfigurations(tc *TableConfigurations) (*TableEngines, error) {
+	if tc == nil {
+		return nil, nil
+	}
+	defaults := tc.Defaults
+	if defaults == nil {
+		defaults = &TableConfiguration{}
+	}
+	engines := &TableEngines{tables: make(map[string]*types.TableEngineOptions, len(tc.Tables))}
+	var err error
+	if tc.Defaults != nil {
+		if engines.defaults, err = toTableEngineOptions(defaults); err != nil {
+			return nil, fmt.Errorf("defaults: %w", err)
+		}
+	}
+	for key, table := range tc.Tables {
+		schemaName, tableName, ok := strings.Cut(key, ".")
+		if !ok || schemaName == "" || tableName == "" {
+			return nil, fmt.Errorf("table key %s should be in the schema.table format", key)
+		}
+		if table == nil {
+			return nil, fmt.Errorf("table %s: configuration is empty", key)
+		}
+		if engines.tables[key], err = toTableEngineOptions(mergeTableConfigurations(defaults, table)); err != nil {
+			return nil, fmt.Errorf("table %s: %w", key, err)
+		}
+	}
+	return engines, nil
+}
+
+func mergeTableConfigurations(defaults *TableConfiguration, table *TableConfiguration) *TableConfiguration {
+	merged := *defaults
+	if table.PartitionBy != nil {
+		merged.PartitionBy = table.PartitionBy
+	}
+	if table.OrderByPrefix != nil {
+		merged.OrderByPrefix = table.OrderByPrefix
+	}
+	if table.OrderBySuffix != nil {
+		merged.OrderBySuffix = table.OrderBySuffix
+	}
+	if table.PrimaryKey != nil {
+		merged.PrimaryKey = table.PrimaryKey
+	}
+	if table.TTL != nil {
+		merged.TTL = table.TTL
+	}
+	if table.StoragePolicy != nil {
+		merged.StoragePolicy = table.StoragePolicy
+	}
+	if table.IndexGranularity != nil {
+		merged.IndexGranularity = table.IndexGranularity
+	}
+	if table.IgnoreDefaultColumns != nil {
+		merged.IgnoreDefaultColumns = table.IgnoreDefaultColumns
+	}
+	if table.Settings != nil {
+		merged.Settings = make(map[string]any, len(defaults.Settings)+len(table.Settings))
+		maps.Copy(merged.Settings, defaults.Settings)
+		maps.Copy(merged.Settings, table.Settings)
+	}
+	return &merged
+}
+
+func toTableEngineOptions(tc *TableConfiguration) (*types.TableEngineOptions, error) {
+	options := &types.TableEngineOptions{}
+	if tc.IgnoreDefaultColumns != nil {
+		options.IgnoreDefaultColumns = *tc.IgnoreDefaultColumns
+	}
+	var err error
+	if options.PartitionBy, err = validateExpression("partition_by", tc.PartitionBy); err != nil {
+		return nil, err
+	}
+	if options.TTL, err = validateExpression("ttl", tc.TTL); err != nil {
+		return nil, err
+	}
+	if options.OrderByPrefix, err = validateColumnNames("order_by_prefix", tc.OrderByPrefix); err != nil {
+		return nil, err
+	}
+	if options.OrderBySuffix, err = validateColumnNames("order_by_suffix", tc.OrderBySuffix); err != nil {
+		return nil, err
+	}
+	if options.PrimaryKey, err = validateColumnNames("primary_key", tc.PrimaryKey); err != nil {
+		return nil, err
+	}
+	// the rest of the ORDER BY is only known at CreateTable, see sql.GetCreateTableStatement
+	for i := 0; i < len(options.PrimaryKey) && i < len(options.OrderByPrefix); i++ {
+		if options.PrimaryKey[i] != options.OrderByPrefix[i] {
+			return nil, fmt.Errorf("primary_key should be a prefix of the ORDER BY, which starts with order_by_prefix")
+		}
+	}
+
+	settings := make(map[string]any, len(tc.Settings)+2)
+	names := make([]string, 0, len(tc.Settings))
+	for name := range tc.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := tc.Settings[name]
+		if !settingNameRegex.MatchString(name) {
+			return nil, fmt.Errorf("setting name %s is not valid", name)
+		}
+		if name == storagePolicySetting || name == indexGranularitySetting {
+			return nil, fmt.Errorf("setting %s should be set via the %s field", name, name)
+		}
+		switch value.(type) {
+		case string, float64, bool:
+			settings[name] = value
+		default:
+			return nil, fmt.Errorf("setting %s should be a string, a number or a boolean", name)
+		}
+	}
+	if tc.StoragePolicy != nil {
+		storagePolicy := strings.TrimSpace(*tc.StoragePolicy)
+		if storagePolicy == "" {
+			return nil, fmt.Errorf("storage_policy should not be empty")
+		}
+		settings[storagePolicySetting] = storagePolicy
+	}
+	if tc.IndexGranularity != nil {
+		if *tc.IndexGranularity == 0 {
+			return nil, fmt.Errorf("index_granularity should be greater than 0")
+		}
+		settings[indexGranularitySetting] = float64(*tc.IndexGranularity)
+	}
+	if len(settings) > 0 {
+		options.Settings = settings
+	}
+	return options, nil
+}
+
+func validateExpression(name string, expression *string) (string, error) {
+	if expression == nil {
+		return "", nil
+	}
+	value := strings.TrimSpace(*expression)
+	if value == "" {
+		return "", fmt.Errorf("%s should not be empty", name)
+	}
+	if strings.Contains(value, ";") {
+		return "", fmt.Errorf("%s should be a single expression without semicolons", name)
+	}
+	return value, nil
+}
+
+func validateColumnNames(name string, columns []string) ([]string, error) {
+	if columns == nil {
+		return nil, nil
+	}
+	result := make([]string, len(columns))
+	for i, column := range columns {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			return nil, fmt.Errorf("%s should not contain empty column names", name)
+		}
+		if strings.Contains(column, "`") {
+			return nil, fmt.Errorf("%s column %s should not contain backticks", name, column)
+		}
+		result[i] = column
+	}
+	return result, nil
+}
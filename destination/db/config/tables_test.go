@@ -0,0 +1,182 @@
+package config
+
+import (
+	"testing"
+
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAllWithTableConfigurations(t *testing.T) {
+	input := configWithAdvancedJSON(
+		map[string]string{"host": "my.host"},
+		`{"table_configurations": {
+			"defaults": {"storage_policy": "tiered", "settings": {"min_bytes_for_wide_part": 0, "ttl_only_drop_parts": true}},
+			"tables": {
+				"analytics.events": {
+					"partition_by": " toYYYYMM(created_at) ",
+					"order_by_prefix": ["tenant_id"],
+					"order_by_suffix": ["created_at"],
+					"primary_key": ["tenant_id"],
+					"ttl": "created_at + INTERVAL 2 YEAR",
+					"index_granularity": 4096,
+					"settings": {"ttl_only_drop_parts": false, "merge_with_ttl_timeout": "3600"}
+				},
+				"analytics.users": {"storage_policy": "default"}
+			}
+		}}`,
+	)
+	cfg, _, err := ParseAll(input)
+	require.NoError(t, err)
+
+	assert.Equal(t, &types.TableEngineOptions{
+		PartitionBy:   "toYYYYMM(created_at)",
+		OrderByPrefix: []string{"tenant_id"},
+		OrderBySuffix: []string{"created_at"},
+		PrimaryKey:    []string{"tenant_id"},
+		TTL:           "created_at + INTERVAL 2 YEAR",
+		Settings: map[string]any{
+			"storage_policy":          "tiered",
+			"index_granularity":       float64(4096),
+			"min_bytes_for_wide_part": float64(0),
+			"ttl_only_drop_parts":     false,
+			"merge_with_ttl_timeout":  "3600",
+		},
+	}, cfg.TableEngines.ForTable("analytics", "events"))
+
+	assert.Equal(t, &types.TableEngineOptions{
+		Settings: map[string]any{
+			"storage_policy":          "default",
+			"min_bytes_for_wide_part": float64(0),
+			"ttl_only_drop_parts":     true,
+		},
+	}, cfg.TableEngines.ForTable("analytics", "users"))
+
+	// tables without their own configuration use the defaults
+	defaults := &types.TableEngineOptions{
+		Settings: map[string]any{
+			"storage_policy":          "tiered",
+			"min_bytes_for_wide_part": float64(0),
+			"ttl_only_drop_parts":     true,
+		},
+	}
+	assert.Equal(t, defaults, cfg.TableEngines.ForTable("analytics", "orders"))
+	assert.Equal(t, defaults, cfg.TableEngines.ForTable("events", "analytics"))
+}
+
+func TestParseAllWithTableConfigurationsWithoutDefaults(t *testing.T) {
+	input := configWithAdvancedJSON(
+		map[string]string{"host": "my.host"},
+		`{"table_configurations": {"tables": {"analytics.events": {"partition_by": "toYYYYMM(created_at)"}}}}`,
+	)
+	cfg, _, err := ParseAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, &types.TableEngineOptions{PartitionBy: "toYYYYMM(created_at)"},
+		cfg.TableEngines.ForTable("analytics", "events"))
+	assert.Nil(t, cfg.TableEngines.ForTable("analytics", "orders"))
+
+	// no table configurations at all
+	cfg, _, err = ParseAll(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+	assert.Nil(t, cfg.TableEngines)
+	assert.Nil(t, cfg.TableEngines.ForTable("analytics", "events"))
+}
+
+func TestParseAllWithIgnoreDefaultColumns(t *testing.T) {
+	input := configWithAdvancedJSON(
+		map[string]string{"host": "my.host"},
+		`{"table_configurations": {
+			"defaults": {"ignore_default_columns": true},
+			"tables": {"analytics.events": {"ttl": "created_at + INTERVAL 1 YEAR"}, "analytics.users": {"ignore_default_columns": false}}
+		}}`,
+	)
+	cfg, _, err := ParseAll(input)
+	require.NoError(t, err)
+	assert.Equal(t, &types.TableEngineOptions{TTL: "created_at + INTERVAL 1 YEAR", IgnoreDefaultColumns: true},
+		cfg.TableEngines.ForTable("analytics", "events"))
+	assert.Equal(t, &types.TableEngineOptions{}, cfg.TableEngines.ForTable("analytics", "users"))
+	assert.Equal(t, &types.TableEngineOptions{IgnoreDefaultColumns: true}, cfg.TableEngines.ForTable("analytics", "orders"))
+}
+
+func TestParseAllInvalidTableConfigurations(t *testing.T) {
+	tests := []struct {
+		name          string
+		json          string
+		expectedError string
+	}{
+		{
+			name:          "table key without schema",
+			json:          `{"tables": {"events": {"ttl": "created_at + INTERVAL 1 YEAR"}}}`,
+			expectedError: "table key events should be in the schema.table format",
+		},
+		{
+			name:          "empty table configuration",
+			json:          `{"tables": {"analytics.events": null}}`,
+			expectedError: "table analytics.events: configuration is empty",
+		},
+		{
+			name:          "empty partition expression",
+			json:          `{"defaults": {"partition_by": " "}}`,
+			expectedError: "defaults: partition_by should not be empty",
+		},
+		{
+			name:          "multiple statements in TTL",
+			json:          `{"tables": {"analytics.events": {"ttl": "created_at + INTERVAL 1 YEAR; DROP TABLE foo"}}}`,
+			expectedError: "table analytics.events: ttl should be a single expression without semicolons",
+		},
+		{
+			name:          "empty ORDER BY column",
+			json:          `{"defaults": {"order_by_prefix": [""]}}`,
+			expectedError: "order_by_prefix should not contain empty column names",
+		},
+		{
+			name:          "backticks in ORDER BY column",
+			json:          "{\"defaults\": {\"order_by_suffix\": [\"a`b\"]}}",
+			expectedError: "order_by_suffix column a`b should not contain backticks",
+		},
+		{
+			name:          "primary key is not a prefix of the ORDER BY",
+			json:          `{"defaults": {"order_by_prefix": ["tenant_id"], "primary_key": ["created_at"]}}`,
+			expectedError: "primary_key should be a prefix of the ORDER BY",
+		},
+		{
+			name:          "zero index granularity",
+			json:          `{"defaults": {"index_granularity": 0}}`,
+			expectedError: "index_granularity should be greater than 0",
+		},
+		{
+			name:          "empty storage policy",
+			json:          `{"defaults": {"storage_policy": ""}}`,
+			expectedError: "storage_policy should not be empty",
+		},
+		{
+			name:          "storage policy in settings",
+			json:          `{"defaults": {"settings": {"storage_policy": "tiered"}}}`,
+			expectedError: "setting storage_policy should be set via the storage_policy field",
+		},
+		{
+			name:          "invalid setting name",
+			json:          `{"defaults": {"settings": {"index_granularity = 1, foo": 1}}}`,
+			expectedError: "setting name index_granularity = 1, foo is not valid",
+		},
+		{
+			name:          "invalid setting value",
+			json:          `{"tables": {"analytics.events": {"settings": {"merge_with_ttl_timeout": [1]}}}}`,
+			expectedError: "setting merge_with_ttl_timeout should be a string, a number or a boolean",
+		},
+		{
+			name:          "invalid merged configuration",
+			json:          `{"defaults": {"primary_key": ["id"]}, "tables": {"analytics.events": {"order_by_prefix": ["tenant_id"]}}}`,
+			expectedError: "table analytics.events: primary_key should be a prefix of the ORDER BY",
+		},
+	}
+	for _, test := range tests {
+		input := configWithAdvancedJSON(map[string]string{"host": "my.host"}, `{"table_configurations": `+test.json+`}`)
+		cfg, settings, err := ParseAll(input)
+		assert.Nil(t, cfg, "Test %s", test.name)
+		assert.Nil(t, settings, "Test %s", test.name)
+		assert.ErrorContains(t, err, "invalid table configurations", "Test %s", test.name)
+		assert.ErrorContains(t, err, test.expectedError, "Test %s", test.name)
+	}
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	TLSCACertificateKey     = "tls_ca_certificate"
+	TLSClientCertificateKey = "tls_client_certificate"
+	TLSClientKeyKey         = "tls_client_key"
+	TLSServerNameKey        = "tls_server_name"
+	TLSMinVersionKey        = "tls_min_version"
+)
+
+// Supported values of the TLSMinVersionKey field.
+const (
+	TLSVersion12 = "1.2"
+	TLSVersion13 = "1.3"
+)
+
+// TLSOptions are the optional TLS settings, required for self-hosted deployments
+// with certificates issued by an internal CA, or with mutual TLS authentication.
+// CACertificates = if set, only these CAs are trusted to verify the server certificate (instead of the system roots).
+// ClientCertificate = the certificate and the private key sent to the server for mTLS; nil if not set.
+// ServerName = overrides the host name used to verify the server certificate (and sent via SNI);
+// useful when the hosts are configured as IP addresses, or are behind a proxy.
+// MinVersion = the minimum accepted TLS version; 0 means the Go default (TLS 1.2).
+type TLSOptions struct {
+	CACertificates    []*x509.Certificate
+	ClientCertificate *tls.Certificate
+	ServerName        string
+	MinVersion        uint16
+}
+
+// TLSConfig returns the TLS configuration for the ClickHouse driver; nil if TLS is disabled (local mode).
+func (c *Config) TLSConfig() *tls.Config {
+	if c.Local {
+		return nil
+	}
+	tlsConfig := &tls.Config{InsecureSkipVerify: false}
+	if c.TLS == nil {
+		return tlsConfig
+	}
+	if len(c.TLS.CACertificates) > 0 {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		for _, cert := range c.TLS.CACertificates {
+			tlsConfig.RootCAs.AddCert(cert)
+		}
+	}
+	if c.TLS.ClientCertificate != nil {
+		tlsConfig.Certificates = []tls.Certificate{*c.TLS.ClientCertificate}
+	}
+	tlsConfig.ServerName = c.TLS.ServerName
+	tlsConfig.MinVersion = c.TLS.MinVersion
+	return tlsConfig
+}
+
+// CheckExpiry returns an error if any of the configured certificates is not valid at the given time.
+// The server only reports a generic TLS alert for an expired client certificate,
+// so it is checked upfront to give a clear error in the connection test. Safe to call on nil.
+func (o *TLSOptions) CheckExpiry(now time.Time) error {
+	if o == nil {
+		return nil
+	}
+	check := func(name string, cert *x509.Certificate) error {
+		if now.After(cert.NotAfter) {
+			return fmt.Errorf("%s %s expired on %s", name, cert.Subject, cert.NotAfter.UTC().Format(time.RFC3339))
+		}
+		if now.Before(cert.NotBefore) {
+			return fmt.Errorf("%s %s is not valid before %s", name, cert.Subject, cert.NotBefore.UTC().Format(time.RFC3339))
+		}
+		return nil
+	}
+	for _, cert := range o.CACertificates {
+		if err := check("CA certificate", cert); err != nil {
+			return err
+		}
+	}
+	if o.ClientCertificate != nil && o.ClientCertificate.Leaf != nil {
+		if err := check("client certificate", o.ClientCertificate.Leaf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseTLSOptions parses the TLS fields of the Fivetran configuration map; returns nil if none of them are set.
+func parseTLSOptions(configuration map[string]string) (*TLSOptions, error) {
+	caPEM, err := getPEM(configuration, TLSCACertificateKey)
+	if err != nil {
+		return nil, err
+	}
+	certPEM, err := getPEM(configuration, TLSClientCertificateKey)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := getPEM(configuration, TLSClientKeyKey)
+	if err != nil {
+		return nil, err
+	}
+	serverName, err := validateTLSServerName(getWithDefault(configuration, TLSServerNameKey, "", true))
+	if err != nil {
+		return nil, err
+	}
+	minVersion, err := validateTLSMinVersion(getWithDefault(configuration, TLSMinVersionKey, "", true))
+	if err != nil {
+		return nil, err
+	}
+	if caPEM == nil && certPEM == nil && keyPEM == nil && serverName == "" && minVersion == 0 {
+		return nil, nil
+	}
+
+	options := &TLSOptions{
+		ServerName: serverName,
+		MinVersion: minVersion,
+	}
+	if caPEM != nil {
+		if options.CACertificates, err = parseCertificates(caPEM); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", TLSCACertificateKey, err)
+		}
+	}
+	if (certPEM == nil) != (keyPEM == nil) {
+		return nil, fmt.Errorf("%s and %s should be set together", TLSClientCertificateKey, TLSClientKeyKey)
+	}
+	if certPEM != nil {
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s or %s: %w", TLSClientCertificateKey, TLSClientKeyKey, err)
+		}
+		options.ClientCertificate = &cert
+	}
+	return options, nil
+}
+
+// getPEM returns the PEM-encoded value of the key, or nil if it is not set.
+// The value is either pasted as is, or uploaded as a file (then it is base64-encoded by Fivetran's UploadField).
+func getPEM(configuration map[string]string, key string) ([]byte, error) {
+	value := strings.TrimSpace(getWithDefault(configuration, key, "", false))
+	if value == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(value, "-----BEGIN") {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s should be either PEM-encoded or a base64-encoded PEM file", key)
+		}
+		value = strings.TrimSpace(string(decoded))
+	}
+	if block, _ := pem.Decode([]byte(value)); block == nil {
+		return nil, fmt.Errorf("%s does not contain a PEM block", key)
+	}
+	return []byte(value), nil
+}
+
+func parseCertificates(bundle []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, bundle = pem.Decode(bundle)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found")
+	}
+	return certs, nil
+}
+
+func validateTLSServerName(serverName string) (string, error) {
+	if strings.ContainsAny(serverName, ":/ ,") {
+		return "", fmt.Errorf("TLS server name %s should be a host name without protocol, port or path", serverName)
+	}
+	return serverName, nil
+}
+
+func validateTLSMinVersion(version string) (uint16, error) {
+	switch version {
+	case "":
+		return 0, nil
+	case TLSVersion12:
+		return tls.VersionTLS12, nil
+	case TLSVersion13:
+		return tls.VersionTLS13, nil
+	}
+	return 0, fmt.Errorf("TLS version %s is not supported, expected %s or %s", version, TLSVersion12, TLSVersion13)
+}
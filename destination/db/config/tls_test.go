@@ -0,0 +1,182 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	certNotBefore = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	certNotAfter  = time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func TestParseTLSOptionsNotSet(t *testing.T) {
+	parsed, err := Parse(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+	assert.Nil(t, parsed.TLS)
+	assert.Equal(t, &tls.Config{InsecureSkipVerify: false}, parsed.TLSConfig())
+
+	parsed, err = Parse(map[string]string{"host": "my.host", "local": "true", TLSServerNameKey: "my.server"})
+	require.NoError(t, err)
+	assert.Nil(t, parsed.TLSConfig())
+}
+
+func TestParseTLSOptions(t *testing.T) {
+	caPEM, caCert, _ := generateTestCertificate(t, "My CA", true)
+	certPEM, _, keyPEM := generateTestCertificate(t, "fivetran", false)
+
+	parsed, err := Parse(map[string]string{
+		"host":                  "10.0.0.1,10.0.0.2",
+		TLSCACertificateKey:     string(caPEM),
+		TLSClientCertificateKey: base64.StdEncoding.EncodeToString(certPEM), // uploaded file
+		TLSClientKeyKey:         string(keyPEM),
+		TLSServerNameKey:        " clickhouse.internal ",
+		TLSMinVersionKey:        "1.3",
+	})
+	require.NoError(t, err)
+	require.NotNil(t, parsed.TLS)
+	assert.Equal(t, []*x509.Certificate{caCert}, parsed.TLS.CACertificates)
+	require.NotNil(t, parsed.TLS.ClientCertificate)
+	assert.Equal(t, "fivetran", parsed.TLS.ClientCertificate.Leaf.Subject.CommonName)
+	assert.Equal(t, "clickhouse.internal", parsed.TLS.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS13), parsed.TLS.MinVersion)
+
+	tlsConfig := parsed.TLSConfig()
+	assert.False(t, tlsConfig.InsecureSkipVerify)
+	assert.True(t, tlsConfig.RootCAs.Equal(certPool(caCert)))
+	assert.Len(t, tlsConfig.Certificates, 1)
+	assert.Equal(t, "clickhouse.internal", tlsConfig.ServerName)
+	assert.Equal(t, uint16(tls.VersionTLS13), tlsConfig.MinVersion)
+
+	// only some of the options are set: the system roots are used
+	parsed, err = Parse(map[string]string{"host": "my.host", TLSMinVersionKey: "1.2"})
+	require.NoError(t, err)
+	assert.Equal(t, &TLSOptions{MinVersion: tls.VersionTLS12}, parsed.TLS)
+	assert.Nil(t, parsed.TLSConfig().RootCAs)
+	assert.Empty(t, parsed.TLSConfig().Certificates)
+}
+
+func TestParseTLSOptionsErrors(t *testing.T) {
+	certPEM, _, keyPEM := generateTestCertificate(t, "fivetran", false)
+	_, _, otherKeyPEM := generateTestCertificate(t, "other", false)
+
+	tests := []struct {
+		name          string
+		configuration map[string]string
+		expectedError string
+	}{
+		{
+			name:          "CA is neither PEM nor base64",
+			configuration: map[string]string{TLSCACertificateKey: "not a certificate"},
+			expectedError: "tls_ca_certificate should be either PEM-encoded or a base64-encoded PEM file",
+		},
+		{
+			name:          "CA is base64, but not PEM",
+			configuration: map[string]string{TLSCACertificateKey: base64.StdEncoding.EncodeToString([]byte("foo"))},
+			expectedError: "tls_ca_certificate does not contain a PEM block",
+		},
+		{
+			name:          "CA contains only a key",
+			configuration: map[string]string{TLSCACertificateKey: string(keyPEM)},
+			expectedError: "invalid tls_ca_certificate: no certificates found",
+		},
+		{
+			name:          "client certificate without a key",
+			configuration: map[string]string{TLSClientCertificateKey: string(certPEM)},
+			expectedError: "tls_client_certificate and tls_client_key should be set together",
+		},
+		{
+			name:          "client key without a certificate",
+			configuration: map[string]string{TLSClientKeyKey: string(keyPEM)},
+			expectedError: "tls_client_certificate and tls_client_key should be set together",
+		},
+		{
+			name:          "client certificate does not match the key",
+			configuration: map[string]string{TLSClientCertificateKey: string(certPEM), TLSClientKeyKey: string(otherKeyPEM)},
+			expectedError: "invalid tls_client_certificate or tls_client_key: tls: private key does not match public key",
+		},
+		{
+			name:          "server name with port",
+			configuration: map[string]string{TLSServerNameKey: "my.host:9440"},
+			expectedError: "TLS server name my.host:9440 should be a host name without protocol, port or path",
+		},
+		{
+			name:          "unsupported min version",
+			configuration: map[string]string{TLSMinVersionKey: "1.1"},
+			expectedError: "TLS version 1.1 is not supported, expected 1.2 or 1.3",
+		},
+	}
+	for _, test := range tests {
+		actual, err := Parse(test.configuration)
+		assert.ErrorContains(t, err, test.expectedError, "Test %s", test.name)
+		assert.Nil(t, actual, "Test %s", test.name)
+	}
+}
+
+func TestTLSOptionsCheckExpiry(t *testing.T) {
+	caPEM, _, _ := generateTestCertificate(t, "My CA", true)
+	certPEM, _, keyPEM := generateTestCertificate(t, "fivetran", false)
+	parsed, err := Parse(map[string]string{
+		TLSCACertificateKey:     string(caPEM),
+		TLSClientCertificateKey: string(certPEM),
+		TLSClientKeyKey:         string(keyPEM),
+	})
+	require.NoError(t, err)
+
+	assert.NoError(t, parsed.TLS.CheckExpiry(certNotBefore.Add(time.Hour)))
+	assert.ErrorContains(t, parsed.TLS.CheckExpiry(certNotAfter.Add(time.Hour)),
+		"CA certificate CN=My CA expired on 2035-01-01T00:00:00Z")
+	assert.ErrorContains(t, parsed.TLS.CheckExpiry(certNotBefore.Add(-time.Hour)),
+		"CA certificate CN=My CA is not valid before 2025-01-01T00:00:00Z")
+
+	parsed.TLS.CACertificates = nil
+	assert.ErrorContains(t, parsed.TLS.CheckExpiry(certNotAfter.Add(time.Hour)),
+		"client certificate CN=fivetran expired on 2035-01-01T00:00:00Z")
+
+	// not set
+	assert.NoError(t, (*TLSOptions)(nil).CheckExpiry(certNotAfter.Add(time.Hour)))
+}
+
+// generateTestCertificate returns a PEM-encoded self-signed certificate, the parsed certificate,
+// and the PEM-encoded private key.
+func generateTestCertificate(t *testing.T, commonName string, isCA bool) ([]byte, *x509.Certificate, []byte) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             certNotBefore,
+		NotAfter:              certNotAfter,
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		cert,
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func certPool(certs ...*x509.Certificate) *x509.CertPool {
+	pool := x509.NewCertPool()
+	for _, cert := range certs {
+		pool.AddCert(cert)
+	}
+	return pool
+}
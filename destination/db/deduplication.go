@@ -0,0 +1,160 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"maps"
+
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/sql"
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// deduplicationTokenSetting is the ClickHouse setting that makes the inserts with the same token idempotent:
+// the blocks inserted with a token that was already used for the table are skipped by the server.
+// https://clickhouse.com/docs/en/operations/settings/settings#insert_deduplication_token
+const deduplicationTokenSetting = "insert_deduplication_token"
+
+// nonReplicatedDeduplicationWindowSetting is the number of the last inserted blocks of a table with a non-replicated
+// MergeTree engine whose tokens are kept by ClickHouse. It is 0 by default, which makes the tokens a no-op for such
+// tables, so it is set to the default replicated_deduplication_window for the tables created by the destination,
+// see withDeduplicationWindow. The existing tables are not altered, so they need the setting to be added manually.
+const (
+	nonReplicatedDeduplicationWindowSetting = "non_replicated_deduplication_window"
+	nonReplicatedDeduplicationWindow        = 1000
+)
+
+// deduplicationTokens derives the insert_deduplication_token of every batch inserted from a replace file
+// (see config.Settings.InsertDeduplication) from the ordinal of the batch in the file, and a hash of the file header
+// and the batch rows. The tokens are deterministic, so a batch that is sent again, either when the insert is retried
+// after a network error, or when Fivetran sends the same file again, possibly under another name,
+// is only written once, even if the previous attempt reached the server.
+//
+// The rows are added one at a time as they are read, and next returns the token for the rows added since
+// the previous call. All methods are safe to call on nil, which means that the tokens are disabled.
+type deduplicationTokens struct {
+	header  []string
+	ordinal int
+	hash    hash.Hash
+	// scratch is reused to encode the values before they are hashed
+	scratch []byte
+}
+
+// newDeduplicationTokens returns the tokens for the batches of the file; nil if insert deduplication is disabled.
+func (conn *ClickHouseConnection) newDeduplicationTokens(header []string) *deduplicationTokens {
+	if !conn.settings.InsertDeduplication {
+		return nil
+	}
+	tokens := &deduplicationTokens{
+		header: header,
+		hash:   sha256.New(),
+	}
+	tokens.addRow(header)
+	return tokens
+}
+
+// addRow adds a row of CSV values to the current batch.
+func (t *deduplicationTokens) addRow(row []string) {
+	if t == nil {
+		return
+	}
+	for _, value := range row {
+		// the values are prefixed with their length, so that different rows can't produce the same input
+		t.scratch = binary.AppendUvarint(t.scratch[:0], uint64(len(value)))
+		t.scratch = append(t.scratch, value...)
+		t.hash.Write(t.scratch)
+	}
+}
+
+// addTypedRow adds a row of typed values (see files.TypedBatchFileReader) to the current batch.
+func (t *deduplicationTokens) addTypedRow(row []any) {
+	if t == nil {
+		return
+	}
+	for _, value := range row {
+		if value == nil {
+			t.hash.Write([]byte{0})
+			continue
+		}
+		// not NULL, followed by the length and the formatted value
+		t.scratch = fmt.Append(append(t.scratch[:0], 1, 0, 0, 0, 0, 0, 0, 0, 0), value)
+		binary.LittleEndian.PutUint64(t.scratch[1:9], uint64(len(t.scratch)-9))
+		t.hash.Write(t.scratch)
+	}
+}
+
+// addRaw adds the rows as they are in the file (see files.RawBatchFileReader) to the current batch.
+func (t *deduplicationTokens) addRaw(rows []byte) {
+	if t == nil {
+		return
+	}
+	t.hash.Write(rows)
+}
+
+// next returns the token of the current batch, and starts the next one; empty if the tokens are disabled.
+func (t *deduplicationTokens) next() string {
+	if t == nil {
+		return ""
+	}
+	sum := t.hash.Sum(nil)
+	t.ordinal++
+	t.hash.Reset()
+	t.addRow(t.header)
+	return fmt.Sprintf("%d-%s", t.ordinal, hex.EncodeToString(sum[:16]))
+}
+
+// withDeduplicationWindow returns the engine options of a table created with insert deduplication enabled:
+// if the table engine is not replicated (see sql.IsReplicatedEngine), non_replicated_deduplication_window is added
+// to the table settings, unless it is already configured. It is a MergeTree setting, so it is accepted in ClickHouse
+// Cloud as well, where the tables are replicated regardless. The options are shared by the tables,
+// so they are copied rather than modified.
+func (conn *ClickHouseConnection) withDeduplicationWindow(options *types.TableEngineOptions) *types.TableEngineOptions {
+	if !conn.settings.InsertDeduplication || sql.IsReplicatedEngine(conn.cluster) {
+		return options
+	}
+	result := &types.TableEngineOptions{}
+	if options != nil {
+		if _, ok := options.Settings[nonReplicatedDeduplicationWindowSetting]; ok {
+			return options
+		}
+		*result = *options
+	}
+	result.Settings = maps.Clone(result.Settings)
+	if result.Settings == nil {
+		result.Settings = make(map[string]any, 1)
+	}
+	result.Settings[nonReplicatedDeduplicationWindowSetting] = float64(nonReplicatedDeduplicationWindow)
+	return result
+}
+
+// withDeduplicationToken returns the context for the insert of a batch with the token, and the other insert settings,
+// as clickhouse.WithSettings replaces the settings of the context. The context is not changed if there are neither.
+func withDeduplicationToken(ctx context.Context, settings clickhouse.Settings, token string) context.Context {
+	if token == "" && settings == nil {
+		return ctx
+	}
+	settings = maps.Clone(settings)
+	if token != "" {
+		if settings == nil {
+			settings = clickhouse.Settings{}
+		}
+		settings[deduplicationTokenSetting] = token
+	}
+	return withInsertSettings(ctx, settings)
+}
+
+// withInsertSettings adds the settings to the queries executed with the context; replaced in tests,
+// as the driver doesn't expose the settings of a context.
+var withInsertSettings = func(ctx context.Context, settings clickhouse.Settings) context.Context {
+	return clickhouse.Context(ctx, clickhouse.WithSettings(settings))
+}
+
+// tokenBatch is a batch read by pipeline, with its insert_deduplication_token (see deduplicationTokens.next).
+type tokenBatch[T any] struct {
+	rows  T
+	token string
+}
@@ -0,0 +1,226 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"fivetran.com/fivetran_sdk/destination/common/files"
+	"fivetran.com/fivetran_sdk/destination/common/retry"
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type insertSettingsKey struct{}
+
+// recordInsertSettings keeps the settings passed to withInsertSettings in the context until the end of the test,
+// so that they can be checked with recordedInsertSettings.
+func recordInsertSettings(t *testing.T) {
+	original := withInsertSettings
+	withInsertSettings = func(ctx context.Context, settings clickhouse.Settings) context.Context {
+		return context.WithValue(original(ctx, settings), insertSettingsKey{}, settings)
+	}
+	t.Cleanup(func() { withInsertSettings = original })
+}
+
+func recordedInsertSettings(ctx context.Context) clickhouse.Settings {
+	settings, _ := ctx.Value(insertSettingsKey{}).(clickhouse.Settings)
+	return settings
+}
+
+func tokensOf(tokens *deduplicationTokens, rows ...[]string) string {
+	for _, row := range rows {
+		tokens.addRow(row)
+	}
+	return tokens.next()
+}
+
+func TestDeduplicationTokens(t *testing.T) {
+	conn := &ClickHouseConnection{settings: &config.Settings{InsertDeduplication: true}}
+	header := []string{"id", "name"}
+	tokens := conn.newDeduplicationTokens(header)
+	first := tokensOf(tokens, []string{"1", "foo"}, []string{"2", "bar"})
+	second := tokensOf(tokens, []string{"3", "baz"})
+	assert.True(t, strings.HasPrefix(first, "1-"), first)
+	assert.True(t, strings.HasPrefix(second, "2-"), second)
+	assert.Len(t, first, len("1-")+32)
+
+	// the same rows produce the same tokens, as the file can be sent again under another name
+	tokens = conn.newDeduplicationTokens(header)
+	assert.Equal(t, first, tokensOf(tokens, []string{"1", "foo"}, []string{"2", "bar"}))
+	assert.Equal(t, second, tokensOf(tokens, []string{"3", "baz"}))
+
+	for name, token := range map[string]string{
+		"header":         tokensOf(conn.newDeduplicationTokens([]string{"id", "title"}), []string{"1", "foo"}, []string{"2", "bar"}),
+		"value":          tokensOf(conn.newDeduplicationTokens(header), []string{"1", "foo"}, []string{"2", "baz"}),
+		"value boundary": tokensOf(conn.newDeduplicationTokens(header), []string{"1", "foo"}, []string{"2b", "ar"}),
+		"rows":           tokensOf(conn.newDeduplicationTokens(header), []string{"1", "foo"}),
+	} {
+		assert.NotEqual(t, first, token, name)
+		assert.True(t, strings.HasPrefix(token, "1-"), name)
+	}
+
+	typed := conn.newDeduplicationTokens(header)
+	typed.addTypedRow([]any{int64(1), nil})
+	nullToken := typed.next()
+	typed = conn.newDeduplicationTokens(header)
+	typed.addTypedRow([]any{int64(1), ""})
+	assert.NotEqual(t, nullToken, typed.next())
+
+	raw := conn.newDeduplicationTokens(header)
+	raw.addRaw([]byte("1,foo\n"))
+	rawToken := raw.next()
+	raw.addRaw([]byte("1,foo\n"))
+	assert.Equal(t, rawToken[len("1-"):], raw.next()[len("2-"):])
+
+	// disabled
+	conn.settings.InsertDeduplication = false
+	tokens = conn.newDeduplicationTokens(header)
+	assert.Nil(t, tokens)
+	assert.Equal(t, "", tokensOf(tokens, []string{"1", "foo"}))
+	tokens.addTypedRow([]any{int64(1)})
+	tokens.addRaw([]byte("1,foo\n"))
+}
+
+func TestWithDeduplicationToken(t *testing.T) {
+	recordInsertSettings(t)
+	ctx := context.Background()
+	assert.Equal(t, ctx, withDeduplicationToken(ctx, nil, ""))
+	assert.Equal(t, clickhouse.Settings{"insert_deduplication_token": "t1"},
+		recordedInsertSettings(withDeduplicationToken(ctx, nil, "t1")))
+
+	settings := clickhouse.Settings{"date_time_input_format": "best_effort"}
+	assert.Equal(t, clickhouse.Settings{"date_time_input_format": "best_effort", "insert_deduplication_token": "t1"},
+		recordedInsertSettings(withDeduplicationToken(ctx, settings, "t1")))
+	assert.Equal(t, settings, recordedInsertSettings(withDeduplicationToken(ctx, settings, "")))
+	// the settings are not modified
+	assert.Equal(t, clickhouse.Settings{"date_time_input_format": "best_effort"}, settings)
+}
+
+// replaceWithFailedSends inserts the history mode file in batches of 2 rows, failing the first failedSends sends
+// with a network error, and returns the insert_deduplication_token of every send attempt.
+func replaceWithFailedSends(t *testing.T, byRow bool, insertDeduplication bool, failedSends int) []string {
+	mock := &batchingConn{t: t, names: []string{"id", "_fivetran_start"},
+		columnTypes: []string{"Int32", "DateTime64(9, 'UTC')"}, failedSends: failedSends}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{
+		WriteBatchSize:      2,
+		WriteQueueDepth:     1,
+		InsertDeduplication: insertDeduplication,
+		Retry:               retry.Settings{MaxRetries: 3, InitialRetryDelayMilliseconds: 1, MaxRetryDelayMilliseconds: 1},
+	}}
+	csvColumns := historyModeCSVColumns()
+	csvColumns.All[1].TableIndex = 1
+
+	reader := openHistoryModeReader(t)
+	defer reader.Close()
+	var batchReader files.BatchFileReader = reader
+	if byRow {
+		batchReader = rowsReader{reader}
+	}
+	totalRows, err := conn.ReplaceBatch(context.Background(), "tester", historyModeTable(), batchReader, csvColumns, "my-null-str")
+	require.NoError(t, err)
+	require.Equal(t, 5, totalRows)
+	tokens := make([]string, len(mock.batches))
+	for i, batch := range mock.batches {
+		if token, ok := batch.settings["insert_deduplication_token"]; ok {
+			tokens[i] = token.(string)
+		}
+	}
+	return tokens
+}
+
+func TestReplaceBatchDeduplicationRetries(t *testing.T) {
+	recordInsertSettings(t)
+	for _, byRow := range []bool{false, true} {
+		tokens := replaceWithFailedSends(t, byRow, true, 0)
+		require.Len(t, tokens, 3, "by row: %t", byRow)
+		assert.NotEqual(t, tokens[0], tokens[1])
+		assert.NotEqual(t, tokens[1], tokens[2])
+		for i, token := range tokens {
+			assert.True(t, strings.HasPrefix(token, fmt.Sprintf("%d-", i+1)), token)
+		}
+
+		// a batch that failed mid-send is sent again with the same token, so that ClickHouse skips it
+		// if it was already written; the batch that is sent by Fivetran again also has the same token
+		retried := replaceWithFailedSends(t, byRow, true, 1)
+		assert.Equal(t, append([]string{tokens[0]}, tokens...), retried, "by row: %t", byRow)
+
+		retried = replaceWithFailedSends(t, byRow, true, 2)
+		assert.Equal(t, append([]string{tokens[0], tokens[0]}, tokens...), retried, "by row: %t", byRow)
+
+		assert.Equal(t, []string{"", "", ""}, replaceWithFailedSends(t, byRow, false, 0))
+	}
+	// the tokens don't depend on how the rows are parsed
+	assert.Equal(t, replaceWithFailedSends(t, false, true, 0), replaceWithFailedSends(t, true, true, 0))
+}
+
+func TestReplaceBatchServerSideCSVDeduplication(t *testing.T) {
+	recordInsertSettings(t)
+	mock := &recordingConn{}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{
+		WriteBatchSize:       3,
+		ServerSideCSVParsing: true,
+		InsertDeduplication:  true,
+	}}
+	reader := openSyncedReader(t)
+	defer reader.Close()
+	totalRows, err := conn.ReplaceBatch(context.Background(), "tester", historyModeTable(), reader, syncedCSVColumns(), "my-null-str")
+	require.NoError(t, err)
+	assert.Equal(t, 5, totalRows)
+	require.Len(t, mock.settings, 2)
+	for i, settings := range mock.settings {
+		// the CSV input format settings are kept
+		assert.Equal(t, "my-null-str", settings["format_csv_null_representation"])
+		token, ok := settings["insert_deduplication_token"].(string)
+		assert.True(t, ok)
+		assert.True(t, strings.HasPrefix(token, fmt.Sprintf("%d-", i+1)), token)
+	}
+	assert.NotEqual(t, mock.settings[0]["insert_deduplication_token"], mock.settings[1]["insert_deduplication_token"])
+}
+
+func TestCreateTableDeduplicationWindow(t *testing.T) {
+	tableDescription := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Int32", IsPrimaryKey: true},
+		{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+	})
+	createStatement := func(conn *ClickHouseConnection, options *types.TableEngineOptions) string {
+		mock := &recordingConn{}
+		conn.Conn = mock
+		require.NoError(t, conn.createTable(context.Background(), "tester", "users", tableDescription, options, createTable))
+		require.Len(t, mock.statements, 1)
+		return mock.statements[0]
+	}
+	// the engine is not replicated without a cluster, whether the server is local or not
+	for _, isLocal := range []bool{true, false} {
+		conn := &ClickHouseConnection{isLocal: isLocal, settings: &config.Settings{InsertDeduplication: true}}
+		assert.True(t, strings.HasSuffix(createStatement(conn, nil),
+			"ENGINE = ReplacingMergeTree(`_fivetran_synced`) ORDER BY (`id`) SETTINGS non_replicated_deduplication_window = 1000"))
+	}
+	conn := &ClickHouseConnection{settings: &config.Settings{InsertDeduplication: true}}
+
+	// the other options are kept, and the shared options are not modified
+	options := &types.TableEngineOptions{
+		TTL:      "`_fivetran_synced` + INTERVAL 1 YEAR",
+		Settings: map[string]any{"index_granularity": float64(4096)},
+	}
+	assert.True(t, strings.HasSuffix(createStatement(conn, options),
+		"TTL `_fivetran_synced` + INTERVAL 1 YEAR SETTINGS index_granularity = 4096, non_replicated_deduplication_window = 1000"))
+	assert.Equal(t, map[string]any{"index_granularity": float64(4096)}, options.Settings)
+
+	// the configured window is used as is
+	options = &types.TableEngineOptions{Settings: map[string]any{"non_replicated_deduplication_window": float64(100)}}
+	assert.True(t, strings.HasSuffix(createStatement(conn, options), "SETTINGS non_replicated_deduplication_window = 100"))
+
+	// the tables are replicated on the self-hosted clusters
+	for name, conn := range map[string]*ClickHouseConnection{
+		"disabled": {settings: &config.Settings{}},
+		"cluster": {cluster: &types.Cluster{Name: "my_cluster"},
+			settings: &config.Settings{InsertDeduplication: true}},
+	} {
+		assert.NotContains(t, createStatement(conn, nil), "non_replicated_deduplication_window", name)
+	}
+}
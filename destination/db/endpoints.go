@@ -0,0 +1,129 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"fivetran.com/fivetran_sdk/destination/common/log"
+	"fivetran.com/fivetran_sdk/destination/common/retry"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// hostHealth is shared by all connections of the process, as the pooled driver.Conn (see connPool) dials
+// a host again for each of its underlying connections, and a host that went down during a sync
+// should not slow down the following dials and calls of the same sync.
+var hostHealth = newHostHealthTracker(time.Now)
+
+// hostHealthTracker remembers the hosts that recently failed to accept a connection.
+// Such hosts are tried only after the healthy ones, until the cooldown expires
+// (or until a connection to them succeeds again).
+type hostHealthTracker struct {
+	mu             sync.Mutex
+	unhealthyUntil map[string]time.Time
+	now            func() time.Time
+}
+
+func newHostHealthTracker(now func() time.Time) *hostHealthTracker {
+	return &hostHealthTracker{
+		unhealthyUntil: make(map[string]time.Time),
+		now:            now,
+	}
+}
+
+func (h *hostHealthTracker) markFailed(addr string, cooldown time.Duration) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.unhealthyUntil[addr] = h.now().Add(cooldown)
+}
+
+func (h *hostHealthTracker) markHealthy(addr string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	delete(h.unhealthyUntil, addr)
+}
+
+// order returns the addresses with the healthy hosts first; the relative order of the addresses is preserved.
+func (h *hostHealthTracker) order(addrs []string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	now := h.now()
+	healthy := make([]string, 0, len(addrs))
+	var unhealthy []string
+	for _, addr := range addrs {
+		if until, ok := h.unhealthyUntil[addr]; ok && now.Before(until) {
+			unhealthy = append(unhealthy, addr)
+		} else {
+			healthy = append(healthy, addr)
+		}
+	}
+	return append(healthy, unhealthy...)
+}
+
+// toConnOpenStrategy maps config.ConnectionStrategyKey values to the driver strategy.
+func toConnOpenStrategy(strategy string) clickhouse.ConnOpenStrategy {
+	switch strategy {
+	case config.ConnectionStrategyRoundRobin:
+		return clickhouse.ConnOpenRoundRobin
+	case config.ConnectionStrategyRandom:
+		return clickhouse.ConnOpenRandom
+	default:
+		return clickhouse.ConnOpenInOrder
+	}
+}
+
+// strategyOrder returns the addresses in the order defined by the connection strategy, same as clickhouse.DefaultDialStrategy.
+func strategyOrder(addrs []string, strategy clickhouse.ConnOpenStrategy, connID int) []string {
+	if len(addrs) == 0 {
+		return addrs
+	}
+	offset := 0
+	switch strategy {
+	case clickhouse.ConnOpenRoundRobin:
+		offset = connID % len(addrs)
+	case clickhouse.ConnOpenRandom:
+		offset = rand.Intn(len(addrs)) //nolint:gosec
+	}
+	result := make([]string, len(addrs))
+	for i := range addrs {
+		result[i] = addrs[(offset+i)%len(addrs)]
+	}
+	return result
+}
+
+// healthAwareDialStrategy is a clickhouse.Options.DialStrategy that tries the hosts in the configured order,
+// skipping to the end of the list the hosts that recently failed with a network error (see hostHealthTracker).
+// Together with the driver dropping the broken connections, losing a host during a sync
+// costs only a retry of the failed operation (see retry.OnNetError).
+func healthAwareDialStrategy(
+	ctx context.Context,
+	connID int,
+	opt *clickhouse.Options,
+	dial clickhouse.Dial,
+) (clickhouse.DialResult, error) {
+	var errs []error
+	for _, addr := range hostHealth.order(strategyOrder(opt.Addr, opt.ConnOpenStrategy, connID)) {
+		result, err := dial(ctx, addr, opt)
+		if err == nil {
+			hostHealth.markHealthy(addr)
+			return result, nil
+		}
+		if retry.IsNetError(err) {
+			log.Warn(fmt.Sprintf("Failed to connect to ClickHouse host %s, trying the next host if available: %v", addr, err))
+			hostHealth.markFailed(addr, *flags.HostFailureCooldown)
+		}
+		errs = append(errs, err)
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(errs) == 0 {
+		return clickhouse.DialResult{}, fmt.Errorf("no ClickHouse hosts to connect to")
+	}
+	return clickhouse.DialResult{}, errors.Join(errs...)
+}
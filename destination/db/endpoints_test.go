@@ -0,0 +1,106 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToConnOpenStrategy(t *testing.T) {
+	assert.Equal(t, clickhouse.ConnOpenInOrder, toConnOpenStrategy(config.ConnectionStrategyInOrder))
+	assert.Equal(t, clickhouse.ConnOpenRoundRobin, toConnOpenStrategy(config.ConnectionStrategyRoundRobin))
+	assert.Equal(t, clickhouse.ConnOpenRandom, toConnOpenStrategy(config.ConnectionStrategyRandom))
+	assert.Equal(t, clickhouse.ConnOpenInOrder, toConnOpenStrategy(""))
+}
+
+func TestStrategyOrder(t *testing.T) {
+	addrs := []string{"a:9000", "b:9000", "c:9000"}
+	assert.Equal(t, addrs, strategyOrder(addrs, clickhouse.ConnOpenInOrder, 1))
+	assert.Equal(t, addrs, strategyOrder(addrs, clickhouse.ConnOpenInOrder, 2))
+	assert.Equal(t, []string{"b:9000", "c:9000", "a:9000"}, strategyOrder(addrs, clickhouse.ConnOpenRoundRobin, 1))
+	assert.Equal(t, []string{"c:9000", "a:9000", "b:9000"}, strategyOrder(addrs, clickhouse.ConnOpenRoundRobin, 2))
+	assert.Equal(t, addrs, strategyOrder(addrs, clickhouse.ConnOpenRoundRobin, 3))
+	assert.ElementsMatch(t, addrs, strategyOrder(addrs, clickhouse.ConnOpenRandom, 1))
+	assert.Empty(t, strategyOrder(nil, clickhouse.ConnOpenRoundRobin, 1))
+}
+
+func TestHostHealthTracker(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newHostHealthTracker(func() time.Time { return now })
+	addrs := []string{"a:9000", "b:9000", "c:9000"}
+	assert.Equal(t, addrs, tracker.order(addrs))
+
+	tracker.markFailed("a:9000", time.Minute)
+	assert.Equal(t, []string{"b:9000", "c:9000", "a:9000"}, tracker.order(addrs))
+	tracker.markFailed("b:9000", time.Minute)
+	assert.Equal(t, []string{"c:9000", "a:9000", "b:9000"}, tracker.order(addrs))
+
+	tracker.markHealthy("b:9000")
+	assert.Equal(t, []string{"b:9000", "c:9000", "a:9000"}, tracker.order(addrs))
+
+	// cooldown expired
+	now = now.Add(time.Minute)
+	assert.Equal(t, addrs, tracker.order(addrs))
+}
+
+func TestHealthAwareDialStrategy(t *testing.T) {
+	prevHealth := hostHealth
+	defer func() { hostHealth = prevHealth }()
+	hostHealth = newHostHealthTracker(time.Now)
+
+	opt := &clickhouse.Options{
+		Addr:             []string{"a:9000", "b:9000", "c:9000"},
+		ConnOpenStrategy: clickhouse.ConnOpenInOrder,
+	}
+	var dialed []string
+	down := map[string]bool{"a:9000": true}
+	dial := func(ctx context.Context, addr string, opt *clickhouse.Options) (clickhouse.DialResult, error) {
+		dialed = append(dialed, addr)
+		if down[addr] {
+			return clickhouse.DialResult{}, syscall.ECONNREFUSED
+		}
+		return clickhouse.DialResult{}, nil
+	}
+
+	// the first host is down: fail over to the next one
+	_, err := healthAwareDialStrategy(context.Background(), 1, opt, dial)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a:9000", "b:9000"}, dialed)
+
+	// the failed host is skipped until the cooldown expires
+	dialed = nil
+	_, err = healthAwareDialStrategy(context.Background(), 2, opt, dial)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b:9000"}, dialed)
+
+	// the failed host is still tried if all other hosts are down
+	dialed = nil
+	down = map[string]bool{"b:9000": true, "c:9000": true}
+	_, err = healthAwareDialStrategy(context.Background(), 3, opt, dial)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b:9000", "c:9000", "a:9000"}, dialed)
+
+	// all hosts are down
+	dialed = nil
+	down = map[string]bool{"a:9000": true, "b:9000": true, "c:9000": true}
+	_, err = healthAwareDialStrategy(context.Background(), 4, opt, dial)
+	assert.ErrorIs(t, err, syscall.ECONNREFUSED)
+	assert.Len(t, dialed, 3)
+
+	// non-network errors (e.g. authentication) do not mark the host as unhealthy
+	hostHealth = newHostHealthTracker(time.Now)
+	authErr := &clickhouse.Exception{Code: 516, Message: "Authentication failed"}
+	dial = func(ctx context.Context, addr string, opt *clickhouse.Options) (clickhouse.DialResult, error) {
+		return clickhouse.DialResult{}, authErr
+	}
+	_, err = healthAwareDialStrategy(context.Background(), 5, opt, dial)
+	var ex *clickhouse.Exception
+	assert.True(t, errors.As(err, &ex))
+	assert.Equal(t, opt.Addr, hostHealth.order(opt.Addr))
+}
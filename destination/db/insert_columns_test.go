@@ -0,0 +1,476 @@
+package db
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+	csvfile "fivetran.com/fivetran_sdk/destination/common/csv"
+	"fivetran.com/fivetran_sdk/destination/common/files"
+	"fivetran.com/fivetran_sdk/destination/common/retry"
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	"fivetran.com/fivetran_sdk/destination/db/sql"
+	"fivetran.com/fivetran_sdk/destination/db/values"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/column"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// columnsBatch is a driver.Batch that appends the values to the driver columns without sending them anywhere;
+// Send waits for sendDelay, and returns sendErr. settings are the insert settings of the context, see recordInsertSettings.
+type columnsBatch struct {
+	driver.Batch
+	columns   []column.Interface
+	sendDelay time.Duration
+	sendErr   error
+	settings  clickhouse.Settings
+}
+
+type columnsBatchColumn struct {
+	column column.Interface
+}
+
+func newColumnsBatch(t testing.TB, names []string, columnTypes []string) *columnsBatch {
+	batch := &columnsBatch{columns: make([]column.Interface, len(names))}
+	for i, name := range names {
+		col, err := column.Type(columnTypes[i]).Column(name, &column.ServerContext{Timezone: time.UTC})
+		require.NoError(t, err)
+		batch.columns[i] = col
+	}
+	return batch
+}
+
+func (b *columnsBatch) Append(v ...any) error {
+	for i, value := range v {
+		if err := b.columns[i].AppendRow(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *columnsBatch) Column(i int) driver.BatchColumn {
+	return &columnsBatchColumn{column: b.columns[i]}
+}
+
+func (b *columnsBatch) Columns() []column.Interface {
+	return b.columns
+}
+
+func (b *columnsBatch) Send() error {
+	time.Sleep(b.sendDelay)
+	return b.sendErr
+}
+
+func (c *columnsBatchColumn) Append(v any) error {
+	_, err := c.column.Append(v)
+	return err
+}
+
+func (c *columnsBatchColumn) AppendRow(v any) error {
+	return c.column.AppendRow(v)
+}
+
+// batchingConn prepares columnsBatch batches with the given column types.
+// The first failedSends batches fail to send with a network error, after the data was sent.
+type batchingConn struct {
+	driver.Conn
+	t           testing.TB
+	names       []string
+	columnTypes []string
+	sendDelay   time.Duration
+	sendErr     error
+	failedSends int
+	batches     []*columnsBatch
+}
+
+func (m *batchingConn) PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error) {
+	batch := newColumnsBatch(m.t, m.names, m.columnTypes)
+	batch.sendDelay = m.sendDelay
+	batch.sendErr = m.sendErr
+	if m.failedSends > 0 {
+		m.failedSends--
+		batch.sendErr = fmt.Errorf("read: %w", syscall.ECONNRESET)
+	}
+	batch.settings = recordedInsertSettings(ctx)
+	m.batches = append(m.batches, batch)
+	return batch, nil
+}
+
+// allDataTypesColumns returns the columns of the all_data_types table from the e2e tests,
+// with the CSV columns in the reverse order of the table columns, and their ClickHouse types.
+func allDataTypesColumns() (*types.CSVColumns, []string) {
+	tableColumns := []struct {
+		name       string
+		dataType   pb.DataType
+		columnType string
+	}{
+		{"b", pb.DataType_BOOLEAN, "Nullable(Bool)"},
+		{"i16", pb.DataType_SHORT, "Nullable(Int16)"},
+		{"i32", pb.DataType_INT, "Nullable(Int32)"},
+		{"i64", pb.DataType_LONG, "Nullable(Int64)"},
+		{"f32", pb.DataType_FLOAT, "Nullable(Float32)"},
+		{"f64", pb.DataType_DOUBLE, "Nullable(Float64)"},
+		{"dec", pb.DataType_DECIMAL, "Nullable(Decimal(10, 4))"},
+		{"d", pb.DataType_NAIVE_DATE, "Nullable(Date32)"},
+		{"dt", pb.DataType_NAIVE_DATETIME, "Nullable(DateTime64(0, 'UTC'))"},
+		{"utc", pb.DataType_UTC_DATETIME, "Nullable(DateTime64(9, 'UTC'))"},
+		{"s", pb.DataType_STRING, "Nullable(String)"},
+		{"j", pb.DataType_JSON, "Nullable(String)"},
+		{"x", pb.DataType_XML, "Nullable(String)"},
+		{"bin", pb.DataType_BINARY, "Nullable(String)"},
+		{"nt", pb.DataType_NAIVE_TIME, "Nullable(String)"},
+		{"_fivetran_synced", pb.DataType_UTC_DATETIME, "DateTime64(9, 'UTC')"},
+		{"_fivetran_id", pb.DataType_STRING, "String"},
+		{"_fivetran_deleted", pb.DataType_BOOLEAN, "Bool"},
+	}
+	coercions := types.NewCoercions(nil)
+	csvColumns := &types.CSVColumns{}
+	columnTypes := make([]string, len(tableColumns))
+	for i, tableColumn := range tableColumns {
+		columnTypes[i] = tableColumn.columnType
+	}
+	for i := len(tableColumns) - 1; i >= 0; i-- {
+		col := &types.CSVColumn{
+			Index:        uint(len(csvColumns.All)),
+			TableIndex:   uint(i),
+			Name:         tableColumns[i].name,
+			Type:         tableColumns[i].dataType,
+			IsPrimaryKey: tableColumns[i].name == constants.FivetranID,
+			Coercions:    coercions,
+		}
+		if col.Type == pb.DataType_DECIMAL {
+			col.DecimalLimits = &pb.DecimalParams{Precision: 10, Scale: 4}
+		}
+		csvColumns.All = append(csvColumns.All, col)
+		if col.IsPrimaryKey {
+			csvColumns.PrimaryKeys = append(csvColumns.PrimaryKeys, col)
+		}
+	}
+	return csvColumns, columnTypes
+}
+
+// allDataTypesRow returns a CSV row for allDataTypesColumns.
+func allDataTypesRow(i int) []string {
+	return []string{"false", fmt.Sprintf("id-%d", i), "2024-05-07T10:11:12.123456789Z", "15:00", "FFFA",
+		"<a>1</a>", `{"a": 1,"b": 2}`, fmt.Sprintf("foo-%d", i), "2024-02-03T12:44:22.123456789Z",
+		"2024-04-05T15:33:14", "2024-05-07", "42.4242", "200.5", "100.5", fmt.Sprint(i), fmt.Sprint(i % 100000),
+		fmt.Sprint(i % 1000), "true"}
+}
+
+func TestInsertColumnsMatchesToInsertRow(t *testing.T) {
+	csvColumns, columnTypes := allDataTypesColumns()
+	names := csvColumns.TableColumnNames()
+	csvRows := [][]string{
+		allDataTypesRow(1),
+		allDataTypesRow(2),
+		// NULL values, and the values that are coerced
+		{"true", "id-3", "2024-05-07T10:11:12Z", "null", "null", "null", "null", "null", "9999-12-31T23:59:59Z",
+			"1800-01-01T00:00:00", "1899-12-31", "1.00005", "-Inf", "null", "null", "null", "null", "null"},
+	}
+
+	rowBatch := newColumnsBatch(t, names, columnTypes)
+	for _, csvRow := range csvRows {
+		insertRow, err := ToInsertRow(csvRow, csvColumns, "null")
+		require.NoError(t, err)
+		require.NoError(t, rowBatch.Append(insertRow...))
+	}
+
+	columns, err := NewInsertColumns(csvColumns, "null", 2)
+	require.NoError(t, err)
+	// the rows of the previous batch are not inserted again after Reset
+	require.NoError(t, columns.AppendRow(allDataTypesRow(0)))
+	columns.Reset()
+	for _, csvRow := range csvRows {
+		require.NoError(t, columns.AppendRow(csvRow))
+	}
+	assert.Equal(t, 3, columns.Rows())
+	columnsBatch := newColumnsBatch(t, names, columnTypes)
+	require.NoError(t, columns.appendTo(columnsBatch))
+
+	for i, col := range rowBatch.columns {
+		require.Equal(t, len(csvRows), col.Rows(), col.Name())
+		require.Equal(t, len(csvRows), columnsBatch.columns[i].Rows(), col.Name())
+		for j := range csvRows {
+			assert.Equal(t, col.Row(j, false), columnsBatch.columns[i].Row(j, false), "%s, row %d", col.Name(), j)
+		}
+	}
+	assert.Nil(t, columnsBatch.columns[3].Row(2, false))
+	// the coerced values are counted by both ToInsertRow and AppendRow
+	assert.Equal(t, "d: 2, dec: 2, dt: 2, f64: 2, utc: 2", csvColumns.All[0].Coercions.Summary())
+}
+
+// appendsBatch is a columnsBatch that records the values passed to Append for every column.
+type appendsBatch struct {
+	*columnsBatch
+	appends [][]any
+}
+
+type appendsBatchColumn struct {
+	driver.BatchColumn
+	appends *[]any
+}
+
+func (b *appendsBatch) Column(i int) driver.BatchColumn {
+	return &appendsBatchColumn{BatchColumn: b.columnsBatch.Column(i), appends: &b.appends[i]}
+}
+
+func (c *appendsBatchColumn) Append(v any) error {
+	*c.appends = append(*c.appends, v)
+	return c.BatchColumn.Append(v)
+}
+
+func TestInsertColumnsLowCardinalityNullable(t *testing.T) {
+	coercions := types.NewCoercions(nil)
+	csvColumns := &types.CSVColumns{All: []*types.CSVColumn{
+		{Index: 0, TableIndex: 1, Name: "s", Type: pb.DataType_STRING, Coercions: coercions},
+		{Index: 1, TableIndex: 0, Name: "_fivetran_id", Type: pb.DataType_STRING, IsPrimaryKey: true, Coercions: coercions},
+	}}
+	columnTypes := []string{"LowCardinality(String)", "LowCardinality(Nullable(String))"}
+	columns, err := NewInsertColumns(csvColumns, "null", 3)
+	require.NoError(t, err)
+	for _, csvRow := range [][]string{{"foo", "id-1"}, {"null", "id-2"}, {"", "id-3"}} {
+		require.NoError(t, columns.AppendRow(csvRow))
+	}
+
+	batch := &appendsBatch{
+		columnsBatch: newColumnsBatch(t, csvColumns.TableColumnNames(), columnTypes),
+		appends:      make([][]any, len(columnTypes)),
+	}
+	require.NoError(t, columns.appendTo(batch))
+	assert.Equal(t, []any{[]string{"id-1", "id-2", "id-3"}}, batch.appends[0])
+	// the values of a Nullable column inside LowCardinality are appended as pointers, with nil for NULL
+	foo, empty := "foo", ""
+	assert.Equal(t, []any{[]*string{&foo, nil, &empty}}, batch.appends[1])
+	assert.Equal(t, 3, batch.columns[1].Rows())
+}
+
+func TestInsertColumnsNotSupportedByTypedColumns(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	csvColumns := &types.CSVColumns{All: []*types.CSVColumn{
+		{Name: "payload", Type: pb.DataType_JSON, NativeJSON: true, TableIndex: 0},
+		{Name: "count", Type: pb.DataType_LONG, ConvertTo: constants.UInt32, TableIndex: 1},
+		{Name: "created_at", Type: pb.DataType_NAIVE_DATETIME, Location: loc, TableIndex: 2},
+	}}
+	columnTypes := []string{"JSON", "Nullable(UInt32)", "Nullable(DateTime64(0, 'Europe/Berlin'))"}
+	names := csvColumns.TableColumnNames()
+	csvRows := [][]string{{`{"a": 1}`, "42", "2024-04-05T15:33:14"}, {"null", "null", "null"}}
+
+	columns, err := NewInsertColumns(csvColumns, "null", 2)
+	require.NoError(t, err)
+	for i, column := range columns.columns {
+		assert.IsType(t, &anyColumn{}, column, names[i])
+	}
+	rowBatch := newColumnsBatch(t, names, columnTypes)
+	for _, csvRow := range csvRows {
+		insertRow, err := ToInsertRow(csvRow, csvColumns, "null")
+		require.NoError(t, err)
+		require.NoError(t, rowBatch.Append(insertRow...))
+		require.NoError(t, columns.AppendRow(csvRow))
+	}
+	columnsBatch := newColumnsBatch(t, names, columnTypes)
+	require.NoError(t, columns.appendTo(columnsBatch))
+	for i, col := range rowBatch.columns {
+		for j := range csvRows {
+			assert.Equal(t, col.Row(j, false), columnsBatch.columns[i].Row(j, false), "%s, row %d", col.Name(), j)
+		}
+	}
+}
+
+func TestInsertColumnsErrors(t *testing.T) {
+	csvColumns, columnTypes := allDataTypesColumns()
+	names := csvColumns.TableColumnNames()
+
+	_, err := NewInsertColumns(csvColumns, "", 10)
+	assert.ErrorContains(t, err, "nullStr can't be empty")
+	_, err = NewInsertColumns(nil, "null", 10)
+	assert.ErrorContains(t, err, "table can't be nil")
+
+	columns, err := NewInsertColumns(csvColumns, "null", 10)
+	require.NoError(t, err)
+	assert.ErrorContains(t, columns.AppendRow([]string{"true"}), "expected 18 columns, but CSV row contains 1")
+	csvRow := allDataTypesRow(1)
+	csvRow[len(csvRow)-4] = "x"
+	_, expected := ToInsertRow(csvRow, csvColumns, "null")
+	assert.EqualError(t, columns.AppendRow(csvRow), expected.Error())
+
+	// NULL values are handled by the driver for the columns that are not Nullable, same as with ToInsertRow
+	csvRow = allDataTypesRow(1)
+	csvRow[1] = "null"
+	insertRow, err := ToInsertRow(csvRow, csvColumns, "null")
+	require.NoError(t, err)
+	rowBatch := newColumnsBatch(t, names, columnTypes)
+	require.NoError(t, rowBatch.Append(insertRow...))
+	columns.Reset()
+	require.NoError(t, columns.AppendRow(csvRow))
+	columnsBatch := newColumnsBatch(t, names, columnTypes)
+	require.NoError(t, columns.appendTo(columnsBatch))
+	assert.Equal(t, rowBatch.columns[16].Row(0, false), columnsBatch.columns[16].Row(0, false))
+
+	err = columns.appendTo(newColumnsBatch(t, names[:1], columnTypes[:1]))
+	assert.ErrorContains(t, err, "expected 18 columns in the batch, but got 1")
+}
+
+func TestSendBatch(t *testing.T) {
+	mock := &batchingConn{t: t, names: []string{"id"}, columnTypes: []string{"Int32"}, failedSends: 1}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{
+		Retry: retry.Settings{MaxRetries: 3, InitialRetryDelayMilliseconds: 1, MaxRetryDelayMilliseconds: 1},
+	}}
+	qualifiedTableName, err := sql.GetQualifiedTableName("tester", "users")
+	require.NoError(t, err)
+
+	// the rows are appended again to a new batch after a network error, without the skipped ones
+	rows := [][]any{{int32(1)}, {int32(2)}, {int32(3)}}
+	require.NoError(t, conn.InsertBatch(context.Background(), qualifiedTableName, []string{"id"}, rows,
+		map[int]bool{1: true}, "test"))
+	require.Len(t, mock.batches, 2)
+	assert.Equal(t, 2, mock.batches[0].columns[0].Rows())
+	assert.Equal(t, int32(3), mock.batches[1].columns[0].Row(1, false))
+
+	csvColumns := &types.CSVColumns{All: []*types.CSVColumn{
+		{Name: "id", Type: pb.DataType_INT},
+		{Index: 1, TableIndex: 1, Name: "name", Type: pb.DataType_STRING},
+	}}
+	columns, err := NewInsertColumns(csvColumns, "null", 1)
+	require.NoError(t, err)
+	require.NoError(t, columns.AppendRow([]string{"1", "foo"}))
+	err = conn.InsertColumns(context.Background(), qualifiedTableName, []string{"id", "name"}, columns, "test")
+	assert.ErrorContains(t, err, "error appending rows to a batch for `tester`.`users`: expected 2 columns in the batch, but got 1")
+
+	mock.sendErr = fmt.Errorf("code: 241, message: Memory limit exceeded")
+	err = conn.InsertBatch(context.Background(), qualifiedTableName, []string{"id"}, rows, nil, "test")
+	assert.ErrorContains(t, err, "error while sending batch for `tester`.`users`: code: 241, message: Memory limit exceeded")
+}
+
+func TestInsertUTCDateTimeLowerPrecisionBeyondNanos(t *testing.T) {
+	precision := uint(3)
+	col := &types.CSVColumn{Name: "created_at", Type: pb.DataType_UTC_DATETIME, IsPrimaryKey: true,
+		UTCPrecision: &precision, Coercions: types.NewCoercions(nil)}
+	csvColumns := &types.CSVColumns{All: []*types.CSVColumn{col}, PrimaryKeys: []*types.CSVColumn{col}}
+	csvRow := []string{"2290-01-02T03:04:05.006Z"}
+	value := time.Date(2290, time.January, 2, 3, 4, 5, 6_000_000, time.UTC)
+
+	// the driver can't insert the values after 2262 into DateTime64(3) either as time.Time or as int64 ticks,
+	// see values.clampUTCDateTime
+	batch := newColumnsBatch(t, []string{col.Name}, []string{"DateTime64(3, 'UTC')"})
+	require.NoError(t, batch.Append(value))
+	require.NoError(t, batch.Append(value.Unix()*1000+int64(value.Nanosecond()/1_000_000)))
+	assert.NotEqual(t, value, batch.columns[0].Row(0, false))
+	assert.NotEqual(t, value, batch.columns[0].Row(1, false))
+
+	// so the value is clamped, and the mapping keys of the CSV and the stored values match
+	insertRow, err := ToInsertRow(csvRow, csvColumns, "null")
+	require.NoError(t, err)
+	assert.Equal(t, []any{values.MaxDateTime64}, insertRow)
+	assert.Equal(t, "created_at: 1", col.Coercions.Summary())
+	columns, err := NewInsertColumns(csvColumns, "null", 1)
+	require.NoError(t, err)
+	require.NoError(t, columns.AppendRow(csvRow))
+	batch = newColumnsBatch(t, []string{col.Name}, []string{"DateTime64(3, 'UTC')"})
+	require.NoError(t, columns.appendTo(batch))
+	stored := batch.columns[0].Row(0, false).(time.Time)
+	assert.Equal(t, values.MaxDateTime64, stored)
+	csvKey, err := GetCSVRowMappingKey(csvRow, csvColumns, false)
+	require.NoError(t, err)
+	dbKey, err := GetDatabaseRowMappingKey([]any{&stored}, csvColumns)
+	require.NoError(t, err)
+	assert.Equal(t, csvKey, dbKey)
+}
+
+func TestReplaceBatchColumns(t *testing.T) {
+	mock := &batchingConn{t: t, names: []string{"id", "_fivetran_start"},
+		columnTypes: []string{"Int32", "DateTime64(9, 'UTC')"}}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{WriteBatchSize: 3}}
+	csvColumns := historyModeCSVColumns()
+
+	reader := openHistoryModeReader(t)
+	defer reader.Close()
+	_, err := conn.ReplaceBatch(context.Background(), "tester", historyModeTable(), reader, csvColumns, "my-null-str")
+	assert.ErrorContains(t, err, "column _fivetran_start has the same table index 0 as another column")
+
+	reader = openHistoryModeReader(t)
+	defer reader.Close()
+	csvColumns.All[1].TableIndex = 1
+	totalRows, err := conn.ReplaceBatch(context.Background(), "tester", historyModeTable(), reader, csvColumns, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, totalRows)
+	require.Len(t, mock.batches, 2)
+	assert.Equal(t, 3, mock.batches[0].columns[0].Rows())
+	assert.Equal(t, 2, mock.batches[1].columns[0].Rows())
+	assert.Equal(t, int32(4), mock.batches[1].columns[0].Row(0, false))
+}
+
+// writeAllDataTypesFile writes a CSV file with the columns of allDataTypesColumns.
+func writeAllDataTypesFile(b *testing.B, csvColumns *types.CSVColumns, rows int) string {
+	fileName := filepath.Join(b.TempDir(), "all_data_types.csv")
+	file, err := os.Create(fileName)
+	require.NoError(b, err)
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	header := make([]string, len(csvColumns.All))
+	for i, col := range csvColumns.All {
+		header[i] = col.Name
+	}
+	require.NoError(b, writer.Write(header))
+	for i := range rows {
+		require.NoError(b, writer.Write(allDataTypesRow(i)))
+	}
+	writer.Flush()
+	require.NoError(b, writer.Error())
+	return fileName
+}
+
+func openBenchmarkReader(b *testing.B, fileName string) *csvfile.CSVFileReader {
+	reader, err := csvfile.NewCSVFileReader(fileName, map[string][]byte{fileName: nil}, pb.Compression_OFF, pb.Encryption_NONE)
+	require.NoError(b, err)
+	return reader
+}
+
+// rowsReader hides the RecordBatchFileReader methods of a reader, so that ReplaceBatch reads the rows with ReadBatch.
+type rowsReader struct {
+	files.BatchFileReader
+}
+
+// benchmarkReplaceBatch inserts 100k rows of the all_data_types shape into a columnsBatch with ReplaceBatch,
+// reading them either row by row (ReadBatch and ToInsertRow) or column by column (ReadRecords and InsertColumns).
+func benchmarkReplaceBatch(b *testing.B, byRow bool) {
+	const rows = 100_000
+	csvColumns, columnTypes := allDataTypesColumns()
+	fileName := writeAllDataTypesFile(b, csvColumns, rows)
+	mock := &batchingConn{t: b, names: csvColumns.TableColumnNames(), columnTypes: columnTypes}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{WriteBatchSize: rows}}
+	table := &pb.Table{Name: "all_data_types"}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		reader := openBenchmarkReader(b, fileName)
+		var batchReader files.BatchFileReader = reader
+		if byRow {
+			batchReader = rowsReader{reader}
+		}
+		totalRows, err := conn.ReplaceBatch(context.Background(), "tester", table, batchReader, csvColumns, "null")
+		require.NoError(b, err)
+		require.Equal(b, rows, totalRows)
+		reader.Close()
+		mock.batches = nil
+	}
+}
+
+func BenchmarkReplaceBatchRows(b *testing.B) {
+	benchmarkReplaceBatch(b, true)
+}
+
+func BenchmarkReplaceBatchColumns(b *testing.B) {
+	benchmarkReplaceBatch(b, false)
+}
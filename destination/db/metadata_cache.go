@@ -0,0 +1,166 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"sync"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// metadataCache is shared by all GRPC calls of the process, so the table metadata is not requested
+// from the system tables on every WriteBatch, AlterTable or Truncate call during a sync.
+// It is invalidated by our own DDL (see invalidateTable and invalidateSchema),
+// while the TTL (metadata-cache-ttl flag) puts a limit on how long an external change can go unnoticed.
+var metadataCache = newTableMetadataCache(time.Now)
+
+// tableMetadataKey identifies a table as seen by a connection: hosts is the set of ClickHouse hosts
+// the connection points to, and identity is a hash of the connection config fields that change
+// the result of the describe queries (see metadataIdentity). ignoreDefaultColumns is the effective
+// table option (see types.TableEngineOptions), as it decides which columns DescribeTable reports as user-owned.
+type tableMetadataKey struct {
+	hosts                string
+	identity             string
+	schemaName           string
+	tableName            string
+	ignoreDefaultColumns bool
+}
+
+// metadataIdentity is a hash of the connection config fields that change the cached table metadata:
+// the hosts, the user, as the metadata is only shared by the calls with the same privileges,
+// and the cluster, as the shard-local table of a Distributed one is described instead (see storageTableName).
+// Unlike poolKey, the password and the transport options are not included.
+func metadataIdentity(connConfig *config.Config) string {
+	h := sha256.New()
+	write := func(values ...string) {
+		for _, value := range values {
+			// length prefix, so different field values can't produce the same input
+			h.Write([]byte(strconv.Itoa(len(value)) + ":" + value))
+		}
+	}
+	write(strconv.Itoa(len(connConfig.Addresses)))
+	write(connConfig.Addresses...)
+	write(connConfig.Username, strconv.FormatBool(connConfig.Local))
+	if connConfig.Cluster != nil {
+		write("cluster", connConfig.Cluster.Name, strconv.FormatBool(connConfig.Cluster.Distributed))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// tableMetadata holds the cached results of DescribeTable and GetColumnTypes for a table;
+// either of them can be nil if it was not requested yet or has expired.
+type tableMetadata struct {
+	description        *types.TableDescription
+	descriptionExpires time.Time
+	columnTypes        []driver.ColumnType
+	columnTypesExpires time.Time
+}
+
+type tableMetadataCache struct {
+	mu      sync.Mutex
+	entries map[tableMetadataKey]*tableMetadata
+	now     func() time.Time
+}
+
+func newTableMetadataCache(now func() time.Time) *tableMetadataCache {
+	return &tableMetadataCache{
+		entries: make(map[tableMetadataKey]*tableMetadata),
+		now:     now,
+	}
+}
+
+// getDescription returns the cached table description; the result is shared and must not be modified.
+func (c *tableMetadataCache) getDescription(key tableMetadataKey) *types.TableDescription {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.description == nil || !c.now().Before(entry.descriptionExpires) {
+		return nil
+	}
+	return entry.description
+}
+
+func (c *tableMetadataCache) putDescription(key tableMetadataKey, description *types.TableDescription) {
+	ttl := *flags.MetadataCacheTTL
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entry(key)
+	entry.description = description
+	entry.descriptionExpires = c.now().Add(ttl)
+}
+
+// getColumnTypes returns the cached column types; the result is shared and must not be modified.
+func (c *tableMetadataCache) getColumnTypes(key tableMetadataKey) []driver.ColumnType {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || entry.columnTypes == nil || !c.now().Before(entry.columnTypesExpires) {
+		return nil
+	}
+	return entry.columnTypes
+}
+
+func (c *tableMetadataCache) putColumnTypes(key tableMetadataKey, columnTypes []driver.ColumnType) {
+	ttl := *flags.MetadataCacheTTL
+	if ttl <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := c.entry(key)
+	entry.columnTypes = columnTypes
+	entry.columnTypesExpires = c.now().Add(ttl)
+}
+
+func (c *tableMetadataCache) entry(key tableMetadataKey) *tableMetadata {
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &tableMetadata{}
+		c.entries[key] = entry
+	}
+	return entry
+}
+
+// invalidateTable removes the entries of the table for all the identities with the same hosts,
+// as the DDL of one user (e.g. the DDL one) changes the table seen by the others.
+func (c *tableMetadataCache) invalidateTable(hosts string, schemaName string, tableName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.hosts == hosts && key.schemaName == schemaName && key.tableName == tableName {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// invalidateSchema is invalidateTable for all the tables in the schema.
+func (c *tableMetadataCache) invalidateSchema(hosts string, schemaName string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if key.hosts == hosts && key.schemaName == schemaName {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// evictExpired removes the entries with both the description and the column types expired,
+// so the cache does not grow with the tables that are not synced anymore.
+func (c *tableMetadataCache) evictExpired() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	for key, entry := range c.entries {
+		if !now.Before(entry.descriptionExpires) && !now.Before(entry.columnTypesExpires) {
+			delete(c.entries, key)
+		}
+	}
+}
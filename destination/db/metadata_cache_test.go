@@ -0,0 +1,177 @@
+package db
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeColumnType implements only the driver.ColumnType methods used by the tests.
+type fakeColumnType struct {
+	driver.ColumnType
+	name string
+}
+
+func (c *fakeColumnType) Name() string { return c.name }
+
+func TestTableMetadataCache(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	cache := newTableMetadataCache(func() time.Time { return now })
+	ttl := *flags.MetadataCacheTTL
+
+	key := tableMetadataKey{hosts: "host1:9440", identity: "id1", schemaName: "db", tableName: "t"}
+	otherKey := tableMetadataKey{hosts: "host1:9440", identity: "id1", schemaName: "db", tableName: "t2"}
+	assert.Nil(t, cache.getDescription(key))
+	assert.Nil(t, cache.getColumnTypes(key))
+
+	description := types.MakeTableDescription([]*types.ColumnDefinition{{Name: "id", Type: "Int32", IsPrimaryKey: true}})
+	columnTypes := []driver.ColumnType{&fakeColumnType{name: "id"}}
+	cache.putDescription(key, description)
+	assert.Same(t, description, cache.getDescription(key))
+	assert.Nil(t, cache.getColumnTypes(key))
+	cache.putColumnTypes(key, columnTypes)
+	assert.Equal(t, columnTypes, cache.getColumnTypes(key))
+	assert.Nil(t, cache.getDescription(otherKey))
+
+	// the TTL applies to the description and the column types separately
+	now = now.Add(ttl / 2)
+	cache.putDescription(otherKey, description)
+	cache.putColumnTypes(key, columnTypes)
+	now = now.Add(ttl / 2)
+	assert.Nil(t, cache.getDescription(key))
+	assert.Equal(t, columnTypes, cache.getColumnTypes(key))
+	assert.Same(t, description, cache.getDescription(otherKey))
+
+	// the entries are only removed when both are expired
+	cache.evictExpired()
+	assert.Len(t, cache.entries, 2)
+	now = now.Add(ttl / 2)
+	cache.evictExpired()
+	assert.Empty(t, cache.entries)
+}
+
+func TestTableMetadataCacheInvalidation(t *testing.T) {
+	prevCache := metadataCache
+	defer func() { metadataCache = prevCache }()
+	metadataCache = newTableMetadataCache(time.Now)
+
+	conn := &ClickHouseConnection{hosts: "host1:9440,host2:9440", identity: "ddl"}
+	dmlConn := &ClickHouseConnection{hosts: "host1:9440,host2:9440", identity: "dml"}
+	otherConn := &ClickHouseConnection{hosts: "other:9440", identity: "ddl"}
+	description := types.MakeTableDescription([]*types.ColumnDefinition{{Name: "id", Type: "Int32", IsPrimaryKey: true}})
+	for _, c := range []*ClickHouseConnection{conn, dmlConn, otherConn} {
+		for _, key := range []tableMetadataKey{
+			c.tableMetadataKey("db", "t1"),
+			c.tableMetadataKey("db", "t2"),
+			c.tableMetadataKey("db2", "t1"),
+		} {
+			metadataCache.putDescription(key, description)
+		}
+	}
+
+	// the DDL of one user invalidates the entries of the other users of the same hosts
+	conn.InvalidateTableMetadata("db", "t1")
+	assert.Nil(t, metadataCache.getDescription(conn.tableMetadataKey("db", "t1")))
+	assert.Nil(t, metadataCache.getDescription(dmlConn.tableMetadataKey("db", "t1")))
+	assert.NotNil(t, metadataCache.getDescription(conn.tableMetadataKey("db", "t2")))
+	assert.NotNil(t, metadataCache.getDescription(otherConn.tableMetadataKey("db", "t1")))
+
+	conn.InvalidateSchemaMetadata("db")
+	assert.Nil(t, metadataCache.getDescription(conn.tableMetadataKey("db", "t2")))
+	assert.Nil(t, metadataCache.getDescription(dmlConn.tableMetadataKey("db", "t2")))
+	assert.NotNil(t, metadataCache.getDescription(conn.tableMetadataKey("db2", "t1")))
+	assert.NotNil(t, metadataCache.getDescription(otherConn.tableMetadataKey("db", "t2")))
+}
+
+func TestMetadataIdentity(t *testing.T) {
+	connConfig := &config.Config{Addresses: []string{"host1:9440", "host2:9440"}, Username: "fivetran", Password: "secret"}
+	identity := metadataIdentity(connConfig)
+
+	// the password and the transport options don't change the metadata
+	same := *connConfig
+	same.Password = "other"
+	same.Protocol = "http"
+	same.TLS = &config.TLSOptions{ServerName: "clickhouse.local"}
+	assert.Equal(t, identity, metadataIdentity(&same))
+
+	for name, modify := range map[string]func(c *config.Config){
+		"hosts":         func(c *config.Config) { c.Addresses = []string{"host1:9440"} },
+		"user":          func(c *config.Config) { c.Username = "fivetran_ddl" },
+		"local":         func(c *config.Config) { c.Local = true },
+		"cluster":       func(c *config.Config) { c.Cluster = &types.Cluster{Name: "default"} },
+		"other cluster": func(c *config.Config) { c.Cluster = &types.Cluster{Name: "other"} },
+	} {
+		other := *connConfig
+		modify(&other)
+		assert.NotEqual(t, identity, metadataIdentity(&other), name)
+	}
+
+	// a Distributed config describes the shard-local tables, so it can't share the entries of a non-Distributed one
+	replicated := *connConfig
+	replicated.Cluster = &types.Cluster{Name: "default"}
+	distributed := *connConfig
+	distributed.Cluster = &types.Cluster{Name: "default", Distributed: true}
+	assert.NotEqual(t, metadataIdentity(&replicated), metadataIdentity(&distributed))
+}
+
+func TestTableMetadataCacheIgnoreDefaultColumns(t *testing.T) {
+	prevCache := metadataCache
+	defer func() { metadataCache = prevCache }()
+	metadataCache = newTableMetadataCache(time.Now)
+
+	// two destinations with the same hosts and user, only one of them treats the DEFAULT columns as user-owned
+	newConn := func(advancedConfig string) *ClickHouseConnection {
+		connConfig, _, err := config.ParseAll(map[string]string{
+			config.HostKey:           "my.host",
+			config.AdvancedConfigKey: base64.StdEncoding.EncodeToString([]byte(advancedConfig)),
+		})
+		require.NoError(t, err)
+		return &ClickHouseConnection{
+			hosts:        strings.Join(connConfig.Addresses, ","),
+			identity:     metadataIdentity(connConfig),
+			tableEngines: connConfig.TableEngines,
+		}
+	}
+	conn := newConn(`{"table_configurations": {"defaults": {"ttl": "created_at + INTERVAL 1 YEAR"}}}`)
+	ignoringConn := newConn(`{"table_configurations": {"defaults": {"ignore_default_columns": true}}}`)
+	assert.Equal(t, conn.identity, ignoringConn.identity)
+
+	description := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Int32", IsPrimaryKey: true},
+		{Name: "region", Type: "String"},
+	})
+	ignoringDescription := types.MakeTableDescription([]*types.ColumnDefinition{{Name: "id", Type: "Int32", IsPrimaryKey: true}})
+	ignoringDescription.UserColumns = []*types.ColumnDefinition{{Name: "region", Type: "String", DefaultKind: "DEFAULT"}}
+	metadataCache.putDescription(conn.tableMetadataKey("db", "t"), description)
+	assert.Nil(t, metadataCache.getDescription(ignoringConn.tableMetadataKey("db", "t")))
+	metadataCache.putDescription(ignoringConn.tableMetadataKey("db", "t"), ignoringDescription)
+	assert.Same(t, description, metadataCache.getDescription(conn.tableMetadataKey("db", "t")))
+	assert.Same(t, ignoringDescription, metadataCache.getDescription(ignoringConn.tableMetadataKey("db", "t")))
+
+	// the DDL through either of them invalidates both
+	ignoringConn.InvalidateTableMetadata("db", "t")
+	assert.Nil(t, metadataCache.getDescription(conn.tableMetadataKey("db", "t")))
+	assert.Nil(t, metadataCache.getDescription(ignoringConn.tableMetadataKey("db", "t")))
+}
+
+func TestTableMetadataCacheDisabled(t *testing.T) {
+	prevTTL := *flags.MetadataCacheTTL
+	defer func() { *flags.MetadataCacheTTL = prevTTL }()
+	*flags.MetadataCacheTTL = 0
+
+	cache := newTableMetadataCache(time.Now)
+	key := tableMetadataKey{hosts: "host1:9440", identity: "id1", schemaName: "db", tableName: "t"}
+	cache.putDescription(key, types.MakeTableDescription(nil))
+	cache.putColumnTypes(key, []driver.ColumnType{&fakeColumnType{name: "id"}})
+	assert.Nil(t, cache.getDescription(key))
+	assert.Nil(t, cache.getColumnTypes(key))
+	assert.Empty(t, cache.entries)
+}
@@ -0,0 +1,301 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+	dt "fivetran.com/fivetran_sdk/destination/common/data_types"
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// nativeTypes holds the opt-in native ClickHouse types for the Fivetran types
+// that are stored as String with a type comment by default.
+// The decoded BINARY columns are stored as String or FixedString, and are distinguished by their comment instead.
+// It also holds the policy for the DECIMAL columns out of the ClickHouse range, see applyDecimal,
+// and the configured datetime types, see applyDateTime. The compatible types of the pre-existing tables
+// are kept regardless of the configuration, see keepCompatibleType.
+type nativeTypes struct {
+	json            *config.JSONTypes
+	naiveTime       string
+	binary          *config.BinaryTypes
+	binaryEncoding  string
+	decimalOverflow string
+	dateTime        *config.DateTimeTypes
+}
+
+func newNativeTypes(connConfig *config.Config) nativeTypes {
+	binaryEncoding := connConfig.BinaryEncoding
+	if binaryEncoding == "" {
+		binaryEncoding = constants.Base64Encoding
+	}
+	return nativeTypes{
+		json:            connConfig.JSONTypes,
+		naiveTime:       connConfig.NaiveTimeType,
+		binary:          connConfig.BinaryTypes,
+		binaryEncoding:  binaryEncoding,
+		decimalOverflow: connConfig.DecimalOverflow,
+		dateTime:        connConfig.DateTimeTypes,
+	}
+}
+
+// forColumn returns the native type for a String column with a Fivetran type comment; empty if there is none.
+func (t nativeTypes) forColumn(schemaName string, tableName string, col *types.ColumnDefinition) string {
+	switch col.Comment {
+	case constants.JSONColumnComment:
+		// the native JSON type can't be Nullable, and can't be used in the ORDER BY
+		if col.IsPrimaryKey {
+			return ""
+		}
+		return t.json.ForColumn(schemaName, tableName, col.Name)
+	case constants.NaiveTimeColumnComment:
+		if t.naiveTime == "" || col.IsPrimaryKey {
+			return t.naiveTime
+		}
+		return fmt.Sprintf("%s(%s)", constants.Nullable, t.naiveTime)
+	case constants.BinaryColumnComment:
+		binaryType := t.binary.ForColumn(schemaName, tableName, col.Name)
+		if binaryType == "" || col.IsPrimaryKey {
+			return binaryType
+		}
+		return fmt.Sprintf("%s(%s)", constants.Nullable, binaryType)
+	default:
+		return ""
+	}
+}
+
+// withSettings adds the settings that are required to create the columns with the native types.
+func (t nativeTypes) withSettings(ctx context.Context) context.Context {
+	if t.naiveTime == "" {
+		return ctx
+	}
+	return clickhouse.Context(ctx, clickhouse.WithSettings(clickhouse.Settings{
+		"enable_time_time64_type": 1,
+	}))
+}
+
+// applyNativeTypes returns a copy of the table description where the String columns with a Fivetran type comment
+// have the native ClickHouse type, if it is enabled (see nativeTypes and nativeTypes.forColumn).
+// The description itself is never modified, as it can be shared via the metadata cache.
+func applyNativeTypes(
+	schemaName string,
+	tableName string,
+	current *types.TableDescription,
+	description *types.TableDescription,
+	nativeTypes nativeTypes,
+) *types.TableDescription {
+	if description == nil || len(description.Columns) == 0 {
+		return description
+	}
+	columns := make([]*types.ColumnDefinition, len(description.Columns))
+	changed := false
+	for i, col := range description.Columns {
+		var currentCol *types.ColumnDefinition
+		if current != nil {
+			currentCol = current.Mapping[col.Name]
+		}
+		columns[i] = col
+		if nativeCol := nativeTypes.apply(schemaName, tableName, currentCol, col); nativeCol != nil {
+			columns[i] = nativeCol
+			changed = true
+		}
+	}
+	if !changed {
+		return description
+	}
+	return types.MakeTableDescription(columns)
+}
+
+// apply returns a copy of the column with the native type; nil if the column is unchanged.
+// The comment is kept, so the Fivetran type is still known; the decoded BINARY columns have the RAW_BINARY comment.
+// If the column already has a native type in the current table definition (currentCol, nil for the new columns),
+// its current type is kept: the type parameters (such as the JSON type hints) are only applied when the column is created
+// or migrated, and ClickHouse also might format them differently. The existing String primary key columns are kept as is.
+// The existing BINARY columns are never decoded, and the decoded ones are kept even if decoding is no longer enabled,
+// as the values can't be converted back and forth in place.
+func (t nativeTypes) apply(
+	schemaName string,
+	tableName string,
+	currentCol *types.ColumnDefinition,
+	col *types.ColumnDefinition,
+) *types.ColumnDefinition {
+	// the decoded BINARY columns can be FixedString, which is a compatible type otherwise
+	if col.Comment == constants.BinaryColumnComment && currentCol != nil && currentCol.Comment == constants.RawBinaryColumnComment {
+		return withType(col, currentCol.Type, currentCol.Comment)
+	}
+	if currentCol != nil && dt.IsCompatibleType(currentCol.Type) {
+		return keepCompatibleType(currentCol, col)
+	}
+	if dt.IsDecimalOverflow(col.DecimalParams) {
+		return t.applyDecimal(currentCol, col)
+	}
+	if dataType, ok := dateTimeDataType(col); ok {
+		return t.applyDateTime(schemaName, tableName, currentCol, col, dataType)
+	}
+	if col.Comment == constants.BinaryColumnComment && currentCol != nil {
+		return nil
+	}
+	if !isStringType(col.Type) {
+		return nil
+	}
+	nativeType := t.forColumn(schemaName, tableName, col)
+	if nativeType == "" {
+		return nil
+	}
+	if currentCol != nil {
+		if isNativeType(currentCol.Type) {
+			return withType(col, currentCol.Type, col.Comment)
+		}
+		if col.IsPrimaryKey {
+			// modifying a primary key column would re-create the table, see GetAlterTableOps
+			return nil
+		}
+	}
+	if col.Comment == constants.BinaryColumnComment {
+		return withType(col, nativeType, constants.RawBinaryColumnComment)
+	}
+	return withType(col, nativeType, col.Comment)
+}
+
+// applyDecimal returns a copy of a DECIMAL column out of the ClickHouse range (see dt.IsDecimalOverflow)
+// stored according to the overflow policy, with the original precision and scale in the comment;
+// nil if the column is clamped (see dt.ToClickHouseDecimalType). The existing columns stored as String are kept
+// regardless of the policy, as their values might not fit any Decimal type; the existing primary key columns
+// only get the comment if they already have the same type.
+func (t nativeTypes) applyDecimal(currentCol *types.ColumnDefinition, col *types.ColumnDefinition) *types.ColumnDefinition {
+	comment := dt.ToDecimalComment(col.DecimalParams)
+	if currentCol != nil && isStringType(currentCol.Type) && dt.IsDecimalComment(currentCol.Comment) {
+		return withType(col, currentCol.Type, comment)
+	}
+	var decimalType string
+	switch t.decimalOverflow {
+	case constants.DecimalOverflowString:
+		decimalType = constants.String
+	case constants.DecimalOverflowDecimal256:
+		decimalType = dt.ToClickHouseDecimal256Type(col.DecimalParams)
+	default:
+		return nil
+	}
+	if !col.IsPrimaryKey {
+		decimalType = fmt.Sprintf("%s(%s)", constants.Nullable, decimalType)
+	}
+	if currentCol != nil && col.IsPrimaryKey && currentCol.Type != decimalType {
+		// modifying a primary key column would re-create the table, see GetAlterTableOps
+		return nil
+	}
+	return withType(col, decimalType, comment)
+}
+
+// applyDateTime returns a copy of a NAIVE_DATETIME or UTC_DATETIME column with the configured DateTime64 type
+// (see config.DateTimeTypes); nil if the column is unchanged. The existing columns of the same Fivetran type keep
+// their current type, so the configuration only applies to the new columns and to the columns changing the type.
+// The metadata columns are always DateTime64(9, 'UTC'), as the history mode statements compare them with nanosecond
+// literals (see values.MaxDateTime64Nanos), and _fivetran_synced is compared in GetTruncateTableStatement.
+func (t nativeTypes) applyDateTime(
+	schemaName string,
+	tableName string,
+	currentCol *types.ColumnDefinition,
+	col *types.ColumnDefinition,
+	dataType pb.DataType,
+) *types.ColumnDefinition {
+	switch col.Name {
+	case constants.FivetranSynced, constants.FivetranStart, constants.FivetranEnd:
+		return nil
+	}
+	if currentCol != nil {
+		currentDataType, _, err := dt.ToFivetranDataType(currentCol.Type, currentCol.Comment, nil)
+		if err == nil && currentDataType == dataType {
+			if currentCol.Type == col.Type {
+				return nil
+			}
+			return withType(col, currentCol.Type, col.Comment)
+		}
+	}
+	dateTimeType := t.dateTime.ForNaiveColumn()
+	if dataType == pb.DataType_UTC_DATETIME {
+		dateTimeType = t.dateTime.ForUTCColumn(schemaName, tableName)
+	}
+	if strings.HasPrefix(col.Type, constants.Nullable+"(") {
+		dateTimeType = fmt.Sprintf("%s(%s)", constants.Nullable, dateTimeType)
+	}
+	if col.Type == dateTimeType {
+		return nil
+	}
+	return withType(col, dateTimeType, col.Comment)
+}
+
+// keepCompatibleType returns a copy of the column with the type of the existing column of a pre-existing table
+// (see dt.IsCompatibleType), if it maps to the same Fivetran type and is Nullable the same way;
+// nil otherwise, so the column is modified to the type the destination would create.
+func keepCompatibleType(currentCol *types.ColumnDefinition, col *types.ColumnDefinition) *types.ColumnDefinition {
+	currentDataType, currentParams, err := dt.ToFivetranDataType(currentCol.Type, currentCol.Comment, currentCol.DecimalParams)
+	if err != nil {
+		return nil
+	}
+	dataType, params, err := dt.ToFivetranDataType(col.Type, col.Comment, col.DecimalParams)
+	if err != nil || dataType != currentDataType || params.GetPrecision() != currentParams.GetPrecision() ||
+		params.GetScale() != currentParams.GetScale() {
+		return nil
+	}
+	if isNullableType(currentCol.Type) != isNullableType(col.Type) {
+		return nil
+	}
+	return withType(col, currentCol.Type, col.Comment)
+}
+
+func isNullableType(colType string) bool {
+	return strings.HasPrefix(colType, constants.Nullable+"(") ||
+		strings.HasPrefix(colType, fmt.Sprintf("%s(%s(", constants.LowCardinality, constants.Nullable))
+}
+
+// dateTimeDataType returns the Fivetran type of a column with the default NAIVE_DATETIME or UTC_DATETIME type,
+// i.e. constants.DateTime or constants.DateTimeUTC, Nullable or not; false for the other columns.
+func dateTimeDataType(col *types.ColumnDefinition) (pb.DataType, bool) {
+	colType := col.Type
+	if strings.HasPrefix(colType, constants.Nullable+"(") {
+		colType = colType[len(constants.Nullable)+1 : len(colType)-1]
+	}
+	switch {
+	case col.Comment != "":
+		return pb.DataType_UNSPECIFIED, false
+	case colType == constants.DateTime:
+		return pb.DataType_NAIVE_DATETIME, true
+	case colType == constants.DateTimeUTC:
+		return pb.DataType_UTC_DATETIME, true
+	default:
+		return pb.DataType_UNSPECIFIED, false
+	}
+}
+
+func withType(col *types.ColumnDefinition, colType string, comment string) *types.ColumnDefinition {
+	colCopy := *col
+	colCopy.Type = colType
+	colCopy.Comment = comment
+	return &colCopy
+}
+
+// nativeTypeMigrations returns the operations that modify the existing String columns to a native type.
+func nativeTypeMigrations(from *types.TableDescription, ops []*types.AlterTableOp) []*types.AlterTableOp {
+	var migrations []*types.AlterTableOp
+	for _, op := range ops {
+		if op.Op != types.AlterTableModify || op.Type == nil || !isNativeType(*op.Type) {
+			continue
+		}
+		if fromCol, ok := from.Mapping[op.Column]; ok && isStringType(fromCol.Type) {
+			migrations = append(migrations, op)
+		}
+	}
+	return migrations
+}
+
+func isStringType(colType string) bool {
+	return colType == constants.String || colType == fmt.Sprintf("%s(%s)", constants.Nullable, constants.String)
+}
+
+func isNativeType(colType string) bool {
+	return dt.IsJSONType(colType) || dt.IsTimeType(colType)
+}
@@ -0,0 +1,302 @@
+package db
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyNativeTypesJSON(t *testing.T) {
+	configured := parseNativeTypes(t,
+		`{"json_configurations": {"native": true, "columns": {"foo.bar.payload": {"type_hints": {"user.id": "UInt64"}}}}}`)
+	description := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "JSON", IsPrimaryKey: true},
+		{Name: "payload", Type: "Nullable(String)", Comment: "JSON"},
+		{Name: "metadata", Type: "Nullable(String)", Comment: "JSON"},
+		{Name: "name", Type: "Nullable(String)"},
+		{Name: "doc", Type: "Nullable(String)", Comment: "XML"},
+		{Name: "time", Type: "Nullable(String)", Comment: "NAIVE_TIME"},
+	})
+
+	result := applyNativeTypes("foo", "bar", nil, description, configured)
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "JSON", IsPrimaryKey: true},
+		{Name: "payload", Type: "JSON(user.id UInt64)", Comment: "JSON"},
+		{Name: "metadata", Type: "JSON", Comment: "JSON"},
+		{Name: "name", Type: "Nullable(String)"},
+		{Name: "doc", Type: "Nullable(String)", Comment: "XML"},
+		{Name: "time", Type: "Nullable(String)", Comment: "NAIVE_TIME"},
+	}), result)
+	// the original description is not modified
+	assert.Equal(t, "Nullable(String)", description.Mapping["payload"].Type)
+
+	// the existing native JSON columns keep their type
+	current := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "JSON", IsPrimaryKey: true},
+		{Name: "payload", Type: "JSON(max_dynamic_paths = 1024)", Comment: "JSON"},
+		{Name: "metadata", Type: "Nullable(String)", Comment: "JSON"},
+	})
+	result = applyNativeTypes("foo", "bar", current, description, configured)
+	assert.Equal(t, "JSON(max_dynamic_paths = 1024)", result.Mapping["payload"].Type)
+	assert.Equal(t, "JSON", result.Mapping["metadata"].Type)
+
+	// native types are not enabled
+	assert.Equal(t, description, applyNativeTypes("foo", "bar", nil, description, nativeTypes{}))
+}
+
+func TestApplyNativeTypesNaiveTime(t *testing.T) {
+	configured := parseNativeTypes(t, `{"type_configurations": {"naive_time": {"native": true, "precision": 3}}}`)
+	description := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "NAIVE_TIME", IsPrimaryKey: true},
+		{Name: "opens_at", Type: "Nullable(String)", Comment: "NAIVE_TIME"},
+		{Name: "closes_at", Type: "LowCardinality(Nullable(String))", Comment: "NAIVE_TIME"},
+		{Name: "payload", Type: "Nullable(String)", Comment: "JSON"},
+	})
+
+	result := applyNativeTypes("foo", "bar", nil, description, configured)
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Time64(3)", Comment: "NAIVE_TIME", IsPrimaryKey: true},
+		{Name: "opens_at", Type: "Nullable(Time64(3))", Comment: "NAIVE_TIME"},
+		{Name: "closes_at", Type: "LowCardinality(Nullable(String))", Comment: "NAIVE_TIME"},
+		{Name: "payload", Type: "Nullable(String)", Comment: "JSON"},
+	}), result)
+
+	// the existing String primary key columns are not modified, the existing native columns keep their type
+	current := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "NAIVE_TIME", IsPrimaryKey: true},
+		{Name: "opens_at", Type: "Nullable(Time64(6))", Comment: "NAIVE_TIME"},
+	})
+	result = applyNativeTypes("foo", "bar", current, description, configured)
+	assert.Equal(t, "String", result.Mapping["id"].Type)
+	assert.Equal(t, "Nullable(Time64(6))", result.Mapping["opens_at"].Type)
+
+	// the new primary key columns of the existing tables have the native type
+	current = types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "opens_at", Type: "Nullable(String)", Comment: "NAIVE_TIME"},
+	})
+	result = applyNativeTypes("foo", "bar", current, description, configured)
+	assert.Equal(t, "Time64(3)", result.Mapping["id"].Type)
+	assert.Equal(t, "Nullable(Time64(3))", result.Mapping["opens_at"].Type)
+}
+
+func TestApplyNativeTypesRawBinary(t *testing.T) {
+	configured := parseNativeTypes(t,
+		`{"type_configurations": {"binary": {"decode": true, "columns": {"foo.bar.hash": {"fixed_length": 32}}}}}`)
+	description := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "BINARY", IsPrimaryKey: true},
+		{Name: "hash", Type: "Nullable(String)", Comment: "BINARY"},
+		{Name: "payload", Type: "Nullable(String)", Comment: "BINARY"},
+		{Name: "name", Type: "Nullable(String)"},
+	})
+
+	result := applyNativeTypes("foo", "bar", nil, description, configured)
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "RAW_BINARY", IsPrimaryKey: true},
+		{Name: "hash", Type: "Nullable(FixedString(32))", Comment: "RAW_BINARY"},
+		{Name: "payload", Type: "Nullable(String)", Comment: "RAW_BINARY"},
+		{Name: "name", Type: "Nullable(String)"},
+	}), result)
+
+	// the existing encoded columns are not decoded, the existing decoded columns are kept
+	current := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "BINARY", IsPrimaryKey: true},
+		{Name: "hash", Type: "Nullable(String)", Comment: "BINARY"},
+		{Name: "payload", Type: "Nullable(String)", Comment: "RAW_BINARY"},
+	})
+	result = applyNativeTypes("foo", "bar", current, description, configured)
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "BINARY", IsPrimaryKey: true},
+		{Name: "hash", Type: "Nullable(String)", Comment: "BINARY"},
+		{Name: "payload", Type: "Nullable(String)", Comment: "RAW_BINARY"},
+		{Name: "name", Type: "Nullable(String)"},
+	}), result)
+
+	// the decoded columns are kept even if decoding is no longer enabled
+	result = applyNativeTypes("foo", "bar", current, description, nativeTypes{})
+	assert.Equal(t, "RAW_BINARY", result.Mapping["payload"].Comment)
+	assert.Equal(t, "BINARY", result.Mapping["hash"].Comment)
+	assert.Equal(t, "String", result.Mapping["id"].Type)
+
+	// the decoded FixedString columns keep their comment; the other FixedString columns are pre-existing STRING columns
+	current = types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "BINARY", IsPrimaryKey: true},
+		{Name: "hash", Type: "Nullable(FixedString(32))", Comment: "RAW_BINARY"},
+		{Name: "country", Type: "Nullable(FixedString(2))"},
+	})
+	description = types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "BINARY", IsPrimaryKey: true},
+		{Name: "hash", Type: "Nullable(String)", Comment: "BINARY"},
+		{Name: "country", Type: "Nullable(String)"},
+	})
+	for _, native := range []nativeTypes{configured, {}} {
+		result = applyNativeTypes("foo", "bar", current, description, native)
+		assert.Equal(t, current, result)
+	}
+}
+
+func TestApplyNativeTypesDecimalOverflow(t *testing.T) {
+	wide := &pb.DecimalParams{Precision: 100, Scale: 10}
+	narrow := &pb.DecimalParams{Precision: 10, Scale: 2}
+	description := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Decimal(76, 10)", IsPrimaryKey: true, DecimalParams: wide},
+		{Name: "total", Type: "Nullable(Decimal(76, 10))", DecimalParams: wide},
+		{Name: "amount", Type: "Nullable(Decimal(10, 2))", DecimalParams: narrow},
+	})
+
+	configured := parseNativeTypes(t, `{"type_configurations": {"decimal": {"overflow": "string"}}}`)
+	result := applyNativeTypes("foo", "bar", nil, description, configured)
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "String", Comment: "DECIMAL(100,10)", IsPrimaryKey: true, DecimalParams: wide},
+		{Name: "total", Type: "Nullable(String)", Comment: "DECIMAL(100,10)", DecimalParams: wide},
+		{Name: "amount", Type: "Nullable(Decimal(10, 2))", DecimalParams: narrow},
+	}), result)
+
+	configured = parseNativeTypes(t, `{"type_configurations": {"decimal": {"overflow": "decimal256"}}}`)
+	result = applyNativeTypes("foo", "bar", nil, description, configured)
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Decimal(76, 10)", Comment: "DECIMAL(100,10)", IsPrimaryKey: true, DecimalParams: wide},
+		{Name: "total", Type: "Nullable(Decimal(76, 10))", Comment: "DECIMAL(100,10)", DecimalParams: wide},
+		{Name: "amount", Type: "Nullable(Decimal(10, 2))", DecimalParams: narrow},
+	}), result)
+
+	// clamped by default
+	assert.Equal(t, description, applyNativeTypes("foo", "bar", nil, description, nativeTypes{}))
+
+	// the existing String columns are kept, the existing primary key columns are not modified
+	current := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Decimal(76, 10)", IsPrimaryKey: true},
+		{Name: "total", Type: "Nullable(String)", Comment: "DECIMAL(90,10)"},
+	})
+	result = applyNativeTypes("foo", "bar", current, description, configured)
+	assert.Equal(t, "Decimal(76, 10)", result.Mapping["id"].Type)
+	assert.Equal(t, "DECIMAL(100,10)", result.Mapping["id"].Comment)
+	assert.Equal(t, "Nullable(String)", result.Mapping["total"].Type)
+	assert.Equal(t, "DECIMAL(100,10)", result.Mapping["total"].Comment)
+
+	configured = parseNativeTypes(t, `{"type_configurations": {"decimal": {"overflow": "string"}}}`)
+	result = applyNativeTypes("foo", "bar", current, description, configured)
+	assert.Equal(t, "Decimal(76, 10)", result.Mapping["id"].Type)
+	assert.Empty(t, result.Mapping["id"].Comment)
+}
+
+func TestApplyNativeTypesDateTime(t *testing.T) {
+	description := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "DateTime64(9, 'UTC')", IsPrimaryKey: true},
+		{Name: "created_at", Type: "Nullable(DateTime64(9, 'UTC'))"},
+		{Name: "local_at", Type: "Nullable(DateTime64(0, 'UTC'))"},
+		{Name: "_fivetran_start", Type: "DateTime64(9, 'UTC')", IsPrimaryKey: true},
+		{Name: "_fivetran_end", Type: "Nullable(DateTime64(9, 'UTC'))"},
+		{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+	})
+
+	configured := parseNativeTypes(t, `{"type_configurations": {"datetime": {
+		"utc_precision": 6, "naive_timezone": "Europe/Berlin", "tables": {"foo.bar": {"utc_precision": 3}}}}}`)
+	result := applyNativeTypes("foo", "bar", nil, description, configured)
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "DateTime64(3, 'UTC')", IsPrimaryKey: true},
+		{Name: "created_at", Type: "Nullable(DateTime64(3, 'UTC'))"},
+		{Name: "local_at", Type: "Nullable(DateTime64(0, 'Europe/Berlin'))"},
+		{Name: "_fivetran_start", Type: "DateTime64(9, 'UTC')", IsPrimaryKey: true},
+		{Name: "_fivetran_end", Type: "Nullable(DateTime64(9, 'UTC'))"},
+		{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+	}), result)
+	result = applyNativeTypes("foo", "baz", nil, description, configured)
+	assert.Equal(t, "Nullable(DateTime64(6, 'UTC'))", result.Mapping["created_at"].Type)
+
+	// default types
+	assert.Equal(t, description, applyNativeTypes("foo", "bar", nil, description, nativeTypes{}))
+
+	// the existing columns of the same Fivetran type keep their type, the other ones are modified
+	current := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "DateTime64(9, 'UTC')", IsPrimaryKey: true},
+		{Name: "created_at", Type: "Nullable(DateTime64(0, 'UTC'))"},
+		{Name: "local_at", Type: "Nullable(DateTime64(0, 'UTC'))"},
+	})
+	result = applyNativeTypes("foo", "bar", current, description, configured)
+	assert.Equal(t, "DateTime64(9, 'UTC')", result.Mapping["id"].Type)
+	assert.Equal(t, "Nullable(DateTime64(3, 'UTC'))", result.Mapping["created_at"].Type)
+	assert.Equal(t, "Nullable(DateTime64(0, 'UTC'))", result.Mapping["local_at"].Type)
+
+	// the configured types are kept even if the configuration is removed
+	current = types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "created_at", Type: "Nullable(DateTime64(3, 'UTC'))"},
+		{Name: "local_at", Type: "Nullable(DateTime64(0, 'Europe/Berlin'))"},
+	})
+	result = applyNativeTypes("foo", "bar", current, description, nativeTypes{})
+	assert.Equal(t, "Nullable(DateTime64(3, 'UTC'))", result.Mapping["created_at"].Type)
+	assert.Equal(t, "Nullable(DateTime64(0, 'Europe/Berlin'))", result.Mapping["local_at"].Type)
+	assert.Equal(t, "DateTime64(9, 'UTC')", result.Mapping["id"].Type)
+}
+
+func TestApplyNativeTypesCompatibleTypes(t *testing.T) {
+	description := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Int64", IsPrimaryKey: true},
+		{Name: "count", Type: "Nullable(Int64)"},
+		{Name: "status", Type: "Nullable(String)"},
+		{Name: "country", Type: "Nullable(String)"},
+		{Name: "seen_at", Type: "Nullable(DateTime64(9, 'UTC'))"},
+		{Name: "day", Type: "Nullable(Date32)"},
+		{Name: "flag", Type: "Nullable(Int16)"},
+	})
+	current := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "UInt64", IsPrimaryKey: true},
+		{Name: "count", Type: "Nullable(UInt32)"},
+		{Name: "status", Type: "Enum8('a' = 1, 'b' = 2)"},
+		{Name: "country", Type: "LowCardinality(Nullable(String))"},
+		{Name: "seen_at", Type: "Nullable(DateTime('Europe/Berlin'))"},
+		{Name: "day", Type: "Nullable(Date)"},
+		{Name: "flag", Type: "Nullable(UInt32)"},
+	})
+
+	result := applyNativeTypes("foo", "bar", current, description, nativeTypes{})
+	assert.Equal(t, types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "UInt64", IsPrimaryKey: true},
+		{Name: "count", Type: "Nullable(UInt32)"},
+		// a different nullability
+		{Name: "status", Type: "Nullable(String)"},
+		{Name: "country", Type: "LowCardinality(Nullable(String))"},
+		{Name: "seen_at", Type: "Nullable(DateTime('Europe/Berlin'))"},
+		{Name: "day", Type: "Nullable(Date)"},
+		// a different Fivetran type
+		{Name: "flag", Type: "Nullable(Int16)"},
+	}), result)
+}
+
+func TestNativeTypeMigrations(t *testing.T) {
+	from := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Int64", IsPrimaryKey: true},
+		{Name: "payload", Type: "Nullable(String)", Comment: "JSON"},
+		{Name: "metadata", Type: "JSON", Comment: "JSON"},
+		{Name: "name", Type: "Nullable(String)"},
+		{Name: "opens_at", Type: "Nullable(String)", Comment: "NAIVE_TIME"},
+		{Name: "closes_at", Type: "Nullable(Time)", Comment: "NAIVE_TIME"},
+	})
+	jsonType := "JSON"
+	timeType := "Nullable(Time64(6))"
+	stringType := "Nullable(String)"
+	ops := []*types.AlterTableOp{
+		{Op: types.AlterTableModify, Column: "payload", Type: &jsonType},
+		{Op: types.AlterTableModify, Column: "opens_at", Type: &timeType},
+		{Op: types.AlterTableModify, Column: "metadata", Type: &stringType},
+		{Op: types.AlterTableModify, Column: "name", Type: &stringType},
+		{Op: types.AlterTableModify, Column: "closes_at", Type: &timeType},
+		{Op: types.AlterTableAdd, Column: "extra", Type: &jsonType},
+		{Op: types.AlterTableDrop, Column: "foo"},
+	}
+	assert.Equal(t, ops[:2], nativeTypeMigrations(from, ops))
+	assert.Empty(t, nativeTypeMigrations(from, ops[2:]))
+}
+
+func parseNativeTypes(t *testing.T, advancedConfig string) nativeTypes {
+	cfg, _, err := config.ParseAll(map[string]string{
+		config.HostKey:           "my.host",
+		config.AdvancedConfigKey: base64.StdEncoding.EncodeToString([]byte(advancedConfig)),
+	})
+	require.NoError(t, err)
+	return newNativeTypes(cfg)
+}
@@ -0,0 +1,64 @@
+package db
+
+import (
+	"context"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// pipeline calls read until it returns false, and handle for every batch it returns, in the same order.
+// The batches are read in a separate goroutine, so that reading, decrypting and parsing the next batch overlaps
+// with handling the previous one (which usually sends it to ClickHouse); up to queueDepth batches are read ahead
+// and wait in the queue. At most queueDepth+2 batches are in memory at the same time: the one being handled,
+// the ones in the queue, and the one being read. If queueDepth is 0, the batches are read and handled in sequence.
+//
+// The first error returned by read or handle is returned, and cancels the context passed to both of them,
+// so that the other function stops as soon as possible. read is never called concurrently,
+// and neither is handle; pipeline returns only after both of them have returned.
+func pipeline[T any](
+	ctx context.Context,
+	queueDepth uint,
+	read func(ctx context.Context) (T, bool, error),
+	handle func(ctx context.Context, batch T) error,
+) error {
+	if queueDepth == 0 {
+		for {
+			batch, ok, err := read(ctx)
+			if err != nil || !ok {
+				return err
+			}
+			if err = handle(ctx, batch); err != nil {
+				return err
+			}
+		}
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	queue := make(chan T, queueDepth)
+	eg.Go(func() error {
+		defer close(queue)
+		for {
+			batch, ok, err := read(ctx)
+			if err != nil || !ok {
+				return err
+			}
+			select {
+			case queue <- batch:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	})
+	eg.Go(func() error {
+		for batch := range queue {
+			// the queue is closed, but not drained, if read fails
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if err := handle(ctx, batch); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return eg.Wait()
+}
@@ -0,0 +1,207 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRead returns the batches 1..n, and counts how many of them were read.
+func countingRead(n int, reads *atomic.Int32) func(ctx context.Context) (int, bool, error) {
+	return func(ctx context.Context) (int, bool, error) {
+		if int(reads.Load()) == n {
+			return 0, false, nil
+		}
+		return int(reads.Add(1)), true, nil
+	}
+}
+
+func TestPipelineHandlesBatchesInOrder(t *testing.T) {
+	for _, queueDepth := range []uint{0, 1, 4} {
+		var reads atomic.Int32
+		var handled []int
+		err := pipeline(context.Background(), queueDepth, countingRead(10, &reads),
+			func(ctx context.Context, batch int) error {
+				handled = append(handled, batch)
+				return nil
+			})
+		assert.NoError(t, err, "queue depth %d", queueDepth)
+		assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, handled, "queue depth %d", queueDepth)
+	}
+}
+
+func TestPipelineSequentialWithoutQueue(t *testing.T) {
+	var reads atomic.Int32
+	err := pipeline(context.Background(), 0, countingRead(3, &reads),
+		func(ctx context.Context, batch int) error {
+			// the next batch is read only after this one is handled
+			assert.Equal(t, int32(batch), reads.Load())
+			return nil
+		})
+	assert.NoError(t, err)
+}
+
+func TestPipelineReadsWhileHandling(t *testing.T) {
+	var reads atomic.Int32
+	err := pipeline(context.Background(), 1, countingRead(3, &reads),
+		func(ctx context.Context, batch int) error {
+			if batch < 3 {
+				// the next batch is read while this one is handled
+				assert.Eventually(t, func() bool { return reads.Load() > int32(batch) }, 5*time.Second, time.Millisecond)
+			}
+			return nil
+		})
+	assert.NoError(t, err)
+}
+
+func TestPipelineBoundsReadAhead(t *testing.T) {
+	for _, queueDepth := range []uint{1, 3} {
+		var reads atomic.Int32
+		release := make(chan struct{})
+		done := make(chan error)
+		go func() {
+			done <- pipeline(context.Background(), queueDepth, countingRead(100, &reads),
+				func(ctx context.Context, batch int) error {
+					<-release
+					return nil
+				})
+		}()
+		// one batch is handled, queueDepth batches are in the queue, and one is waiting to be queued
+		expected := int32(queueDepth + 2)
+		assert.Eventually(t, func() bool { return reads.Load() == expected }, 5*time.Second, time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		assert.Equal(t, expected, reads.Load(), "queue depth %d", queueDepth)
+		close(release)
+		assert.NoError(t, <-done)
+		assert.Equal(t, int32(100), reads.Load())
+	}
+}
+
+func TestPipelineReadError(t *testing.T) {
+	for _, queueDepth := range []uint{0, 2} {
+		var handled []int
+		reads := 0
+		err := pipeline(context.Background(), queueDepth,
+			func(ctx context.Context) (int, bool, error) {
+				reads++
+				if reads == 3 {
+					return 0, false, fmt.Errorf("read error")
+				}
+				return reads, true, nil
+			},
+			func(ctx context.Context, batch int) error {
+				handled = append(handled, batch)
+				return nil
+			})
+		assert.EqualError(t, err, "read error", "queue depth %d", queueDepth)
+		assert.Equal(t, 3, reads)
+		assert.LessOrEqual(t, len(handled), 2)
+	}
+}
+
+func TestPipelineHandleError(t *testing.T) {
+	for _, queueDepth := range []uint{0, 2} {
+		var reads atomic.Int32
+		var handled []int
+		err := pipeline(context.Background(), queueDepth, countingRead(100, &reads),
+			func(ctx context.Context, batch int) error {
+				handled = append(handled, batch)
+				if batch == 2 {
+					return fmt.Errorf("handle error")
+				}
+				return nil
+			})
+		assert.EqualError(t, err, "handle error", "queue depth %d", queueDepth)
+		assert.Equal(t, []int{1, 2}, handled)
+		// reading stops after the error
+		assert.LessOrEqual(t, reads.Load(), int32(queueDepth+3))
+	}
+}
+
+func TestPipelineCancellation(t *testing.T) {
+	for _, queueDepth := range []uint{0, 2} {
+		ctx, cancel := context.WithCancel(context.Background())
+		var reads atomic.Int32
+		err := pipeline(ctx, queueDepth, countingRead(100, &reads),
+			func(ctx context.Context, batch int) error {
+				if batch < 2 {
+					return nil
+				}
+				cancel()
+				<-ctx.Done()
+				return ctx.Err()
+			})
+		assert.ErrorIs(t, err, context.Canceled, "queue depth %d", queueDepth)
+		assert.LessOrEqual(t, reads.Load(), int32(queueDepth+3))
+	}
+}
+
+func TestReplaceBatchPipeline(t *testing.T) {
+	mock := &batchingConn{t: t, names: []string{"id", "_fivetran_start"},
+		columnTypes: []string{"Int32", "DateTime64(9, 'UTC')"}}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{WriteBatchSize: 2, WriteQueueDepth: 2}}
+	csvColumns := historyModeCSVColumns()
+	csvColumns.All[1].TableIndex = 1
+
+	reader := openHistoryModeReader(t)
+	defer reader.Close()
+	totalRows, err := conn.ReplaceBatch(context.Background(), "tester", historyModeTable(), reader, csvColumns, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, totalRows)
+	require.Len(t, mock.batches, 3)
+	var ids []any
+	for _, batch := range mock.batches {
+		for i := range batch.columns[0].Rows() {
+			ids = append(ids, batch.columns[0].Row(i, false))
+		}
+	}
+	assert.Equal(t, []any{int32(1), int32(2), int32(3), int32(4), int32(5)}, ids)
+
+	// an insert error stops reading the file
+	mock.batches = nil
+	mock.sendErr = fmt.Errorf("send error")
+	reader = openHistoryModeReader(t)
+	defer reader.Close()
+	_, err = conn.ReplaceBatch(context.Background(), "tester", historyModeTable(), reader, csvColumns, "my-null-str")
+	assert.ErrorContains(t, err, "send error")
+	assert.Len(t, mock.batches, 1)
+}
+
+// benchmarkReplaceBatchLatency inserts 100k rows of the all_data_types shape in batches of 10k rows,
+// with a 50ms delay for every batch sent, which is about as long as it takes to read and parse it.
+// Overlapping the two would at best halve the time; the pipelined benchmark is about 1.4 times faster
+// than the sequential one (0.75s and 1.05s per op with a single CPU), as appending the columns to the batch
+// before it is sent competes with parsing the next batch for the CPU.
+func benchmarkReplaceBatchLatency(b *testing.B, queueDepth uint) {
+	const rows = 100_000
+	csvColumns, columnTypes := allDataTypesColumns()
+	fileName := writeAllDataTypesFile(b, csvColumns, rows)
+	mock := &batchingConn{t: b, names: csvColumns.TableColumnNames(), columnTypes: columnTypes,
+		sendDelay: 50 * time.Millisecond}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{WriteBatchSize: 10_000, WriteQueueDepth: queueDepth}}
+	table := &pb.Table{Name: "all_data_types"}
+	b.ResetTimer()
+	for range b.N {
+		reader := openBenchmarkReader(b, fileName)
+		totalRows, err := conn.ReplaceBatch(context.Background(), "tester", table, reader, csvColumns, "null")
+		require.NoError(b, err)
+		require.Equal(b, rows, totalRows)
+		reader.Close()
+		mock.batches = nil
+	}
+}
+
+func BenchmarkReplaceBatchSequential(b *testing.B) {
+	benchmarkReplaceBatchLatency(b, 0)
+}
+
+func BenchmarkReplaceBatchPipelined(b *testing.B) {
+	benchmarkReplaceBatchLatency(b, 1)
+}
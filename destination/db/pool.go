@@ -0,0 +1,204 @@
+package db
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"fivetran.com/fivetran_sdk/destination/common/log"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+)
+
+// pool is shared by all GRPC calls of the process: Fivetran issues many small calls per table per sync,
+// and opening a new connection for each of them means a TLS handshake and a ping every time.
+// As driver.Conn is a pool of connections itself, the MaxOpenConnections and MaxIdleConnections flags
+// apply across all the calls with the same connection config.
+var pool = newConnPool(time.Now)
+
+// connPool keeps one driver.Conn per connection config (see poolKey).
+// Entries that are not used by any call for longer than the idle timeout are closed in the background;
+// entries that were not pinged for longer than the health check interval are pinged again before they are reused.
+type connPool struct {
+	mu      sync.Mutex
+	entries map[string]*poolEntry
+	now     func() time.Time
+	// open creates a new driver.Conn for the connection config; replaced in tests
+	open      func(connConfig *config.Config) (driver.Conn, error)
+	evictOnce sync.Once
+	stop      chan struct{}
+	closed    bool
+}
+
+type poolEntry struct {
+	key  string
+	conn driver.Conn
+	// refs is the number of ClickHouseConnection wrappers that are not released yet
+	refs int
+	// lastUsed is the time when the entry was acquired or released the last time
+	lastUsed time.Time
+	// lastChecked is the time of the last successful ping; zero for the new entries
+	lastChecked time.Time
+	// evicted entries are closed as soon as they are released by all the calls
+	evicted bool
+}
+
+func newConnPool(now func() time.Time) *connPool {
+	return &connPool{
+		entries: make(map[string]*poolEntry),
+		now:     now,
+		open:    openClickHouseConn,
+		stop:    make(chan struct{}),
+	}
+}
+
+// acquire returns the pool entry for the connection config, creating it if necessary.
+// needsCheck is true if the entry has to be pinged before use; see checked and evict.
+func (p *connPool) acquire(connConfig *config.Config) (entry *poolEntry, needsCheck bool, err error) {
+	p.evictOnce.Do(func() {
+		go p.evictIdleLoop()
+	})
+	key := poolKey(connConfig)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil, false, fmt.Errorf("connection pool is closed")
+	}
+	now := p.now()
+	entry, ok := p.entries[key]
+	if !ok {
+		conn, err := p.open(connConfig)
+		if err != nil {
+			return nil, false, err
+		}
+		entry = &poolEntry{key: key, conn: conn}
+		p.entries[key] = entry
+	}
+	entry.refs++
+	entry.lastUsed = now
+	needsCheck = now.Sub(entry.lastChecked) >= *flags.ConnectionPoolHealthCheckInterval
+	return entry, needsCheck, nil
+}
+
+// checked records a successful ping of the entry.
+func (p *connPool) checked(entry *poolEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry.lastChecked = p.now()
+}
+
+// evict removes the entry from the pool, so the next calls open a new connection;
+// the entry itself is closed once it is released by all the calls that are still using it.
+func (p *connPool) evict(entry *poolEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.evictLocked(entry)
+}
+
+func (p *connPool) evictLocked(entry *poolEntry) {
+	if p.entries[entry.key] == entry {
+		delete(p.entries, entry.key)
+	}
+	entry.evicted = true
+	if entry.refs == 0 {
+		entry.conn.Close() //nolint:errcheck
+	}
+}
+
+func (p *connPool) release(entry *poolEntry) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	entry.refs--
+	entry.lastUsed = p.now()
+	if entry.evicted && entry.refs == 0 {
+		entry.conn.Close() //nolint:errcheck
+	}
+}
+
+// evictIdle closes the entries that are not used by any call for longer than the idle timeout.
+func (p *connPool) evictIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	now := p.now()
+	for _, entry := range p.entries {
+		if entry.refs == 0 && now.Sub(entry.lastUsed) >= *flags.ConnectionPoolIdleTimeout {
+			log.Info("Closing an idle ClickHouse connection")
+			p.evictLocked(entry)
+		}
+	}
+}
+
+func (p *connPool) evictIdleLoop() {
+	interval := *flags.ConnectionPoolIdleTimeout / 2
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.evictIdle()
+			// housekeeping for the other process-wide state
+			metadataCache.evictExpired()
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// close closes all the connections; any further acquire fails.
+func (p *connPool) close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return
+	}
+	p.closed = true
+	close(p.stop)
+	for _, entry := range p.entries {
+		entry.conn.Close() //nolint:errcheck
+		entry.evicted = true
+	}
+	p.entries = make(map[string]*poolEntry)
+}
+
+// ClosePool closes all the pooled ClickHouse connections; should be called once on the server shutdown.
+func ClosePool() {
+	pool.close()
+}
+
+// poolKey is a hash of all the connection config fields that affect the driver.Conn options.
+// DDL credentials are not included, as config.Config.ForDDL returns a config with the DDL user as Username.
+func poolKey(connConfig *config.Config) string {
+	h := sha256.New()
+	write := func(values ...string) {
+		for _, value := range values {
+			// length prefix, so different field values can't produce the same input
+			h.Write([]byte(strconv.Itoa(len(value)) + ":" + value))
+		}
+	}
+	write(strconv.Itoa(len(connConfig.Addresses)))
+	write(connConfig.Addresses...)
+	write(connConfig.ConnectionStrategy, connConfig.Username, connConfig.Password, connConfig.Protocol,
+		strconv.FormatBool(connConfig.Local))
+	if connConfig.Cluster != nil {
+		write("cluster", connConfig.Cluster.Name, strconv.FormatBool(connConfig.Cluster.Distributed))
+	}
+	if tlsOptions := connConfig.TLS; tlsOptions != nil {
+		write("tls", tlsOptions.ServerName, strconv.Itoa(int(tlsOptions.MinVersion)))
+		for _, cert := range tlsOptions.CACertificates {
+			write("ca", string(cert.Raw))
+		}
+		if tlsOptions.ClientCertificate != nil {
+			for _, certDER := range tlsOptions.ClientCertificate.Certificate {
+				write("client", string(certDER))
+			}
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
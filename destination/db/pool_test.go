@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePoolConn counts the pings and tracks if the connection was closed.
+type fakePoolConn struct {
+	driver.Conn
+	pingCount atomic.Int64
+	pingErr   error
+	closed    atomic.Bool
+}
+
+func (c *fakePoolConn) Ping(ctx context.Context) error {
+	c.pingCount.Add(1)
+	return c.pingErr
+}
+
+func (c *fakePoolConn) Close() error {
+	c.closed.Store(true)
+	return nil
+}
+
+// withTestPool replaces the process-wide pool with a pool of fakePoolConn, and a controllable clock.
+func withTestPool(t *testing.T) (opened *[]*fakePoolConn, advance func(d time.Duration)) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	prevPool := pool
+	pool = newConnPool(func() time.Time { return now })
+	opened = &[]*fakePoolConn{}
+	pool.open = func(connConfig *config.Config) (driver.Conn, error) {
+		conn := &fakePoolConn{}
+		*opened = append(*opened, conn)
+		return conn, nil
+	}
+	t.Cleanup(func() {
+		pool.close()
+		pool = prevPool
+	})
+	return opened, func(d time.Duration) { now = now.Add(d) }
+}
+
+func TestConnectionPoolReusesConnections(t *testing.T) {
+	opened, advance := withTestPool(t)
+	ctx := context.Background()
+	settings, err := config.NewSettings(nil)
+	require.NoError(t, err)
+	connConfig, err := config.Parse(map[string]string{"host": "my.host", "username": "fivetran", "password": "secret"})
+	require.NoError(t, err)
+
+	// the first call opens and pings the connection
+	conn1, err := GetClickHouseConnection(ctx, connConfig, settings)
+	require.NoError(t, err)
+	require.Len(t, *opened, 1)
+	assert.Equal(t, int64(1), (*opened)[0].pingCount.Load())
+
+	// a concurrent call with the same config shares the connection, without another ping
+	conn2, err := GetClickHouseConnection(ctx, connConfig, settings)
+	require.NoError(t, err)
+	assert.Len(t, *opened, 1)
+	assert.Equal(t, int64(1), (*opened)[0].pingCount.Load())
+	assert.Same(t, conn1.Conn, conn2.Conn)
+
+	// closing the wrappers only releases the pooled connection (a double close is a no-op)
+	assert.NoError(t, conn1.Close())
+	assert.NoError(t, conn1.Close())
+	assert.NoError(t, conn2.Close())
+	assert.False(t, (*opened)[0].closed.Load())
+	assert.Equal(t, 0, pool.entries[poolKey(connConfig)].refs)
+
+	// a different config (e.g. the DDL user) gets its own connection
+	ddlConfig := *connConfig
+	ddlConfig.Username = "fivetran_ddl"
+	conn3, err := GetClickHouseConnection(ctx, &ddlConfig, settings)
+	require.NoError(t, err)
+	assert.Len(t, *opened, 2)
+	assert.NoError(t, conn3.Close())
+
+	// after the health check interval, the connection is pinged again before reuse
+	advance(*flags.ConnectionPoolHealthCheckInterval)
+	conn4, err := GetClickHouseConnection(ctx, connConfig, settings)
+	require.NoError(t, err)
+	assert.Len(t, *opened, 2)
+	assert.Equal(t, int64(2), (*opened)[0].pingCount.Load())
+	assert.NoError(t, conn4.Close())
+}
+
+func TestConnectionPoolEvictsFailedConnections(t *testing.T) {
+	opened, advance := withTestPool(t)
+	ctx := context.Background()
+	settings, err := config.NewSettings(nil)
+	require.NoError(t, err)
+	connConfig, err := config.Parse(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+
+	conn1, err := GetClickHouseConnection(ctx, connConfig, settings)
+	require.NoError(t, err)
+
+	// the health check of the next call fails while the first call still uses the connection
+	advance(*flags.ConnectionPoolHealthCheckInterval)
+	(*opened)[0].pingErr = errors.New("authentication failed")
+	_, err = GetClickHouseConnection(ctx, connConfig, settings)
+	assert.ErrorContains(t, err, "ClickHouse connection error: authentication failed")
+	assert.False(t, (*opened)[0].closed.Load())
+
+	// the evicted connection is closed once released by the first call
+	assert.NoError(t, conn1.Close())
+	assert.True(t, (*opened)[0].closed.Load())
+
+	// the next call opens a new connection
+	conn2, err := GetClickHouseConnection(ctx, connConfig, settings)
+	require.NoError(t, err)
+	assert.Len(t, *opened, 2)
+	assert.NoError(t, conn2.Close())
+}
+
+func TestConnectionPoolEvictsIdleConnections(t *testing.T) {
+	opened, advance := withTestPool(t)
+	ctx := context.Background()
+	settings, err := config.NewSettings(nil)
+	require.NoError(t, err)
+	connConfig, err := config.Parse(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+
+	conn1, err := GetClickHouseConnection(ctx, connConfig, settings)
+	require.NoError(t, err)
+
+	// connections in use are never evicted
+	advance(*flags.ConnectionPoolIdleTimeout)
+	pool.evictIdle()
+	assert.False(t, (*opened)[0].closed.Load())
+
+	assert.NoError(t, conn1.Close())
+	advance(*flags.ConnectionPoolIdleTimeout - time.Second)
+	pool.evictIdle()
+	assert.False(t, (*opened)[0].closed.Load())
+
+	advance(time.Second)
+	pool.evictIdle()
+	assert.True(t, (*opened)[0].closed.Load())
+	assert.Empty(t, pool.entries)
+}
+
+func TestConnectionPoolClose(t *testing.T) {
+	opened, _ := withTestPool(t)
+	ctx := context.Background()
+	settings, err := config.NewSettings(nil)
+	require.NoError(t, err)
+	connConfig, err := config.Parse(map[string]string{"host": "my.host"})
+	require.NoError(t, err)
+
+	conn, err := GetClickHouseConnection(ctx, connConfig, settings)
+	require.NoError(t, err)
+	assert.NoError(t, conn.Close())
+
+	pool.close()
+	assert.True(t, (*opened)[0].closed.Load())
+	_, err = GetClickHouseConnection(ctx, connConfig, settings)
+	assert.ErrorContains(t, err, "connection pool is closed")
+}
+
+func TestPoolKey(t *testing.T) {
+	base, err := config.Parse(map[string]string{"host": "host1,host2", "username": "fivetran", "password": "secret"})
+	require.NoError(t, err)
+	assert.Equal(t, poolKey(base), poolKey(base))
+
+	// DDL credentials are not a part of the key, only the user the config connects as
+	withDDL := *base
+	withDDL.DDLUsername = "fivetran_ddl"
+	assert.Equal(t, poolKey(base), poolKey(&withDDL))
+	assert.NotEqual(t, poolKey(base), poolKey(withDDL.ForDDL()))
+
+	withPassword := *base
+	withPassword.Password = "other"
+	assert.NotEqual(t, poolKey(base), poolKey(&withPassword))
+
+	withAddresses := *base
+	withAddresses.Addresses = []string{"host1:9440host2:9440"}
+	assert.NotEqual(t, poolKey(base), poolKey(&withAddresses))
+
+	withTLS := *base
+	withTLS.TLS = &config.TLSOptions{ServerName: "my.host"}
+	assert.NotEqual(t, poolKey(base), poolKey(&withTLS))
+}
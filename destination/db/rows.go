@@ -20,6 +20,8 @@ import (
 // TODO: maybe add CSV index to the SELECT query to avoid all this?
 type RowsByPrimaryKeyValue map[string][]interface{}
 
+const emptyJSONObject = "{}"
+
 // ColumnTypesToEmptyScanRows creates a slice of N empty rows to scan the selected data into,
 // with correct types at every index based on the introspected table column types from ClickHouse.
 func ColumnTypesToEmptyScanRows(driverColumns *types.DriverColumns, n uint) [][]interface{} {
@@ -50,13 +52,28 @@ func GetDatabaseRowMappingKey(row []interface{}, csvCols *types.CSVColumns) (str
 		p := row[col.TableIndex]
 		switch p := p.(type) {
 		case *string:
-			key.WriteString(fmt.Sprint(*p))
+			if col.BinaryEncoding != "" {
+				// decoded BINARY, see GetCSVRowMappingKey
+				key.WriteString(values.EncodeRawBinary(col.BinaryEncoding, *p))
+			} else {
+				key.WriteString(fmt.Sprint(*p))
+			}
 		case *int16:
 			key.WriteString(fmt.Sprint(*p))
 		case *int32:
 			key.WriteString(fmt.Sprint(*p))
 		case *int64:
 			key.WriteString(fmt.Sprint(*p))
+		case *int8: // compatible integer types, see values.Convert
+			key.WriteString(fmt.Sprint(*p))
+		case *uint8:
+			key.WriteString(fmt.Sprint(*p))
+		case *uint16:
+			key.WriteString(fmt.Sprint(*p))
+		case *uint32:
+			key.WriteString(fmt.Sprint(*p))
+		case *uint64:
+			key.WriteString(fmt.Sprint(*p))
 		case *float32:
 			key.WriteString(fmt.Sprint(*p))
 		case *float64:
@@ -64,11 +81,11 @@ func GetDatabaseRowMappingKey(row []interface{}, csvCols *types.CSVColumns) (str
 		case *bool:
 			key.WriteString(fmt.Sprint(*p))
 		case *time.Time:
-			// format UTC datetime as nanos (due to possibly variable precision),
+			// format UTC datetime as ticks (due to possibly variable precision),
 			// the rest should actually match CSV datetime format
 			switch col.Type {
 			case pb.DataType_UTC_DATETIME:
-				key.WriteString(fmt.Sprint(p.UnixNano()))
+				key.WriteString(fmt.Sprint(values.UTCDateTimeTicks(*p, col.UTCDateTimePrecision())))
 			case pb.DataType_NAIVE_DATETIME:
 				key.WriteString(p.Format(constants.NaiveDateTimeFormat))
 			default:
@@ -76,6 +93,9 @@ func GetDatabaseRowMappingKey(row []interface{}, csvCols *types.CSVColumns) (str
 			}
 		case *decimal.Decimal:
 			key.WriteString(p.String())
+		case *time.Duration:
+			// native NAIVE_TIME, see GetCSVRowMappingKey
+			key.WriteString(fmt.Sprint(p.Nanoseconds()))
 		default:
 			return "", fmt.Errorf("can't use type %T as mapping key", p)
 		}
@@ -98,14 +118,28 @@ func GetCSVRowMappingKey(csvRow []string, csvCols *types.CSVColumns, isHistoryMo
 	for i, col := range csvCols.PrimaryKeys {
 		key.WriteString(col.Name)
 		key.WriteRune(':')
-		// reformat UTC datetime as nanos (due to possibly variable precision)
+		// reformat UTC datetime as ticks and native naive time as nanos (due to possibly variable precision);
+		// the ticks are truncated to the column precision, the same way as the database values
 		if col.Type == pb.DataType_UTC_DATETIME {
 			t, err := time.Parse(constants.UTCDateTimeFormat, csvRow[col.Index])
 			if err != nil {
 				return "", fmt.Errorf("can't parse value %s as UTC datetime for column %s: %w",
 					csvRow[col.Index], col.Name, err)
 			}
-			key.WriteString(fmt.Sprint(t.UnixNano()))
+			key.WriteString(fmt.Sprint(values.UTCDateTimeTicks(t, col.UTCDateTimePrecision())))
+		} else if col.NativeTime {
+			d, err := values.ParseNaiveTime(col.Name, csvRow[col.Index])
+			if err != nil {
+				return "", err
+			}
+			key.WriteString(fmt.Sprint(d.Nanoseconds()))
+		} else if col.BinaryEncoding != "" {
+			// re-encode decoded BINARY in the canonical form, as it is re-encoded from the database values
+			raw, err := values.ParseRawBinary(col.Name, col.BinaryEncoding, csvRow[col.Index], 0)
+			if err != nil {
+				return "", err
+			}
+			key.WriteString(values.EncodeRawBinary(col.BinaryEncoding, raw))
 		} else {
 			key.WriteString(csvRow[col.Index])
 		}
@@ -170,10 +204,10 @@ func ToInsertRow(
 	for i, col := range csvColumns.All {
 		// as a CSV may come in "shuffled", get the correct ClickHouse column index
 		if csvRow[i] == nullStr {
-			insertRow[col.TableIndex] = nil
+			insertRow[col.TableIndex] = nullValue(col)
 			continue
 		}
-		value, err := values.Parse(col.Name, col.Type, csvRow[i])
+		value, err := parseValue(col, csvRow[i])
 		if err != nil {
 			return nil, err
 		}
@@ -182,6 +216,58 @@ func ToInsertRow(
 	return insertRow, nil
 }
 
+// ToInsertRowFromTyped is similar to ToInsertRow, but converts a row of already typed values
+// from a typed batch file (such as Parquet), where NULL values are represented as nil.
+// See also: values.ParseTyped.
+func ToInsertRowFromTyped(
+	typedRow []any,
+	csvColumns *types.CSVColumns,
+) ([]any, error) {
+	if csvColumns == nil {
+		return nil, fmt.Errorf("table can't be nil")
+	}
+	if len(csvColumns.All) != len(typedRow) {
+		return nil, fmt.Errorf("expected %d columns, but the row contains %d", len(csvColumns.All), len(typedRow))
+	}
+	insertRow := make([]any, len(typedRow))
+	for i, col := range csvColumns.All {
+		if typedRow[i] == nil {
+			insertRow[col.TableIndex] = nullValue(col)
+			continue
+		}
+		value, coerced, err := values.ParseTypedWithPolicy(col.Name, col.Type, typedRow[i], col.Coercions.Policy(col.Type))
+		if err != nil {
+			return nil, err
+		}
+		if value, err = convert(col, inLocation(col, value), coerced); err != nil {
+			return nil, err
+		}
+		if str, ok := value.(string); ok && (col.NativeJSON || col.NativeTime) {
+			if value, err = parseValue(col, str); err != nil {
+				return nil, err
+			}
+		}
+		if str, ok := value.(string); ok && col.BinaryEncoding != "" {
+			// typed batch files always encode the binary values as base64, regardless of the configured encoding
+			value, err = values.ParseRawBinary(col.Name, constants.Base64Encoding, str, col.FixedLength)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if d, ok := value.(decimal.Decimal); ok {
+			if col.DecimalAsString {
+				value = d.String()
+			} else if col.DecimalLimits != nil {
+				if value, err = coerceDecimal(col, d); err != nil {
+					return nil, err
+				}
+			}
+		}
+		insertRow[col.TableIndex] = value
+	}
+	return insertRow, nil
+}
+
 // ToUpdatedRow merges an existing ClickHouse row with the CSV row values.
 // Fields that are equal to unmodifiedStr are not updated.
 // csvColumns - all CSV columns (not just primary keys).
@@ -209,16 +295,21 @@ func ToUpdatedRow(
 	updatedRow := make([]any, len(dbRow))
 	for i, value := range csvRow {
 		// as a CSV may come in "shuffled", get the correct ClickHouse column index
-		tableColIndex := csvColumns.All[i].TableIndex
+		col := csvColumns.All[i]
+		tableColIndex := col.TableIndex
 		if value == nullStr {
-			updatedRow[tableColIndex] = nil
+			updatedRow[tableColIndex] = nullValue(col)
 			continue
 		}
 		if value == unmodifiedStr {
 			updatedRow[tableColIndex] = dbRow[tableColIndex]
+			if str, ok := dbRow[tableColIndex].(*string); ok && col.NativeJSON {
+				// the driver does not accept *string for the native JSON columns, see values.ParseNativeJSON
+				updatedRow[tableColIndex] = *str
+			}
 			continue
 		}
-		parsedValue, err := values.Parse(csvColumns.All[i].Name, csvColumns.All[i].Type, value)
+		parsedValue, err := parseValue(col, value)
 		if err != nil {
 			return nil, err
 		}
@@ -227,6 +318,91 @@ func ToUpdatedRow(
 	return updatedRow, nil
 }
 
+// parseValue converts a CSV value to the column type, see values.ParseWithPolicy.
+// The columns with the opt-in native types are parsed with values.ParseNativeJSON, values.ParseNaiveTime
+// and values.ParseRawBinary instead; the DECIMAL values are validated with values.ParseDecimalString
+// or values.CoerceDecimal. The NAIVE_DATETIME values are converted to the column timezone, see inLocation,
+// and the values of the compatible columns to the column type, see convert.
+func parseValue(col *types.CSVColumn, value string) (any, error) {
+	switch {
+	case col.NativeJSON:
+		return values.ParseNativeJSON(col.Name, value)
+	case col.NativeTime:
+		return values.ParseNaiveTime(col.Name, value)
+	case col.BinaryEncoding != "":
+		return values.ParseRawBinary(col.Name, col.BinaryEncoding, value, col.FixedLength)
+	case col.DecimalAsString:
+		return values.ParseDecimalString(col.Name, value)
+	case col.DecimalLimits != nil:
+		result, err := values.Parse(col.Name, col.Type, value)
+		if err != nil {
+			return nil, err
+		}
+		return coerceDecimal(col, result.(decimal.Decimal))
+	default:
+		result, coerced, err := values.ParseWithPolicy(col.Name, col.Type, value, col.Coercions.Policy(col.Type))
+		if err != nil {
+			return nil, err
+		}
+		return convert(col, inLocation(col, result), coerced)
+	}
+}
+
+// convert converts a value to the compatible ClickHouse type of its column (see types.CSVColumn.ConvertTo)
+// with the coercion policy of the column type, and counts the value if it was coerced either by the conversion
+// or before it, see countCoercion.
+func convert(col *types.CSVColumn, value any, coerced bool) (any, error) {
+	if col.ConvertTo != "" && value != nil {
+		var converted bool
+		var err error
+		if value, converted, err = values.Convert(col.Name, col.ConvertTo, value, col.Coercions.Policy(col.Type)); err != nil {
+			return nil, err
+		}
+		coerced = coerced || converted
+	}
+	return countCoercion(col, value, coerced)
+}
+
+// inLocation converts a NAIVE_DATETIME value to the timezone of its column, if it is not UTC;
+// see types.CSVColumn.Location and values.InLocation.
+func inLocation(col *types.CSVColumn, value any) any {
+	if t, ok := value.(time.Time); ok && col.Location != nil {
+		return values.InLocation(t, col.Location)
+	}
+	return value
+}
+
+func coerceDecimal(col *types.CSVColumn, value decimal.Decimal) (any, error) {
+	result, coerced, err := values.CoerceDecimal(col.Name, value, col.DecimalLimits, col.Coercions.Policy(col.Type))
+	if err != nil {
+		return nil, err
+	}
+	return countCoercion(col, result, coerced)
+}
+
+// countCoercion counts a coerced value, see types.Coercions.
+// The primary key columns can't be NULL, so such values are rejected instead of being replaced with NULL.
+func countCoercion(col *types.CSVColumn, value any, coerced bool) (any, error) {
+	if !coerced {
+		return value, nil
+	}
+	if value == nil && col.IsPrimaryKey {
+		return nil, fmt.Errorf("value for primary key column %s is out of the supported range, and can't be replaced with NULL",
+			col.Name)
+	}
+	col.Coercions.Add(col.Name)
+	return value, nil
+}
+
+// nullValue is the value of a NULL field in a row to insert.
+// Native JSON columns can't be Nullable, so an empty JSON object is inserted instead.
+func nullValue(col *types.CSVColumn) any {
+	if col.NativeJSON {
+		return emptyJSONObject
+	}
+	return nil
+}
+
 // ToSoftDeletedRow updates an existing ClickHouse row with _fivetran_deleted and _fivetran_synced values from the CSV.
 // The rest of the fields are not updated (all other fields are marked as nullStr in the CSV).
 func ToSoftDeletedRow(
@@ -4,10 +4,13 @@ import (
 	"testing"
 	"time"
 
+	"fivetran.com/fivetran_sdk/destination/common/constants"
 	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/values"
 	pb "fivetran.com/fivetran_sdk/proto"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetCSVRowMappingKey(t *testing.T) {
@@ -90,6 +93,127 @@ func TestGetCSVRowMappingKeyArbitraryUTCPrecision(t *testing.T) {
 	}
 }
 
+func TestGetCSVRowMappingKeyLowerUTCPrecision(t *testing.T) {
+	precision := uint(3)
+	csvCol := &types.CSVColumn{Name: "dt_utc", Type: pb.DataType_UTC_DATETIME, Index: 0, UTCPrecision: &precision}
+	csvColumns := &types.CSVColumns{All: []*types.CSVColumn{csvCol}, PrimaryKeys: []*types.CSVColumn{csvCol}}
+	for _, value := range []string{"2021-03-04T22:44:22.123Z", "2021-03-04T22:44:22.123456789Z"} {
+		key, err := GetCSVRowMappingKey([]string{value}, csvColumns, false)
+		assert.NoError(t, err)
+		assert.Equal(t, "dt_utc:1614897862123", key)
+	}
+
+	// the database values are already truncated to the column precision
+	dbValue := time.Date(2021, time.March, 4, 22, 44, 22, 123000000, time.UTC)
+	key, err := GetDatabaseRowMappingKey([]any{&dbValue}, csvColumns)
+	assert.NoError(t, err)
+	assert.Equal(t, "dt_utc:1614897862123", key)
+}
+
+func TestToInsertRowNaiveDateTimeLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	colID := &types.CSVColumn{Name: "id", Type: pb.DataType_LONG, Index: 0, TableIndex: 0}
+	colLocal := &types.CSVColumn{Name: "local_at", Type: pb.DataType_NAIVE_DATETIME, Index: 1, TableIndex: 1, Location: berlin}
+	csvCols := &types.CSVColumns{
+		All:         []*types.CSVColumn{colID, colLocal},
+		PrimaryKeys: []*types.CSVColumn{colID},
+	}
+
+	row, err := ToInsertRow([]string{"42", "2024-07-01T10:30:15"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), time.Date(2024, time.July, 1, 10, 30, 15, 0, berlin)}, row)
+
+	row, err = ToInsertRowFromTyped([]any{int64(42), time.Date(2024, time.July, 1, 10, 30, 15, 0, time.UTC)}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), time.Date(2024, time.July, 1, 10, 30, 15, 0, berlin)}, row)
+
+	// the database values are read in the column timezone, so the wall clock time matches the CSV value
+	csvCols.PrimaryKeys = []*types.CSVColumn{colLocal}
+	dbValue := time.Date(2024, time.July, 1, 10, 30, 15, 0, berlin)
+	key, err := GetDatabaseRowMappingKey([]any{nil, &dbValue}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, "local_at:2024-07-01T10:30:15", key)
+}
+
+func TestToInsertRowCompatibleTypes(t *testing.T) {
+	colID := &types.CSVColumn{Name: "id", Type: pb.DataType_SHORT, Index: 0, TableIndex: 0, IsPrimaryKey: true, ConvertTo: "UInt8"}
+	colCount := &types.CSVColumn{Name: "count", Type: pb.DataType_LONG, Index: 1, TableIndex: 1, ConvertTo: "UInt32"}
+	colDay := &types.CSVColumn{Name: "day", Type: pb.DataType_NAIVE_DATE, Index: 2, TableIndex: 2, ConvertTo: "Date"}
+	csvCols := &types.CSVColumns{
+		All:         []*types.CSVColumn{colID, colCount, colDay},
+		PrimaryKeys: []*types.CSVColumn{colID},
+	}
+
+	row, err := ToInsertRow([]string{"42", "4294967295", "2024-07-01"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{uint8(42), uint32(4294967295), time.Date(2024, time.July, 1, 0, 0, 0, 0, time.UTC)}, row)
+
+	row, err = ToInsertRow([]string{"42", "my-null-str", "my-null-str"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{uint8(42), nil, nil}, row)
+
+	row, err = ToInsertRowFromTyped([]any{int32(42), int64(7), nil}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{uint8(42), uint32(7), nil}, row)
+
+	id := uint8(42)
+	key, err := GetDatabaseRowMappingKey([]any{&id, nil, nil}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, "id:42", key)
+}
+
+func TestToInsertRowCompatibleTypesCoercion(t *testing.T) {
+	newColumns := func(policies map[string]string) *types.CSVColumns {
+		coercions := types.NewCoercions(policies)
+		colID := &types.CSVColumn{Name: "id", Type: pb.DataType_SHORT, Index: 0, TableIndex: 0, IsPrimaryKey: true,
+			ConvertTo: "UInt8", Coercions: coercions}
+		colCount := &types.CSVColumn{Name: "count", Type: pb.DataType_LONG, Index: 1, TableIndex: 1,
+			ConvertTo: "UInt32", Coercions: coercions}
+		colDay := &types.CSVColumn{Name: "day", Type: pb.DataType_NAIVE_DATE, Index: 2, TableIndex: 2,
+			ConvertTo: "Date", Coercions: coercions}
+		return &types.CSVColumns{
+			All:         []*types.CSVColumn{colID, colCount, colDay},
+			PrimaryKeys: []*types.CSVColumn{colID},
+		}
+	}
+
+	// clamp (default): the values are set to the nearest value of the column type range,
+	// including the dates that were already clamped to the Date32 range
+	csvCols := newColumns(nil)
+	row, err := ToInsertRow([]string{"42", "-1", "1950-07-20"}, csvCols, "my-null-str")
+	require.NoError(t, err)
+	assert.Equal(t, []any{uint8(42), uint32(0), time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)}, row)
+	row, err = ToInsertRow([]string{"42", "4294967296", "1850-07-20"}, csvCols, "my-null-str")
+	require.NoError(t, err)
+	assert.Equal(t, []any{uint8(42), uint32(4294967295), time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)}, row)
+	row, err = ToInsertRowFromTyped([]any{int32(256), int64(7), nil}, csvCols)
+	require.NoError(t, err)
+	assert.Equal(t, []any{uint8(255), uint32(7), nil}, row)
+	assert.Equal(t, "count: 2, day: 2, id: 1", csvCols.All[0].Coercions.Summary())
+
+	// null: a primary key value can't be NULL
+	csvCols = newColumns(map[string]string{"short": constants.CoercionNull, "long": constants.CoercionNull,
+		"naive_date": constants.CoercionNull})
+	row, err = ToInsertRow([]string{"42", "-1", "1950-07-20"}, csvCols, "my-null-str")
+	require.NoError(t, err)
+	assert.Equal(t, []any{uint8(42), nil, nil}, row)
+	_, err = ToInsertRowFromTyped([]any{int32(256), int64(7), nil}, csvCols)
+	assert.ErrorContains(t, err, "value for primary key column id is out of the supported range")
+	assert.Equal(t, "count: 1, day: 1", csvCols.All[0].Coercions.Summary())
+
+	// fail
+	csvCols = newColumns(map[string]string{"short": constants.CoercionFail, "long": constants.CoercionFail,
+		"naive_date": constants.CoercionFail})
+	_, err = ToInsertRow([]string{"42", "-1", "2024-07-01"}, csvCols, "my-null-str")
+	assert.ErrorContains(t, err, "value -1 for column count is out of the UInt32 range")
+	_, err = ToInsertRow([]string{"42", "1", "1950-07-20"}, csvCols, "my-null-str")
+	assert.ErrorContains(t, err, "value 1950-07-20T00:00:00Z for column day is out of the Date range")
+	_, err = ToInsertRowFromTyped([]any{int32(256), int64(7), nil}, csvCols)
+	assert.ErrorContains(t, err, "value 256 for column id is out of the UInt8 range")
+	assert.Empty(t, csvCols.All[0].Coercions.Summary())
+}
+
 func TestToInsertRowValidation(t *testing.T) {
 	_, err := ToInsertRow(nil, nil, "")
 	assert.ErrorContains(t, err, "nullStr can't be empty")
@@ -213,6 +337,228 @@ func TestToInsertRowNullStr(t *testing.T) {
 	assert.Equal(t, []any{int64(43), "bar", nil, `{"foo": "bar"}`}, row)
 }
 
+func TestToInsertRowNativeJSON(t *testing.T) {
+	colID := &types.CSVColumn{Name: "id", Type: pb.DataType_LONG, Index: 0, TableIndex: 0}
+	colPayload := &types.CSVColumn{Name: "payload", Type: pb.DataType_JSON, Index: 1, TableIndex: 1, NativeJSON: true}
+	csvCols := &types.CSVColumns{
+		All:         []*types.CSVColumn{colID, colPayload},
+		PrimaryKeys: []*types.CSVColumn{colID},
+	}
+
+	row, err := ToInsertRow([]string{"42", `{"foo": "bar"}`}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), `{"foo": "bar"}`}, row)
+
+	// native JSON columns are not Nullable
+	row, err = ToInsertRow([]string{"42", "my-null-str"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), "{}"}, row)
+
+	_, err = ToInsertRow([]string{"42", `[1, 2]`}, csvCols, "my-null-str")
+	assert.ErrorContains(t, err, "native JSON columns only support JSON objects")
+
+	row, err = ToInsertRowFromTyped([]any{int64(42), `{"foo": "bar"}`}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), `{"foo": "bar"}`}, row)
+
+	row, err = ToInsertRowFromTyped([]any{int64(42), nil}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), "{}"}, row)
+
+	_, err = ToInsertRowFromTyped([]any{int64(42), `foo`}, csvCols)
+	assert.ErrorContains(t, err, "can't parse value foo as JSON for column payload")
+}
+
+func TestToInsertRowNativeTime(t *testing.T) {
+	colID := &types.CSVColumn{Name: "id", Type: pb.DataType_LONG, Index: 0, TableIndex: 0}
+	colTime := &types.CSVColumn{Name: "opens_at", Type: pb.DataType_NAIVE_TIME, Index: 1, TableIndex: 1, NativeTime: true}
+	csvCols := &types.CSVColumns{
+		All:         []*types.CSVColumn{colID, colTime},
+		PrimaryKeys: []*types.CSVColumn{colID},
+	}
+
+	row, err := ToInsertRow([]string{"42", "09:30:15.5"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), 9*time.Hour + 30*time.Minute + 15*time.Second + 500*time.Millisecond}, row)
+
+	row, err = ToInsertRow([]string{"42", "my-null-str"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), nil}, row)
+
+	_, err = ToInsertRow([]string{"42", "foo"}, csvCols, "my-null-str")
+	assert.ErrorContains(t, err, "can't parse value foo as naive time for column opens_at")
+
+	row, err = ToInsertRowFromTyped([]any{int64(42), "09:30"}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), 9*time.Hour + 30*time.Minute}, row)
+}
+
+func TestGetCSVRowMappingKeyNativeTime(t *testing.T) {
+	csvCol := &types.CSVColumn{Name: "t", Type: pb.DataType_NAIVE_TIME, Index: 0, NativeTime: true}
+	csvColumns := &types.CSVColumns{All: []*types.CSVColumn{csvCol}, PrimaryKeys: []*types.CSVColumn{csvCol}}
+	for _, value := range []string{"09:30", "09:30:00", "09:30:00.000"} {
+		key, err := GetCSVRowMappingKey([]string{value}, csvColumns, true)
+		assert.NoError(t, err)
+		assert.Equal(t, "t:34200000000000", key)
+	}
+	_, err := GetCSVRowMappingKey([]string{"foo"}, csvColumns, true)
+	assert.ErrorContains(t, err, "can't parse value foo as naive time for column t")
+
+	d := 9*time.Hour + 30*time.Minute
+	key, err := GetDatabaseRowMappingKey([]any{&d}, csvColumns)
+	assert.NoError(t, err)
+	assert.Equal(t, "t:34200000000000", key)
+}
+
+func TestToInsertRowRawBinary(t *testing.T) {
+	colID := &types.CSVColumn{Name: "id", Type: pb.DataType_LONG, Index: 0, TableIndex: 0}
+	colHash := &types.CSVColumn{Name: "hash", Type: pb.DataType_BINARY, Index: 1, TableIndex: 1,
+		BinaryEncoding: "hex", FixedLength: 2}
+	csvCols := &types.CSVColumns{
+		All:         []*types.CSVColumn{colID, colHash},
+		PrimaryKeys: []*types.CSVColumn{colID},
+	}
+
+	row, err := ToInsertRow([]string{"42", "ff00"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), "\xff\x00"}, row)
+
+	row, err = ToInsertRow([]string{"42", "my-null-str"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), nil}, row)
+
+	_, err = ToInsertRow([]string{"42", "ff"}, csvCols, "my-null-str")
+	assert.ErrorContains(t, err, "decoded value ff for column hash has length 1, expected 2")
+
+	// typed batch files encode the binary values as base64
+	row, err = ToInsertRowFromTyped([]any{int64(42), "/wA="}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), "\xff\x00"}, row)
+}
+
+func TestGetCSVRowMappingKeyRawBinary(t *testing.T) {
+	csvCol := &types.CSVColumn{Name: "b", Type: pb.DataType_BINARY, Index: 0, BinaryEncoding: "hex"}
+	csvColumns := &types.CSVColumns{All: []*types.CSVColumn{csvCol}, PrimaryKeys: []*types.CSVColumn{csvCol}}
+	for _, value := range []string{"ff00", "FF00"} {
+		key, err := GetCSVRowMappingKey([]string{value}, csvColumns, true)
+		assert.NoError(t, err)
+		assert.Equal(t, "b:ff00", key)
+	}
+	_, err := GetCSVRowMappingKey([]string{"foo"}, csvColumns, true)
+	assert.ErrorContains(t, err, "can't decode value foo as hex for column b")
+
+	raw := "\xff\x00"
+	key, err := GetDatabaseRowMappingKey([]any{&raw}, csvColumns)
+	assert.NoError(t, err)
+	assert.Equal(t, "b:ff00", key)
+
+	csvCol.BinaryEncoding = "base64"
+	key, err = GetDatabaseRowMappingKey([]any{&raw}, csvColumns)
+	assert.NoError(t, err)
+	assert.Equal(t, "b:/wA=", key)
+}
+
+func TestToInsertRowOutOfRangeDecimal(t *testing.T) {
+	colID := &types.CSVColumn{Name: "id", Type: pb.DataType_DECIMAL, Index: 0, TableIndex: 0, IsPrimaryKey: true,
+		DecimalAsString: true}
+	colAmount := &types.CSVColumn{Name: "amount", Type: pb.DataType_DECIMAL, Index: 1, TableIndex: 1,
+		DecimalLimits: &pb.DecimalParams{Precision: 6, Scale: 2}}
+	csvCols := &types.CSVColumns{
+		All:         []*types.CSVColumn{colID, colAmount},
+		PrimaryKeys: []*types.CSVColumn{colID},
+	}
+
+	row, err := ToInsertRow([]string{"1.500", "1234.5"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"1.500", decimal.New(12345, -1)}, row)
+
+	_, err = ToInsertRow([]string{"foo", "1234.5"}, csvCols, "my-null-str")
+	assert.ErrorContains(t, err, "can't parse value foo as decimal for column id")
+
+	_, err = ToInsertRow([]string{"1", "12345"}, csvCols, "my-null-str")
+	assert.ErrorContains(t, err, "value 12345 for column amount has 5 integer digits, and does not fit Decimal(6, 2)")
+
+	row, err = ToInsertRowFromTyped([]any{decimal.New(15, -1), decimal.New(12345, -1)}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{"1.5", decimal.New(12345, -1)}, row)
+
+	// too many fractional digits are truncated with the default clamp policy
+	row, err = ToInsertRowFromTyped([]any{decimal.New(15, -1), decimal.New(12345, -3)}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, "12.34", row[1].(decimal.Decimal).String())
+
+	// the values stored as String are mapped as is
+	key, err := GetCSVRowMappingKey([]string{"1.500", "1234.5"}, csvCols, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "id:1.500", key)
+	id := "1.500"
+	key, err = GetDatabaseRowMappingKey([]any{&id, nil}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, "id:1.500", key)
+}
+
+func TestToInsertRowCoercions(t *testing.T) {
+	coercions := types.NewCoercions(map[string]string{"naive_date": "null", "double": "fail"})
+	colID := &types.CSVColumn{Name: "id", Type: pb.DataType_NAIVE_DATE, Index: 0, TableIndex: 0, IsPrimaryKey: true,
+		Coercions: coercions}
+	colDate := &types.CSVColumn{Name: "d", Type: pb.DataType_NAIVE_DATE, Index: 1, TableIndex: 1, Coercions: coercions}
+	colTime := &types.CSVColumn{Name: "t", Type: pb.DataType_UTC_DATETIME, Index: 2, TableIndex: 2, Coercions: coercions}
+	colDouble := &types.CSVColumn{Name: "f", Type: pb.DataType_DOUBLE, Index: 3, TableIndex: 3, Coercions: coercions}
+	csvCols := &types.CSVColumns{
+		All:         []*types.CSVColumn{colID, colDate, colTime, colDouble},
+		PrimaryKeys: []*types.CSVColumn{colID},
+	}
+
+	row, err := ToInsertRow([]string{"2024-01-02", "1800-01-01", "9999-01-01T00:00:00Z", "1.5"}, csvCols, "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), nil, values.MaxDateTime64, 1.5}, row)
+
+	row, err = ToInsertRowFromTyped([]any{"2024-01-02", time.Date(2500, 1, 1, 0, 0, 0, 0, time.UTC), nil, nil}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), nil, nil, nil}, row)
+	assert.Equal(t, "d: 2, t: 1", coercions.Summary())
+
+	_, err = ToInsertRow([]string{"2024-01-02", "my-null-str", "my-null-str", "NaN"}, csvCols, "my-null-str")
+	assert.ErrorContains(t, err, "value NaN for column f is out of the supported DOUBLE range")
+
+	// the primary key columns can't be NULL
+	_, err = ToInsertRow([]string{"1800-01-01", "my-null-str", "my-null-str", "my-null-str"}, csvCols, "my-null-str")
+	assert.ErrorContains(t, err, "value for primary key column id is out of the supported range, and can't be replaced with NULL")
+	assert.Equal(t, "d: 2, t: 1", coercions.Summary())
+}
+
+func TestToInsertRowFromTyped(t *testing.T) {
+	colID := &types.CSVColumn{Name: "id", Type: pb.DataType_INT, Index: 0, TableIndex: 1}
+	colName := &types.CSVColumn{Name: "name", Type: pb.DataType_STRING, Index: 1, TableIndex: 2}
+	colAmount := &types.CSVColumn{Name: "amount", Type: pb.DataType_DECIMAL, Index: 2, TableIndex: 0}
+	colUpdatedAt := &types.CSVColumn{Name: "updated_at", Type: pb.DataType_UTC_DATETIME, Index: 3, TableIndex: 3}
+	csvCols := &types.CSVColumns{
+		All:         []*types.CSVColumn{colID, colName, colAmount, colUpdatedAt},
+		PrimaryKeys: []*types.CSVColumn{colID},
+	}
+
+	row, err := ToInsertRowFromTyped([]any{
+		int64(42), "foo", decimal.New(4747, -2), time.Date(2022, 3, 5, 4, 45, 12, 123456000, time.UTC),
+	}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{
+		decimal.New(4747, -2), int32(42), "foo", time.Date(2022, 3, 5, 4, 45, 12, 123456000, time.UTC),
+	}, row)
+
+	row, err = ToInsertRowFromTyped([]any{int64(43), nil, nil, nil}, csvCols)
+	assert.NoError(t, err)
+	assert.Equal(t, []any{nil, int32(43), nil, nil}, row)
+
+	_, err = ToInsertRowFromTyped([]any{int64(2147483648), "foo", nil, nil}, csvCols)
+	assert.ErrorContains(t, err, "value 2147483648 is out of int32 range for column id")
+
+	_, err = ToInsertRowFromTyped([]any{int64(42)}, csvCols)
+	assert.ErrorContains(t, err, "expected 4 columns, but the row contains 1")
+
+	_, err = ToInsertRowFromTyped([]any{int64(42)}, nil)
+	assert.ErrorContains(t, err, "table can't be nil")
+}
+
 func TestToUpdatedRowValidation(t *testing.T) {
 	_, err := ToUpdatedRow(nil, nil, nil, "", "")
 	assert.ErrorContains(t, err, "unmodifiedStr can't be empty")
@@ -301,6 +647,33 @@ func TestToUpdatedRow(t *testing.T) {
 	assert.Equal(t, []any{nil, "foo", nil, nil}, row)
 }
 
+func TestToUpdatedRowNativeJSON(t *testing.T) {
+	colID := &types.CSVColumn{Name: "id", Type: pb.DataType_LONG, Index: 0, TableIndex: 0}
+	colPayload := &types.CSVColumn{Name: "payload", Type: pb.DataType_JSON, Index: 1, TableIndex: 1, NativeJSON: true}
+	csvCols := &types.CSVColumns{
+		All:         []*types.CSVColumn{colID, colPayload},
+		PrimaryKeys: []*types.CSVColumn{colID},
+	}
+	// native JSON columns are scanned as *string, see types.MakeDriverColumns
+	payload := `{"foo":"bar"}`
+	dbRow := []any{int64(42), &payload}
+
+	row, err := ToUpdatedRow([]string{"42", "my-unmodified-str"}, dbRow, csvCols, "my-null-str", "my-unmodified-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), `{"foo":"bar"}`}, row)
+
+	row, err = ToUpdatedRow([]string{"42", "my-null-str"}, dbRow, csvCols, "my-null-str", "my-unmodified-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), "{}"}, row)
+
+	row, err = ToUpdatedRow([]string{"42", `{"foo": "qaz"}`}, dbRow, csvCols, "my-null-str", "my-unmodified-str")
+	assert.NoError(t, err)
+	assert.Equal(t, []any{int64(42), `{"foo": "qaz"}`}, row)
+
+	_, err = ToUpdatedRow([]string{"42", `42`}, dbRow, csvCols, "my-null-str", "my-unmodified-str")
+	assert.ErrorContains(t, err, "native JSON columns only support JSON objects")
+}
+
 func TestToSoftDeletedRowValidation(t *testing.T) {
 	csvRow := []string{"null", "null", "2022-03-05T04:45:12.123456789Z", "false"}
 	_, err := ToSoftDeletedRow(csvRow, nil, 1, 4)
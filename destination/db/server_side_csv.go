@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+	"fivetran.com/fivetran_sdk/destination/common/files"
+	"fivetran.com/fivetran_sdk/destination/common/log"
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/sql"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/ClickHouse/clickhouse-go/v2"
+)
+
+// isServerSideCSVSupported returns true if server-side CSV parsing is enabled (see config.Settings.ServerSideCSVParsing),
+// and ClickHouse parses the values of all the columns the same way as ToInsertRow does. It is not the case for the columns
+// with the values that are validated, decoded or converted on our side, and for the coercion policies other than clamp.
+// The values clamped by ClickHouse are not counted (see types.Coercions), so they are missing from the logged summary.
+//
+// Nor is it the case for the date and datetime columns: ClickHouse doesn't clamp the values out of the column type range
+// when it parses them, and the range of the DateTime64 columns goes beyond the one we clamp to (see values.MaxDateTime64).
+// _fivetran_synced is the exception, as it is always set by Fivetran to the time of the sync.
+func (conn *ClickHouseConnection) isServerSideCSVSupported(csvColumns *types.CSVColumns) bool {
+	if !conn.settings.ServerSideCSVParsing {
+		return false
+	}
+	for _, col := range csvColumns.All {
+		if col.NativeJSON || col.NativeTime || col.BinaryEncoding != "" || col.DecimalAsString || col.ConvertTo != "" {
+			return false
+		}
+		switch col.Type {
+		case pb.DataType_NAIVE_DATE, pb.DataType_NAIVE_DATETIME, pb.DataType_UTC_DATETIME:
+			if col.Name != constants.FivetranSynced {
+				return false
+			}
+		}
+		if col.Coercions.Policy(col.Type) != constants.CoercionClamp {
+			return false
+		}
+	}
+	return true
+}
+
+// serverSideCSVSettings are the CSV input format settings that reproduce the parsing of the Fivetran CSV values
+// in ToInsertRow: nullStr is the only NULL representation, the values are used as is, including empty strings
+// and whitespace, and the datetime values are in ISO 8601 with the "T" separator and optional "Z" suffix.
+func serverSideCSVSettings(nullStr string) clickhouse.Settings {
+	return clickhouse.Settings{
+		"format_csv_null_representation":    nullStr,
+		"input_format_null_as_default":      0,
+		"input_format_csv_empty_as_default": 0,
+		"input_format_csv_trim_whitespaces": 0,
+		"format_csv_allow_single_quotes":    0,
+		"date_time_input_format":            "best_effort",
+	}
+}
+
+// replaceBatchCSV is ReplaceBatch with server-side CSV parsing: the rows are read from the file WriteBatchSize
+// at a time, and sent as they are as the data of an INSERT ... FORMAT CSVWithNames statement, preceded by the CSV header,
+// so that the values are matched to the columns by name. The next batches are read while the previous one is sent,
+// see pipeline.
+//
+// NB: retries are handled by ExecInsert, which doesn't log the rows
+func (conn *ClickHouseConnection) replaceBatchCSV(
+	ctx context.Context,
+	qualifiedTableName sql.QualifiedTableName,
+	reader files.RawBatchFileReader,
+	nullStr string,
+	tokens *deduplicationTokens,
+) (int, error) {
+	statement, err := sql.GetInsertCSVStatement(qualifiedTableName, reader.Header())
+	if err != nil {
+		return 0, err
+	}
+	settings := serverSideCSVSettings(nullStr)
+	header := reader.RawHeader()
+	totalRows := 0
+	err = pipeline(ctx, conn.settings.WriteQueueDepth,
+		func(ctx context.Context) (tokenBatch[[]byte], bool, error) {
+			rows, count, err := reader.ReadRawBatch(conn.settings.WriteBatchSize)
+			if err != nil || rows == nil {
+				return tokenBatch[[]byte]{}, false, err
+			}
+			tokens.addRaw(rows)
+			totalRows += int(count)
+			log.Notice(fmt.Sprintf("[%s] Read batch of %d rows (total so far: %d)", insertBatchReplaceCSV, count, totalRows))
+			return tokenBatch[[]byte]{rows: rows, token: tokens.next()}, true, nil
+		},
+		func(ctx context.Context, batch tokenBatch[[]byte]) error {
+			return conn.ExecInsert(withDeduplicationToken(ctx, settings, batch.token), statement,
+				insertBatchReplaceCSV, true, header, batch.rows)
+		})
+	return totalRows, err
+}
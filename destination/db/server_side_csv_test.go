@@ -0,0 +1,190 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"fivetran.com/fivetran_sdk/destination/common/constants"
+	csvfile "fivetran.com/fivetran_sdk/destination/common/csv"
+	"fivetran.com/fivetran_sdk/destination/common/types"
+	"fivetran.com/fivetran_sdk/destination/db/config"
+	pb "fivetran.com/fivetran_sdk/proto"
+	"github.com/ClickHouse/clickhouse-go/v2"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/rs/zerolog"
+	zlog "github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sync/errgroup"
+)
+
+// syncedCSVFile has the same rows as historyModeCSVFile, with _fivetran_synced instead of _fivetran_start,
+// as the tables with other date and datetime columns are not parsed on the server (see isServerSideCSVSupported).
+const syncedCSVFile = "../../tests/resources/synced.csv"
+
+func syncedCSVColumns() *types.CSVColumns {
+	idCol := &types.CSVColumn{Index: 0, Name: "id", Type: pb.DataType_INT, IsPrimaryKey: true}
+	syncedCol := &types.CSVColumn{Index: 1, Name: constants.FivetranSynced, Type: pb.DataType_UTC_DATETIME}
+	return &types.CSVColumns{
+		All:         []*types.CSVColumn{idCol, syncedCol},
+		PrimaryKeys: []*types.CSVColumn{idCol},
+	}
+}
+
+func openSyncedReader(t *testing.T) *csvfile.CSVFileReader {
+	t.Helper()
+	reader, err := csvfile.NewCSVFileReader(syncedCSVFile, map[string][]byte{syncedCSVFile: nil},
+		pb.Compression_OFF, pb.Encryption_NONE)
+	require.NoError(t, err)
+	return reader
+}
+
+// recordingConn records the statements passed to Exec, and their insert settings (see recordInsertSettings).
+type recordingConn struct {
+	driver.Conn
+	mutex      sync.Mutex
+	statements []string
+	settings   []clickhouse.Settings
+	err        error
+}
+
+func (m *recordingConn) Exec(ctx context.Context, query string, args ...any) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.statements = append(m.statements, query)
+	m.settings = append(m.settings, recordedInsertSettings(ctx))
+	return m.err
+}
+
+func TestIsServerSideCSVSupported(t *testing.T) {
+	csvColumns := syncedCSVColumns()
+	conn := &ClickHouseConnection{settings: &config.Settings{ServerSideCSVParsing: true}}
+	assert.True(t, conn.isServerSideCSVSupported(csvColumns))
+
+	conn.settings.ServerSideCSVParsing = false
+	assert.False(t, conn.isServerSideCSVSupported(csvColumns))
+
+	conn.settings.ServerSideCSVParsing = true
+	for name, col := range map[string]*types.CSVColumn{
+		"native JSON":     {Name: "payload", Type: pb.DataType_JSON, NativeJSON: true},
+		"native time":     {Name: "opens_at", Type: pb.DataType_NAIVE_TIME, NativeTime: true},
+		"decoded binary":  {Name: "hash", Type: pb.DataType_BINARY, BinaryEncoding: constants.HexEncoding},
+		"decimal string":  {Name: "price", Type: pb.DataType_DECIMAL, DecimalAsString: true},
+		"compatible type": {Name: "count", Type: pb.DataType_LONG, ConvertTo: "UInt32"},
+		"null policy": {Name: "price", Type: pb.DataType_DOUBLE,
+			Coercions: types.NewCoercions(map[string]string{"double": constants.CoercionNull})},
+		"naive date":     {Name: "birthday", Type: pb.DataType_NAIVE_DATE},
+		"naive datetime": {Name: "opened_at", Type: pb.DataType_NAIVE_DATETIME},
+		"utc datetime":   {Name: constants.FivetranEnd, Type: pb.DataType_UTC_DATETIME},
+	} {
+		columns := &types.CSVColumns{All: append([]*types.CSVColumn{col}, csvColumns.All...), PrimaryKeys: csvColumns.PrimaryKeys}
+		assert.False(t, conn.isServerSideCSVSupported(columns), name)
+	}
+
+	// the clamp policy is reproduced by the CSV input format settings
+	columns := &types.CSVColumns{
+		All: append([]*types.CSVColumn{{Name: "price", Type: pb.DataType_DOUBLE,
+			Coercions: types.NewCoercions(map[string]string{"double": constants.CoercionClamp})}}, csvColumns.All...),
+		PrimaryKeys: csvColumns.PrimaryKeys,
+	}
+	assert.True(t, conn.isServerSideCSVSupported(columns))
+}
+
+func TestReplaceBatchServerSideCSV(t *testing.T) {
+	mock := &recordingConn{}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{WriteBatchSize: 3, ServerSideCSVParsing: true}}
+
+	reader := openSyncedReader(t)
+	defer reader.Close()
+
+	totalRows, err := conn.ReplaceBatch(context.Background(), "tester", historyModeTable(),
+		reader, syncedCSVColumns(), "my-null-str")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, totalRows)
+	assert.Len(t, mock.statements, 2)
+	assert.Contains(t, mock.statements[0],
+		"\nINSERT INTO `tester`.`users` (`id`,`_fivetran_synced`) FORMAT CSVWithNames\n"+
+			"\"id\",\"_fivetran_synced\"\n"+
+			"\"1\",\"2025-11-11T20:57:00Z\"\n"+
+			"\"2\",\"2025-11-11T20:57:00Z\"\n"+
+			"\"3\",\"2025-11-11T20:57:00Z\"\n")
+	assert.Contains(t, mock.statements[1],
+		"\nINSERT INTO `tester`.`users` (`id`,`_fivetran_synced`) FORMAT CSVWithNames\n"+
+			"\"id\",\"_fivetran_synced\"\n"+
+			"\"4\",\"2025-11-11T20:57:00Z\"\n"+
+			"\"5\",\"2025-11-11T20:57:00Z\"")
+}
+
+func TestReplaceBatchServerSideCSVParallelFiles(t *testing.T) {
+	mock := &recordingConn{}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{
+		WriteBatchSize:       1,
+		WriteQueueDepth:      1,
+		ServerSideCSVParsing: true,
+		ParallelReplaceFiles: 4,
+	}}
+
+	// the logs are written to stderr by all the goroutines, which would hide a data race from the race detector
+	level := zerolog.GlobalLevel()
+	zerolog.SetGlobalLevel(zerolog.WarnLevel)
+	defer zerolog.SetGlobalLevel(level)
+
+	// the files of a batch are inserted at the same time with the same connection, see service.processFilesInParallel
+	var eg errgroup.Group
+	totalRows := make([]int, conn.settings.ParallelReplaceFiles)
+	for i := range totalRows {
+		reader := openSyncedReader(t)
+		defer reader.Close()
+		eg.Go(func() (err error) {
+			totalRows[i], err = conn.ReplaceBatch(context.Background(), "tester", historyModeTable(),
+				reader, syncedCSVColumns(), "my-null-str")
+			return err
+		})
+	}
+	require.NoError(t, eg.Wait())
+	assert.Equal(t, []int{5, 5, 5, 5}, totalRows)
+	assert.Len(t, mock.statements, 20)
+	assert.Equal(t, int64(0), conn.errorCount)
+}
+
+func TestReplaceBatchServerSideCSVLogs(t *testing.T) {
+	var output bytes.Buffer
+	logger, level := zlog.Logger, zerolog.GlobalLevel()
+	zlog.Logger = zerolog.New(&output)
+	zerolog.SetGlobalLevel(zerolog.TraceLevel)
+	defer func() {
+		zlog.Logger = logger
+		zerolog.SetGlobalLevel(level)
+	}()
+
+	mock := &recordingConn{}
+	conn := &ClickHouseConnection{Conn: mock, settings: &config.Settings{WriteBatchSize: 3, ServerSideCSVParsing: true}}
+	reader := openSyncedReader(t)
+	defer reader.Close()
+	_, err := conn.ReplaceBatch(context.Background(), "tester", historyModeTable(),
+		reader, syncedCSVColumns(), "my-null-str")
+	require.NoError(t, err)
+
+	mock.err = fmt.Errorf("code: 27, message: Cannot parse input")
+	reader = openSyncedReader(t)
+	defer reader.Close()
+	_, err = conn.ReplaceBatch(context.Background(), "tester", historyModeTable(),
+		reader, syncedCSVColumns(), "my-null-str")
+	require.ErrorContains(t, err, "INSERT INTO `tester`.`users` (`id`,`_fivetran_synced`) FORMAT CSVWithNames")
+	assert.NotContains(t, err.Error(), "2025-11-11")
+
+	// the rows are sent, but only the statement is logged
+	assert.Contains(t, mock.statements[0], "\"1\",\"2025-11-11T20:57:00Z\"")
+	assert.Contains(t, output.String(), "INSERT INTO `tester`.`users` (`id`,`_fivetran_synced`) FORMAT CSVWithNames")
+	assert.NotContains(t, output.String(), "2025-11-11")
+}
+
+func TestServerSideCSVSettings(t *testing.T) {
+	settings := serverSideCSVSettings("my-null-str")
+	assert.Equal(t, "my-null-str", settings["format_csv_null_representation"])
+	assert.Equal(t, 0, settings["input_format_csv_empty_as_default"])
+	assert.Equal(t, "best_effort", settings["date_time_input_format"])
+}
@@ -2,6 +2,8 @@ package sql
 
 import (
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,6 +15,9 @@ import (
 
 type QualifiedTableName string
 
+// localTableSuffix is appended to the Fivetran table name for shard-local tables of a Distributed table.
+const localTableSuffix = "_local"
+
 func GetQualifiedTableName(schemaName string, tableName string) (QualifiedTableName, error) {
 	if tableName == "" {
 		return "", fmt.Errorf("table name is empty")
@@ -32,7 +37,8 @@ func GetQualifiedTableName(schemaName string, tableName string) (QualifiedTableN
 //	MODIFY COLUMN IF EXISTS `c3` Int32 COMMENT ''
 //
 // Comments are added to distinguish certain Fivetran data types, see data_types.FivetranToClickHouseTypeWithComment.
-func GetAlterTableStatement(schemaName string, tableName string, ops []*types.AlterTableOp) (string, error) {
+// On self-hosted clusters, ON CLUSTER is added after the table name.
+func GetAlterTableStatement(schemaName string, tableName string, ops []*types.AlterTableOp, cluster *types.Cluster) (string, error) {
 	fullTableName, err := GetQualifiedTableName(schemaName, tableName)
 	if err != nil {
 		return "", err
@@ -53,6 +59,9 @@ func GetAlterTableStatement(schemaName string, tableName string, ops []*types.Al
 			if op.Comment != nil {
 				statementsBuilder.WriteString(fmt.Sprintf(" COMMENT '%s'", *op.Comment))
 			}
+			if op.Codec != nil && *op.Codec != "" {
+				statementsBuilder.WriteString(fmt.Sprintf(" CODEC(%s)", *op.Codec))
+			}
 		case types.AlterTableModify:
 			if op.Type == nil {
 				return "", fmt.Errorf("type for column %s is not specified", op.Column)
@@ -61,8 +70,21 @@ func GetAlterTableStatement(schemaName string, tableName string, ops []*types.Al
 			if op.Comment != nil {
 				statementsBuilder.WriteString(fmt.Sprintf(" COMMENT '%s'", *op.Comment))
 			}
+			if op.Codec != nil && *op.Codec != "" {
+				statementsBuilder.WriteString(fmt.Sprintf(" CODEC(%s)", *op.Codec))
+			}
 		case types.AlterTableDrop:
 			statementsBuilder.WriteString(fmt.Sprintf("DROP COLUMN IF EXISTS %s", identifier(op.Column)))
+		case types.AlterTableAddIndex:
+			if op.Index == nil {
+				return "", fmt.Errorf("index for column %s is not specified", op.Column)
+			}
+			statementsBuilder.WriteString(fmt.Sprintf("ADD INDEX IF NOT EXISTS %s", skipIndexDefinition(op.Column, op.Index)))
+		case types.AlterTableDropIndex:
+			if op.Index == nil {
+				return "", fmt.Errorf("index for column %s is not specified", op.Column)
+			}
+			statementsBuilder.WriteString(fmt.Sprintf("DROP INDEX IF EXISTS %s", identifier(op.Index.Name)))
 		}
 		if count < len(ops)-1 {
 			statementsBuilder.WriteString(",")
@@ -71,7 +93,7 @@ func GetAlterTableStatement(schemaName string, tableName string, ops []*types.Al
 	}
 
 	statements := statementsBuilder.String()
-	query := fmt.Sprintf("ALTER TABLE %s %s", fullTableName, statements)
+	query := fmt.Sprintf("ALTER TABLE %s%s %s", fullTableName, onCluster(cluster), statements)
 	return query, nil
 }
 
@@ -90,15 +112,15 @@ func GetCheckTableExistsStatement(schemaName string, tableName string) (string,
 	return fmt.Sprintf("EXISTS TABLE %s", fullName), nil
 }
 
-func GetCreateDatabaseStatement(schemaName string) (string, error) {
+func GetCreateDatabaseStatement(schemaName string, cluster *types.Cluster) (string, error) {
 	if schemaName == "" {
 		return "", fmt.Errorf("schema name is empty")
 	}
-	return fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s", identifier(schemaName)), nil
+	return fmt.Sprintf("CREATE DATABASE IF NOT EXISTS %s%s", identifier(schemaName), onCluster(cluster)), nil
 }
 
-func GetDropTableStatement(tableName QualifiedTableName) (string, error) {
-	return fmt.Sprintf("DROP TABLE IF EXISTS %s SYNC", tableName), nil
+func GetDropTableStatement(tableName QualifiedTableName, cluster *types.Cluster) (string, error) {
+	return fmt.Sprintf("DROP TABLE IF EXISTS %s%s SYNC", tableName, onCluster(cluster)), nil
 }
 
 func GetSelectFromSystemGrantsQuery(username string) (string, error) {
@@ -117,10 +139,18 @@ func GetSelectFromSystemGrantsQuery(username string) (string, error) {
 //	(`id` Int64, `c2` Nullable(String), `_fivetran_synced` DateTime64(9, 'UTC'), `_fivetran_deleted` Bool)
 //	ENGINE = ReplacingMergeTree(`_fivetran_synced`)
 //	ORDER BY (`id`)
+//
+// On self-hosted clusters, the table is created ON CLUSTER with ReplicatedReplacingMergeTree engine,
+// see replacingMergeTreeEngine.
+// Column codecs and skip indexes are taken from the table description, see types.ColumnOptions.
+// Optional options add the PARTITION BY, PRIMARY KEY, TTL and SETTINGS clauses, and extend the ORDER BY
+// with extra columns before and after the primary key columns; see getTableEngineClauses.
 func GetCreateTableStatement(
 	schemaName string,
 	tableName string,
 	tableDescription *types.TableDescription,
+	cluster *types.Cluster,
+	options *types.TableEngineOptions,
 ) (string, error) {
 	fullName, err := GetQualifiedTableName(schemaName, tableName)
 	if err != nil {
@@ -136,6 +166,7 @@ func GetCreateTableStatement(
 		return "", fmt.Errorf("no %s column for table %s", constants.FivetranSynced, fullName)
 	}
 	var orderByCols []string
+	var indexes []string
 	var columnsBuilder strings.Builder
 	count := 0
 	for _, col := range tableDescription.Columns {
@@ -143,25 +174,199 @@ func GetCreateTableStatement(
 		if col.Comment != "" {
 			columnsBuilder.WriteString(fmt.Sprintf(" COMMENT '%s'", col.Comment))
 		}
+		if col.Codec != "" {
+			columnsBuilder.WriteString(fmt.Sprintf(" CODEC(%s)", col.Codec))
+		}
 		if col.IsPrimaryKey {
 			orderByCols = append(orderByCols, identifier(col.Name))
 		}
+		for _, index := range col.SkipIndexes {
+			indexes = append(indexes, fmt.Sprintf("INDEX %s", skipIndexDefinition(col.Name, index)))
+		}
 		if count < len(tableDescription.Columns)-1 {
 			columnsBuilder.WriteString(",")
 		}
 		count++
 	}
+	for _, index := range indexes {
+		columnsBuilder.WriteString(",")
+		columnsBuilder.WriteString(index)
+	}
 	columns := columnsBuilder.String()
+	clauses, err := getTableEngineClauses(fullName, tableDescription, orderByCols, options)
+	if err != nil {
+		return "", err
+	}
 
 	query := fmt.Sprintf(
-		"CREATE TABLE IF NOT EXISTS %s (%s) ENGINE = ReplacingMergeTree(%s) ORDER BY (%s)",
-		fullName, columns, identifier(constants.FivetranSynced), strings.Join(orderByCols, ","))
+		"CREATE TABLE IF NOT EXISTS %s%s (%s) ENGINE = %s %s",
+		fullName, onCluster(cluster), columns, replacingMergeTreeEngine(cluster), clauses)
 	return query, nil
 }
 
+// getTableEngineClauses generates the ORDER BY clause, followed by the optional clauses from options:
+//
+//	ORDER BY (`tenant`,`id`,`updated_at`) PARTITION BY toYYYYMM(`created_at`) PRIMARY KEY (`tenant`,`id`)
+//	TTL `created_at` + INTERVAL 1 YEAR SETTINGS storage_policy = 'tiered', index_granularity = 4096
+//
+// The ORDER BY prefix and suffix columns have to exist in the table; primary key columns are not repeated.
+// Since ReplacingMergeTree deduplicates the rows by the ORDER BY columns, the extra columns
+// should never change between the versions of the same row.
+func getTableEngineClauses(
+	fullName QualifiedTableName,
+	tableDescription *types.TableDescription,
+	primaryKeyCols []string,
+	options *types.TableEngineOptions,
+) (string, error) {
+	if options == nil {
+		return fmt.Sprintf("ORDER BY (%s)", strings.Join(primaryKeyCols, ",")), nil
+	}
+	orderByCols := make([]string, 0, len(options.OrderByPrefix)+len(primaryKeyCols)+len(options.OrderBySuffix))
+	seen := make(map[string]bool, cap(orderByCols))
+	addOrderByCol := func(col string) {
+		if !seen[col] {
+			seen[col] = true
+			orderByCols = append(orderByCols, col)
+		}
+	}
+	for _, cols := range [][]string{options.OrderByPrefix, options.OrderBySuffix} {
+		for _, col := range cols {
+			if tableDescription.Mapping[col] == nil {
+				return "", fmt.Errorf("ORDER BY column %s does not exist in table %s", col, fullName)
+			}
+		}
+	}
+	for _, col := range options.OrderByPrefix {
+		addOrderByCol(identifier(col))
+	}
+	for _, col := range primaryKeyCols {
+		addOrderByCol(col)
+	}
+	for _, col := range options.OrderBySuffix {
+		addOrderByCol(identifier(col))
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("ORDER BY (%s)", strings.Join(orderByCols, ",")))
+	if options.PartitionBy != "" {
+		builder.WriteString(fmt.Sprintf(" PARTITION BY %s", options.PartitionBy))
+	}
+	if len(options.PrimaryKey) > 0 {
+		if len(options.PrimaryKey) > len(orderByCols) {
+			return "", fmt.Errorf("PRIMARY KEY of table %s has more columns than the ORDER BY", fullName)
+		}
+		primaryKey := make([]string, len(options.PrimaryKey))
+		for i, col := range options.PrimaryKey {
+			primaryKey[i] = identifier(col)
+			if primaryKey[i] != orderByCols[i] {
+				return "", fmt.Errorf("PRIMARY KEY of table %s should be a prefix of the ORDER BY (%s)",
+					fullName, strings.Join(orderByCols, ","))
+			}
+		}
+		builder.WriteString(fmt.Sprintf(" PRIMARY KEY (%s)", strings.Join(primaryKey, ",")))
+	}
+	if options.TTL != "" {
+		builder.WriteString(fmt.Sprintf(" TTL %s", options.TTL))
+	}
+	if len(options.Settings) > 0 {
+		builder.WriteString(" SETTINGS ")
+		builder.WriteString(getTableSettings(options.Settings))
+	}
+	return builder.String(), nil
+}
+
+// getTableSettings generates the SETTINGS clause values; storage_policy and index_granularity go first,
+// followed by the other settings sorted by name, so the statement is deterministic.
+func getTableSettings(settings map[string]any) string {
+	var names, otherNames []string
+	for _, name := range []string{"storage_policy", "index_granularity"} {
+		if _, ok := settings[name]; ok {
+			names = append(names, name)
+		}
+	}
+	for name := range settings {
+		if name != "storage_policy" && name != "index_granularity" {
+			otherNames = append(otherNames, name)
+		}
+	}
+	sort.Strings(otherNames)
+	names = append(names, otherNames...)
+	result := make([]string, len(names))
+	for i, name := range names {
+		var value string
+		switch v := settings[name].(type) {
+		case string:
+			value = values.QuoteAndEscapeString(v)
+		case bool:
+			value = "0"
+			if v {
+				value = "1"
+			}
+		case float64:
+			value = strconv.FormatFloat(v, 'f', -1, 64)
+		default:
+			value = values.QuoteAndEscapeString(fmt.Sprint(v))
+		}
+		result[i] = fmt.Sprintf("%s = %s", name, value)
+	}
+	return strings.Join(result, ", ")
+}
+
+// GetCreateDistributedTableStatement sample generated query:
+//
+//	CREATE TABLE IF NOT EXISTS `foo`.`bar` ON CLUSTER `my_cluster` AS `foo`.`bar_local`
+//	ENGINE = Distributed('my_cluster', 'foo', 'bar_local', cityHash64(`id`))
+//
+// The Distributed table has the same structure as the shard-local table (see LocalTableName).
+// If the cluster has no explicit sharding key, a hash of the primary key columns (excluding _fivetran_start)
+// is used, so all versions of a particular row are stored on the same shard.
+func GetCreateDistributedTableStatement(
+	schemaName string,
+	tableName string,
+	tableDescription *types.TableDescription,
+	cluster *types.Cluster,
+) (string, error) {
+	fullName, err := GetQualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return "", err
+	}
+	if !cluster.IsDistributed() {
+		return "", fmt.Errorf("cluster is not configured to use Distributed tables for table %s", fullName)
+	}
+	shardingKey := cluster.ShardingKey
+	if shardingKey == "" {
+		if tableDescription == nil {
+			return "", fmt.Errorf("no table description to generate a sharding key for table %s", fullName)
+		}
+		var keyCols []string
+		for _, col := range tableDescription.PrimaryKeys {
+			if col != constants.FivetranStart {
+				keyCols = append(keyCols, identifier(col))
+			}
+		}
+		if len(keyCols) == 0 {
+			return "", fmt.Errorf("no primary keys to generate a sharding key for table %s", fullName)
+		}
+		shardingKey = fmt.Sprintf("cityHash64(%s)", strings.Join(keyCols, ","))
+	}
+	localTableName := LocalTableName(tableName)
+	return fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s%s AS %s.%s ENGINE = Distributed(%s, %s, %s, %s)",
+		fullName, onCluster(cluster), identifier(schemaName), identifier(localTableName),
+		values.QuoteAndEscapeString(cluster.Name), values.QuoteAndEscapeString(schemaName),
+		values.QuoteAndEscapeString(localTableName), shardingKey), nil
+}
+
+// LocalTableName returns the name of the shard-local table that stores the data of a Distributed table.
+func LocalTableName(tableName string) string {
+	return tableName + localTableSuffix
+}
+
 // GetTruncateTableStatement generates a query for either "soft" (ALTER TABLE UPDATE) or "hard" (ALTER TABLE DELETE) table truncation.
 //
 // Important: Fivetran uses milliseconds (not nanos) precision for the _fivetran_synced column.
+// The column is always DateTime64(9, 'UTC') regardless of config.DateTimeTypes, though toUnixTimestamp64Milli
+// would also work with any other DateTime64 precision.
 //
 // Even though syncedColumn and softDeletedColumn are known constants (_fivetran_synced, _fivetran_deleted)
 // and it is guaranteed that they were present in CreateTableRequest (and, consequently, GetCreateTableStatement),
@@ -184,6 +389,7 @@ func GetTruncateTableStatement(
 	syncedColumn string,
 	truncateBefore time.Time,
 	softDeletedColumn *string,
+	cluster *types.Cluster,
 ) (string, error) {
 	fullName, err := GetQualifiedTableName(schemaName, tableName)
 	if err != nil {
@@ -204,11 +410,11 @@ func GetTruncateTableStatement(
 	truncateBeforeMilli := truncateBefore.UnixMilli()
 
 	if softDeletedColumn != nil && *softDeletedColumn != "" {
-		query = fmt.Sprintf("ALTER TABLE %s UPDATE %s = 1 WHERE %s <= '%d'",
-			fullName, identifier(*softDeletedColumn), syncedColumnMilli, truncateBeforeMilli)
+		query = fmt.Sprintf("ALTER TABLE %s%s UPDATE %s = 1 WHERE %s <= '%d'",
+			fullName, onCluster(cluster), identifier(*softDeletedColumn), syncedColumnMilli, truncateBeforeMilli)
 	} else {
-		query = fmt.Sprintf("ALTER TABLE %s DELETE WHERE %s <= '%d'",
-			fullName, syncedColumnMilli, truncateBeforeMilli)
+		query = fmt.Sprintf("ALTER TABLE %s%s DELETE WHERE %s <= '%d'",
+			fullName, onCluster(cluster), syncedColumnMilli, truncateBeforeMilli)
 	}
 
 	return query, nil
@@ -232,25 +438,58 @@ func GetDescribeTableQuery(schemaName string, tableName string) (string, error)
 		return "", fmt.Errorf("table name is empty")
 	}
 	return fmt.Sprintf(
-		"SELECT name, type, comment, is_in_primary_key, numeric_precision, numeric_scale FROM system.columns WHERE database = '%s' AND table = '%s'",
+		"SELECT name, type, comment, is_in_primary_key, numeric_precision, numeric_scale, default_kind, default_expression "+
+			"FROM system.columns WHERE database = '%s' AND table = '%s'",
 		schemaName, tableName), nil
 }
 
+// GetInsertStatement generates the statement for a batch insert with an explicit list of columns,
+// so that the columns added to the table outside of Fivetran are filled by ClickHouse itself
+// (see types.TableDescription.UserColumns). Sample generated statement:
+//
+//	INSERT INTO `foo`.`bar` (`id`,`name`)
+func GetInsertStatement(qualifiedTableName QualifiedTableName, columnNames []string) (string, error) {
+	if qualifiedTableName == "" {
+		return "", fmt.Errorf("table name is empty")
+	}
+	if len(columnNames) == 0 {
+		return "", fmt.Errorf("column names list for table %s is empty", qualifiedTableName)
+	}
+	return fmt.Sprintf("INSERT INTO %s (%s)", qualifiedTableName, joinIdentifiers(columnNames)), nil
+}
+
+// GetInsertCSVStatement generates the statement for a batch insert of the CSV rows as they are in the batch file,
+// so that the values are parsed by ClickHouse; the header and the rows follow the statement on the next line.
+// Sample generated statement:
+//
+//	INSERT INTO `foo`.`bar` (`id`,`name`) FORMAT CSVWithNames
+func GetInsertCSVStatement(qualifiedTableName QualifiedTableName, columnNames []string) (string, error) {
+	statement, err := GetInsertStatement(qualifiedTableName, columnNames)
+	if err != nil {
+		return "", err
+	}
+	return statement + " FORMAT CSVWithNames", nil
+}
+
 // GetSelectByPrimaryKeysQuery converts a CSV slice + known primary key columns and their CSV cell indices to a SELECT FINAL query using values from CSV rows.
 // Sample generated query:
 //
-//	SELECT * FROM `foo`.`bar` FINAL WHERE (`id`, `name`) IN ((42, 'foo'), (144, 'bar')) ORDER BY (`id`, `name`) LIMIT N
+//	SELECT `id`, `name`, `ts` FROM `foo`.`bar` FINAL WHERE (`id`, `name`) IN ((42, 'foo'), (144, 'bar')) ORDER BY (`id`, `name`) LIMIT N
 //
-// Where N is the number of rows in the CSV slice.
+// Where N is the number of rows in the CSV slice. `columnNames` are the selected columns, in the order of the table.
 func GetSelectByPrimaryKeysQuery(
 	csv [][]string,
 	csvColumns *types.CSVColumns,
+	columnNames []string,
 	qualifiedTableName QualifiedTableName,
 	isHistoryMode bool,
 ) (string, error) {
 	if qualifiedTableName == "" {
 		return "", fmt.Errorf("table name is empty")
 	}
+	if len(columnNames) == 0 {
+		return "", fmt.Errorf("column names list for table %s is empty", qualifiedTableName)
+	}
 	if len(csv) == 0 {
 		return "", fmt.Errorf("expected non-empty CSV slice for table %s", qualifiedTableName)
 	}
@@ -260,7 +499,7 @@ func GetSelectByPrimaryKeysQuery(
 	var orderByBuilder strings.Builder
 	orderByBuilder.WriteRune('(')
 	var clauseBuilder strings.Builder
-	clauseBuilder.WriteString(fmt.Sprintf("SELECT * FROM %s FINAL WHERE(", qualifiedTableName))
+	clauseBuilder.WriteString(fmt.Sprintf("SELECT %s FROM %s FINAL WHERE(", joinIdentifiers(columnNames), qualifiedTableName))
 
 	if isHistoryMode {
 		csvColumns.RemovePrimaryKey(constants.FivetranStart)
@@ -282,7 +521,7 @@ func GetSelectByPrimaryKeysQuery(
 			if col.Index > uint(len(csvRow)) {
 				return "", fmt.Errorf("can't find matching value for primary key with index %d", col.Index)
 			}
-			value, err := values.Value(col.Type, csvRow[col.Index])
+			value, err := columnValue(col, csvRow[col.Index])
 			if err != nil {
 				return "", err
 			}
@@ -306,11 +545,14 @@ func GetSelectByPrimaryKeysQuery(
 //
 //	DELETE FROM `foo`.`bar` WHERE (`id`, `name`) IN ((42, 'foo'), (43, 'bar'))
 //
+// On self-hosted clusters, ON CLUSTER is added after the table name.
+//
 // See also: https://clickhouse.com/docs/en/guides/developer/lightweight-delete
 func GetHardDeleteStatement(
 	csv [][]string,
 	csvColumns *types.CSVColumns,
 	qualifiedTableName QualifiedTableName,
+	cluster *types.Cluster,
 ) (string, error) {
 	if qualifiedTableName == "" {
 		return "", fmt.Errorf("table name is empty")
@@ -322,7 +564,7 @@ func GetHardDeleteStatement(
 		return "", fmt.Errorf("expected non-empty primary keys for table %s", qualifiedTableName)
 	}
 	var clauseBuilder strings.Builder
-	clauseBuilder.WriteString(fmt.Sprintf("DELETE FROM %s WHERE(", qualifiedTableName))
+	clauseBuilder.WriteString(fmt.Sprintf("DELETE FROM %s%s WHERE(", qualifiedTableName, onCluster(cluster)))
 	for i, col := range csvColumns.PrimaryKeys {
 		clauseBuilder.WriteString(identifier(col.Name))
 		if i < len(csvColumns.PrimaryKeys)-1 {
@@ -336,7 +578,7 @@ func GetHardDeleteStatement(
 			if col.Index > uint(len(csvRow)) {
 				return "", fmt.Errorf("can't find matching value for primary key with index %d", col.Index)
 			}
-			value, err := values.Value(col.Type, csvRow[col.Index])
+			value, err := columnValue(col, csvRow[col.Index])
 			if err != nil {
 				return "", err
 			}
@@ -363,7 +605,7 @@ func GetHardDeleteStatement(
 //
 // This function combines primary key equality checks with a timestamp comparison for each row,
 // matching the behavior of the Java writeDelete method which uses AND conditions between
-// primary keys and the timestamp filter.
+// primary keys and the timestamp filter. On self-hosted clusters, ON CLUSTER is added after the table name.
 //
 // The timestampColumn parameter specifies which column to use for the timestamp comparison (e.g., "_fivetran_start").
 // The timestampIndex parameter specifies the index of the timestamp column in the CSV rows.
@@ -376,6 +618,7 @@ func GetHardDeleteWithTimestampStatement(
 	timestampColumn string,
 	timestampIndex uint,
 	timestampType pb.DataType,
+	cluster *types.Cluster,
 ) (string, error) {
 	if qualifiedTableName == "" {
 		return "", fmt.Errorf("table name is empty")
@@ -391,7 +634,7 @@ func GetHardDeleteWithTimestampStatement(
 	}
 
 	var clauseBuilder strings.Builder
-	clauseBuilder.WriteString(fmt.Sprintf("DELETE FROM %s WHERE", qualifiedTableName))
+	clauseBuilder.WriteString(fmt.Sprintf("DELETE FROM %s%s WHERE", qualifiedTableName, onCluster(cluster)))
 
 	for i, csvRow := range csv {
 		if timestampIndex >= uint(len(csvRow)) {
@@ -406,7 +649,7 @@ func GetHardDeleteWithTimestampStatement(
 			if col.Index >= uint(len(csvRow)) {
 				return "", fmt.Errorf("can't find matching value for primary key with index %d", col.Index)
 			}
-			value, err := values.Value(col.Type, csvRow[col.Index])
+			value, err := columnValue(col, csvRow[col.Index])
 			if err != nil {
 				return "", err
 			}
@@ -462,6 +705,7 @@ func GetUpdateHistoryActiveStatement(
 	qualifiedTableName QualifiedTableName,
 	endTimestampIndex uint,
 	endTimestampType pb.DataType,
+	cluster *types.Cluster,
 ) (string, error) {
 	if qualifiedTableName == "" {
 		return "", fmt.Errorf("table name is empty")
@@ -474,7 +718,7 @@ func GetUpdateHistoryActiveStatement(
 	}
 
 	var queryBuilder strings.Builder
-	queryBuilder.WriteString(fmt.Sprintf("ALTER TABLE %s UPDATE ", qualifiedTableName))
+	queryBuilder.WriteString(fmt.Sprintf("ALTER TABLE %s%s UPDATE ", qualifiedTableName, onCluster(cluster)))
 
 	// SET clause: _fivetran_active = FALSE
 	queryBuilder.WriteString(identifier(constants.FivetranActive))
@@ -501,7 +745,7 @@ func GetUpdateHistoryActiveStatement(
 			if col.Index >= uint(len(csvRow)) {
 				return "", fmt.Errorf("can't find matching value for primary key with index %d", col.Index)
 			}
-			value, err := values.Value(col.Type, csvRow[col.Index])
+			value, err := columnValue(col, csvRow[col.Index])
 			if err != nil {
 				return "", err
 			}
@@ -545,7 +789,7 @@ func GetUpdateHistoryActiveStatement(
 			if col.Index >= uint(len(csvRow)) {
 				return "", fmt.Errorf("can't find matching value for primary key with index %d", col.Index)
 			}
-			value, err := values.Value(col.Type, csvRow[col.Index])
+			value, err := columnValue(col, csvRow[col.Index])
 			if err != nil {
 				return "", err
 			}
@@ -572,7 +816,7 @@ func GetUpdateHistoryActiveStatement(
 				if col.Index >= uint(len(csvRow)) {
 					return "", fmt.Errorf("can't find matching value for primary key with index %d", col.Index)
 				}
-				value, err := values.Value(col.Type, csvRow[col.Index])
+				value, err := columnValue(col, csvRow[col.Index])
 				if err != nil {
 					return "", err
 				}
@@ -599,9 +843,13 @@ func GetUpdateHistoryActiveStatement(
 
 // GetAllReplicasActiveQuery generates a query to check if there are no inactive replicas.
 // Excludes Hydra Read Only instances which have disable_insertion_and_mutation = '1'
+//
+// On self-hosted clusters, system.replicas is checked on all replicas of the configured cluster instead.
+// Unavailable shards are not skipped: the query fails if any of them is unreachable, as its replicas can't be checked.
 func GetAllReplicasActiveQuery(
 	schemaName string,
 	tableName string,
+	cluster *types.Cluster,
 ) (string, error) {
 	if tableName == "" {
 		return "", fmt.Errorf("table name is empty")
@@ -609,6 +857,14 @@ func GetAllReplicasActiveQuery(
 	if schemaName == "" {
 		return "", fmt.Errorf("schema name for table %s is empty", tableName)
 	}
+	if cluster != nil {
+		return fmt.Sprintf(
+			"SELECT toBool(countIf(mapExists((k, v) -> v = 0, replica_is_active)) = 0) AS all_replicas_active "+
+				"FROM clusterAllReplicas(%s, system.replicas) "+
+				"WHERE database = '%s' AND table = '%s'",
+			values.QuoteAndEscapeString(cluster.Name), schemaName, tableName,
+		), nil
+	}
 	return fmt.Sprintf(
 		`SELECT toBool(mapExists((k, v) -> (v = 0 AND k IN (
            SELECT replica_host FROM (
@@ -629,9 +885,11 @@ func GetAllReplicasActiveQuery(
 }
 
 // GetAllMutationsCompletedQuery generates a query to check that all mutations over a particular table on all cluster replicas are completed.
+// Uses the configured cluster on self-hosted deployments, and the default cluster in ClickHouse Cloud.
 func GetAllMutationsCompletedQuery(
 	schemaName string,
 	tableName string,
+	cluster *types.Cluster,
 ) (string, error) {
 	if tableName == "" {
 		return "", fmt.Errorf("table name is empty")
@@ -639,9 +897,13 @@ func GetAllMutationsCompletedQuery(
 	if schemaName == "" {
 		return "", fmt.Errorf("schema name for table %s is empty", tableName)
 	}
+	clusterName := "default"
+	if cluster != nil {
+		clusterName = values.QuoteAndEscapeString(cluster.Name)
+	}
 	return fmt.Sprintf(
-		"SELECT toBool(count(*) = 0) FROM clusterAllReplicas(default, system.mutations) WHERE database = '%s' AND table = '%s' AND is_done = 0",
-		schemaName, tableName,
+		"SELECT toBool(count(*) = 0) FROM clusterAllReplicas(%s, system.mutations) WHERE database = '%s' AND table = '%s' AND is_done = 0",
+		clusterName, schemaName, tableName,
 	), nil
 }
 
@@ -665,14 +927,7 @@ func GetInsertFromSelectStatement(
 	if len(colNames) == 0 {
 		return "", fmt.Errorf("column names list is empty")
 	}
-	b := strings.Builder{}
-	for i, colName := range colNames {
-		b.WriteString(identifier(colName))
-		if i < len(colNames)-1 {
-			b.WriteString(",")
-		}
-	}
-	joinedColNames := b.String()
+	joinedColNames := joinIdentifiers(colNames)
 	tableIdentifier := fmt.Sprintf("%s.%s", identifier(schemaName), identifier(tableName))
 	newTableIdentifier := fmt.Sprintf("%s.%s", identifier(schemaName), identifier(newTableName))
 	return fmt.Sprintf(
@@ -680,10 +935,96 @@ func GetInsertFromSelectStatement(
 		newTableIdentifier, joinedColNames, joinedColNames, tableIdentifier), nil
 }
 
+// GetNativeJSONCheckQuery generates a query that checks if all values of a String column are JSON objects,
+// and thus the column can be modified to the native JSON type. NULLs and empty strings are ignored,
+// see GetNativeJSONReplaceNullsStatement. Sample generated query:
+//
+//	SELECT toBool(count(*) = 0) FROM `foo`.`bar`
+//	WHERE `col` != '' AND NOT (isValidJSON(`col`) AND JSONType(`col`) = 'Object')
+func GetNativeJSONCheckQuery(schemaName string, tableName string, columnName string) (string, error) {
+	fullName, err := GetQualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return "", err
+	}
+	if columnName == "" {
+		return "", fmt.Errorf("column name is empty")
+	}
+	column := identifier(columnName)
+	return fmt.Sprintf(
+		"SELECT toBool(count(*) = 0) FROM %s WHERE %s != '' AND NOT (isValidJSON(%s) AND JSONType(%s) = 'Object')",
+		fullName, column, column, column), nil
+}
+
+// GetNativeJSONReplaceNullsStatement generates a statement that replaces NULLs and empty strings of a String column
+// with empty JSON objects, as the native JSON type can't be Nullable. Sample generated query:
+//
+//	ALTER TABLE `foo`.`bar` UPDATE `col` = '{}' WHERE `col` IS NULL OR `col` = ''
+func GetNativeJSONReplaceNullsStatement(
+	schemaName string,
+	tableName string,
+	columnName string,
+	cluster *types.Cluster,
+) (string, error) {
+	fullName, err := GetQualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return "", err
+	}
+	if columnName == "" {
+		return "", fmt.Errorf("column name is empty")
+	}
+	column := identifier(columnName)
+	return fmt.Sprintf("ALTER TABLE %s%s UPDATE %s = '{}' WHERE %s IS NULL OR %s = ''",
+		fullName, onCluster(cluster), column, column, column), nil
+}
+
+// GetNativeTimeNormalizeStatement generates a statement that appends the seconds to the short time values (HH:MM)
+// of a String column, before it is modified to the native time type. Sample generated query:
+//
+//	ALTER TABLE `foo`.`bar` UPDATE `col` = concat(`col`, ':00') WHERE length(`col`) = 5
+func GetNativeTimeNormalizeStatement(
+	schemaName string,
+	tableName string,
+	columnName string,
+	cluster *types.Cluster,
+) (string, error) {
+	fullName, err := GetQualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return "", err
+	}
+	if columnName == "" {
+		return "", fmt.Errorf("column name is empty")
+	}
+	column := identifier(columnName)
+	return fmt.Sprintf("ALTER TABLE %s%s UPDATE %s = concat(%s, ':00') WHERE length(%s) = %d",
+		fullName, onCluster(cluster), column, column, column, len(constants.NaiveTimeShortFormat)), nil
+}
+
+// GetCastCheckQuery generates a query that checks if all non-NULL values of a column can be converted to another type.
+// Sample generated query:
+//
+//	SELECT toBool(count(*) = 0) FROM `foo`.`bar`
+//	WHERE `col` IS NOT NULL AND accurateCastOrNull(`col`, 'Time64(6)') IS NULL
+func GetCastCheckQuery(schemaName string, tableName string, columnName string, columnType string) (string, error) {
+	fullName, err := GetQualifiedTableName(schemaName, tableName)
+	if err != nil {
+		return "", err
+	}
+	if columnName == "" {
+		return "", fmt.Errorf("column name is empty")
+	}
+	if columnType == "" {
+		return "", fmt.Errorf("column type is empty")
+	}
+	column := identifier(columnName)
+	return fmt.Sprintf("SELECT toBool(count(*) = 0) FROM %s WHERE %s IS NOT NULL AND accurateCastOrNull(%s, %s) IS NULL",
+		fullName, column, column, values.QuoteAndEscapeString(columnType)), nil
+}
+
 func GetRenameTableStatement(
 	schemaName string,
 	fromTableName string,
 	toTableName string,
+	cluster *types.Cluster,
 ) (string, error) {
 	if fromTableName == "" {
 		return "", fmt.Errorf("from table name is empty")
@@ -696,14 +1037,86 @@ func GetRenameTableStatement(
 	}
 	fromTableIdentifier := fmt.Sprintf("%s.%s", identifier(schemaName), identifier(fromTableName))
 	toTableIdentifier := fmt.Sprintf("%s.%s", identifier(schemaName), identifier(toTableName))
-	return fmt.Sprintf("RENAME TABLE %s TO %s",
-		fromTableIdentifier, toTableIdentifier), nil
+	return fmt.Sprintf("RENAME TABLE %s TO %s%s",
+		fromTableIdentifier, toTableIdentifier, onCluster(cluster)), nil
+}
+
+// onCluster returns the ON CLUSTER clause for DDL statements on self-hosted clusters,
+// or an empty string for ClickHouse Cloud.
+func onCluster(cluster *types.Cluster) string {
+	if cluster == nil {
+		return ""
+	}
+	return fmt.Sprintf(" ON CLUSTER %s", identifier(cluster.Name))
+}
+
+// replacingMergeTreeEngine returns the engine for Fivetran tables, with _fivetran_synced as the version column.
+// On self-hosted clusters, ReplicatedReplacingMergeTree is used; without explicit Keeper path,
+// the server defaults (default_replica_path, default_replica_name) apply.
+func replacingMergeTreeEngine(cluster *types.Cluster) string {
+	versionColumn := identifier(constants.FivetranSynced)
+	if !IsReplicatedEngine(cluster) {
+		return fmt.Sprintf("ReplacingMergeTree(%s)", versionColumn)
+	}
+	if cluster.KeeperPath == "" {
+		return fmt.Sprintf("ReplicatedReplacingMergeTree(%s)", versionColumn)
+	}
+	replicaName := cluster.ReplicaName
+	if replicaName == "" {
+		replicaName = "{replica}"
+	}
+	return fmt.Sprintf("ReplicatedReplacingMergeTree('%s', '%s', %s)", cluster.KeeperPath, replicaName, versionColumn)
+}
+
+// IsReplicatedEngine reports whether the tables are created with a Replicated engine, see replacingMergeTreeEngine.
+// Otherwise, the engine is ReplacingMergeTree, which ClickHouse Cloud replaces with SharedReplacingMergeTree.
+func IsReplicatedEngine(cluster *types.Cluster) bool {
+	return cluster != nil
+}
+
+// skipIndexDefinition generates a data-skipping index definition for the column, for example:
+//
+//	`email_bloom_filter_idx` `email` TYPE bloom_filter(0.01) GRANULARITY 1
+func skipIndexDefinition(columnName string, index *types.SkipIndex) string {
+	return fmt.Sprintf("%s %s TYPE %s GRANULARITY %d",
+		identifier(index.Name), identifier(columnName), index.Type, index.Granularity)
+}
+
+// columnValue formats a CSV value of a column as a SQL literal, see values.Value.
+// The values of the native NAIVE_TIME columns are normalized, as Fivetran might omit the seconds;
+// the values of the decoded BINARY columns are decoded by ClickHouse, see values.RawBinaryValue.
+// The UTC_DATETIME values are formatted as the ticks of the column precision, see values.UTCDateTimeValue;
+// the NAIVE_DATETIME values are parsed by ClickHouse in the column timezone, so they are kept as is.
+func columnValue(col *types.CSVColumn, value string) (string, error) {
+	if col.Type == pb.DataType_UTC_DATETIME {
+		return values.UTCDateTimeValue(value, col.UTCDateTimePrecision())
+	}
+	if col.BinaryEncoding != "" {
+		return values.RawBinaryValue(col.Name, col.BinaryEncoding, value)
+	}
+	if col.NativeTime {
+		d, err := values.ParseNaiveTime(col.Name, value)
+		if err != nil {
+			return "", err
+		}
+		return values.QuoteAndEscapeString(values.FormatNaiveTime(d)), nil
+	}
+	return values.Value(col.Type, value)
 }
 
 func identifier(s string) string {
 	return fmt.Sprintf("`%s`", s)
 }
 
+// joinIdentifiers returns the comma-separated list of the quoted identifiers, e.g. `a`,`b`.
+func joinIdentifiers(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = identifier(name)
+	}
+	return strings.Join(quoted, ",")
+}
+
 func toUnixTimestamp64Milli(arg string) string {
 	return fmt.Sprintf("toUnixTimestamp64Milli(%s)", arg)
 }
@@ -12,23 +12,23 @@ import (
 
 // GetRenameColumnStatement generates: ALTER TABLE `schema`.`table` RENAME COLUMN IF EXISTS `from` TO `to`
 // This is an instant metadata-only operation in ClickHouse.
-func GetRenameColumnStatement(schemaName string, tableName string, fromColumn string, toColumn string) (string, error) {
+func GetRenameColumnStatement(schemaName string, tableName string, fromColumn string, toColumn string, cluster *types.Cluster) (string, error) {
 	fullTableName, err := GetQualifiedTableName(schemaName, tableName)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("ALTER TABLE %s RENAME COLUMN IF EXISTS %s TO %s", fullTableName, identifier(fromColumn), identifier(toColumn)), nil
+	return fmt.Sprintf("ALTER TABLE %s%s RENAME COLUMN IF EXISTS %s TO %s", fullTableName, onCluster(cluster), identifier(fromColumn), identifier(toColumn)), nil
 }
 
 // GetUpdateColumnValueStatement generates: ALTER TABLE `schema`.`table` UPDATE `column` = <value> WHERE true
 // The value's SQL literal is embedded as-is (including NULL when value.IsNull()).
 // This is a mutation (background rewrite) in ClickHouse.
-func GetUpdateColumnValueStatement(schemaName string, tableName string, column string, value values.MigrateValue) (string, error) {
+func GetUpdateColumnValueStatement(schemaName string, tableName string, column string, value values.MigrateValue, cluster *types.Cluster) (string, error) {
 	fullTableName, err := GetQualifiedTableName(schemaName, tableName)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("ALTER TABLE %s UPDATE %s = %s WHERE true", fullTableName, identifier(column), value.Literal()), nil
+	return fmt.Sprintf("ALTER TABLE %s%s UPDATE %s = %s WHERE true", fullTableName, onCluster(cluster), identifier(column), value.Literal()), nil
 }
 
 // GetUpdateRowsAtOperationTimestampStatement generates:
@@ -44,14 +44,16 @@ func GetUpdateRowsAtOperationTimestampStatement(
 	column string,
 	value values.MigrateValue,
 	operationTimestampNanos string,
+	cluster *types.Cluster,
 ) (string, error) {
 	fullTableName, err := GetQualifiedTableName(schemaName, tableName)
 	if err != nil {
 		return "", err
 	}
 	return fmt.Sprintf(
-		"ALTER TABLE %s UPDATE %s = %s WHERE %s = '%s'",
+		"ALTER TABLE %s%s UPDATE %s = %s WHERE %s = '%s'",
 		fullTableName,
+		onCluster(cluster),
 		identifier(column),
 		value.Literal(),
 		identifier(constants.FivetranStart),
@@ -61,20 +63,20 @@ func GetUpdateRowsAtOperationTimestampStatement(
 
 // GetCopyColumnUpdateStatement generates: ALTER TABLE `schema`.`table` UPDATE `toColumn` = `fromColumn` WHERE true
 // Used for copying data from one column to another within the same table.
-func GetCopyColumnUpdateStatement(schemaName string, tableName string, toColumn string, fromColumn string) (string, error) {
+func GetCopyColumnUpdateStatement(schemaName string, tableName string, toColumn string, fromColumn string, cluster *types.Cluster) (string, error) {
 	fullTableName, err := GetQualifiedTableName(schemaName, tableName)
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("ALTER TABLE %s UPDATE %s = %s WHERE true", fullTableName, identifier(toColumn), identifier(fromColumn)), nil
+	return fmt.Sprintf("ALTER TABLE %s%s UPDATE %s = %s WHERE true", fullTableName, onCluster(cluster), identifier(toColumn), identifier(fromColumn)), nil
 }
 
 // GetCreateTableAsStatement generates: CREATE TABLE IF NOT EXISTS `schema`.`toTable` AS `schema`.`fromTable`
 // Clones the table structure and engine settings.
-func GetCreateTableAsStatement(schemaName string, fromTable string, toTable string) (string, error) {
+func GetCreateTableAsStatement(schemaName string, fromTable string, toTable string, cluster *types.Cluster) (string, error) {
 	fromIdentifier := fmt.Sprintf("%s.%s", identifier(schemaName), identifier(fromTable))
 	toIdentifier := fmt.Sprintf("%s.%s", identifier(schemaName), identifier(toTable))
-	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s AS %s", toIdentifier, fromIdentifier), nil
+	return fmt.Sprintf("CREATE TABLE IF NOT EXISTS %s%s AS %s", toIdentifier, onCluster(cluster), fromIdentifier), nil
 }
 
 // GetCloseActiveRowsStatement generates:
@@ -94,6 +96,7 @@ func GetCloseActiveRowsStatement(
 	tableName string,
 	operationTimestampNanos string,
 	columnFilter string,
+	cluster *types.Cluster,
 ) (string, error) {
 	fullTableName, err := GetQualifiedTableName(schemaName, tableName)
 	if err != nil {
@@ -108,8 +111,9 @@ func GetCloseActiveRowsStatement(
 		whereSuffix = fmt.Sprintf(" AND %s IS NOT NULL", identifier(columnFilter))
 	}
 	return fmt.Sprintf(
-		"ALTER TABLE %s UPDATE %s = false, %s = '%s' WHERE %s = true AND %s < '%s'%s",
+		"ALTER TABLE %s%s UPDATE %s = false, %s = '%s' WHERE %s = true AND %s < '%s'%s",
 		fullTableName,
+		onCluster(cluster),
 		identifier(constants.FivetranActive),
 		identifier(constants.FivetranEnd),
 		endTimestampNanos,
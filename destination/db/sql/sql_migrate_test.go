@@ -10,32 +10,32 @@ import (
 )
 
 func TestGetRenameColumnStatement(t *testing.T) {
-	stmt, err := GetRenameColumnStatement("s", "t", "old", "new")
+	stmt, err := GetRenameColumnStatement("s", "t", "old", "new", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `s`.`t` RENAME COLUMN IF EXISTS `old` TO `new`", stmt)
 
-	_, err = GetRenameColumnStatement("", "t", "old", "new")
+	_, err = GetRenameColumnStatement("", "t", "old", "new", nil)
 	assert.ErrorContains(t, err, "schema name for table t is empty")
 }
 
 func TestGetUpdateColumnValueStatement(t *testing.T) {
-	stmt, err := GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueQuoted("42"))
+	stmt, err := GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueQuoted("42"), nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `s`.`t` UPDATE `col` = '42' WHERE true", stmt)
 
-	stmt, err = GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueNull())
+	stmt, err = GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueNull(), nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `s`.`t` UPDATE `col` = NULL WHERE true", stmt)
 }
 
 func TestGetUpdateRowsAtOperationTimestampStatement(t *testing.T) {
-	stmt, err := GetUpdateRowsAtOperationTimestampStatement("s", "t", "col", values.NewMigrateValueQuoted("42"), "1117314420000000000")
+	stmt, err := GetUpdateRowsAtOperationTimestampStatement("s", "t", "col", values.NewMigrateValueQuoted("42"), "1117314420000000000", nil)
 	assert.NoError(t, err)
 	assert.Equal(t,
 		"ALTER TABLE `s`.`t` UPDATE `col` = '42' WHERE `_fivetran_start` = '1117314420000000000'",
 		stmt)
 
-	stmt, err = GetUpdateRowsAtOperationTimestampStatement("s", "t", "col", values.NewMigrateValueNull(), "1117314420000000000")
+	stmt, err = GetUpdateRowsAtOperationTimestampStatement("s", "t", "col", values.NewMigrateValueNull(), "1117314420000000000", nil)
 	assert.NoError(t, err)
 	assert.Equal(t,
 		"ALTER TABLE `s`.`t` UPDATE `col` = NULL WHERE `_fivetran_start` = '1117314420000000000'",
@@ -43,13 +43,13 @@ func TestGetUpdateRowsAtOperationTimestampStatement(t *testing.T) {
 }
 
 func TestGetCopyColumnUpdateStatement(t *testing.T) {
-	stmt, err := GetCopyColumnUpdateStatement("s", "t", "new_col", "old_col")
+	stmt, err := GetCopyColumnUpdateStatement("s", "t", "new_col", "old_col", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `s`.`t` UPDATE `new_col` = `old_col` WHERE true", stmt)
 }
 
 func TestGetCreateTableAsStatement(t *testing.T) {
-	stmt, err := GetCreateTableAsStatement("s", "from_t", "to_t")
+	stmt, err := GetCreateTableAsStatement("s", "from_t", "to_t", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `s`.`to_t` AS `s`.`from_t`", stmt)
 }
@@ -58,17 +58,45 @@ func TestGetCloseActiveRowsStatement(t *testing.T) {
 	// 1117314420000000000 - 1000000 = 1117314419999000000 (minus 1ms)
 	unfiltered := "ALTER TABLE `s`.`t` UPDATE `_fivetran_active` = false, `_fivetran_end` = '1117314419999000000' WHERE `_fivetran_active` = true AND `_fivetran_start` < '1117314420000000000'"
 
-	stmt, err := GetCloseActiveRowsStatement("s", "t", "1117314420000000000", "")
+	stmt, err := GetCloseActiveRowsStatement("s", "t", "1117314420000000000", "", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, unfiltered, stmt)
 
-	stmt, err = GetCloseActiveRowsStatement("s", "t", "1117314420000000000", "desc")
+	stmt, err = GetCloseActiveRowsStatement("s", "t", "1117314420000000000", "desc", nil)
 	assert.NoError(t, err)
 	assert.Equal(t,
 		unfiltered+" AND `desc` IS NOT NULL",
 		stmt)
 }
 
+func TestMigrateStatementsOnCluster(t *testing.T) {
+	cluster := &types.Cluster{Name: "my_cluster"}
+
+	stmt, err := GetRenameColumnStatement("s", "t", "old", "new", cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `s`.`t` ON CLUSTER `my_cluster` RENAME COLUMN IF EXISTS `old` TO `new`", stmt)
+
+	stmt, err = GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueQuoted("42"), cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `s`.`t` ON CLUSTER `my_cluster` UPDATE `col` = '42' WHERE true", stmt)
+
+	stmt, err = GetUpdateRowsAtOperationTimestampStatement("s", "t", "col", values.NewMigrateValueNull(), "1117314420000000000", cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `s`.`t` ON CLUSTER `my_cluster` UPDATE `col` = NULL WHERE `_fivetran_start` = '1117314420000000000'", stmt)
+
+	stmt, err = GetCopyColumnUpdateStatement("s", "t", "new_col", "old_col", cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `s`.`t` ON CLUSTER `my_cluster` UPDATE `new_col` = `old_col` WHERE true", stmt)
+
+	stmt, err = GetCreateTableAsStatement("s", "from_t", "to_t", cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `s`.`to_t` ON CLUSTER `my_cluster` AS `s`.`from_t`", stmt)
+
+	stmt, err = GetCloseActiveRowsStatement("s", "t", "1117314420000000000", "", cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `s`.`t` ON CLUSTER `my_cluster` UPDATE `_fivetran_active` = false, `_fivetran_end` = '1117314419999000000' WHERE `_fivetran_active` = true AND `_fivetran_start` < '1117314420000000000'", stmt)
+}
+
 func TestGetInsertNewActiveVersionsStatement(t *testing.T) {
 	defaultVal := values.NewMigrateValueQuoted("Ordered article")
 	dataCols := []*types.ColumnDefinition{
@@ -192,17 +220,17 @@ func TestSubtractOneMillisecond(t *testing.T) {
 
 func TestGetUpdateColumnValueStatementSpecialChars(t *testing.T) {
 	// Single quote in value should be escaped
-	stmt, err := GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueQuoted("O'Brien"))
+	stmt, err := GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueQuoted("O'Brien"), nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `s`.`t` UPDATE `col` = 'O''Brien' WHERE true", stmt)
 
 	// Backslash in value
-	stmt, err = GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueQuoted("path\\to\\file"))
+	stmt, err = GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueQuoted("path\\to\\file"), nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `s`.`t` UPDATE `col` = 'path\\\\to\\\\file' WHERE true", stmt)
 
 	// Empty string value (not null)
-	stmt, err = GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueQuoted(""))
+	stmt, err = GetUpdateColumnValueStatement("s", "t", "col", values.NewMigrateValueQuoted(""), nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `s`.`t` UPDATE `col` = '' WHERE true", stmt)
 }
@@ -221,7 +249,7 @@ func TestGetMaxFivetranStartQuery(t *testing.T) {
 
 func TestGetCloseActiveRowsStatement_InvalidTimestamp(t *testing.T) {
 	// A non-numeric operation timestamp is a real parse error — not a boundary-validated field.
-	_, err := GetCloseActiveRowsStatement("s", "t", "not-a-number", "")
+	_, err := GetCloseActiveRowsStatement("s", "t", "not-a-number", "", nil)
 	assert.ErrorContains(t, err, "invalid operation timestamp")
 }
 
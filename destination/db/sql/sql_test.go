@@ -31,50 +31,50 @@ func TestGetAlterTableStatement(t *testing.T) {
 	// already exists (see Schema Migration Helper spec, Note 2).
 	statement, err := GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{
 		{Op: types.AlterTableAdd, Column: "qaz", Type: &intType},
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `foo`.`bar` ADD COLUMN IF NOT EXISTS `qaz` Int32", statement)
 
 	statement, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{
 		{Op: types.AlterTableAdd, Column: "qaz", Type: &intType, Comment: &emptyComment},
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `foo`.`bar` ADD COLUMN IF NOT EXISTS `qaz` Int32 COMMENT ''", statement)
 
 	statement, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{
 		{Op: types.AlterTableAdd, Column: "qaz", Type: &intType, Comment: &comment},
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `foo`.`bar` ADD COLUMN IF NOT EXISTS `qaz` Int32 COMMENT 'foobar'", statement)
 
 	statement, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{
 		{Op: types.AlterTableDrop, Column: "qaz"},
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `foo`.`bar` DROP COLUMN IF EXISTS `qaz`", statement)
 
 	// Type and Comment are ignored with AlterTableDrop
 	statement, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{
 		{Op: types.AlterTableDrop, Column: "qaz", Type: &strType, Comment: &comment},
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `foo`.`bar` DROP COLUMN IF EXISTS `qaz`", statement)
 
 	statement, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{
 		{Op: types.AlterTableModify, Column: "qaz", Type: &strType},
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `foo`.`bar` MODIFY COLUMN IF EXISTS `qaz` String", statement)
 
 	statement, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{
 		{Op: types.AlterTableModify, Column: "qaz", Type: &strType, Comment: &emptyComment},
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `foo`.`bar` MODIFY COLUMN IF EXISTS `qaz` String COMMENT ''", statement)
 
 	statement, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{
 		{Op: types.AlterTableModify, Column: "qaz", Type: &strType, Comment: &comment},
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "ALTER TABLE `foo`.`bar` MODIFY COLUMN IF EXISTS `qaz` String COMMENT 'foobar'", statement)
 
@@ -83,28 +83,52 @@ func TestGetAlterTableStatement(t *testing.T) {
 		{Op: types.AlterTableDrop, Column: "qux"},
 		{Op: types.AlterTableModify, Column: "zaq", Type: &intType, Comment: &emptyComment},
 		{Op: types.AlterTableModify, Column: "qwe", Type: &strType},
-	})
+	}, nil)
 	assert.NoError(t, err)
 	assert.Equal(t,
 		"ALTER TABLE `foo`.`bar` ADD COLUMN IF NOT EXISTS `qaz` String COMMENT 'foobar',DROP COLUMN IF EXISTS `qux`,MODIFY COLUMN IF EXISTS `zaq` Int32 COMMENT '',MODIFY COLUMN IF EXISTS `qwe` String",
 		statement)
 
-	_, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{})
+	_, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{}, nil)
 	assert.ErrorContains(t, err, "no statements to execute for altering table `foo`.`bar`")
 
-	_, err = GetAlterTableStatement("foo", "", []*types.AlterTableOp{{Op: types.AlterTableModify, Column: "qaz", Type: &strType, Comment: &comment}})
+	_, err = GetAlterTableStatement("foo", "", []*types.AlterTableOp{{Op: types.AlterTableModify, Column: "qaz", Type: &strType, Comment: &comment}}, nil)
 	assert.ErrorContains(t, err, "table name is empty")
 
-	_, err = GetAlterTableStatement("", "bar", []*types.AlterTableOp{{Op: types.AlterTableModify, Column: "qaz", Type: &strType, Comment: &comment}})
+	_, err = GetAlterTableStatement("", "bar", []*types.AlterTableOp{{Op: types.AlterTableModify, Column: "qaz", Type: &strType, Comment: &comment}}, nil)
 	assert.ErrorContains(t, err, "schema name for table bar is empty")
 
-	_, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{{Op: types.AlterTableAdd, Column: "qaz"}})
+	_, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{{Op: types.AlterTableAdd, Column: "qaz"}}, nil)
 	assert.ErrorContains(t, err, "type for column qaz is not specified")
 
-	_, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{{Op: types.AlterTableModify, Column: "qaz"}})
+	_, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{{Op: types.AlterTableModify, Column: "qaz"}}, nil)
 	assert.ErrorContains(t, err, "type for column qaz is not specified")
 }
 
+func TestGetAlterTableStatementWithColumnOptions(t *testing.T) {
+	strType := "LowCardinality(Nullable(String))"
+	comment := "XML"
+	codec := "ZSTD(3)"
+	index := &types.SkipIndex{Name: "qaz_bloom_filter_idx", Type: "bloom_filter(0.01)", Granularity: 1}
+	statement, err := GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{
+		{Op: types.AlterTableAdd, Column: "qaz", Type: &strType, Comment: &comment, Codec: &codec},
+		{Op: types.AlterTableAddIndex, Column: "qaz", Index: index},
+		{Op: types.AlterTableModify, Column: "qux", Type: &strType, Codec: &codec},
+		{Op: types.AlterTableDropIndex, Column: "zaq", Index: &types.SkipIndex{Name: "zaq_minmax_idx"}},
+		{Op: types.AlterTableDrop, Column: "zaq"},
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t,
+		"ALTER TABLE `foo`.`bar` ADD COLUMN IF NOT EXISTS `qaz` LowCardinality(Nullable(String)) COMMENT 'XML' CODEC(ZSTD(3)),"+
+			"ADD INDEX IF NOT EXISTS `qaz_bloom_filter_idx` `qaz` TYPE bloom_filter(0.01) GRANULARITY 1,"+
+			"MODIFY COLUMN IF EXISTS `qux` LowCardinality(Nullable(String)) CODEC(ZSTD(3)),"+
+			"DROP INDEX IF EXISTS `zaq_minmax_idx`,DROP COLUMN IF EXISTS `zaq`",
+		statement)
+
+	_, err = GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{{Op: types.AlterTableAddIndex, Column: "qaz"}}, nil)
+	assert.ErrorContains(t, err, "index for column qaz is not specified")
+}
+
 func TestGetCreateTableStatement(t *testing.T) {
 	statement, err := GetCreateTableStatement("foo", "bar",
 		types.MakeTableDescription([]*types.ColumnDefinition{
@@ -112,7 +136,7 @@ func TestGetCreateTableStatement(t *testing.T) {
 			{Name: "qux", Type: "String", IsPrimaryKey: true},
 			{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
 			{Name: "_fivetran_deleted", Type: "Boolean"},
-		}))
+		}), nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` (`qaz` Int32,`qux` String,`_fivetran_synced` DateTime64(9, 'UTC'),`_fivetran_deleted` Boolean) ENGINE = ReplacingMergeTree(`_fivetran_synced`) ORDER BY (`qux`)", statement)
 
@@ -122,7 +146,7 @@ func TestGetCreateTableStatement(t *testing.T) {
 			{Name: "qux", Type: "String", IsPrimaryKey: true},
 			{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
 			{Name: "_fivetran_deleted", Type: "Boolean"},
-		}))
+		}), nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` (`qaz` Int32,`qux` String,`_fivetran_synced` DateTime64(9, 'UTC'),`_fivetran_deleted` Boolean) ENGINE = ReplacingMergeTree(`_fivetran_synced`) ORDER BY (`qaz`,`qux`)", statement)
 
@@ -133,7 +157,7 @@ func TestGetCreateTableStatement(t *testing.T) {
 			{Name: "bin", Type: "String", IsPrimaryKey: false, Comment: "BINARY"},
 			{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
 			{Name: "_fivetran_deleted", Type: "Boolean"},
-		}))
+		}), nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` (`i` Int32,`x` String COMMENT 'XML',`bin` String COMMENT 'BINARY',`_fivetran_synced` DateTime64(9, 'UTC'),`_fivetran_deleted` Boolean) ENGINE = ReplacingMergeTree(`_fivetran_synced`) ORDER BY (`i`)", statement)
 
@@ -143,36 +167,213 @@ func TestGetCreateTableStatement(t *testing.T) {
 			{Name: "i", Type: "Int32", IsPrimaryKey: true},
 			{Name: "x", Type: "String", IsPrimaryKey: false},
 			{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
-		}))
+		}), nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` (`i` Int32,`x` String,`_fivetran_synced` DateTime64(9, 'UTC')) ENGINE = ReplacingMergeTree(`_fivetran_synced`) ORDER BY (`i`)", statement)
 
-	_, err = GetCreateTableStatement("foo", "", nil)
+	_, err = GetCreateTableStatement("foo", "", nil, nil, nil)
 	assert.ErrorContains(t, err, "table name is empty")
 
-	_, err = GetCreateTableStatement("", "bar", nil)
+	_, err = GetCreateTableStatement("", "bar", nil, nil, nil)
 	assert.ErrorContains(t, err, "schema name for table bar is empty")
 
-	_, err = GetCreateTableStatement("foo", "bar", nil)
+	_, err = GetCreateTableStatement("foo", "bar", nil, nil, nil)
 	assert.ErrorContains(t, err, "no columns to create table `foo`.`bar`")
 
-	_, err = GetCreateTableStatement("foo", "bar", &types.TableDescription{})
+	_, err = GetCreateTableStatement("foo", "bar", &types.TableDescription{}, nil, nil)
 	assert.ErrorContains(t, err, "no columns to create table `foo`.`bar`")
 
 	_, err = GetCreateTableStatement("foo", "bar",
-		types.MakeTableDescription([]*types.ColumnDefinition{{Name: "qaz", Type: "Int32"}}))
+		types.MakeTableDescription([]*types.ColumnDefinition{{Name: "qaz", Type: "Int32"}}), nil, nil)
 	assert.ErrorContains(t, err, "no primary keys for table `foo`.`bar`")
 
 	_, err = GetCreateTableStatement("foo", "bar",
-		types.MakeTableDescription([]*types.ColumnDefinition{{Name: "qaz", Type: "Int32", IsPrimaryKey: true}}))
+		types.MakeTableDescription([]*types.ColumnDefinition{{Name: "qaz", Type: "Int32", IsPrimaryKey: true}}), nil, nil)
 	assert.ErrorContains(t, err, "no _fivetran_synced column")
 }
 
+func TestGetCreateTableStatementOnCluster(t *testing.T) {
+	tableDescription := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "qaz", Type: "Int32", IsPrimaryKey: true},
+		{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+	})
+
+	// server defaults for the Keeper path and the replica name
+	statement, err := GetCreateTableStatement("foo", "bar", tableDescription, &types.Cluster{Name: "my_cluster"}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` ON CLUSTER `my_cluster` (`qaz` Int32,`_fivetran_synced` DateTime64(9, 'UTC')) ENGINE = ReplicatedReplacingMergeTree(`_fivetran_synced`) ORDER BY (`qaz`)", statement)
+
+	// explicit Keeper path, default replica name
+	statement, err = GetCreateTableStatement("foo", "bar", tableDescription, &types.Cluster{
+		Name:       "my_cluster",
+		KeeperPath: "/clickhouse/tables/{shard}/{uuid}",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` ON CLUSTER `my_cluster` (`qaz` Int32,`_fivetran_synced` DateTime64(9, 'UTC')) ENGINE = ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/{uuid}', '{replica}', `_fivetran_synced`) ORDER BY (`qaz`)", statement)
+
+	// explicit Keeper path and replica name
+	statement, err = GetCreateTableStatement("foo", "bar", tableDescription, &types.Cluster{
+		Name:        "my_cluster",
+		KeeperPath:  "/clickhouse/tables/{shard}/{uuid}",
+		ReplicaName: "{host}",
+	}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` ON CLUSTER `my_cluster` (`qaz` Int32,`_fivetran_synced` DateTime64(9, 'UTC')) ENGINE = ReplicatedReplacingMergeTree('/clickhouse/tables/{shard}/{uuid}', '{host}', `_fivetran_synced`) ORDER BY (`qaz`)", statement)
+}
+
+func TestGetCreateTableStatementWithColumnOptions(t *testing.T) {
+	statement, err := GetCreateTableStatement("foo", "bar",
+		types.MakeTableDescription([]*types.ColumnDefinition{
+			{Name: "id", Type: "Int64", IsPrimaryKey: true, Codec: "Delta(8), ZSTD"},
+			{Name: "country", Type: "LowCardinality(Nullable(String))", SkipIndexes: []*types.SkipIndex{
+				{Name: "country_set_idx", Type: "set(100)", Granularity: 1},
+			}},
+			{Name: "email", Type: "Nullable(String)", Comment: "XML", Codec: "ZSTD(3)", SkipIndexes: []*types.SkipIndex{
+				{Name: "email_bloom_filter_idx", Type: "bloom_filter(0.01)", Granularity: 1},
+				{Name: "email_minmax_idx", Type: "minmax", Granularity: 4},
+			}},
+			{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+		}), nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` ("+
+		"`id` Int64 CODEC(Delta(8), ZSTD),"+
+		"`country` LowCardinality(Nullable(String)),"+
+		"`email` Nullable(String) COMMENT 'XML' CODEC(ZSTD(3)),"+
+		"`_fivetran_synced` DateTime64(9, 'UTC'),"+
+		"INDEX `country_set_idx` `country` TYPE set(100) GRANULARITY 1,"+
+		"INDEX `email_bloom_filter_idx` `email` TYPE bloom_filter(0.01) GRANULARITY 1,"+
+		"INDEX `email_minmax_idx` `email` TYPE minmax GRANULARITY 4"+
+		") ENGINE = ReplacingMergeTree(`_fivetran_synced`) ORDER BY (`id`)", statement)
+}
+
+func TestGetCreateTableStatementWithOptions(t *testing.T) {
+	tableDescription := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "id", Type: "Int64", IsPrimaryKey: true},
+		{Name: "tenant_id", Type: "Int32"},
+		{Name: "created_at", Type: "DateTime64(9, 'UTC')"},
+		{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+	})
+	columns := "(`id` Int64,`tenant_id` Int32,`created_at` DateTime64(9, 'UTC'),`_fivetran_synced` DateTime64(9, 'UTC'))"
+
+	statement, err := GetCreateTableStatement("foo", "bar", tableDescription, nil, &types.TableEngineOptions{
+		PartitionBy:   "toYYYYMM(`created_at`)",
+		OrderByPrefix: []string{"tenant_id"},
+		OrderBySuffix: []string{"created_at"},
+		PrimaryKey:    []string{"tenant_id", "id"},
+		TTL:           "`created_at` + INTERVAL 1 YEAR",
+		Settings: map[string]any{
+			"ttl_only_drop_parts":     true,
+			"index_granularity":       float64(4096),
+			"min_bytes_for_wide_part": float64(0),
+			"storage_policy":          "tiered",
+			"merge_with_ttl_timeout":  "it's",
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` "+columns+" ENGINE = ReplacingMergeTree(`_fivetran_synced`) "+
+		"ORDER BY (`tenant_id`,`id`,`created_at`) PARTITION BY toYYYYMM(`created_at`) PRIMARY KEY (`tenant_id`,`id`) "+
+		"TTL `created_at` + INTERVAL 1 YEAR SETTINGS storage_policy = 'tiered', index_granularity = 4096, "+
+		"merge_with_ttl_timeout = 'it''s', min_bytes_for_wide_part = 0, ttl_only_drop_parts = 1", statement)
+
+	// empty options are the same as no options
+	statement, err = GetCreateTableStatement("foo", "bar", tableDescription, nil, &types.TableEngineOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` "+columns+" ENGINE = ReplacingMergeTree(`_fivetran_synced`) ORDER BY (`id`)", statement)
+
+	// primary key columns are not repeated in the ORDER BY
+	statement, err = GetCreateTableStatement("foo", "bar", tableDescription, &types.Cluster{Name: "my_cluster"},
+		&types.TableEngineOptions{OrderByPrefix: []string{"id"}, OrderBySuffix: []string{"id", "created_at"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` ON CLUSTER `my_cluster` "+columns+" ENGINE = ReplicatedReplacingMergeTree(`_fivetran_synced`) ORDER BY (`id`,`created_at`)", statement)
+
+	_, err = GetCreateTableStatement("foo", "bar", tableDescription, nil,
+		&types.TableEngineOptions{OrderBySuffix: []string{"updated_at"}})
+	assert.ErrorContains(t, err, "ORDER BY column updated_at does not exist in table `foo`.`bar`")
+
+	_, err = GetCreateTableStatement("foo", "bar", tableDescription, nil,
+		&types.TableEngineOptions{PrimaryKey: []string{"tenant_id"}})
+	assert.ErrorContains(t, err, "PRIMARY KEY of table `foo`.`bar` should be a prefix of the ORDER BY (`id`)")
+
+	_, err = GetCreateTableStatement("foo", "bar", tableDescription, nil,
+		&types.TableEngineOptions{PrimaryKey: []string{"id", "tenant_id"}})
+	assert.ErrorContains(t, err, "PRIMARY KEY of table `foo`.`bar` has more columns than the ORDER BY")
+}
+
+func TestGetCreateDistributedTableStatement(t *testing.T) {
+	tableDescription := types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "qaz", Type: "Int32", IsPrimaryKey: true},
+		{Name: "qux", Type: "String", IsPrimaryKey: true},
+		{Name: "_fivetran_start", Type: "DateTime64(9, 'UTC')", IsPrimaryKey: true},
+		{Name: "_fivetran_synced", Type: "DateTime64(9, 'UTC')"},
+	})
+
+	// default sharding key is a hash of the primary keys, excluding _fivetran_start
+	statement, err := GetCreateDistributedTableStatement("foo", "bar", tableDescription, &types.Cluster{Name: "my_cluster", Distributed: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` ON CLUSTER `my_cluster` AS `foo`.`bar_local` ENGINE = Distributed('my_cluster', 'foo', 'bar_local', cityHash64(`qaz`,`qux`))", statement)
+
+	// explicit sharding key
+	statement, err = GetCreateDistributedTableStatement("foo", "bar", nil, &types.Cluster{Name: "my_cluster", Distributed: true, ShardingKey: "sipHash64(`qaz`)"})
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `foo`.`bar` ON CLUSTER `my_cluster` AS `foo`.`bar_local` ENGINE = Distributed('my_cluster', 'foo', 'bar_local', sipHash64(`qaz`))", statement)
+
+	// the names are escaped in the string literals
+	statement, err = GetCreateDistributedTableStatement(`fo'o`, `ba\r`, tableDescription, &types.Cluster{Name: "my_cluster", Distributed: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE IF NOT EXISTS `fo'o`.`ba\\r` ON CLUSTER `my_cluster` AS `fo'o`.`ba\\r_local` ENGINE = Distributed('my_cluster', 'fo''o', 'ba\\\\r_local', cityHash64(`qaz`,`qux`))", statement)
+
+	_, err = GetCreateDistributedTableStatement("foo", "bar", tableDescription, nil)
+	assert.ErrorContains(t, err, "cluster is not configured to use Distributed tables for table `foo`.`bar`")
+	_, err = GetCreateDistributedTableStatement("foo", "bar", tableDescription, &types.Cluster{Name: "my_cluster"})
+	assert.ErrorContains(t, err, "cluster is not configured to use Distributed tables for table `foo`.`bar`")
+	_, err = GetCreateDistributedTableStatement("foo", "bar", nil, &types.Cluster{Name: "my_cluster", Distributed: true})
+	assert.ErrorContains(t, err, "no table description to generate a sharding key for table `foo`.`bar`")
+	_, err = GetCreateDistributedTableStatement("foo", "bar", types.MakeTableDescription([]*types.ColumnDefinition{
+		{Name: "_fivetran_start", Type: "DateTime64(9, 'UTC')", IsPrimaryKey: true},
+	}), &types.Cluster{Name: "my_cluster", Distributed: true})
+	assert.ErrorContains(t, err, "no primary keys to generate a sharding key for table `foo`.`bar`")
+	_, err = GetCreateDistributedTableStatement("", "bar", tableDescription, &types.Cluster{Name: "my_cluster", Distributed: true})
+	assert.ErrorContains(t, err, "schema name for table bar is empty")
+}
+
+func TestLocalTableName(t *testing.T) {
+	assert.Equal(t, "bar_local", LocalTableName("bar"))
+}
+
+func TestDDLStatementsOnCluster(t *testing.T) {
+	cluster := &types.Cluster{Name: "my_cluster"}
+	strType := "String"
+
+	statement, err := GetAlterTableStatement("foo", "bar", []*types.AlterTableOp{{Op: types.AlterTableAdd, Column: "qaz", Type: &strType}}, cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `foo`.`bar` ON CLUSTER `my_cluster` ADD COLUMN IF NOT EXISTS `qaz` String", statement)
+
+	statement, err = GetCreateDatabaseStatement("foo", cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE DATABASE IF NOT EXISTS `foo` ON CLUSTER `my_cluster`", statement)
+
+	statement, err = GetDropTableStatement("`foo`.`bar`", cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLE IF EXISTS `foo`.`bar` ON CLUSTER `my_cluster` SYNC", statement)
+
+	statement, err = GetRenameTableStatement("foo", "bar", "qaz", cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "RENAME TABLE `foo`.`bar` TO `foo`.`qaz` ON CLUSTER `my_cluster`", statement)
+
+	softDeletedColumn := "_fivetran_deleted"
+	statement, err = GetTruncateTableStatement("foo", "bar", "_fivetran_synced", time.Unix(1646455512, 123456789), nil, cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `foo`.`bar` ON CLUSTER `my_cluster` DELETE WHERE toUnixTimestamp64Milli(`_fivetran_synced`) <= '1646455512123'", statement)
+	statement, err = GetTruncateTableStatement("foo", "bar", "_fivetran_synced", time.Unix(1646455512, 123456789), &softDeletedColumn, cluster)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `foo`.`bar` ON CLUSTER `my_cluster` UPDATE `_fivetran_deleted` = 1 WHERE toUnixTimestamp64Milli(`_fivetran_synced`) <= '1646455512123'", statement)
+}
+
 func TestGetDropTableStatement(t *testing.T) {
 	qualified, err := GetQualifiedTableName("foo", "bar")
 	assert.NoError(t, err)
 
-	stmt, err := GetDropTableStatement(qualified)
+	stmt, err := GetDropTableStatement(qualified, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "DROP TABLE IF EXISTS `foo`.`bar` SYNC", stmt)
 }
@@ -186,38 +387,141 @@ func TestGetTruncateTableStatement(t *testing.T) {
 	expectedHard := "ALTER TABLE `foo`.`bar` DELETE WHERE toUnixTimestamp64Milli(`_fivetran_synced`) <= '1646455512123'"
 	expectedSoft := "ALTER TABLE `foo`.`bar` UPDATE `_fivetran_deleted` = 1 WHERE toUnixTimestamp64Milli(`_fivetran_synced`) <= '1646455512123'"
 
-	statement, err := GetTruncateTableStatement("foo", "bar", syncedColumn, truncateBefore, nil)
+	statement, err := GetTruncateTableStatement("foo", "bar", syncedColumn, truncateBefore, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedHard, statement)
 
-	statement, err = GetTruncateTableStatement("foo", "bar", syncedColumn, truncateBefore, &emptyStr)
+	statement, err = GetTruncateTableStatement("foo", "bar", syncedColumn, truncateBefore, &emptyStr, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedHard, statement)
 
-	statement, err = GetTruncateTableStatement("foo", "bar", syncedColumn, truncateBefore, &softDeletedColumn)
+	statement, err = GetTruncateTableStatement("foo", "bar", syncedColumn, truncateBefore, &softDeletedColumn, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedSoft, statement)
 
-	_, err = GetTruncateTableStatement("foo", "", syncedColumn, truncateBefore, nil)
+	_, err = GetTruncateTableStatement("foo", "", syncedColumn, truncateBefore, nil, nil)
 	assert.ErrorContains(t, err, "table name is empty")
 
-	_, err = GetTruncateTableStatement("", "bar", syncedColumn, truncateBefore, nil)
+	_, err = GetTruncateTableStatement("", "bar", syncedColumn, truncateBefore, nil, nil)
 	assert.ErrorContains(t, err, "schema name for table bar is empty")
 
-	_, err = GetTruncateTableStatement("foo", "bar", "", truncateBefore, nil)
+	_, err = GetTruncateTableStatement("foo", "bar", "", truncateBefore, nil, nil)
 	assert.ErrorContains(t, err, "synced column name is empty")
 
-	_, err = GetTruncateTableStatement("foo", "bar", syncedColumn, time.Time{}, nil)
+	_, err = GetTruncateTableStatement("foo", "bar", syncedColumn, time.Time{}, nil, nil)
 	assert.ErrorContains(t, err, "truncate before time is zero")
 
 	truncateBeforeDate := time.Date(2000, 1, 15, 14, 35, 0, 0, time.UTC)
 	expectedStmtBeforeDate := "ALTER TABLE `foo`.`bar` DELETE WHERE toUnixTimestamp64Milli(`_fivetran_synced`) <= '947946900000'"
 
-	statement, err = GetTruncateTableStatement("foo", "bar", syncedColumn, truncateBeforeDate, nil)
+	statement, err = GetTruncateTableStatement("foo", "bar", syncedColumn, truncateBeforeDate, nil, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, expectedStmtBeforeDate, statement)
 }
 
+func TestGetNativeJSONCheckQuery(t *testing.T) {
+	query, err := GetNativeJSONCheckQuery("foo", "bar", "payload")
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT toBool(count(*) = 0) FROM `foo`.`bar` WHERE `payload` != '' AND "+
+		"NOT (isValidJSON(`payload`) AND JSONType(`payload`) = 'Object')", query)
+
+	_, err = GetNativeJSONCheckQuery("foo", "bar", "")
+	assert.ErrorContains(t, err, "column name is empty")
+
+	_, err = GetNativeJSONCheckQuery("foo", "", "payload")
+	assert.ErrorContains(t, err, "table name is empty")
+}
+
+func TestGetNativeJSONReplaceNullsStatement(t *testing.T) {
+	statement, err := GetNativeJSONReplaceNullsStatement("foo", "bar", "payload", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `foo`.`bar` UPDATE `payload` = '{}' WHERE `payload` IS NULL OR `payload` = ''", statement)
+
+	statement, err = GetNativeJSONReplaceNullsStatement("foo", "bar_local", "payload", &types.Cluster{Name: "my_cluster"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `foo`.`bar_local` ON CLUSTER `my_cluster` UPDATE `payload` = '{}' WHERE `payload` IS NULL OR `payload` = ''", statement)
+
+	_, err = GetNativeJSONReplaceNullsStatement("foo", "bar", "", nil)
+	assert.ErrorContains(t, err, "column name is empty")
+}
+
+func TestGetNativeTimeNormalizeStatement(t *testing.T) {
+	statement, err := GetNativeTimeNormalizeStatement("foo", "bar", "opens_at", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `foo`.`bar` UPDATE `opens_at` = concat(`opens_at`, ':00') WHERE length(`opens_at`) = 5", statement)
+
+	statement, err = GetNativeTimeNormalizeStatement("foo", "bar_local", "opens_at", &types.Cluster{Name: "my_cluster"})
+	assert.NoError(t, err)
+	assert.Equal(t, "ALTER TABLE `foo`.`bar_local` ON CLUSTER `my_cluster` UPDATE `opens_at` = concat(`opens_at`, ':00') WHERE length(`opens_at`) = 5", statement)
+
+	_, err = GetNativeTimeNormalizeStatement("foo", "bar", "", nil)
+	assert.ErrorContains(t, err, "column name is empty")
+}
+
+func TestGetCastCheckQuery(t *testing.T) {
+	query, err := GetCastCheckQuery("foo", "bar", "opens_at", "Time64(6)")
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT toBool(count(*) = 0) FROM `foo`.`bar` WHERE `opens_at` IS NOT NULL AND "+
+		"accurateCastOrNull(`opens_at`, 'Time64(6)') IS NULL", query)
+
+	_, err = GetCastCheckQuery("foo", "bar", "", "Time64(6)")
+	assert.ErrorContains(t, err, "column name is empty")
+
+	_, err = GetCastCheckQuery("foo", "bar", "opens_at", "")
+	assert.ErrorContains(t, err, "column type is empty")
+
+	_, err = GetCastCheckQuery("foo", "", "opens_at", "Time64(6)")
+	assert.ErrorContains(t, err, "table name is empty")
+}
+
+func TestColumnValue(t *testing.T) {
+	col := &types.CSVColumn{Name: "opens_at", Type: pb.DataType_NAIVE_TIME}
+	value, err := columnValue(col, "09:30")
+	assert.NoError(t, err)
+	assert.Equal(t, "'09:30'", value)
+
+	col.NativeTime = true
+	value, err = columnValue(col, "09:30")
+	assert.NoError(t, err)
+	assert.Equal(t, "'09:30:00'", value)
+
+	value, err = columnValue(col, "09:30:15.250")
+	assert.NoError(t, err)
+	assert.Equal(t, "'09:30:15.25'", value)
+
+	_, err = columnValue(col, "foo")
+	assert.ErrorContains(t, err, "can't parse value foo as naive time for column opens_at")
+
+	col = &types.CSVColumn{Name: "hash", Type: pb.DataType_BINARY}
+	value, err = columnValue(col, "/wA=")
+	assert.NoError(t, err)
+	assert.Equal(t, "'/wA='", value)
+
+	col.BinaryEncoding = "base64"
+	value, err = columnValue(col, "/wA=")
+	assert.NoError(t, err)
+	assert.Equal(t, "base64Decode('/wA=')", value)
+
+	col.BinaryEncoding = "hex"
+	value, err = columnValue(col, "ff00")
+	assert.NoError(t, err)
+	assert.Equal(t, "unhex('ff00')", value)
+
+	col = &types.CSVColumn{Name: "created_at", Type: pb.DataType_UTC_DATETIME}
+	value, err = columnValue(col, "2022-03-05T04:45:12.123456789Z")
+	assert.NoError(t, err)
+	assert.Equal(t, "'1646455512123456789'", value)
+
+	precision := uint(3)
+	col.UTCPrecision = &precision
+	value, err = columnValue(col, "2022-03-05T04:45:12.123456789Z")
+	assert.NoError(t, err)
+	assert.Equal(t, "'1646455512123'", value)
+
+	_, err = columnValue(col, "foo")
+	assert.ErrorContains(t, err, "can't parse value foo as UTC datetime")
+}
+
 func TestGetColumnTypesQuery(t *testing.T) {
 	query, err := GetColumnTypesQuery("foo", "bar")
 	assert.NoError(t, err)
@@ -233,7 +537,8 @@ func TestGetColumnTypesQuery(t *testing.T) {
 func TestGetDescribeTableQuery(t *testing.T) {
 	query, err := GetDescribeTableQuery("foo", "bar")
 	assert.NoError(t, err)
-	assert.Equal(t, "SELECT name, type, comment, is_in_primary_key, numeric_precision, numeric_scale FROM system.columns WHERE database = 'foo' AND table = 'bar'", query)
+	assert.Equal(t, "SELECT name, type, comment, is_in_primary_key, numeric_precision, numeric_scale, default_kind, default_expression "+
+		"FROM system.columns WHERE database = 'foo' AND table = 'bar'", query)
 
 	_, err = GetDescribeTableQuery("", "bar")
 	assert.ErrorContains(t, err, "schema name for table bar is empty")
@@ -242,6 +547,27 @@ func TestGetDescribeTableQuery(t *testing.T) {
 	assert.ErrorContains(t, err, "table name is empty")
 }
 
+func TestGetInsertStatement(t *testing.T) {
+	statement, err := GetInsertStatement("`foo`.`bar`", []string{"id", "name"})
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO `foo`.`bar` (`id`,`name`)", statement)
+
+	_, err = GetInsertStatement("", []string{"id"})
+	assert.ErrorContains(t, err, "table name is empty")
+
+	_, err = GetInsertStatement("`foo`.`bar`", nil)
+	assert.ErrorContains(t, err, "column names list for table `foo`.`bar` is empty")
+}
+
+func TestGetInsertCSVStatement(t *testing.T) {
+	statement, err := GetInsertCSVStatement("`foo`.`bar`", []string{"name", "id"})
+	assert.NoError(t, err)
+	assert.Equal(t, "INSERT INTO `foo`.`bar` (`name`,`id`) FORMAT CSVWithNames", statement)
+
+	_, err = GetInsertCSVStatement("`foo`.`bar`", nil)
+	assert.ErrorContains(t, err, "column names list for table `foo`.`bar` is empty")
+}
+
 func TestGetSelectByPrimaryKeysQueryValidation(t *testing.T) {
 	fullTableName := QualifiedTableName("`foo`.`bar`")
 	csvCols := &types.CSVColumns{
@@ -250,17 +576,20 @@ func TestGetSelectByPrimaryKeysQueryValidation(t *testing.T) {
 	}
 	batch := [][]string{{"42", "foo", "2022-03-05T04:45:12.123456789Z"}}
 
-	_, err := GetSelectByPrimaryKeysQuery(batch, csvCols, "", false)
+	_, err := GetSelectByPrimaryKeysQuery(batch, csvCols, []string{"id"}, "", false)
 	assert.ErrorContains(t, err, "table name is empty")
 
-	_, err = GetSelectByPrimaryKeysQuery([][]string{}, csvCols, fullTableName, false)
+	_, err = GetSelectByPrimaryKeysQuery([][]string{}, csvCols, []string{"id"}, fullTableName, false)
 	assert.ErrorContains(t, err, "expected non-empty CSV slice")
-	_, err = GetSelectByPrimaryKeysQuery(nil, csvCols, fullTableName, false)
+	_, err = GetSelectByPrimaryKeysQuery(nil, csvCols, []string{"id"}, fullTableName, false)
 	assert.ErrorContains(t, err, "expected non-empty CSV slice")
 
-	_, err = GetSelectByPrimaryKeysQuery(batch, nil, fullTableName, false)
+	_, err = GetSelectByPrimaryKeysQuery(batch, csvCols, nil, fullTableName, false)
+	assert.ErrorContains(t, err, "column names list for table `foo`.`bar` is empty")
+
+	_, err = GetSelectByPrimaryKeysQuery(batch, nil, []string{"id"}, fullTableName, false)
 	assert.ErrorContains(t, err, "expected non-empty primary keys")
-	_, err = GetSelectByPrimaryKeysQuery(batch, csvCols, fullTableName, false)
+	_, err = GetSelectByPrimaryKeysQuery(batch, csvCols, []string{"id"}, fullTableName, false)
 	assert.ErrorContains(t, err, "expected non-empty primary keys")
 
 	withInvalidCol := []*types.CSVColumn{{Index: 5, Name: "id", Type: pb.DataType_LONG, IsPrimaryKey: false}}
@@ -268,7 +597,7 @@ func TestGetSelectByPrimaryKeysQueryValidation(t *testing.T) {
 		All:         withInvalidCol,
 		PrimaryKeys: withInvalidCol,
 	}
-	_, err = GetSelectByPrimaryKeysQuery([][]string{{"foo"}}, invalidIndexCSVCols, fullTableName, false)
+	_, err = GetSelectByPrimaryKeysQuery([][]string{{"foo"}}, invalidIndexCSVCols, []string{"id"}, fullTableName, false)
 	assert.ErrorContains(t, err, "can't find matching value for primary key with index 5")
 }
 
@@ -285,9 +614,9 @@ func TestGetSelectByPrimaryKeysQuery(t *testing.T) {
 			{Index: 2, Name: "ts", Type: pb.DataType_UTC_DATETIME}},
 		PrimaryKeys: []*types.CSVColumn{
 			{Index: 0, Name: "id", Type: pb.DataType_LONG, IsPrimaryKey: true}},
-	}, fullTableName, false)
+	}, []string{"id", "name", "ts"}, fullTableName, false)
 	assert.NoError(t, err)
-	assert.Equal(t, "SELECT * FROM `foo`.`bar` FINAL WHERE(`id`)IN((42),(43))ORDER BY(`id`)LIMIT 2", statement)
+	assert.Equal(t, "SELECT `id`,`name`,`ts` FROM `foo`.`bar` FINAL WHERE(`id`)IN((42),(43))ORDER BY(`id`)LIMIT 2", statement)
 
 	statement, err = GetSelectByPrimaryKeysQuery(batch, &types.CSVColumns{
 		All: []*types.CSVColumn{
@@ -297,9 +626,9 @@ func TestGetSelectByPrimaryKeysQuery(t *testing.T) {
 		PrimaryKeys: []*types.CSVColumn{
 			{Index: 0, Name: "id", Type: pb.DataType_LONG, IsPrimaryKey: true},
 			{Index: 1, Name: "name", Type: pb.DataType_STRING, IsPrimaryKey: true}},
-	}, fullTableName, false)
+	}, []string{"id", "name", "ts"}, fullTableName, false)
 	assert.NoError(t, err)
-	assert.Equal(t, "SELECT * FROM `foo`.`bar` FINAL WHERE(`id`,`name`)IN((42,'foo'),(43,'bar'))ORDER BY(`id`,`name`)LIMIT 2", statement)
+	assert.Equal(t, "SELECT `id`,`name`,`ts` FROM `foo`.`bar` FINAL WHERE(`id`,`name`)IN((42,'foo'),(43,'bar'))ORDER BY(`id`,`name`)LIMIT 2", statement)
 
 	statement, err = GetSelectByPrimaryKeysQuery(batch, &types.CSVColumns{
 		All: []*types.CSVColumn{
@@ -308,10 +637,10 @@ func TestGetSelectByPrimaryKeysQuery(t *testing.T) {
 			{Index: 2, Name: "ts", Type: pb.DataType_UTC_DATETIME, IsPrimaryKey: true}},
 		PrimaryKeys: []*types.CSVColumn{
 			{Index: 2, Name: "ts", Type: pb.DataType_UTC_DATETIME, IsPrimaryKey: true}},
-	}, fullTableName, false)
+	}, []string{"id", "name", "ts"}, fullTableName, false)
 	assert.NoError(t, err)
 	// DateTime64(9, 'UTC') is converted to nanoseconds.
-	assert.Equal(t, "SELECT * FROM `foo`.`bar` FINAL WHERE(`ts`)IN(('1646455512123456789'),('1680784200234567890'))ORDER BY(`ts`)LIMIT 2", statement)
+	assert.Equal(t, "SELECT `id`,`name`,`ts` FROM `foo`.`bar` FINAL WHERE(`ts`)IN(('1646455512123456789'),('1680784200234567890'))ORDER BY(`ts`)LIMIT 2", statement)
 }
 
 func TestGetCheckDatabaseExistsStatement(t *testing.T) {
@@ -336,11 +665,11 @@ func TestGetCheckTableExistsStatement(t *testing.T) {
 }
 
 func TestGetCreateDatabaseStatement(t *testing.T) {
-	statement, err := GetCreateDatabaseStatement("foo")
+	statement, err := GetCreateDatabaseStatement("foo", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "CREATE DATABASE IF NOT EXISTS `foo`", statement)
 
-	_, err = GetCreateDatabaseStatement("")
+	_, err = GetCreateDatabaseStatement("", nil)
 	assert.ErrorContains(t, err, "schema name is empty")
 }
 
@@ -361,17 +690,17 @@ func TestGetHardDeleteStatementValidation(t *testing.T) {
 	}
 	batch := [][]string{{"42", "foo", "2022-03-05T04:45:12.123456789Z"}}
 
-	_, err := GetHardDeleteStatement(batch, csvCols, "")
+	_, err := GetHardDeleteStatement(batch, csvCols, "", nil)
 	assert.ErrorContains(t, err, "table name is empty")
 
-	_, err = GetHardDeleteStatement(batch, nil, fullTableName)
+	_, err = GetHardDeleteStatement(batch, nil, fullTableName, nil)
 	assert.ErrorContains(t, err, "expected non-empty primary keys")
-	_, err = GetHardDeleteStatement(batch, &types.CSVColumns{}, fullTableName)
+	_, err = GetHardDeleteStatement(batch, &types.CSVColumns{}, fullTableName, nil)
 	assert.ErrorContains(t, err, "expected non-empty primary keys")
 
-	_, err = GetHardDeleteStatement(nil, csvCols, fullTableName)
+	_, err = GetHardDeleteStatement(nil, csvCols, fullTableName, nil)
 	assert.ErrorContains(t, err, "expected non-empty CSV slice")
-	_, err = GetHardDeleteStatement([][]string{}, csvCols, fullTableName)
+	_, err = GetHardDeleteStatement([][]string{}, csvCols, fullTableName, nil)
 	assert.ErrorContains(t, err, "expected non-empty CSV slice")
 
 	withInvalidCol := []*types.CSVColumn{{Index: 5, Name: "id", Type: pb.DataType_LONG, IsPrimaryKey: true}}
@@ -379,7 +708,7 @@ func TestGetHardDeleteStatementValidation(t *testing.T) {
 		All:         withInvalidCol,
 		PrimaryKeys: withInvalidCol,
 	}
-	_, err = GetHardDeleteStatement([][]string{{"foo"}}, invalidIndexCSVCols, fullTableName)
+	_, err = GetHardDeleteStatement([][]string{{"foo"}}, invalidIndexCSVCols, fullTableName, nil)
 	assert.ErrorContains(t, err, "can't find matching value for primary key with index 5")
 }
 
@@ -396,7 +725,7 @@ func TestGetHardDeleteStatement(t *testing.T) {
 			{Index: 2, Name: "ts", Type: pb.DataType_UTC_DATETIME}},
 		PrimaryKeys: []*types.CSVColumn{
 			{Index: 0, Name: "id", Type: pb.DataType_LONG, IsPrimaryKey: true}},
-	}, fullTableName)
+	}, fullTableName, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "DELETE FROM `foo`.`bar` WHERE(`id`)IN((42),(43))", statement)
 
@@ -408,7 +737,7 @@ func TestGetHardDeleteStatement(t *testing.T) {
 		PrimaryKeys: []*types.CSVColumn{
 			{Index: 0, Name: "id", Type: pb.DataType_LONG, IsPrimaryKey: true},
 			{Index: 1, Name: "name", Type: pb.DataType_STRING, IsPrimaryKey: true}},
-	}, fullTableName)
+	}, fullTableName, nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "DELETE FROM `foo`.`bar` WHERE(`id`,`name`)IN((42,'foo'),(43,'bar'))", statement)
 
@@ -419,14 +748,23 @@ func TestGetHardDeleteStatement(t *testing.T) {
 			{Index: 2, Name: "ts", Type: pb.DataType_UTC_DATETIME, IsPrimaryKey: true}},
 		PrimaryKeys: []*types.CSVColumn{
 			{Index: 2, Name: "ts", Type: pb.DataType_UTC_DATETIME, IsPrimaryKey: true}},
-	}, fullTableName)
+	}, fullTableName, nil)
 	assert.NoError(t, err)
 	// DateTime64(9, 'UTC') is converted to nanoseconds.
 	assert.Equal(t, "DELETE FROM `foo`.`bar` WHERE(`ts`)IN(('1646455512123456789'),('1680784200234567890'))", statement)
+
+	statement, err = GetHardDeleteStatement(batch, &types.CSVColumns{
+		All: []*types.CSVColumn{
+			{Index: 0, Name: "id", Type: pb.DataType_LONG, IsPrimaryKey: true}},
+		PrimaryKeys: []*types.CSVColumn{
+			{Index: 0, Name: "id", Type: pb.DataType_LONG, IsPrimaryKey: true}},
+	}, "`foo`.`bar_local`", &types.Cluster{Name: "my_cluster", Distributed: true})
+	assert.NoError(t, err)
+	assert.Equal(t, "DELETE FROM `foo`.`bar_local` ON CLUSTER `my_cluster` WHERE(`id`)IN((42),(43))", statement)
 }
 
 func TestGetAllReplicasActiveQuery(t *testing.T) {
-	query, err := GetAllReplicasActiveQuery("foo", "bar")
+	query, err := GetAllReplicasActiveQuery("foo", "bar", nil)
 	assert.NoError(t, err)
 	expected := `SELECT toBool(mapExists((k, v) -> (v = 0 AND k IN (
            SELECT replica_host FROM (
@@ -443,23 +781,43 @@ func TestGetAllReplicasActiveQuery(t *testing.T) {
        WHERE database = 'foo' AND table = 'bar' 
        LIMIT 1`
 	assert.Equal(t, expected, query)
-	_, err = GetAllReplicasActiveQuery("", "bar")
+	_, err = GetAllReplicasActiveQuery("", "bar", nil)
 	assert.ErrorContains(t, err, "schema name for table bar is empty")
 
-	_, err = GetAllReplicasActiveQuery("foo", "")
+	_, err = GetAllReplicasActiveQuery("foo", "", nil)
 	assert.ErrorContains(t, err, "table name is empty")
 }
 
+func TestGetAllReplicasActiveQueryOnCluster(t *testing.T) {
+	query, err := GetAllReplicasActiveQuery("foo", "bar", &types.Cluster{Name: "my_cluster"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT toBool(countIf(mapExists((k, v) -> v = 0, replica_is_active)) = 0) AS all_replicas_active "+
+		"FROM clusterAllReplicas('my_cluster', system.replicas) "+
+		"WHERE database = 'foo' AND table = 'bar'", query)
+
+	query, err = GetAllReplicasActiveQuery("foo", "bar", &types.Cluster{Name: `my'cluster`})
+	assert.NoError(t, err)
+	assert.Contains(t, query, "FROM clusterAllReplicas('my''cluster', system.replicas) ")
+}
+
 func TestGetAllMutationsCompletedQuery(t *testing.T) {
-	query, err := GetAllMutationsCompletedQuery("foo", "bar")
+	query, err := GetAllMutationsCompletedQuery("foo", "bar", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "SELECT toBool(count(*) = 0) FROM clusterAllReplicas(default, system.mutations) WHERE database = 'foo' AND table = 'bar' AND is_done = 0", query)
 
-	_, err = GetAllMutationsCompletedQuery("", "bar")
+	_, err = GetAllMutationsCompletedQuery("", "bar", nil)
 	assert.ErrorContains(t, err, "schema name for table bar is empty")
 
-	_, err = GetAllMutationsCompletedQuery("foo", "")
+	_, err = GetAllMutationsCompletedQuery("foo", "", nil)
 	assert.ErrorContains(t, err, "table name is empty")
+
+	query, err = GetAllMutationsCompletedQuery("foo", "bar", &types.Cluster{Name: "my_cluster"})
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT toBool(count(*) = 0) FROM clusterAllReplicas('my_cluster', system.mutations) WHERE database = 'foo' AND table = 'bar' AND is_done = 0", query)
+
+	query, err = GetAllMutationsCompletedQuery("foo", "bar", &types.Cluster{Name: `my'cluster`})
+	assert.NoError(t, err)
+	assert.Contains(t, query, "FROM clusterAllReplicas('my''cluster', system.mutations) ")
 }
 
 func TestGetInsertFromSelectStatement(t *testing.T) {
@@ -489,18 +847,18 @@ func TestGetInsertFromSelectStatementErrors(t *testing.T) {
 }
 
 func TestGetRenameTablesStatement(t *testing.T) {
-	query, err := GetRenameTableStatement("s", "table", "new")
+	query, err := GetRenameTableStatement("s", "table", "new", nil)
 	assert.NoError(t, err)
 	assert.Equal(t, "RENAME TABLE `s`.`table` TO `s`.`new`", query)
 }
 
 func TestGetRenameTablesStatementErrors(t *testing.T) {
-	_, err := GetRenameTableStatement("s", "table", "")
+	_, err := GetRenameTableStatement("s", "table", "", nil)
 	assert.ErrorContains(t, err, "to table name is empty")
 
-	_, err = GetRenameTableStatement("s", "", "new")
+	_, err = GetRenameTableStatement("s", "", "new", nil)
 	assert.ErrorContains(t, err, "from table name is empty")
 
-	_, err = GetRenameTableStatement("", "table", "new")
+	_, err = GetRenameTableStatement("", "table", "new", nil)
 	assert.ErrorContains(t, err, "schema name for tables table/new is empty")
 }
@@ -2,17 +2,43 @@ package db
 
 import (
 	"context"
+	"maps"
+	"os"
 	"testing"
 
 	"fivetran.com/fivetran_sdk/destination/db/config"
 	"github.com/stretchr/testify/require"
 )
 
+// Ports of the local ClickHouse server in Docker, see docker-compose.yml
+const (
+	testNativePort = "9000"
+	testHTTPPort   = "8123"
+)
+
 func getTestConnection(t *testing.T, ctx context.Context, configuration map[string]string) *ClickHouseConnection {
 	t.Helper()
-	connConfig, err := config.Parse(configuration)
+	connConfig, err := config.Parse(withTestProtocol(configuration))
 	require.NoError(t, err)
-	conn, err := GetClickHouseConnection(ctx, connConfig)
+	conn, err := GetClickHouseConnection(ctx, connConfig, config.DefaultSettings())
 	require.NoError(t, err)
 	return conn
 }
+
+// withTestProtocol switches the test configuration to HTTP if CLICKHOUSE_PROTOCOL=http is set,
+// so the same integration tests can be run against both protocols (see the CI matrix).
+func withTestProtocol(configuration map[string]string) map[string]string {
+	if !isHTTPTestProtocol() {
+		return configuration
+	}
+	result := maps.Clone(configuration)
+	result[config.ProtocolKey] = config.ProtocolHTTP
+	if result[config.PortKey] == testNativePort {
+		result[config.PortKey] = testHTTPPort
+	}
+	return result
+}
+
+func isHTTPTestProtocol() bool {
+	return os.Getenv("CLICKHOUSE_PROTOCOL") == config.ProtocolHTTP
+}
@@ -1,7 +1,11 @@
 package values
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
@@ -34,20 +38,51 @@ func Value(colType pb.DataType, value string) (string, error) {
 		pb.DataType_DOUBLE,
 		pb.DataType_BINARY,
 		pb.DataType_XML,
-		pb.DataType_JSON:
+		pb.DataType_JSON,
+		pb.DataType_NAIVE_TIME:
 		return QuoteAndEscapeString(value), nil
 	// specify DateTime64(9) as nanos instead
 	case pb.DataType_UTC_DATETIME:
-		utcDateTime, err := time.Parse(constants.UTCDateTimeFormat, value)
-		if err != nil {
-			return "", fmt.Errorf("can't parse value %s as UTC datetime: %w", value, err)
-		}
-		return fmt.Sprintf("'%d'", utcDateTime.UnixNano()), nil
+		return UTCDateTimeValue(value, 9)
 	default:
 		return value, nil
 	}
 }
 
+// UTCDateTimeValue is Value for a UTC_DATETIME column with the DateTime64 precision (see types.CSVColumn.UTCPrecision),
+// formatted as the number of ticks since epoch, see UTCDateTimeTicks.
+func UTCDateTimeValue(value string, precision uint) (string, error) {
+	utcDateTime, err := time.Parse(constants.UTCDateTimeFormat, value)
+	if err != nil {
+		return "", fmt.Errorf("can't parse value %s as UTC datetime: %w", value, err)
+	}
+	return fmt.Sprintf("'%d'", UTCDateTimeTicks(utcDateTime, precision)), nil
+}
+
+// UTCDateTimeTicks returns a UTC_DATETIME value as the number of DateTime64 ticks since epoch for the precision,
+// e.g. milliseconds for DateTime64(3, 'UTC') and seconds for DateTime, truncated the same way as the driver does on insert.
+// The value is clamped first (see clampUTCDateTime), so the ticks match the stored value, and don't overflow.
+func UTCDateTimeTicks(t time.Time, precision uint) int64 {
+	t = clampUTCDateTime(t)
+	if precision >= 9 {
+		return t.UnixNano()
+	}
+	return t.UnixNano() / int64(math.Pow10(9-int(precision)))
+}
+
+// InLocation re-interprets a NAIVE_DATETIME value, which is parsed as UTC, as the wall clock time in the timezone
+// of its DateTime64 column, see types.CSVColumn.Location. The values that end up beyond MaxDateTime64 are clamped to it,
+// as the driver can't insert them.
+func InLocation(t time.Time, location *time.Location) time.Time {
+	year, month, day := t.Date()
+	hours, minutes, seconds := t.Clock()
+	result := time.Date(year, month, day, hours, minutes, seconds, t.Nanosecond(), location)
+	if result.After(MaxDateTime64) {
+		return MaxDateTime64
+	}
+	return result
+}
+
 // MigrateValue is a pre-formatted SQL literal for a migration statement.
 // The literal is embedded directly by the SQL builders — it already carries
 // the quoting and escaping they need.
@@ -100,129 +135,612 @@ func NewMigrateValue(colType pb.DataType, value string) (MigrateValue, error) {
 	}
 }
 
+// NewUTCDateTimeMigrateValue is NewMigrateValue for a UTC_DATETIME column with the DateTime64 precision,
+// see ParseUTCTimestampToTicks.
+func NewUTCDateTimeMigrateValue(value string, precision uint) (MigrateValue, error) {
+	ticks, err := ParseUTCTimestampToTicks(value, precision)
+	if err != nil {
+		return MigrateValue{}, err
+	}
+	return NewMigrateValueQuoted(ticks), nil
+}
+
 // ParseUTCTimestampToNanos parses a Fivetran UTC_DATETIME value (ISO 8601 with
 // a literal `Z` suffix and 0–9 fractional-second digits) and returns the
 // nanosecond-since-epoch value as a string, ready for interpolation into a
 // DateTime64(9,'UTC') literal.
 func ParseUTCTimestampToNanos(value string) (string, error) {
+	return ParseUTCTimestampToTicks(value, 9)
+}
+
+// ParseUTCTimestampToTicks is ParseUTCTimestampToNanos for a DateTime64 column
+// with the precision, see UTCDateTimeTicks.
+func ParseUTCTimestampToTicks(value string, precision uint) (string, error) {
 	t, err := time.Parse(constants.UTCDateTimeFormat, value)
 	if err != nil {
 		return "", fmt.Errorf("can't parse %q as UTC datetime: %w", value, err)
 	}
-	return strconv.FormatInt(t.UnixNano(), 10), nil
+	return strconv.FormatInt(UTCDateTimeTicks(t, precision), 10), nil
 }
 
+// Parse converts a CSV value to the Go type of the column; the values out of the ClickHouse range are clamped,
+// see ParseWithPolicy.
 func Parse(colName string, colType pb.DataType, val string) (any, error) {
+	result, _, err := ParseWithPolicy(colName, colType, val, constants.CoercionClamp)
+	return result, err
+}
+
+// ParseWithPolicy is Parse with the coercion policy for the values out of the ClickHouse range (see coerce),
+// and also reports whether the value was coerced.
+func ParseWithPolicy(colName string, colType pb.DataType, val string, policy string) (any, bool, error) {
+	result, err := parse(colName, colType, val)
+	if err != nil {
+		return nil, false, err
+	}
+	return coerce(colName, colType, result, policy)
+}
+
+func parse(colName string, colType pb.DataType, val string) (any, error) {
 	switch colType {
 	case pb.DataType_BOOLEAN:
-		result, err := strconv.ParseBool(val)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as boolean for column %s: %w", val, colName, err)
-		}
-		return result, nil
+		return asAny(ParseBool(colName, val))
 	case pb.DataType_SHORT:
-		result, err := strconv.ParseInt(val, 10, 16)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as int16 for column %s: %w", val, colName, err)
-		}
-		return int16(result), nil
+		return asAny(ParseInt16(colName, val))
 	case pb.DataType_INT:
-		result, err := strconv.ParseInt(val, 10, 32)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as int32 for column %s: %w", val, colName, err)
-		}
-		return int32(result), nil
+		return asAny(ParseInt32(colName, val))
 	case pb.DataType_LONG:
-		result, err := strconv.ParseInt(val, 10, 64)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as int64 for column %s: %w", val, colName, err)
-		}
-		return result, nil
-	case pb.DataType_FLOAT:
-		result, err := strconv.ParseFloat(val, 32)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as float32 for column %s: %w", val, colName, err)
-		}
-		return result, nil
-	case pb.DataType_DOUBLE:
-		result, err := strconv.ParseFloat(val, 64)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as float64 for column %s: %w", val, colName, err)
-		}
-		return result, nil
+		return asAny(ParseInt64(colName, val))
+	case pb.DataType_FLOAT, pb.DataType_DOUBLE:
+		return asAny(ParseFloat(colName, colType, val))
 	case pb.DataType_DECIMAL:
-		result, err := decimal.NewFromString(val)
+		return asAny(ParseDecimal(colName, val))
+	case pb.DataType_NAIVE_DATE, pb.DataType_NAIVE_DATETIME, pb.DataType_UTC_DATETIME:
+		return asAny(ParseTime(colName, colType, val))
+	case // "string" types work as-is
+		pb.DataType_BINARY,
+		pb.DataType_XML,
+		pb.DataType_STRING,
+		pb.DataType_JSON,
+		pb.DataType_NAIVE_TIME:
+		return val, nil
+	default:
+		return nil, fmt.Errorf("no target type for column %s with type %s", colName, colType.String())
+	}
+}
+
+func asAny[T any](val T, err error) (any, error) {
+	if err != nil {
+		return nil, err
+	}
+	return val, nil
+}
+
+// ParseBool is the typed counterpart of Parse for a BOOLEAN value.
+// The typed Parse* functions don't coerce the values out of the ClickHouse range, see TimeInRange and FloatInRange.
+func ParseBool(colName string, val string) (bool, error) {
+	result, err := strconv.ParseBool(val)
+	if err != nil {
+		return false, fmt.Errorf("can't parse value %s as boolean for column %s: %w", val, colName, err)
+	}
+	return result, nil
+}
+
+// ParseInt16 is the typed counterpart of Parse for a SHORT value.
+func ParseInt16(colName string, val string) (int16, error) {
+	result, err := strconv.ParseInt(val, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse value %s as int16 for column %s: %w", val, colName, err)
+	}
+	return int16(result), nil
+}
+
+// ParseInt32 is the typed counterpart of Parse for an INT value.
+func ParseInt32(colName string, val string) (int32, error) {
+	result, err := strconv.ParseInt(val, 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse value %s as int32 for column %s: %w", val, colName, err)
+	}
+	return int32(result), nil
+}
+
+// ParseInt64 is the typed counterpart of Parse for a LONG value.
+func ParseInt64(colName string, val string) (int64, error) {
+	result, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse value %s as int64 for column %s: %w", val, colName, err)
+	}
+	return result, nil
+}
+
+// ParseFloat is the typed counterpart of Parse for a FLOAT or DOUBLE value;
+// same as in Parse, FLOAT values are rounded to float32, but represented as float64.
+func ParseFloat(colName string, colType pb.DataType, val string) (float64, error) {
+	if colType == pb.DataType_FLOAT {
+		result, err := strconv.ParseFloat(val, 32)
 		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as decimal for column %s: %w", val, colName, err)
+			return 0, fmt.Errorf("can't parse value %s as float32 for column %s: %w", val, colName, err)
 		}
 		return result, nil
+	}
+	result, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse value %s as float64 for column %s: %w", val, colName, err)
+	}
+	return result, nil
+}
+
+// ParseDecimal is the typed counterpart of Parse for a DECIMAL value.
+func ParseDecimal(colName string, val string) (decimal.Decimal, error) {
+	result, err := decimal.NewFromString(val)
+	if err != nil {
+		return decimal.Decimal{}, fmt.Errorf("can't parse value %s as decimal for column %s: %w", val, colName, err)
+	}
+	return result, nil
+}
+
+// ParseTime is the typed counterpart of Parse for a NAIVE_DATE, NAIVE_DATETIME or UTC_DATETIME value.
+func ParseTime(colName string, colType pb.DataType, val string) (time.Time, error) {
+	var (
+		layout   string
+		typeName string
+	)
+	switch colType {
 	case pb.DataType_NAIVE_DATE:
-		result, err := time.Parse(constants.NaiveDateFormat, val)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as naive date for column %s: %w", val, colName, err)
+		layout, typeName = constants.NaiveDateFormat, "naive date"
+	case pb.DataType_NAIVE_DATETIME:
+		layout, typeName = constants.NaiveDateTimeFormat, "naive datetime"
+	case pb.DataType_UTC_DATETIME:
+		layout, typeName = constants.UTCDateTimeFormat, "UTC datetime"
+	default:
+		return time.Time{}, fmt.Errorf("no target type for column %s with type %s", colName, colType.String())
+	}
+	result, err := time.Parse(layout, val)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("can't parse value %s as %s for column %s: %w", val, typeName, colName, err)
+	}
+	return result, nil
+}
+
+// TimeInRange reports whether a value returned by ParseTime is in the ClickHouse range of the column type,
+// and doesn't need to be coerced (see coerce).
+func TimeInRange(colType pb.DataType, val time.Time) bool {
+	switch colType {
+	case pb.DataType_NAIVE_DATE:
+		return clampNaiveDate(val).Equal(val)
+	case pb.DataType_NAIVE_DATETIME:
+		return clampNaiveDateTime(val).Equal(val)
+	case pb.DataType_UTC_DATETIME:
+		return clampUTCDateTime(val).Equal(val)
+	default:
+		return true
+	}
+}
+
+// FloatInRange reports whether a value returned by ParseFloat doesn't need to be coerced, i.e. is not NaN or infinite.
+func FloatInRange(val float64) bool {
+	return !math.IsNaN(val) && !math.IsInf(val, 0)
+}
+
+// ParseNativeJSON validates a value for a column with the native ClickHouse JSON type.
+// Unlike a String column, such a column only accepts JSON objects; the value is returned as is,
+// since the driver inserts strings into JSON columns as-is (see types.CSVColumn.NativeJSON).
+func ParseNativeJSON(colName string, val string) (string, error) {
+	if !json.Valid([]byte(val)) {
+		return "", fmt.Errorf("can't parse value %s as JSON for column %s", val, colName)
+	}
+	if !strings.HasPrefix(strings.TrimLeft(val, " \t\r\n"), "{") {
+		return "", fmt.Errorf("value %s for column %s is not a JSON object; native JSON columns only support JSON objects", val, colName)
+	}
+	return val, nil
+}
+
+// ParseNaiveTime parses a NAIVE_TIME value, such as 15:04, 15:04:05 or 15:04:05.123456,
+// as a duration since midnight for a column with the ClickHouse Time or Time64 type (see types.CSVColumn.NativeTime).
+func ParseNaiveTime(colName string, val string) (time.Duration, error) {
+	layout := constants.NaiveTimeFormat
+	if len(val) == len(constants.NaiveTimeShortFormat) {
+		layout = constants.NaiveTimeShortFormat
+	}
+	result, err := time.Parse(layout, val)
+	if err != nil {
+		return 0, fmt.Errorf("can't parse value %s as naive time for column %s: %w", val, colName, err)
+	}
+	return result.Sub(time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC)), nil
+}
+
+// FormatNaiveTime formats a duration since midnight (see ParseNaiveTime) as 15:04:05.999999999.
+func FormatNaiveTime(d time.Duration) string {
+	return time.Date(0, time.January, 1, 0, 0, 0, 0, time.UTC).Add(d).Format(constants.NaiveTimeFormat)
+}
+
+// ParseRawBinary decodes a BINARY value for a column with the decoded bytes (see types.CSVColumn.BinaryEncoding).
+// The bytes are returned as a string, as the driver accepts strings for both String and FixedString columns.
+// If fixedLength is set, the decoded value should have exactly that length, as FixedString would pad shorter values.
+func ParseRawBinary(colName string, encoding string, val string, fixedLength uint) (string, error) {
+	var (
+		result []byte
+		err    error
+	)
+	switch encoding {
+	case constants.HexEncoding:
+		result, err = hex.DecodeString(val)
+	default:
+		result, err = base64.StdEncoding.DecodeString(val)
+	}
+	if err != nil {
+		return "", fmt.Errorf("can't decode value %s as %s for column %s: %w", val, encoding, colName, err)
+	}
+	if fixedLength > 0 && uint(len(result)) != fixedLength {
+		return "", fmt.Errorf("decoded value %s for column %s has length %d, expected %d",
+			val, colName, len(result), fixedLength)
+	}
+	return string(result), nil
+}
+
+// EncodeRawBinary is the reverse of ParseRawBinary; the result is in the canonical form of the encoding
+// (padded base64 or lowercase hex), so it can be compared with the other encoded values.
+func EncodeRawBinary(encoding string, val string) string {
+	if encoding == constants.HexEncoding {
+		return hex.EncodeToString([]byte(val))
+	}
+	return base64.StdEncoding.EncodeToString([]byte(val))
+}
+
+// RawBinaryValue is the counterpart of Value for a column with the decoded bytes:
+// the value is decoded by ClickHouse, e.g. base64Decode('/wA=') or unhex('ff00').
+func RawBinaryValue(colName string, encoding string, val string) (string, error) {
+	if _, err := ParseRawBinary(colName, encoding, val, 0); err != nil {
+		return "", err
+	}
+	if encoding == constants.HexEncoding {
+		return fmt.Sprintf("unhex(%s)", QuoteAndEscapeString(val)), nil
+	}
+	return fmt.Sprintf("base64Decode(%s)", QuoteAndEscapeString(val)), nil
+}
+
+// ParseDecimalString validates a DECIMAL value for a column stored as String (see types.CSVColumn.DecimalAsString);
+// the value is returned as is, so none of its digits are lost.
+func ParseDecimalString(colName string, val string) (string, error) {
+	if _, err := decimal.NewFromString(val); err != nil {
+		return "", fmt.Errorf("can't parse value %s as decimal for column %s: %w", val, colName, err)
+	}
+	return val, nil
+}
+
+// ParseTyped is the counterpart of Parse for the already typed values from typed batch files (such as Parquet).
+// The result has the same Go type as Parse would return for the string representation of the value.
+// Supported input types are bool, int32, int64, float32, float64, decimal.Decimal, time.Time and string;
+// if the input type does not match the column type, the value is formatted and parsed using Parse.
+func ParseTyped(colName string, colType pb.DataType, val any) (any, error) {
+	result, _, err := ParseTypedWithPolicy(colName, colType, val, constants.CoercionClamp)
+	return result, err
+}
+
+// ParseTypedWithPolicy is the counterpart of ParseWithPolicy for the already typed values, see ParseTyped.
+func ParseTypedWithPolicy(colName string, colType pb.DataType, val any, policy string) (any, bool, error) {
+	result, err := parseTyped(colName, colType, val)
+	if err != nil {
+		return nil, false, err
+	}
+	return coerce(colName, colType, result, policy)
+}
+
+func parseTyped(colName string, colType pb.DataType, val any) (any, error) {
+	switch v := val.(type) {
+	case string:
+		return parse(colName, colType, v)
+	case bool:
+		if colType == pb.DataType_BOOLEAN {
+			return v, nil
 		}
-		// Date32 date range is the same as DateTime64, so that makes it [1900-01-01, 2299-12-31].
-		// See https://clickhouse.com/docs/en/sql-reference/data-types/date32
-		// See https://clickhouse.com/docs/en/sql-reference/data-types/datetime64
-		year := result.Year()
-		if year < 1900 {
-			return time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC), nil
+	case int32:
+		return parseTypedInt(colName, colType, int64(v))
+	case int64:
+		return parseTypedInt(colName, colType, v)
+	case float32:
+		// same as in Parse, FLOAT values are represented as float64
+		if colType == pb.DataType_FLOAT || colType == pb.DataType_DOUBLE {
+			return float64(v), nil
 		}
-		if year > 2299 {
-			return time.Date(2299, time.December, 31, 0, 0, 0, 0, time.UTC), nil
+	case float64:
+		if colType == pb.DataType_FLOAT || colType == pb.DataType_DOUBLE {
+			return v, nil
 		}
-		return result, nil
-	case pb.DataType_NAIVE_DATETIME:
-		result, err := time.Parse(constants.NaiveDateTimeFormat, val)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as naive datetime for column %s: %w", val, colName, err)
+	case decimal.Decimal:
+		if colType == pb.DataType_DECIMAL {
+			return v, nil
 		}
-		// Supported range of values: [1900-01-01 00:00:00, 2299-12-31 23:59:59.99999999].
-		// See https://clickhouse.com/docs/en/sql-reference/data-types/datetime64
-		// However, due to the way the driver works, the actual upper bound is 2262-04-11 23:47:16.
-		year, month, day := result.Date()
-		if year > 2262 || (year == 2262 && month > 4) || (year == 2262 && month == 4 && day > 11) {
-			return MaxDateTime64, nil
+	case time.Time:
+		switch colType {
+		case pb.DataType_NAIVE_DATE:
+			return v.UTC().Truncate(24 * time.Hour), nil
+		case pb.DataType_NAIVE_DATETIME, pb.DataType_UTC_DATETIME:
+			return v.UTC(), nil
 		}
-		if year < 1900 {
-			return time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC), nil
+	}
+	return parse(colName, colType, formatTyped(val))
+}
+
+// coerce applies the coercion policy to a parsed value that is out of the ClickHouse range:
+// NAIVE_DATE, NAIVE_DATETIME and UTC_DATETIME values outside of the supported range (see clampNaiveDate,
+// clampNaiveDateTime and clampUTCDateTime), and NaN or infinite FLOAT and DOUBLE values.
+// With the clamp policy, the date and time values are clamped, and the floats are kept as is, as ClickHouse supports them;
+// with the null policy, the value is replaced with nil; with the fail policy, an error is returned.
+// The second result reports whether the value was coerced, so it can be counted (see types.Coercions).
+func coerce(colName string, colType pb.DataType, val any, policy string) (any, bool, error) {
+	var clamped any
+	switch v := val.(type) {
+	case time.Time:
+		var result time.Time
+		switch colType {
+		case pb.DataType_NAIVE_DATE:
+			result = clampNaiveDate(v)
+		case pb.DataType_NAIVE_DATETIME:
+			result = clampNaiveDateTime(v)
+		case pb.DataType_UTC_DATETIME:
+			result = clampUTCDateTime(v)
+		default:
+			return val, false, nil
 		}
-		hours, minutes, seconds := result.Clock()
-		if year == 2262 && month == 4 && day == 11 && hours == 23 {
-			if minutes > 47 || minutes == 47 && seconds > 16 || minutes == 47 && seconds == 16 {
-				return MaxDateTime64, nil
-			}
+		if result.Equal(v) {
+			return val, false, nil
 		}
-		return result, nil
-	case pb.DataType_UTC_DATETIME:
-		result, err := time.Parse(constants.UTCDateTimeFormat, val)
-		if err != nil {
-			return nil, fmt.Errorf("can't parse value %s as UTC datetime for column %s: %w", val, colName, err)
+		clamped = result
+	case float64:
+		if FloatInRange(v) {
+			return val, false, nil
 		}
-		// With max precision (9, which is nanoseconds), the maximum supported value is 2262-04-11 23:47:16 in UTC.
-		// See https://clickhouse.com/docs/en/sql-reference/data-types/datetime64
-		year, month, day := result.Date()
-		if year > 2262 || (year == 2262 && month > 4) || (year == 2262 && month == 4 && day > 11) {
-			return MaxDateTime64, nil
+		clamped = v
+	default:
+		return val, false, nil
+	}
+	switch policy {
+	case constants.CoercionNull:
+		return nil, true, nil
+	case constants.CoercionFail:
+		return nil, false, fmt.Errorf("value %s for column %s is out of the supported %s range",
+			formatTyped(val), colName, colType.String())
+	default:
+		return clamped, true, nil
+	}
+}
+
+// CoerceDecimal applies the coercion policy to a DECIMAL value that does not fit the ClickHouse Decimal type
+// with the given limits (see types.CSVColumn.DecimalLimits), as it would otherwise overflow or be truncated on insert.
+// With the clamp policy, the values with too many fractional digits are truncated to the scale, same as ClickHouse does,
+// and the values with too many integer digits are rejected; with the null policy, such values are replaced with nil;
+// with the fail policy, an error is returned. The second result reports whether the value was coerced.
+func CoerceDecimal(colName string, val decimal.Decimal, limits *pb.DecimalParams, policy string) (any, bool, error) {
+	if DecimalFits(val, limits) {
+		return val, false, nil
+	}
+	integerDigits := 0
+	if integerPart := val.Abs().Truncate(0); !integerPart.IsZero() {
+		integerDigits = len(integerPart.String())
+	}
+	if integerDigits > int(limits.Precision-limits.Scale) {
+		if policy == constants.CoercionNull {
+			return nil, true, nil
 		}
-		if year < 1900 {
-			return time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC), nil
+		return nil, false, fmt.Errorf("value %s for column %s has %d integer digits, and does not fit Decimal(%d, %d)",
+			val.String(), colName, integerDigits, limits.Precision, limits.Scale)
+	}
+	truncated := val.Truncate(int32(limits.Scale))
+	if truncated.Equal(val) {
+		return val, false, nil
+	}
+	switch policy {
+	case constants.CoercionNull:
+		return nil, true, nil
+	case constants.CoercionFail:
+		return nil, false, fmt.Errorf("value %s for column %s has more than %d fractional digits, and does not fit Decimal(%d, %d)",
+			val.String(), colName, limits.Scale, limits.Precision, limits.Scale)
+	default:
+		return truncated, true, nil
+	}
+}
+
+// DecimalFits reports whether a DECIMAL value fits the ClickHouse Decimal type with the given limits as is,
+// and doesn't need to be coerced (see CoerceDecimal). It only checks the digits of the value as it was parsed,
+// so it can return false for a value that fits, such as 1.50 for a scale of 1; CoerceDecimal handles those.
+func DecimalFits(val decimal.Decimal, limits *pb.DecimalParams) bool {
+	exponent := val.Exponent()
+	if exponent > 0 || exponent < -int32(limits.Scale) {
+		return false
+	}
+	return val.NumDigits()+int(exponent) <= int(limits.Precision-limits.Scale)
+}
+
+// Convert converts a parsed value to the Go type of a column with a compatible ClickHouse type
+// (see types.CSVColumn.ConvertTo), e.g. int64 to uint32 for UInt32. The values out of the range of the column type,
+// which would overflow on insert, get the coercion policy of the Fivetran type (see coerce): with the clamp policy,
+// they are set to the nearest value of the range; so are the dates and the timestamps out of the Date
+// and DateTime range respectively. The second result reports whether the value was coerced.
+func Convert(colName string, colType string, val any, policy string) (any, bool, error) {
+	switch colType {
+	case constants.Int8:
+		return convertInt[int8](colName, colType, val, math.MinInt8, math.MaxInt8, policy)
+	case constants.UInt8:
+		return convertInt[uint8](colName, colType, val, 0, math.MaxUint8, policy)
+	case constants.Int16:
+		return convertInt[int16](colName, colType, val, math.MinInt16, math.MaxInt16, policy)
+	case constants.UInt16:
+		return convertInt[uint16](colName, colType, val, 0, math.MaxUint16, policy)
+	case constants.Int32:
+		return convertInt[int32](colName, colType, val, math.MinInt32, math.MaxInt32, policy)
+	case constants.UInt32:
+		return convertInt[uint32](colName, colType, val, 0, math.MaxUint32, policy)
+	case constants.Int64:
+		return convertInt[int64](colName, colType, val, math.MinInt64, math.MaxInt64, policy)
+	case constants.UInt64:
+		return convertInt[uint64](colName, colType, val, 0, math.MaxInt64, policy)
+	case constants.Date16:
+		return convertTime(colName, colType, val, minDate16, maxDate16, policy)
+	case constants.DateTime32:
+		return convertTime(colName, colType, val, minDateTime32, maxDateTime32, policy)
+	default:
+		return val, false, nil
+	}
+}
+
+// Date and DateTime ranges, see https://clickhouse.com/docs/sql-reference/data-types/date
+// and https://clickhouse.com/docs/sql-reference/data-types/datetime
+var (
+	minDate16     = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maxDate16     = time.Date(2149, time.June, 6, 0, 0, 0, 0, time.UTC)
+	minDateTime32 = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+	maxDateTime32 = time.Date(2106, time.February, 7, 6, 28, 15, 0, time.UTC)
+)
+
+// convertInt converts an integer value to T with the range [minValue, maxValue];
+// the parsed values are at most int64, so the upper bound of UInt64 is math.MaxInt64.
+func convertInt[T int8 | uint8 | int16 | uint16 | int32 | uint32 | int64 | uint64](
+	colName string,
+	colType string,
+	val any,
+	minValue int64,
+	maxValue int64,
+	policy string,
+) (any, bool, error) {
+	var value int64
+	switch v := val.(type) {
+	case int16:
+		value = int64(v)
+	case int32:
+		value = int64(v)
+	case int64:
+		value = v
+	default:
+		return nil, false, fmt.Errorf("can't convert value %v of type %T to %s for column %s", val, val, colType, colName)
+	}
+	if value >= minValue && value <= maxValue {
+		return T(value), false, nil
+	}
+	switch policy {
+	case constants.CoercionNull:
+		return nil, true, nil
+	case constants.CoercionFail:
+		return nil, false, fmt.Errorf("value %d for column %s is out of the %s range", value, colName, colType)
+	default:
+		return T(min(max(value, minValue), maxValue)), true, nil
+	}
+}
+
+func convertTime(
+	colName string,
+	colType string,
+	val any,
+	minTime time.Time,
+	maxTime time.Time,
+	policy string,
+) (any, bool, error) {
+	t, ok := val.(time.Time)
+	if !ok {
+		return nil, false, fmt.Errorf("can't convert value %v of type %T to %s for column %s", val, val, colType, colName)
+	}
+	if !t.Before(minTime) && !t.After(maxTime) {
+		return t, false, nil
+	}
+	switch policy {
+	case constants.CoercionNull:
+		return nil, true, nil
+	case constants.CoercionFail:
+		return nil, false, fmt.Errorf("value %s for column %s is out of the %s range", t.Format(time.RFC3339Nano), colName, colType)
+	default:
+		if t.Before(minTime) {
+			return minTime, true, nil
 		}
-		hours, minutes, seconds := result.Clock()
-		if year == 2262 && month == 4 && day == 11 && hours == 23 {
-			if minutes > 47 || minutes == 47 && seconds > 16 || minutes == 47 && seconds == 16 && result.Nanosecond() > 0 {
-				return MaxDateTime64, nil
-			}
+		return maxTime, true, nil
+	}
+}
+
+func parseTypedInt(colName string, colType pb.DataType, val int64) (any, error) {
+	switch colType {
+	case pb.DataType_SHORT:
+		if val < math.MinInt16 || val > math.MaxInt16 {
+			return nil, fmt.Errorf("value %d is out of int16 range for column %s", val, colName)
 		}
-		return result, nil
-	case // "string" types work as-is
-		pb.DataType_BINARY,
-		pb.DataType_XML,
-		pb.DataType_STRING,
-		pb.DataType_JSON,
-		pb.DataType_NAIVE_TIME:
+		return int16(val), nil
+	case pb.DataType_INT:
+		if val < math.MinInt32 || val > math.MaxInt32 {
+			return nil, fmt.Errorf("value %d is out of int32 range for column %s", val, colName)
+		}
+		return int32(val), nil
+	case pb.DataType_LONG:
 		return val, nil
+	case pb.DataType_DECIMAL:
+		return decimal.NewFromInt(val), nil
 	default:
-		return nil, fmt.Errorf("no target type for column %s with type %s", colName, colType.String())
+		return parse(colName, colType, strconv.FormatInt(val, 10))
+	}
+}
+
+func formatTyped(val any) string {
+	switch v := val.(type) {
+	case time.Time:
+		return v.UTC().Format(time.RFC3339Nano)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// clampNaiveDate clamps a NAIVE_DATE value to the supported range.
+// Date32 date range is the same as DateTime64, so that makes it [1900-01-01, 2299-12-31].
+// See https://clickhouse.com/docs/en/sql-reference/data-types/date32
+// See https://clickhouse.com/docs/en/sql-reference/data-types/datetime64
+func clampNaiveDate(result time.Time) time.Time {
+	year := result.Year()
+	if year < 1900 {
+		return time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	if year > 2299 {
+		return time.Date(2299, time.December, 31, 0, 0, 0, 0, time.UTC)
+	}
+	return result
+}
+
+// clampNaiveDateTime clamps a NAIVE_DATETIME value to the supported range.
+// Supported range of values: [1900-01-01 00:00:00, 2299-12-31 23:59:59.99999999].
+// See https://clickhouse.com/docs/en/sql-reference/data-types/datetime64
+// However, due to the way the driver works, the actual upper bound is 2262-04-11 23:47:16.
+func clampNaiveDateTime(result time.Time) time.Time {
+	year, month, day := result.Date()
+	if year > 2262 || (year == 2262 && month > 4) || (year == 2262 && month == 4 && day > 11) {
+		return MaxDateTime64
+	}
+	if year < 1900 {
+		return time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	hours, minutes, seconds := result.Clock()
+	if year == 2262 && month == 4 && day == 11 && hours == 23 {
+		if minutes > 47 || minutes == 47 && seconds > 16 || minutes == 47 && seconds == 16 {
+			return MaxDateTime64
+		}
+	}
+	return result
+}
+
+// clampUTCDateTime clamps a UTC_DATETIME value to the supported range.
+// With max precision (9, which is nanoseconds), the maximum supported value is 2262-04-11 23:47:16 in UTC.
+// DateTime64 with a lower precision (see config.DateTimeTypes) could store values up to 2299-12-31,
+// but the driver can't insert them: time.Time values are converted with UnixNano (see proto.ToDateTime64),
+// and int64 values are taken as milliseconds, and converted to time.Time first, so both overflow after 2262.
+// See https://clickhouse.com/docs/en/sql-reference/data-types/datetime64
+func clampUTCDateTime(result time.Time) time.Time {
+	year, month, day := result.Date()
+	if year > 2262 || (year == 2262 && month > 4) || (year == 2262 && month == 4 && day > 11) {
+		return MaxDateTime64
+	}
+	if year < 1900 {
+		return time.Date(1900, time.January, 1, 0, 0, 0, 0, time.UTC)
+	}
+	hours, minutes, seconds := result.Clock()
+	if year == 2262 && month == 4 && day == 11 && hours == 23 {
+		if minutes > 47 || minutes == 47 && seconds > 16 || minutes == 47 && seconds == 16 && result.Nanosecond() > 0 {
+			return MaxDateTime64
+		}
 	}
+	return result
 }
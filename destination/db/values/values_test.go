@@ -1,9 +1,11 @@
 package values
 
 import (
+	"math"
 	"testing"
 	"time"
 
+	"fivetran.com/fivetran_sdk/destination/common/constants"
 	pb "fivetran.com/fivetran_sdk/proto"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -29,6 +31,7 @@ func TestQuoteValue(t *testing.T) {
 		{pb.DataType_JSON, "{\"foo\": \"bar\"}", "'{\"foo\": \"bar\"}'"},
 		{pb.DataType_NAIVE_DATE, "2022-03-05", "'2022-03-05'"},
 		{pb.DataType_NAIVE_DATETIME, "2022-03-05T04:45:12", "'2022-03-05T04:45:12'"},
+		{pb.DataType_NAIVE_TIME, "04:45:12", "'04:45:12'"},
 		// Make sure the values are escaped (all remaining variations tested in TestQuoteAndEscapeString)
 		{pb.DataType_STRING, "a'b", "'a''b'"},
 		{pb.DataType_STRING, `a\nb`, `'a\\nb'`},
@@ -76,6 +79,110 @@ func TestQuoteUTCDateTime(t *testing.T) {
 	assert.Equal(t, "", result)
 }
 
+func TestUTCDateTimeValue(t *testing.T) {
+	args := []struct {
+		precision uint
+		value     string
+		expected  string
+	}{
+		{9, "2022-03-05T04:45:12.123456789Z", "'1646455512123456789'"},
+		{0, "2022-03-05T04:45:12.123456789Z", "'1646455512'"},
+		{6, "2022-03-05T04:45:12.123456789Z", "'1646455512123456'"},
+		{3, "2022-03-05T04:45:12.123456789Z", "'1646455512123'"},
+		{3, "2022-03-05T04:45:12Z", "'1646455512000'"},
+		// truncated towards zero, the same way as the driver does
+		{3, "1969-12-31T23:59:59.9995Z", "'0'"},
+		// clamped the same way as the inserted values, instead of overflowing
+		{9, "2290-01-02T03:04:05.006Z", "'" + MaxDateTime64Nanos + "'"},
+		{3, "2290-01-02T03:04:05.006Z", "'9223372036000'"},
+		{3, "1850-01-01T00:00:00Z", "'-2208988800000'"},
+	}
+	for _, arg := range args {
+		result, err := UTCDateTimeValue(arg.value, arg.precision)
+		assert.NoError(t, err, "value %s, precision %d", arg.value, arg.precision)
+		assert.Equal(t, arg.expected, result, "value %s, precision %d", arg.value, arg.precision)
+	}
+
+	_, err := UTCDateTimeValue("foobar", 3)
+	assert.ErrorContains(t, err, "can't parse value foobar as UTC datetime")
+}
+
+func TestInLocation(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+
+	result := InLocation(time.Date(2024, time.July, 1, 10, 30, 15, 500, time.UTC), berlin)
+	assert.Equal(t, time.Date(2024, time.July, 1, 10, 30, 15, 500, berlin), result)
+	assert.Equal(t, time.Date(2024, time.July, 1, 8, 30, 15, 500, time.UTC), result.UTC())
+
+	// the clamped values are still within the driver range after the conversion
+	assert.Equal(t, time.Date(2262, time.April, 11, 23, 47, 16, 0, berlin), InLocation(MaxDateTime64, berlin))
+	assert.Equal(t, MaxDateTime64, InLocation(MaxDateTime64, newYork))
+}
+
+func TestConvert(t *testing.T) {
+	args := []struct {
+		colType  string
+		value    any
+		expected any
+	}{
+		{"Int8", int16(-128), int8(-128)},
+		{"UInt8", int16(255), uint8(255)},
+		{"UInt16", int32(65535), uint16(65535)},
+		{"Int16", int64(-42), int16(-42)},
+		{"UInt32", int64(4294967295), uint32(4294967295)},
+		{"UInt64", int64(math.MaxInt64), uint64(math.MaxInt64)},
+		{"Date", time.Date(2149, time.June, 6, 0, 0, 0, 0, time.UTC), time.Date(2149, time.June, 6, 0, 0, 0, 0, time.UTC)},
+		{"DateTime", time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC), time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"String", "foo", "foo"},
+	}
+	for _, arg := range args {
+		for _, policy := range []string{constants.CoercionClamp, constants.CoercionNull, constants.CoercionFail} {
+			result, coerced, err := Convert("col", arg.colType, arg.value, policy)
+			assert.NoError(t, err, "type %s, value %v", arg.colType, arg.value)
+			assert.False(t, coerced, "type %s, value %v", arg.colType, arg.value)
+			assert.Equal(t, arg.expected, result, "type %s, value %v", arg.colType, arg.value)
+		}
+	}
+
+	outOfRange := []struct {
+		colType string
+		value   any
+		clamped any
+		error   string
+	}{
+		{"Int8", int16(128), int8(127), "value 128 for column col is out of the Int8 range"},
+		{"UInt8", int16(-1), uint8(0), "value -1 for column col is out of the UInt8 range"},
+		{"UInt32", int64(4294967296), uint32(4294967295), "value 4294967296 for column col is out of the UInt32 range"},
+		{"UInt32", int64(-5), uint32(0), "value -5 for column col is out of the UInt32 range"},
+		{"UInt64", int64(-1), uint64(0), "value -1 for column col is out of the UInt64 range"},
+		{"Date", time.Date(1950, time.December, 31, 0, 0, 0, 0, time.UTC), time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC),
+			"value 1950-12-31T00:00:00Z for column col is out of the Date range"},
+		{"DateTime", time.Date(2106, time.February, 7, 6, 28, 16, 0, time.UTC), time.Date(2106, time.February, 7, 6, 28, 15, 0, time.UTC),
+			"value 2106-02-07T06:28:16Z for column col is out of the DateTime range"},
+	}
+	for _, arg := range outOfRange {
+		result, coerced, err := Convert("col", arg.colType, arg.value, constants.CoercionClamp)
+		assert.NoError(t, err, "type %s, value %v", arg.colType, arg.value)
+		assert.True(t, coerced, "type %s, value %v", arg.colType, arg.value)
+		assert.Equal(t, arg.clamped, result, "type %s, value %v", arg.colType, arg.value)
+
+		result, coerced, err = Convert("col", arg.colType, arg.value, constants.CoercionNull)
+		assert.NoError(t, err, "type %s, value %v", arg.colType, arg.value)
+		assert.True(t, coerced, "type %s, value %v", arg.colType, arg.value)
+		assert.Nil(t, result, "type %s, value %v", arg.colType, arg.value)
+
+		_, coerced, err = Convert("col", arg.colType, arg.value, constants.CoercionFail)
+		assert.ErrorContains(t, err, arg.error, "type %s, value %v", arg.colType, arg.value)
+		assert.False(t, coerced, "type %s, value %v", arg.colType, arg.value)
+	}
+
+	_, _, err := Convert("col", "UInt32", "foo", constants.CoercionClamp)
+	assert.ErrorContains(t, err, "can't convert value foo of type string to UInt32 for column col")
+}
+
 func TestParseValue(t *testing.T) {
 	// Boolean
 	val, err := Parse("test", pb.DataType_BOOLEAN, "true")
@@ -210,6 +317,350 @@ func TestParseValue(t *testing.T) {
 	assert.ErrorContains(t, err, "no target type for column test with type UNSPECIFIED")
 }
 
+func TestParseNativeJSON(t *testing.T) {
+	val, err := ParseNativeJSON("test", `{"foo": {"bar": [1, 2]}}`)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"foo": {"bar": [1, 2]}}`, val)
+
+	val, err = ParseNativeJSON("test", ` {}`)
+	assert.NoError(t, err)
+	assert.Equal(t, ` {}`, val)
+
+	_, err = ParseNativeJSON("test", `{"foo": `)
+	assert.ErrorContains(t, err, `can't parse value {"foo":  as JSON for column test`)
+
+	_, err = ParseNativeJSON("test", `[1, 2]`)
+	assert.ErrorContains(t, err, "value [1, 2] for column test is not a JSON object; native JSON columns only support JSON objects")
+
+	_, err = ParseNativeJSON("test", `"foo"`)
+	assert.ErrorContains(t, err, "native JSON columns only support JSON objects")
+}
+
+func TestParseNaiveTime(t *testing.T) {
+	args := []struct {
+		value  string
+		result time.Duration
+	}{
+		{"00:00", 0},
+		{"15:04", 15*time.Hour + 4*time.Minute},
+		{"15:04:05", 15*time.Hour + 4*time.Minute + 5*time.Second},
+		{"15:04:05.123", 15*time.Hour + 4*time.Minute + 5*time.Second + 123*time.Millisecond},
+		{"23:59:59.999999999", 24*time.Hour - time.Nanosecond},
+	}
+	for _, arg := range args {
+		result, err := ParseNaiveTime("test", arg.value)
+		assert.NoError(t, err, "expected no error for value %s", arg.value)
+		assert.Equal(t, arg.result, result, "values mismatch for %s", arg.value)
+	}
+
+	_, err := ParseNaiveTime("test", "25:00:00")
+	assert.ErrorContains(t, err, "can't parse value 25:00:00 as naive time for column test")
+	_, err = ParseNaiveTime("test", "foobar")
+	assert.ErrorContains(t, err, "can't parse value foobar as naive time for column test")
+}
+
+func TestParseRawBinary(t *testing.T) {
+	val, err := ParseRawBinary("test", "base64", "/wA=", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "\xff\x00", val)
+
+	val, err = ParseRawBinary("test", "hex", "FF00", 2)
+	assert.NoError(t, err)
+	assert.Equal(t, "\xff\x00", val)
+
+	val, err = ParseRawBinary("test", "base64", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "", val)
+
+	_, err = ParseRawBinary("test", "base64", "foo", 0)
+	assert.ErrorContains(t, err, "can't decode value foo as base64 for column test")
+
+	_, err = ParseRawBinary("test", "hex", "xyz", 0)
+	assert.ErrorContains(t, err, "can't decode value xyz as hex for column test")
+
+	_, err = ParseRawBinary("test", "hex", "ff00", 4)
+	assert.ErrorContains(t, err, "decoded value ff00 for column test has length 2, expected 4")
+}
+
+func TestEncodeRawBinary(t *testing.T) {
+	assert.Equal(t, "/wA=", EncodeRawBinary("base64", "\xff\x00"))
+	assert.Equal(t, "ff00", EncodeRawBinary("hex", "\xff\x00"))
+	assert.Equal(t, "", EncodeRawBinary("hex", ""))
+}
+
+func TestRawBinaryValue(t *testing.T) {
+	val, err := RawBinaryValue("test", "base64", "/wA=")
+	assert.NoError(t, err)
+	assert.Equal(t, "base64Decode('/wA=')", val)
+
+	val, err = RawBinaryValue("test", "hex", "FF00")
+	assert.NoError(t, err)
+	assert.Equal(t, "unhex('FF00')", val)
+
+	_, err = RawBinaryValue("test", "hex", "ff'00")
+	assert.ErrorContains(t, err, "can't decode value ff'00 as hex for column test")
+}
+
+func TestParseDecimalString(t *testing.T) {
+	val, err := ParseDecimalString("test", "123456789012345678901234567890123456789012345678901234567890123456789012345678901.5")
+	assert.NoError(t, err)
+	assert.Equal(t, "123456789012345678901234567890123456789012345678901234567890123456789012345678901.5", val)
+
+	val, err = ParseDecimalString("test", "-0.100")
+	assert.NoError(t, err)
+	assert.Equal(t, "-0.100", val)
+
+	_, err = ParseDecimalString("test", "foo")
+	assert.ErrorContains(t, err, "can't parse value foo as decimal for column test")
+}
+
+func TestCoerceDecimal(t *testing.T) {
+	limits := &pb.DecimalParams{Precision: 6, Scale: 2}
+	for _, val := range []string{"1234.56", "-1234.56", "0.5", "0", "1234.5600", "-0.01"} {
+		result, coerced, err := CoerceDecimal("test", decimal.RequireFromString(val), limits, "fail")
+		assert.NoError(t, err, "Value %s", val)
+		assert.False(t, coerced, "Value %s", val)
+		assert.True(t, decimal.RequireFromString(val).Equal(result.(decimal.Decimal)), "Value %s", val)
+	}
+
+	// too many integer digits can't be clamped
+	for _, policy := range []string{"clamp", "fail"} {
+		_, _, err := CoerceDecimal("test", decimal.RequireFromString("12345.6"), limits, policy)
+		assert.ErrorContains(t, err, "value 12345.6 for column test has 5 integer digits, and does not fit Decimal(6, 2)")
+	}
+	result, coerced, err := CoerceDecimal("test", decimal.RequireFromString("-12345"), limits, "null")
+	assert.NoError(t, err)
+	assert.True(t, coerced)
+	assert.Nil(t, result)
+
+	// too many fractional digits
+	result, coerced, err = CoerceDecimal("test", decimal.RequireFromString("1.239"), limits, "clamp")
+	assert.NoError(t, err)
+	assert.True(t, coerced)
+	assert.Equal(t, "1.23", result.(decimal.Decimal).String())
+	result, coerced, err = CoerceDecimal("test", decimal.RequireFromString("1.239"), limits, "null")
+	assert.NoError(t, err)
+	assert.True(t, coerced)
+	assert.Nil(t, result)
+	_, _, err = CoerceDecimal("test", decimal.RequireFromString("1.239"), limits, "fail")
+	assert.ErrorContains(t, err, "value 1.239 for column test has more than 2 fractional digits, and does not fit Decimal(6, 2)")
+
+	// only fractional digits
+	limits = &pb.DecimalParams{Precision: 3, Scale: 3}
+	_, coerced, err = CoerceDecimal("test", decimal.RequireFromString("0.123"), limits, "fail")
+	assert.NoError(t, err)
+	assert.False(t, coerced)
+	_, _, err = CoerceDecimal("test", decimal.RequireFromString("1.123"), limits, "fail")
+	assert.Error(t, err)
+}
+
+func TestDecimalFits(t *testing.T) {
+	limits := &pb.DecimalParams{Precision: 6, Scale: 2}
+	for _, val := range []string{"1234.56", "-1234.56", "0.5", "0", "-0.01", "9999"} {
+		assert.True(t, DecimalFits(decimal.RequireFromString(val), limits), "Value %s", val)
+	}
+	// the values that need to be coerced, or to be checked by CoerceDecimal
+	for _, val := range []string{"12345.6", "1.239", "1234.5600", "1e3"} {
+		assert.False(t, DecimalFits(decimal.RequireFromString(val), limits), "Value %s", val)
+	}
+}
+
+func TestParseWithPolicy(t *testing.T) {
+	minDate := time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		colType pb.DataType
+		value   string
+		clamped any
+	}{
+		{pb.DataType_NAIVE_DATE, "1899-12-31", minDate},
+		{pb.DataType_NAIVE_DATE, "2300-01-01", time.Date(2299, 12, 31, 0, 0, 0, 0, time.UTC)},
+		{pb.DataType_NAIVE_DATETIME, "1800-01-01T00:00:00", minDate},
+		{pb.DataType_NAIVE_DATETIME, "2262-04-12T00:00:00", MaxDateTime64},
+		{pb.DataType_UTC_DATETIME, "1800-01-01T00:00:00Z", minDate},
+		{pb.DataType_UTC_DATETIME, "9999-12-31T23:59:59.999Z", MaxDateTime64},
+		{pb.DataType_DOUBLE, "Inf", math.Inf(1)},
+		{pb.DataType_FLOAT, "-Inf", math.Inf(-1)},
+	}
+	for _, test := range tests {
+		result, coerced, err := ParseWithPolicy("test", test.colType, test.value, "clamp")
+		assert.NoError(t, err, "Value %s", test.value)
+		assert.True(t, coerced, "Value %s", test.value)
+		assert.Equal(t, test.clamped, result, "Value %s", test.value)
+
+		result, coerced, err = ParseWithPolicy("test", test.colType, test.value, "null")
+		assert.NoError(t, err, "Value %s", test.value)
+		assert.True(t, coerced, "Value %s", test.value)
+		assert.Nil(t, result, "Value %s", test.value)
+
+		_, _, err = ParseWithPolicy("test", test.colType, test.value, "fail")
+		assert.ErrorContains(t, err, "for column test is out of the supported "+test.colType.String()+" range",
+			"Value %s", test.value)
+	}
+
+	// NaN is kept as is with the clamp policy
+	result, coerced, err := ParseWithPolicy("test", pb.DataType_DOUBLE, "NaN", "clamp")
+	assert.NoError(t, err)
+	assert.True(t, coerced)
+	assert.True(t, math.IsNaN(result.(float64)))
+
+	// the values in range are never coerced
+	result, coerced, err = ParseWithPolicy("test", pb.DataType_NAIVE_DATE, "2024-01-02", "fail")
+	assert.NoError(t, err)
+	assert.False(t, coerced)
+	assert.Equal(t, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), result)
+	result, coerced, err = ParseWithPolicy("test", pb.DataType_DOUBLE, "1.5", "fail")
+	assert.NoError(t, err)
+	assert.False(t, coerced)
+	assert.Equal(t, 1.5, result)
+
+	// typed values
+	result, coerced, err = ParseTypedWithPolicy("test", pb.DataType_NAIVE_DATE,
+		time.Date(1800, 1, 1, 0, 0, 0, 0, time.UTC), "null")
+	assert.NoError(t, err)
+	assert.True(t, coerced)
+	assert.Nil(t, result)
+	_, _, err = ParseTypedWithPolicy("test", pb.DataType_FLOAT, float32(math.Inf(1)), "fail")
+	assert.ErrorContains(t, err, "value +Inf for column test is out of the supported FLOAT range")
+}
+
+func TestParseTypedValues(t *testing.T) {
+	b, err := ParseBool("test", "true")
+	assert.NoError(t, err)
+	assert.True(t, b)
+	i16, err := ParseInt16("test", "-42")
+	assert.NoError(t, err)
+	assert.Equal(t, int16(-42), i16)
+	i32, err := ParseInt32("test", "100500")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(100500), i32)
+	i64, err := ParseInt64("test", "9223372036854775807")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(math.MaxInt64), i64)
+	f, err := ParseFloat("test", pb.DataType_FLOAT, "100.1")
+	assert.NoError(t, err)
+	assert.Equal(t, float64(float32(100.1)), f)
+	f, err = ParseFloat("test", pb.DataType_DOUBLE, "100.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 100.1, f)
+	d, err := ParseDecimal("test", "42.42")
+	assert.NoError(t, err)
+	assert.Equal(t, "42.42", d.String())
+	ts, err := ParseTime("test", pb.DataType_UTC_DATETIME, "2024-02-03T12:44:22.123456789Z")
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 2, 3, 12, 44, 22, 123456789, time.UTC), ts)
+
+	// same errors as Parse
+	for _, test := range []struct {
+		colType pb.DataType
+		value   string
+		parse   func() error
+	}{
+		{pb.DataType_BOOLEAN, "yes", func() error { _, err := ParseBool("test", "yes"); return err }},
+		{pb.DataType_SHORT, "32768", func() error { _, err := ParseInt16("test", "32768"); return err }},
+		{pb.DataType_INT, "x", func() error { _, err := ParseInt32("test", "x"); return err }},
+		{pb.DataType_LONG, "1.5", func() error { _, err := ParseInt64("test", "1.5"); return err }},
+		{pb.DataType_FLOAT, "x", func() error { _, err := ParseFloat("test", pb.DataType_FLOAT, "x"); return err }},
+		{pb.DataType_DECIMAL, "x", func() error { _, err := ParseDecimal("test", "x"); return err }},
+		{pb.DataType_NAIVE_DATE, "2024-13-01", func() error {
+			_, err := ParseTime("test", pb.DataType_NAIVE_DATE, "2024-13-01")
+			return err
+		}},
+	} {
+		_, expected := Parse("test", test.colType, test.value)
+		assert.Error(t, expected, "Value %s", test.value)
+		assert.EqualError(t, test.parse(), expected.Error(), "Value %s", test.value)
+	}
+	_, err = ParseTime("test", pb.DataType_STRING, "foo")
+	assert.ErrorContains(t, err, "no target type for column test with type STRING")
+
+	assert.True(t, TimeInRange(pb.DataType_NAIVE_DATE, time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, TimeInRange(pb.DataType_NAIVE_DATE, time.Date(1899, 12, 31, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, TimeInRange(pb.DataType_NAIVE_DATETIME, time.Date(2262, 4, 12, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, TimeInRange(pb.DataType_UTC_DATETIME, time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, FloatInRange(1.5))
+	assert.False(t, FloatInRange(math.NaN()))
+	assert.False(t, FloatInRange(math.Inf(-1)))
+}
+
+func TestFormatNaiveTime(t *testing.T) {
+	assert.Equal(t, "00:00:00", FormatNaiveTime(0))
+	assert.Equal(t, "15:04:00", FormatNaiveTime(15*time.Hour+4*time.Minute))
+	assert.Equal(t, "15:04:05.123", FormatNaiveTime(15*time.Hour+4*time.Minute+5*time.Second+123*time.Millisecond))
+	assert.Equal(t, "23:59:59.999999999", FormatNaiveTime(24*time.Hour-time.Nanosecond))
+}
+
+func TestParseTyped(t *testing.T) {
+	// Values of the matching type are passed through
+	val, err := ParseTyped("test", pb.DataType_BOOLEAN, true)
+	assert.NoError(t, err)
+	assert.Equal(t, true, val)
+	val, err = ParseTyped("test", pb.DataType_LONG, int64(42))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(42), val)
+	val, err = ParseTyped("test", pb.DataType_DOUBLE, float64(200.55))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(200.55), val)
+	val, err = ParseTyped("test", pb.DataType_DECIMAL, decimal.New(4747, -2))
+	assert.NoError(t, err)
+	assert.Equal(t, decimal.New(4747, -2), val)
+
+	// Integers are narrowed with a range check
+	val, err = ParseTyped("test", pb.DataType_SHORT, int32(-32768))
+	assert.NoError(t, err)
+	assert.Equal(t, int16(-32768), val)
+	_, err = ParseTyped("test", pb.DataType_SHORT, int32(32768))
+	assert.ErrorContains(t, err, "value 32768 is out of int16 range for column test")
+	val, err = ParseTyped("test", pb.DataType_INT, int64(2147483647))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2147483647), val)
+	_, err = ParseTyped("test", pb.DataType_INT, int64(2147483648))
+	assert.ErrorContains(t, err, "value 2147483648 is out of int32 range for column test")
+	val, err = ParseTyped("test", pb.DataType_LONG, int32(43))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(43), val)
+	val, err = ParseTyped("test", pb.DataType_DECIMAL, int64(44))
+	assert.NoError(t, err)
+	assert.Equal(t, decimal.NewFromInt(44), val)
+
+	// FLOAT values are represented as float64, same as in Parse
+	val, err = ParseTyped("test", pb.DataType_FLOAT, float32(100.5))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(100.5), val)
+
+	// Date and time values are clamped, same as in Parse
+	val, err = ParseTyped("test", pb.DataType_NAIVE_DATE, time.Date(2022, 3, 5, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 3, 5, 0, 0, 0, 0, time.UTC), val)
+	val, err = ParseTyped("test", pb.DataType_NAIVE_DATE, time.Date(1800, 3, 5, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(1900, 1, 1, 0, 0, 0, 0, time.UTC), val)
+	val, err = ParseTyped("test", pb.DataType_NAIVE_DATETIME, time.Date(2022, 3, 5, 4, 45, 11, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 3, 5, 4, 45, 11, 0, time.UTC), val)
+	val, err = ParseTyped("test", pb.DataType_UTC_DATETIME, time.Date(2022, 3, 5, 4, 45, 12, 123456789, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2022, 3, 5, 4, 45, 12, 123456789, time.UTC), val)
+	val, err = ParseTyped("test", pb.DataType_UTC_DATETIME, time.Date(2300, 3, 5, 0, 0, 0, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, MaxDateTime64, val)
+
+	// Strings and mismatched types fall back to Parse
+	val, err = ParseTyped("test", pb.DataType_INT, "42")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(42), val)
+	val, err = ParseTyped("test", pb.DataType_STRING, int64(42))
+	assert.NoError(t, err)
+	assert.Equal(t, "42", val)
+	val, err = ParseTyped("test", pb.DataType_STRING, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "true", val)
+	val, err = ParseTyped("test", pb.DataType_STRING, time.Date(2022, 3, 5, 4, 45, 12, 0, time.UTC))
+	assert.NoError(t, err)
+	assert.Equal(t, "2022-03-05T04:45:12Z", val)
+	_, err = ParseTyped("test", pb.DataType_BOOLEAN, int32(42))
+	assert.ErrorContains(t, err, "can't parse value 42 as boolean for column test")
+}
+
 func TestParseTruncatedDate(t *testing.T) {
 	val, err := Parse("test", pb.DataType_NAIVE_DATE, "1899-12-31")
 	assert.NoError(t, err)
@@ -438,6 +889,19 @@ func TestNewMigrateValue(t *testing.T) {
 	}
 }
 
+func TestNewUTCDateTimeMigrateValue(t *testing.T) {
+	got, err := NewUTCDateTimeMigrateValue("2022-03-05T04:45:12.123456789Z", 3)
+	require.NoError(t, err)
+	assert.Equal(t, "'1646455512123'", got.Literal())
+
+	got, err = NewUTCDateTimeMigrateValue("2022-03-05T04:45:12.123456789Z", 6)
+	require.NoError(t, err)
+	assert.Equal(t, "'1646455512123456'", got.Literal())
+
+	_, err = NewUTCDateTimeMigrateValue("not-a-date", 3)
+	assert.ErrorContains(t, err, "UTC datetime")
+}
+
 func TestNewMigrateValue_InvalidUTCDateTime(t *testing.T) {
 	// Unlike the old formatMigrateValue (which quietly returned the raw string),
 	// NewMigrateValue errors out so the handler can fail fast instead of sending
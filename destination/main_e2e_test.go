@@ -821,11 +821,20 @@ func runQuery(t *testing.T, query string) string {
 	t.Logf("Running ClickHouse query: %s", query)
 
 	conf := readConfig(t)
+	host, port, err := net.SplitHostPort(conf.Addresses[0])
+	require.NoError(t, err)
+	// clickhouse-client always uses the native protocol
+	if conf.Protocol == config.ProtocolHTTP {
+		port = "9440"
+		if conf.Local {
+			port = "9000"
+		}
+	}
 	cmdArgs := []string{
 		"exec", "fivetran-destination-clickhouse-server",
 		"clickhouse-client", "--query", query,
-		"--host", conf.Host,
-		"--port", fmt.Sprint(conf.Port),
+		"--host", host,
+		"--port", port,
 		"--user", conf.Username,
 		"--password", conf.Password,
 	}
@@ -912,33 +921,38 @@ func TestUserFriendlyConnectionFailureMessage(t *testing.T) {
 		*flags.InitialRetryDelayMilliseconds = prevDelay
 	}()
 
-	s := &service.Server{}
-	resp, err := s.DescribeTable(context.Background(), &pb.DescribeTableRequest{
-		Configuration: map[string]string{
-			"host":     "127.0.0.1",
-			"port":     fmt.Sprint(unusedPort),
-			"username": "default",
-			"local":    "true",
-		},
-		SchemaName: "any_schema",
-		TableName:  "any_table",
-	})
-	require.NoError(t, err)
-
-	task := resp.GetTask()
-	require.NotNil(t, task, "expected DescribeTable to return a Task on connection failure, got: %+v", resp)
-
-	msg := task.GetMessage()
-	t.Logf("Task message: %s", msg)
-
-	assert.True(t, strings.HasPrefix(msg, "Failed to describe table `any_schema`.`any_table`: Could not reach the ClickHouse service."),
-		"missing operation/headline prefix; got: %q", msg)
-	assert.Contains(t, msg, "Verify the ClickHouse Cloud service is running and reachable",
-		"missing actionable hint; got: %q", msg)
-	assert.Contains(t, msg, "Technical details:",
-		"missing technical details section; got: %q", msg)
-	assert.Contains(t, msg, "ClickHouse connection error",
-		"missing original sentinel string; got: %q", msg)
-	assert.Contains(t, msg, "ping failed after",
-		"missing retry context from technical details; got: %q", msg)
+	for _, protocol := range []string{config.ProtocolNative, config.ProtocolHTTP} {
+		t.Run(protocol, func(t *testing.T) {
+			s := &service.Server{}
+			resp, err := s.DescribeTable(context.Background(), &pb.DescribeTableRequest{
+				Configuration: map[string]string{
+					"host":     "127.0.0.1",
+					"port":     fmt.Sprint(unusedPort),
+					"protocol": protocol,
+					"username": "default",
+					"local":    "true",
+				},
+				SchemaName: "any_schema",
+				TableName:  "any_table",
+			})
+			require.NoError(t, err)
+
+			task := resp.GetTask()
+			require.NotNil(t, task, "expected DescribeTable to return a Task on connection failure, got: %+v", resp)
+
+			msg := task.GetMessage()
+			t.Logf("Task message: %s", msg)
+
+			assert.True(t, strings.HasPrefix(msg, "Failed to describe table `any_schema`.`any_table`: Could not reach the ClickHouse service."),
+				"missing operation/headline prefix; got: %q", msg)
+			assert.Contains(t, msg, "Verify the ClickHouse Cloud service is running and reachable",
+				"missing actionable hint; got: %q", msg)
+			assert.Contains(t, msg, "Technical details:",
+				"missing technical details section; got: %q", msg)
+			assert.Contains(t, msg, "ClickHouse connection error",
+				"missing original sentinel string; got: %q", msg)
+			assert.Contains(t, msg, "ping failed after",
+				"missing retry context from technical details; got: %q", msg)
+		})
+	}
 }
@@ -48,10 +48,11 @@ func ToClickHouse(table *pb.Table) (*types.TableDescription, error) {
 			return nil, err
 		}
 		result[i] = &types.ColumnDefinition{
-			Name:         column.Name,
-			Type:         chType.Type,
-			Comment:      chType.Comment,
-			IsPrimaryKey: column.PrimaryKey,
+			Name:          column.Name,
+			Type:          chType.Type,
+			Comment:       chType.Comment,
+			IsPrimaryKey:  column.PrimaryKey,
+			DecimalParams: column.Params.GetDecimal(), // the Fivetran parameters, see db.nativeTypes.applyDecimal
 		}
 	}
 	return types.MakeTableDescription(result), nil
@@ -87,7 +87,8 @@ func TestToClickHouseColumns(t *testing.T) {
 	i64Col := &types.ColumnDefinition{Name: "i64", Type: "Nullable(Int64)", IsPrimaryKey: false}
 	f32Col := &types.ColumnDefinition{Name: "f32", Type: "Nullable(Float32)", IsPrimaryKey: false}
 	f64Col := &types.ColumnDefinition{Name: "f64", Type: "Nullable(Float64)", IsPrimaryKey: false}
-	decimalCol := &types.ColumnDefinition{Name: "dec", Type: "Nullable(Decimal(10, 4))", IsPrimaryKey: false}
+	decimalCol := &types.ColumnDefinition{Name: "dec", Type: "Nullable(Decimal(10, 4))", IsPrimaryKey: false,
+		DecimalParams: &pb.DecimalParams{Precision: 10, Scale: 4}}
 	dateCol := &types.ColumnDefinition{Name: "d", Type: "Nullable(Date32)", IsPrimaryKey: false}
 	datetimeCol := &types.ColumnDefinition{Name: "dt", Type: "Nullable(DateTime64(0, 'UTC'))", IsPrimaryKey: false}
 	strCol := &types.ColumnDefinition{Name: "str", Type: "Nullable(String)", IsPrimaryKey: false}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"golang.org/x/sync/errgroup"
+	"golang.org/x/sync/semaphore"
+)
+
+// fileSlots limits the number of files processed at the same time by all the calls of the process.
+// Every file holds a ClickHouse connection while its batches are inserted, and the connections are shared
+// by all the calls (see db.ClickHouseConnection), so the limit is the same as the max-open-connections flag.
+var fileSlots = sync.OnceValue(func() *semaphore.Weighted {
+	return semaphore.NewWeighted(int64(max(*flags.MaxOpenConnections, 1)))
+})
+
+// processFilesInParallel calls process for every file, with up to parallelism files at the same time (see fileSlots),
+// and returns the total number of rows returned by process. With parallelism 1, the files are processed in order.
+//
+// The first error cancels the context passed to the other calls, and no more files are started;
+// the error is returned once all the started calls have returned, so that no file is still being written afterward.
+func processFilesInParallel(
+	ctx context.Context,
+	fileNames []string,
+	parallelism uint,
+	process func(ctx context.Context, fileIdx int, fileName string) (int, error),
+) (int, error) {
+	slots := fileSlots()
+	eg, ctx := errgroup.WithContext(ctx)
+	eg.SetLimit(int(max(parallelism, 1)))
+	var totalRows atomic.Int64
+	for fileIdx, fileName := range fileNames {
+		eg.Go(func() error {
+			// fails without waiting once the context is cancelled, so the rest of the files are skipped
+			if err := slots.Acquire(ctx, 1); err != nil {
+				return err
+			}
+			defer slots.Release(1)
+			rows, err := process(ctx, fileIdx, fileName)
+			totalRows.Add(int64(rows))
+			return err
+		})
+	}
+	err := eg.Wait()
+	return int(totalRows.Load()), err
+}
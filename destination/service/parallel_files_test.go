@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"fivetran.com/fivetran_sdk/destination/common/flags"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeFileNames(n int) []string {
+	fileNames := make([]string, n)
+	for i := range fileNames {
+		fileNames[i] = fmt.Sprintf("file_%d.csv", i)
+	}
+	return fileNames
+}
+
+// concurrencyTracker records the number of the concurrent process calls, and their max;
+// the calls return once release is closed.
+type concurrencyTracker struct {
+	active  atomic.Int32
+	max     atomic.Int32
+	release chan struct{}
+}
+
+func newConcurrencyTracker() *concurrencyTracker {
+	return &concurrencyTracker{release: make(chan struct{})}
+}
+
+func (c *concurrencyTracker) process(ctx context.Context, fileIdx int, fileName string) (int, error) {
+	active := c.active.Add(1)
+	defer c.active.Add(-1)
+	for {
+		current := c.max.Load()
+		if active <= current || c.max.CompareAndSwap(current, active) {
+			break
+		}
+	}
+	<-c.release
+	return fileIdx + 1, nil
+}
+
+// processWithLimit processes the files with the tracker, and checks that the number of the concurrent calls
+// reaches the limit, before the calls are released.
+func (c *concurrencyTracker) processWithLimit(t *testing.T, fileNames []string, parallelism uint, limit int32) (int, error) {
+	var totalRows int
+	var err error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		totalRows, err = processFilesInParallel(context.Background(), fileNames, parallelism, c.process)
+	}()
+	assert.Eventually(t, func() bool { return c.active.Load() == limit }, 5*time.Second, time.Millisecond)
+	close(c.release)
+	<-done
+	assert.Equal(t, limit, c.max.Load())
+	return totalRows, err
+}
+
+func TestProcessFilesInParallel(t *testing.T) {
+	totalRows, err := newConcurrencyTracker().processWithLimit(t, makeFileNames(12), 3, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 78, totalRows) // 1 + 2 + ... + 12
+}
+
+func TestProcessFilesInParallelSequential(t *testing.T) {
+	var processed []string
+	fileNames := makeFileNames(5)
+	totalRows, err := processFilesInParallel(context.Background(), fileNames, 1,
+		func(ctx context.Context, fileIdx int, fileName string) (int, error) {
+			processed = append(processed, fileName)
+			return 10, nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 50, totalRows)
+	assert.Equal(t, fileNames, processed)
+
+	totalRows, err = processFilesInParallel(context.Background(), nil, 4,
+		func(ctx context.Context, fileIdx int, fileName string) (int, error) {
+			t.Fatal("no files to process")
+			return 0, nil
+		})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, totalRows)
+}
+
+func TestProcessFilesInParallelMaxOpenConnections(t *testing.T) {
+	fileCount := int(*flags.MaxOpenConnections) * 3
+	totalRows, err := newConcurrencyTracker().processWithLimit(t, makeFileNames(fileCount),
+		flags.ParallelReplaceFilesSetting.MaxValue, int32(*flags.MaxOpenConnections))
+	assert.NoError(t, err)
+	assert.Equal(t, fileCount*(fileCount+1)/2, totalRows)
+}
+
+func TestProcessFilesInParallelError(t *testing.T) {
+	for _, parallelism := range []uint{1, 4} {
+		var (
+			mutex     sync.Mutex
+			started   []int
+			cancelled atomic.Int32
+		)
+		// closed once parallelism files are started
+		allStarted := make(chan struct{})
+		_, err := processFilesInParallel(context.Background(), makeFileNames(20), parallelism,
+			func(ctx context.Context, fileIdx int, fileName string) (int, error) {
+				mutex.Lock()
+				started = append(started, fileIdx)
+				if len(started) == int(parallelism) {
+					close(allStarted)
+				}
+				mutex.Unlock()
+				if fileIdx == 2 {
+					<-allStarted
+					return 0, fmt.Errorf("failed to insert %s", fileName)
+				}
+				if parallelism == 1 {
+					return 1, nil
+				}
+				// the other files are processed until the context is cancelled by the error
+				<-ctx.Done()
+				cancelled.Add(1)
+				return 0, ctx.Err()
+			})
+		assert.EqualError(t, err, "failed to insert file_2.csv", "parallelism %d", parallelism)
+		if parallelism == 1 {
+			assert.Equal(t, []int{0, 1, 2}, started)
+			assert.Equal(t, int32(0), cancelled.Load())
+		} else {
+			// the files processed at the same time are cancelled, and no more files are started
+			assert.ElementsMatch(t, []int{0, 1, 2, 3}, started)
+			assert.Equal(t, int32(3), cancelled.Load())
+		}
+	}
+}
+
+func TestProcessFilesInParallelCancellation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := processFilesInParallel(ctx, makeFileNames(3), 2,
+		func(ctx context.Context, fileIdx int, fileName string) (int, error) {
+			t.Fatal("no files are started with a cancelled context")
+			return 0, nil
+		})
+	assert.ErrorIs(t, err, context.Canceled)
+}
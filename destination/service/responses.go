@@ -9,11 +9,19 @@ import (
 	"fivetran.com/fivetran_sdk/destination/common/retry"
 	"fivetran.com/fivetran_sdk/destination/db/config"
 	pb "fivetran.com/fivetran_sdk/proto"
-	"github.com/ClickHouse/clickhouse-go/v2"
 )
 
-var hostDescription = "ClickHouse Cloud service host without protocol or port. For example, my.service.clickhouse.cloud"
-var portDescription = "ClickHouse Cloud service native protocol SSL/TLS port. Default is 9440"
+var hostDescription = "ClickHouse Cloud service host without protocol or port. For example, my.service.clickhouse.cloud. For self-hosted clusters, a comma-separated list of hosts can be provided; each host may optionally specify its own port, e.g. node1:9440,node2:9440"
+var portDescription = "ClickHouse Cloud service SSL/TLS port. Default is 9440 for the native protocol, and 8443 for HTTPS"
+var protocolDescription = "Protocol used to connect to ClickHouse. Use HTTP if only HTTPS traffic is allowed between Fivetran and ClickHouse (for example, via a proxy or a load balancer). Default is native"
+var connectionStrategyDescription = "How a host is selected when multiple hosts are configured: in_order (fail over to the next host), round_robin or random. Hosts that recently failed are tried last. Default is in_order"
+var ddlUsernameDescription = "Optional separate user to create and alter databases and tables. If set, the main user only needs the privileges to insert, select, update and delete the data"
+var clusterDescription = "Self-hosted ClickHouse only: the cluster name from the remote_servers configuration. DDL is executed ON CLUSTER and tables are created with the ReplicatedReplacingMergeTree engine. Leave empty for ClickHouse Cloud"
+var tlsCACertificateDescription = "Optional PEM-encoded CA certificate bundle used to verify the ClickHouse server certificate, instead of the system trusted CAs. Required if the server certificate is issued by an internal CA"
+var tlsClientCertificateDescription = "Optional PEM-encoded client certificate for mutual TLS authentication. Requires the client key"
+var tlsClientKeyDescription = "Optional PEM-encoded private key of the client certificate"
+var tlsServerNameDescription = "Optional host name used to verify the server certificate, if it differs from the configured host (for example, when connecting via an IP address or a proxy)"
+var tlsMinVersionDescription = "Minimum accepted TLS version. Default is 1.2"
 var advancedConfigDescription = "Optional JSON configuration file for fine-tuning destination behavior. See the documentation for the file schema"
 
 func GetConfigurationFormResponse() *pb.ConfigurationFormResponse {
@@ -44,6 +52,32 @@ func GetConfigurationFormResponse() *pb.ConfigurationFormResponse {
 					TextField: pb.TextField_PlainText,
 				},
 			},
+			{
+				Name:        config.ProtocolKey,
+				Label:       "Protocol",
+				Description: &protocolDescription,
+				Required:    &isNotRequired,
+				Type: &pb.FormField_DropdownField{
+					DropdownField: &pb.DropdownField{
+						DropdownField: []string{config.ProtocolNative, config.ProtocolHTTP},
+					},
+				},
+			},
+			{
+				Name:        config.ConnectionStrategyKey,
+				Label:       "Connection strategy",
+				Description: &connectionStrategyDescription,
+				Required:    &isNotRequired,
+				Type: &pb.FormField_DropdownField{
+					DropdownField: &pb.DropdownField{
+						DropdownField: []string{
+							config.ConnectionStrategyInOrder,
+							config.ConnectionStrategyRoundRobin,
+							config.ConnectionStrategyRandom,
+						},
+					},
+				},
+			},
 			{
 				Name:     config.UsernameKey,
 				Label:    "Username",
@@ -60,6 +94,88 @@ func GetConfigurationFormResponse() *pb.ConfigurationFormResponse {
 					TextField: pb.TextField_Password,
 				},
 			},
+			{
+				Name:        config.DDLUsernameKey,
+				Label:       "DDL username",
+				Description: &ddlUsernameDescription,
+				Required:    &isNotRequired,
+				Type: &pb.FormField_TextField{
+					TextField: pb.TextField_PlainText,
+				},
+			},
+			{
+				Name:     config.DDLPasswordKey,
+				Label:    "DDL password",
+				Required: &isNotRequired,
+				Type: &pb.FormField_TextField{
+					TextField: pb.TextField_Password,
+				},
+			},
+			{
+				Name:        config.ClusterKey,
+				Label:       "Cluster",
+				Description: &clusterDescription,
+				Required:    &isNotRequired,
+				Type: &pb.FormField_TextField{
+					TextField: pb.TextField_PlainText,
+				},
+			},
+			{
+				Name:        config.TLSCACertificateKey,
+				Label:       "TLS CA certificate",
+				Description: &tlsCACertificateDescription,
+				Required:    &isNotRequired,
+				Type: &pb.FormField_UploadField{
+					UploadField: &pb.UploadField{
+						AllowedFileType:  []string{".pem", ".crt"},
+						MaxFileSizeBytes: 1_048_576, // 1 MiB
+					},
+				},
+			},
+			{
+				Name:        config.TLSClientCertificateKey,
+				Label:       "TLS client certificate",
+				Description: &tlsClientCertificateDescription,
+				Required:    &isNotRequired,
+				Type: &pb.FormField_UploadField{
+					UploadField: &pb.UploadField{
+						AllowedFileType:  []string{".pem", ".crt"},
+						MaxFileSizeBytes: 1_048_576, // 1 MiB
+					},
+				},
+			},
+			{
+				Name:        config.TLSClientKeyKey,
+				Label:       "TLS client key",
+				Description: &tlsClientKeyDescription,
+				Required:    &isNotRequired,
+				Type: &pb.FormField_UploadField{
+					UploadField: &pb.UploadField{
+						AllowedFileType:  []string{".pem", ".key"},
+						MaxFileSizeBytes: 1_048_576, // 1 MiB
+					},
+				},
+			},
+			{
+				Name:        config.TLSServerNameKey,
+				Label:       "TLS server name",
+				Description: &tlsServerNameDescription,
+				Required:    &isNotRequired,
+				Type: &pb.FormField_TextField{
+					TextField: pb.TextField_PlainText,
+				},
+			},
+			{
+				Name:        config.TLSMinVersionKey,
+				Label:       "TLS minimum version",
+				Description: &tlsMinVersionDescription,
+				Required:    &isNotRequired,
+				Type: &pb.FormField_DropdownField{
+					DropdownField: &pb.DropdownField{
+						DropdownField: []string{config.TLSVersion12, config.TLSVersion13},
+					},
+				},
+			},
 			{
 				Name:        config.AdvancedConfigKey,
 				Label:       "Advanced Configuration",
@@ -210,14 +326,14 @@ const (
 func addUserReadableHintsToError(operation string, err error) string {
 	friendly := "Unexpected error in the ClickHouse destination. Please contact Fivetran support and include the technical details below."
 
-	var ex *clickhouse.Exception
+	code, isException := retry.ExceptionCode(err)
 	switch {
 	case errors.Is(err, context.DeadlineExceeded):
 		friendly = "The ClickHouse operation took too long to complete. Retry the sync. If the problem persists, check the performance of the SQL executed. You may need to optimize batch sizes or scale up the ClickHouse service."
 	case errors.Is(err, context.Canceled):
 		friendly = "The operation was cancelled before ClickHouse could complete it. Retry the sync."
-	case errors.As(err, &ex):
-		switch ex.Code {
+	case isException:
+		switch code {
 		case chCodeAuthenticationFailed, chCodeUnknownUser:
 			friendly = "ClickHouse rejected the credentials. Verify the username and password configured for the destination."
 		case chCodeNotEnoughPrivileges:
@@ -227,6 +343,8 @@ func addUserReadableHintsToError(operation string, err error) string {
 		case chCodeTimeoutExceeded, chCodeSocketTimeout:
 			friendly = "The ClickHouse query took too long to complete. Retry the sync. If the problem persists, check the performance of the SQL executed. You may need to optimize batch sizes or scale up the ClickHouse service."
 		}
+	case retry.IsTLSError(err):
+		friendly = "The TLS handshake with ClickHouse failed. Verify the TLS settings configured for the destination (CA certificate, client certificate and key, server name, minimum TLS version), and that the port accepts secure connections."
 	case retry.IsNetError(err):
 		friendly = "Could not reach the ClickHouse service. Verify the ClickHouse Cloud service is running and reachable from Fivetran (host, port, IP allowlist)."
 	}
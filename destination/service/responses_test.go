@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
@@ -98,6 +100,16 @@ func TestAddUserReadableHintsToError(t *testing.T) {
 			err:              fmt.Errorf("read: %w", syscall.ECONNRESET),
 			expectedFriendly: "Could not reach the ClickHouse service. Verify the ClickHouse Cloud service is running and reachable from Fivetran (host, port, IP allowlist).",
 		},
+		{
+			name:             "tls_alert",
+			err:              fmt.Errorf("ClickHouse connection error: %w", &net.OpError{Op: "remote error", Err: tls.AlertError(42)}),
+			expectedFriendly: "The TLS handshake with ClickHouse failed. Verify the TLS settings configured for the destination (CA certificate, client certificate and key, server name, minimum TLS version), and that the port accepts secure connections.",
+		},
+		{
+			name:             "tls_unknown_authority",
+			err:              &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}},
+			expectedFriendly: "The TLS handshake with ClickHouse failed. Verify the TLS settings configured for the destination (CA certificate, client certificate and key, server name, minimum TLS version), and that the port accepts secure connections.",
+		},
 		{
 			name:             "plain_error",
 			err:              errors.New("something blew up"),
@@ -8,7 +8,9 @@ import (
 	"fivetran.com/fivetran_sdk/destination/common/benchmark"
 	"fivetran.com/fivetran_sdk/destination/common/constants"
 	csvreader "fivetran.com/fivetran_sdk/destination/common/csv"
+	"fivetran.com/fivetran_sdk/destination/common/files"
 	"fivetran.com/fivetran_sdk/destination/common/log"
+	parquetreader "fivetran.com/fivetran_sdk/destination/common/parquet"
 	"fivetran.com/fivetran_sdk/destination/common/types"
 	"fivetran.com/fivetran_sdk/destination/db"
 	"fivetran.com/fivetran_sdk/destination/db/config"
@@ -27,42 +29,44 @@ func (s *Server) ConfigurationForm(_ context.Context, _ *pb.ConfigurationFormReq
 }
 
 func (s *Server) Capabilities(_ context.Context, _ *pb.CapabilitiesRequest) (*pb.CapabilitiesResponse, error) {
-	return &pb.CapabilitiesResponse{BatchFileFormat: pb.BatchFileFormat_CSV}, nil
+	batchFileFormat, err := files.FormatFromFlags()
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CapabilitiesResponse{BatchFileFormat: batchFileFormat}, nil
 }
 
 func (s *Server) Test(ctx context.Context, in *pb.TestRequest) (*pb.TestResponse, error) {
 	log.Info(fmt.Sprintf("[Test_%s] Starting test", in.Name))
-	connConfig, err := config.ParseAll(in.GetConfiguration())
+	connConfig, settings, err := config.ParseAll(in.GetConfiguration())
 	if err != nil {
 		log.Error(fmt.Errorf("[Test_%s] %w", in.Name, err))
 		return FailedTestResponse(in.Name, err), nil
 	}
-	conn, err := db.GetClickHouseConnection(ctx, connConfig)
-	if err != nil {
-		log.Error(fmt.Errorf("[Test_%s] Failed to connect: %w", in.Name, err))
-		return FailedTestResponse(in.Name, err), nil
-	}
-	defer conn.Close() //nolint:errcheck
-
-	switch in.Name {
-	case ConnectionTest:
-		err = conn.ConnectionTest(ctx)
-		if err != nil {
-			log.Error(fmt.Errorf("[Test_%s] Connection test failed: %w", in.Name, err))
+	if in.Name == ConnectionTest {
+		// the server only reports a generic TLS alert for expired certificates
+		if err = connConfig.TLS.CheckExpiry(time.Now()); err != nil {
+			log.Error(fmt.Errorf("[Test_%s] Invalid TLS certificates: %w", in.Name, err))
 			return FailedTestResponse(in.Name, err), nil
 		}
-		log.Info("Connection test passed")
-	case GrantsTest:
-		err = conn.GrantsTest(ctx)
+	}
+	// with a separate DDL user, both users are tested, each with its own set of privileges
+	users := []testUser{{connConfig: connConfig, grants: db.AllGrants}}
+	if connConfig.HasDDLCredentials() {
+		users = []testUser{
+			{connConfig: connConfig, grants: db.DMLGrants},
+			{connConfig: connConfig.ForDDL(), grants: db.DDLGrants},
+		}
+	}
+	for _, user := range users {
+		err = runTest(ctx, in.Name, user.connConfig, settings, user.grants)
 		if err != nil {
-			log.Error(fmt.Errorf("[Test_%s] Grants test failed: %w", in.Name, err))
+			if connConfig.HasDDLCredentials() {
+				err = fmt.Errorf("user %s: %w", user.connConfig.Username, err)
+			}
+			log.Error(fmt.Errorf("[Test_%s] %w", in.Name, err))
 			return FailedTestResponse(in.Name, err), nil
 		}
-		log.Info("User grants test passed")
-	default:
-		err := fmt.Errorf("unexpected test name: %s", in.Name)
-		log.Error(fmt.Errorf("[Test_%s] %w", in.Name, err))
-		return FailedTestResponse(in.Name, err), nil
 	}
 
 	log.Info(fmt.Sprintf("[Test_%s] Test completed successfully", in.Name))
@@ -73,14 +77,44 @@ func (s *Server) Test(ctx context.Context, in *pb.TestRequest) (*pb.TestResponse
 	}, nil
 }
 
+type testUser struct {
+	connConfig *config.Config
+	grants     db.GrantsScope
+}
+
+func runTest(ctx context.Context, name string, connConfig *config.Config, settings *config.Settings, grants db.GrantsScope) error {
+	if name != ConnectionTest && name != GrantsTest {
+		return fmt.Errorf("unexpected test name: %s", name)
+	}
+	conn, err := db.GetClickHouseConnection(ctx, connConfig, settings)
+	if err != nil {
+		return fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	switch name {
+	case ConnectionTest:
+		if err = conn.ConnectionTest(ctx); err != nil {
+			return fmt.Errorf("connection test failed: %w", err)
+		}
+		log.Info(fmt.Sprintf("Connection test passed for user %s", connConfig.Username))
+	case GrantsTest:
+		if err = conn.GrantsTest(ctx, grants); err != nil {
+			return fmt.Errorf("grants test failed: %w", err)
+		}
+		log.Info(fmt.Sprintf("User grants test passed for user %s", connConfig.Username))
+	}
+	return nil
+}
+
 func (s *Server) DescribeTable(ctx context.Context, in *pb.DescribeTableRequest) (*pb.DescribeTableResponse, error) {
 	log.Info(fmt.Sprintf("[DescribeTable] Starting for %s.%s", in.SchemaName, in.TableName))
-	connConfig, err := config.ParseAll(in.GetConfiguration())
+	connConfig, settings, err := config.ParseAll(in.GetConfiguration())
 	if err != nil {
 		log.Error(fmt.Errorf("[DescribeTable] %w", err))
 		return FailedDescribeTableResponse(in.SchemaName, in.TableName, err), nil
 	}
-	conn, err := db.GetClickHouseConnection(ctx, connConfig)
+	conn, err := db.GetClickHouseConnection(ctx, connConfig, settings)
 	if err != nil {
 		log.Error(fmt.Errorf("[DescribeTable] Failed to connect for %s.%s: %w", in.SchemaName, in.TableName, err))
 		return FailedDescribeTableResponse(in.SchemaName, in.TableName, err), nil
@@ -116,12 +150,12 @@ func (s *Server) DescribeTable(ctx context.Context, in *pb.DescribeTableRequest)
 
 func (s *Server) CreateTable(ctx context.Context, in *pb.CreateTableRequest) (*pb.CreateTableResponse, error) {
 	log.Info(fmt.Sprintf("[CreateTable] Starting for %s.%s with %d columns", in.SchemaName, in.Table.Name, len(in.Table.Columns)))
-	connConfig, err := config.ParseAll(in.GetConfiguration())
+	connConfig, settings, err := config.ParseAll(in.GetConfiguration())
 	if err != nil {
 		log.Error(fmt.Errorf("[CreateTable] %w", err))
 		return FailedCreateTableResponse(in.SchemaName, in.Table.Name, err), nil
 	}
-	conn, err := db.GetClickHouseConnection(ctx, connConfig)
+	conn, err := db.GetClickHouseConnection(ctx, connConfig.ForDDL(), settings)
 	if err != nil {
 		log.Error(fmt.Errorf("[CreateTable] Failed to connect for %s.%s: %w", in.SchemaName, in.Table.Name, err))
 		return FailedCreateTableResponse(in.SchemaName, in.Table.Name, err), nil
@@ -152,12 +186,12 @@ func (s *Server) CreateTable(ctx context.Context, in *pb.CreateTableRequest) (*p
 
 func (s *Server) AlterTable(ctx context.Context, in *pb.AlterTableRequest) (*pb.AlterTableResponse, error) {
 	log.Info(fmt.Sprintf("[AlterTable] Starting for %s.%s with %d columns", in.SchemaName, in.Table.Name, len(in.Table.Columns)))
-	connConfig, err := config.ParseAll(in.GetConfiguration())
+	connConfig, settings, err := config.ParseAll(in.GetConfiguration())
 	if err != nil {
 		log.Error(fmt.Errorf("[AlterTable] %w", err))
 		return FailedAlterTableResponse(in.SchemaName, in.Table.Name, err), nil
 	}
-	conn, err := db.GetClickHouseConnection(ctx, connConfig)
+	conn, err := db.GetClickHouseConnection(ctx, connConfig.ForDDL(), settings)
 	if err != nil {
 		log.Error(fmt.Errorf("[AlterTable] Failed to connect for %s.%s: %w", in.SchemaName, in.Table.Name, err))
 		return FailedAlterTableResponse(in.SchemaName, in.Table.Name, err), nil
@@ -165,7 +199,7 @@ func (s *Server) AlterTable(ctx context.Context, in *pb.AlterTableRequest) (*pb.
 	defer conn.Close() //nolint:errcheck
 
 	log.Info(fmt.Sprintf("[AlterTable] Describing current table %s.%s", in.SchemaName, in.Table.Name))
-	currentTableDescription, err := conn.DescribeTable(ctx, in.SchemaName, in.Table.Name)
+	currentTableDescription, err := conn.DescribeTableCached(ctx, in.SchemaName, in.Table.Name)
 	if err != nil {
 		log.Error(fmt.Errorf("[AlterTable] Failed to describe current table %s.%s: %w", in.SchemaName, in.Table.Name, err))
 		return FailedAlterTableResponse(in.SchemaName, in.Table.Name, err), nil
@@ -208,12 +242,12 @@ func (s *Server) Truncate(ctx context.Context, in *pb.TruncateRequest) (*pb.Trun
 	log.Info(fmt.Sprintf("[Truncate] Starting %s delete for %s.%s, synced_column=%s, truncate_before=%s",
 		deleteType, in.SchemaName, in.TableName, in.SyncedColumn, truncateBefore.Format(time.RFC3339)))
 
-	connConfig, err := config.ParseAll(in.GetConfiguration())
+	connConfig, settings, err := config.ParseAll(in.GetConfiguration())
 	if err != nil {
 		log.Error(fmt.Errorf("[Truncate] %w", err))
 		return FailedTruncateTableResponse(in.SchemaName, in.TableName, err), nil
 	}
-	conn, err := db.GetClickHouseConnection(ctx, connConfig)
+	conn, err := db.GetClickHouseConnection(ctx, connConfig, settings)
 	if err != nil {
 		log.Error(fmt.Errorf("[Truncate] GetClickHouseConnection error for %s.%s: %w", in.SchemaName, in.TableName, err))
 		return FailedTruncateTableResponse(in.SchemaName, in.TableName, err), nil
@@ -222,7 +256,7 @@ func (s *Server) Truncate(ctx context.Context, in *pb.TruncateRequest) (*pb.Trun
 
 	log.Info(fmt.Sprintf("[Truncate] Checking if table %s.%s exists", in.SchemaName, in.TableName))
 	// should not be failed if the table does not exist, as per SDK documentation
-	tableDescription, err := conn.DescribeTable(ctx, in.SchemaName, in.TableName)
+	tableDescription, err := conn.DescribeTableCached(ctx, in.SchemaName, in.TableName)
 	if err != nil {
 		log.Error(fmt.Errorf("[Truncate] DescribeTable error for %s.%s: %w", in.SchemaName, in.TableName, err))
 		return FailedTruncateTableResponse(in.SchemaName, in.TableName, err), nil
@@ -270,12 +304,12 @@ func (s *Server) WriteHistoryBatch(ctx context.Context, in *pb.WriteHistoryBatch
 		return FailedWriteHistoryBatchResponse(in.SchemaName, in.Table.Name, fmt.Errorf("GetFivetranTableMetadata error: %w", err)), nil
 	}
 
-	connConfig, err := config.ParseAll(in.GetConfiguration())
+	connConfig, settings, err := config.ParseAll(in.GetConfiguration())
 	if err != nil {
 		log.Error(fmt.Errorf("[WriteHistoryBatch] %w", err))
 		return FailedWriteHistoryBatchResponse(in.SchemaName, in.Table.Name, err), nil
 	}
-	conn, err := db.GetClickHouseConnection(ctx, connConfig)
+	conn, err := db.GetClickHouseConnection(ctx, connConfig, settings)
 	if err != nil {
 		log.Error(fmt.Errorf("[WriteHistoryBatch] GetClickHouseConnection error for %s.%s: %w", in.SchemaName, in.Table.Name, err))
 		return FailedWriteHistoryBatchResponse(in.SchemaName, in.Table.Name, fmt.Errorf("GetClickHouseConnection error: %w", err)), nil
@@ -283,39 +317,43 @@ func (s *Server) WriteHistoryBatch(ctx context.Context, in *pb.WriteHistoryBatch
 	defer conn.Close() //nolint:errcheck
 
 	log.Notice(fmt.Sprintf("[WriteHistoryBatch] Getting column types for %s.%s", in.SchemaName, in.Table.Name))
-	columnTypes, err := conn.GetColumnTypes(ctx, in.SchemaName, in.Table.Name)
+	driverColumns, err := conn.GetDriverColumns(ctx, in.SchemaName, in.Table.Name)
 	if err != nil {
 		log.Error(fmt.Errorf("[WriteHistoryBatch] GetColumnTypes error for %s.%s: %w", in.SchemaName, in.Table.Name, err))
 		return FailedWriteHistoryBatchResponse(in.SchemaName, in.Table.Name, fmt.Errorf("GetColumnTypes error: %w", err)), nil
 	}
-	driverColumns := types.MakeDriverColumns(columnTypes)
 
 	// Benchmark overall WriteHistoryBatchRequest and, separately, EarliestStart/Replace/Update/Delete operations
+	// As with WriteBatch, only the replace files may be inserted in parallel, and the steps are run in sequence.
 	err = benchmark.RunAndNotice(func() error {
-		err = s.processEarliestStartFilesForHistoryBatch(ctx, in, conn, compression, encryption, metadata, driverColumns)
+		err = s.processEarliestStartFilesForHistoryBatch(ctx, in, conn, settings.BatchFileFormat, compression, encryption, metadata, driverColumns)
 		if err != nil {
 			return err
 		}
 
-		err = s.processReplaceFilesForHistoryBatch(ctx, in, conn, compression, encryption, nullStr, metadata, driverColumns)
+		err = s.processReplaceFilesForHistoryBatch(ctx, in, conn, settings.BatchFileFormat, compression, encryption, nullStr, metadata, driverColumns,
+			settings.ParallelReplaceFiles)
 		if err != nil {
 			return err
 		}
-		err = s.processUpdateFilesForHistoryBatch(ctx, in, conn, compression, encryption, nullStr, unmodifiedStr, metadata, driverColumns)
+		err = s.processUpdateFilesForHistoryBatch(ctx, in, conn, settings.BatchFileFormat, compression, encryption, nullStr, unmodifiedStr, metadata, driverColumns)
 		if err != nil {
 			return err
 		}
-		err = s.processDeleteFilesForHistoryBatch(ctx, in, conn, compression, encryption, metadata, driverColumns)
+		err = s.processDeleteFilesForHistoryBatch(ctx, in, conn, settings.BatchFileFormat, compression, encryption, metadata, driverColumns)
 		if err != nil {
 			return err
 		}
 		return nil
 	}, writeHistoryBatchTotalOp)
 	if err != nil {
+		// the table could have been changed outside of the destination; don't reuse the cached column types on retry
+		conn.InvalidateTableMetadata(in.SchemaName, in.Table.Name)
 		log.Error(fmt.Errorf("[WriteHistoryBatch] Operation error for %s.%s: %w", in.SchemaName, in.Table.Name, err))
 		return FailedWriteHistoryBatchResponse(in.SchemaName, in.Table.Name, fmt.Errorf("operation error: %w", err)), nil
 	}
 
+	logCoercions("WriteHistoryBatch", in.SchemaName, in.Table.Name, driverColumns)
 	log.Notice(fmt.Sprintf("[WriteHistoryBatch] Completed successfully for %s.%s", in.SchemaName, in.Table.Name))
 	return &pb.WriteBatchResponse{
 		Response: &pb.WriteBatchResponse_Success{
@@ -352,12 +390,12 @@ func (s *Server) WriteBatch(ctx context.Context, in *pb.WriteBatchRequest) (*pb.
 		return FailedWriteBatchResponse(in.SchemaName, in.Table.Name, err), nil
 	}
 
-	connConfig, err := config.ParseAll(in.GetConfiguration())
+	connConfig, settings, err := config.ParseAll(in.GetConfiguration())
 	if err != nil {
 		log.Error(fmt.Errorf("[WriteBatch] %w", err))
 		return FailedWriteBatchResponse(in.SchemaName, in.Table.Name, err), nil
 	}
-	conn, err := db.GetClickHouseConnection(ctx, connConfig)
+	conn, err := db.GetClickHouseConnection(ctx, connConfig, settings)
 	if err != nil {
 		log.Error(fmt.Errorf("[WriteBatch] Failed to connect for %s.%s: %w", in.SchemaName, in.Table.Name, err))
 		return FailedWriteBatchResponse(in.SchemaName, in.Table.Name, err), nil
@@ -365,34 +403,39 @@ func (s *Server) WriteBatch(ctx context.Context, in *pb.WriteBatchRequest) (*pb.
 	defer conn.Close() //nolint:errcheck
 
 	log.Notice(fmt.Sprintf("[WriteBatch] Getting column types for %s.%s", in.SchemaName, in.Table.Name))
-	columnTypes, err := conn.GetColumnTypes(ctx, in.SchemaName, in.Table.Name)
+	driverColumns, err := conn.GetDriverColumns(ctx, in.SchemaName, in.Table.Name)
 	if err != nil {
 		log.Error(fmt.Errorf("[WriteBatch] Failed to get column types for %s.%s: %w", in.SchemaName, in.Table.Name, err))
 		return FailedWriteBatchResponse(in.SchemaName, in.Table.Name, err), nil
 	}
-	driverColumns := types.MakeDriverColumns(columnTypes)
 
 	// Benchmark overall WriteBatchRequest and, separately, Replace/Update/Delete operations
+	// The replace files may be inserted in parallel, but each step only starts after the previous one is complete,
+	// and the update and delete files are processed in order, as a later file may change the rows of an earlier one.
 	err = benchmark.RunAndNotice(func() error {
-		err = s.processReplaceFiles(ctx, in, conn, compression, encryption, nullStr, metadata, driverColumns)
+		err = s.processReplaceFiles(ctx, in, conn, settings.BatchFileFormat, compression, encryption, nullStr, metadata, driverColumns,
+			settings.ParallelReplaceFiles)
 		if err != nil {
 			return err
 		}
-		err = s.processUpdateFiles(ctx, in, conn, compression, encryption, nullStr, unmodifiedStr, metadata, driverColumns)
+		err = s.processUpdateFiles(ctx, in, conn, settings.BatchFileFormat, compression, encryption, nullStr, unmodifiedStr, metadata, driverColumns)
 		if err != nil {
 			return err
 		}
-		err = s.processDeleteFiles(ctx, in, conn, compression, encryption, metadata, driverColumns)
+		err = s.processDeleteFiles(ctx, in, conn, settings.BatchFileFormat, compression, encryption, metadata, driverColumns)
 		if err != nil {
 			return err
 		}
 		return nil
 	}, writeBatchTotalOp)
 	if err != nil {
+		// the table could have been changed outside of the destination; don't reuse the cached column types on retry
+		conn.InvalidateTableMetadata(in.SchemaName, in.Table.Name)
 		log.Error(fmt.Errorf("[WriteBatch] Failed for %s.%s: %w", in.SchemaName, in.Table.Name, err))
 		return FailedWriteBatchResponse(in.SchemaName, in.Table.Name, err), nil
 	}
 
+	logCoercions("WriteBatch", in.SchemaName, in.Table.Name, driverColumns)
 	log.Notice(fmt.Sprintf("[WriteBatch] Completed successfully for %s.%s", in.SchemaName, in.Table.Name))
 	return &pb.WriteBatchResponse{
 		Response: &pb.WriteBatchResponse_Success{
@@ -401,37 +444,56 @@ func (s *Server) WriteBatch(ctx context.Context, in *pb.WriteBatchRequest) (*pb.
 	}, nil
 }
 
+// logCoercions logs the number of the coerced values per column (see types.Coercions), if there are any.
+// The batch is already written at this point, so it still succeeds: a task would make Fivetran retry it,
+// and the retry would coerce the same values again. The fail policy returns an error while writing instead.
+func logCoercions(op string, schemaName string, tableName string, driverColumns *types.DriverColumns) {
+	summary := driverColumns.Coercions.Summary()
+	if summary == "" {
+		return
+	}
+	log.Warn(fmt.Sprintf("[%s] Values out of the supported range were coerced for %s.%s (column: count): %s",
+		op, schemaName, tableName, summary))
+}
+
 func (s *Server) processReplaceFiles(
 	ctx context.Context,
 	in *pb.WriteBatchRequest,
 	conn *db.ClickHouseConnection,
+	batchFileFormat pb.BatchFileFormat,
 	compression pb.Compression,
 	encryption pb.Encryption,
 	nullStr string,
 	metadata *types.FivetranTableMetadata,
 	driverColumns *types.DriverColumns,
+	parallelism uint,
 ) (err error) {
 	if len(in.ReplaceFiles) > 0 {
-		log.Notice(fmt.Sprintf("[%s] Processing %d replace files for %s.%s", writeBatchReplaceOp, len(in.ReplaceFiles), in.SchemaName, in.Table.Name))
+		log.Notice(fmt.Sprintf("[%s] Processing %d replace files for %s.%s (up to %d at a time)",
+			writeBatchReplaceOp, len(in.ReplaceFiles), in.SchemaName, in.Table.Name, parallelism))
+		totalRows := 0
 		err = benchmark.RunAndNotice(func() error {
-			for fileIdx, replaceFile := range in.ReplaceFiles {
-				log.Notice(fmt.Sprintf("[%s] Processing file %d/%d: %s", writeBatchReplaceOp, fileIdx+1, len(in.ReplaceFiles), replaceFile))
-				if err := func() error {
-					reader, err := csvreader.NewCSVFileReader(replaceFile, in.Keys, compression, encryption)
+			// the replace files are independent of each other, as ReplacingMergeTree keeps the row with the latest
+			// _fivetran_synced version regardless of the insert order; the update and delete files
+			// are only processed after all of them are inserted
+			totalRows, err = processFilesInParallel(ctx, in.ReplaceFiles, parallelism,
+				func(ctx context.Context, fileIdx int, replaceFile string) (int, error) {
+					log.Notice(fmt.Sprintf("[%s] Processing file %d/%d: %s", writeBatchReplaceOp, fileIdx+1, len(in.ReplaceFiles), replaceFile))
+					reader, err := openBatchFile(replaceFile, in.Keys, batchFileFormat, compression, encryption, in.GetFileParams().GetNullString())
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to open CSV file %s: %w", writeBatchReplaceOp, replaceFile, err)
+						return 0, fmt.Errorf("[%s] Failed to open batch file %s: %w", writeBatchReplaceOp, replaceFile, err)
 					}
 					defer reader.Close()
 					csvColumns, err := types.MakeCSVColumns(reader.Header(), driverColumns, metadata.ColumnsMap, true)
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to make CSV columns for file %s: %w", writeBatchReplaceOp, replaceFile, err)
+						return 0, fmt.Errorf("[%s] Failed to make CSV columns for file %s: %w", writeBatchReplaceOp, replaceFile, err)
 					}
-					log.Notice(fmt.Sprintf("[%s] Executing ReplaceBatch for %s.%s", writeBatchReplaceOp, in.SchemaName, in.Table.Name))
-					totalRows, err := conn.ReplaceBatch(ctx, in.SchemaName, in.Table, reader, csvColumns, nullStr)
+					log.Notice(fmt.Sprintf("[%s] Executing ReplaceBatch for %s.%s with file %s", writeBatchReplaceOp, in.SchemaName, in.Table.Name, replaceFile))
+					fileRows, err := conn.ReplaceBatch(ctx, in.SchemaName, in.Table, reader, csvColumns, nullStr)
 					if err != nil {
-						return fmt.Errorf("[%s] ReplaceBatch failed for %s.%s: %w", writeBatchReplaceOp, in.SchemaName, in.Table.Name, err)
+						return 0, fmt.Errorf("[%s] ReplaceBatch failed for %s.%s with file %s: %w", writeBatchReplaceOp, in.SchemaName, in.Table.Name, replaceFile, err)
 					}
-					if totalRows == 0 {
+					if fileRows == 0 {
 						logEmptyCSV(&emptyCSVWarnParams{
 							operation:  writeBatchReplaceOp,
 							schemaName: in.SchemaName,
@@ -439,19 +501,17 @@ func (s *Server) processReplaceFiles(
 							fileName:   replaceFile,
 						})
 					} else {
-						log.Notice(fmt.Sprintf("[%s] File %s contained %d rows total", writeBatchReplaceOp, replaceFile, totalRows))
+						log.Notice(fmt.Sprintf("[%s] File %s contained %d rows total", writeBatchReplaceOp, replaceFile, fileRows))
 					}
-					return nil
-				}(); err != nil {
-					return err
-				}
-			}
-			return nil
+					return fileRows, nil
+				})
+			return err
 		}, writeBatchReplaceOp)
 		if err != nil {
 			return err
 		}
-		log.Notice(fmt.Sprintf("[%s] Completed processing all replace files for %s.%s", writeBatchReplaceOp, in.SchemaName, in.Table.Name))
+		log.Notice(fmt.Sprintf("[%s] Completed processing all replace files for %s.%s (%d rows total)",
+			writeBatchReplaceOp, in.SchemaName, in.Table.Name, totalRows))
 	}
 	return nil
 }
@@ -460,6 +520,7 @@ func (s *Server) processEarliestStartFilesForHistoryBatch(
 	ctx context.Context,
 	in *pb.WriteHistoryBatchRequest,
 	conn *db.ClickHouseConnection,
+	batchFileFormat pb.BatchFileFormat,
 	compression pb.Compression,
 	encryption pb.Encryption,
 	metadata *types.FivetranTableMetadata,
@@ -472,9 +533,9 @@ func (s *Server) processEarliestStartFilesForHistoryBatch(
 				log.Notice(fmt.Sprintf("[%s] Processing file %d/%d: %s", writeHistoryBatchEarliestStartOp, fileIdx+1, len(in.EarliestStartFiles), earliestStartFile))
 				if err := func() error {
 					// First pass: hard delete overlapping records
-					deleteReader, err := csvreader.NewCSVFileReader(earliestStartFile, in.Keys, compression, encryption)
+					deleteReader, err := openBatchFile(earliestStartFile, in.Keys, batchFileFormat, compression, encryption, in.GetFileParams().GetNullString())
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to open CSV file %s: %w", writeHistoryBatchEarliestStartOp, earliestStartFile, err)
+						return fmt.Errorf("[%s] Failed to open batch file %s: %w", writeHistoryBatchEarliestStartOp, earliestStartFile, err)
 					}
 					defer deleteReader.Close()
 					csvColumns, err := types.MakeCSVColumns(deleteReader.Header(), driverColumns, metadata.ColumnsMap, false)
@@ -488,9 +549,9 @@ func (s *Server) processEarliestStartFilesForHistoryBatch(
 					}
 
 					// Second pass: update active records
-					updateReader, err := csvreader.NewCSVFileReader(earliestStartFile, in.Keys, compression, encryption)
+					updateReader, err := openBatchFile(earliestStartFile, in.Keys, batchFileFormat, compression, encryption, in.GetFileParams().GetNullString())
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to open CSV file %s: %w", writeHistoryBatchEarliestStartOp, earliestStartFile, err)
+						return fmt.Errorf("[%s] Failed to open batch file %s: %w", writeHistoryBatchEarliestStartOp, earliestStartFile, err)
 					}
 					defer updateReader.Close()
 					log.Notice(fmt.Sprintf("[%s] Executing UpdateForEarliestStartHistory for %s.%s", writeHistoryBatchEarliestStartOp, in.SchemaName, in.Table.Name))
@@ -529,33 +590,40 @@ func (s *Server) processReplaceFilesForHistoryBatch(
 	ctx context.Context,
 	in *pb.WriteHistoryBatchRequest,
 	conn *db.ClickHouseConnection,
+	batchFileFormat pb.BatchFileFormat,
 	compression pb.Compression,
 	encryption pb.Encryption,
 	nullStr string,
 	metadata *types.FivetranTableMetadata,
 	driverColumns *types.DriverColumns,
+	parallelism uint,
 ) (err error) {
 	if len(in.ReplaceFiles) > 0 {
-		log.Notice(fmt.Sprintf("[%s] Processing %d replace files for %s.%s", writeHistoryBatchReplaceOp, len(in.ReplaceFiles), in.SchemaName, in.Table.Name))
+		log.Notice(fmt.Sprintf("[%s] Processing %d replace files for %s.%s (up to %d at a time)",
+			writeHistoryBatchReplaceOp, len(in.ReplaceFiles), in.SchemaName, in.Table.Name, parallelism))
+		totalRows := 0
 		err = benchmark.RunAndNotice(func() error {
-			for fileIdx, replaceFile := range in.ReplaceFiles {
-				log.Notice(fmt.Sprintf("[%s] Processing file %d/%d: %s", writeHistoryBatchReplaceOp, fileIdx+1, len(in.ReplaceFiles), replaceFile))
-				if err := func() error {
-					reader, err := csvreader.NewCSVFileReader(replaceFile, in.Keys, compression, encryption)
+			// the replace files are independent of each other, as ReplacingMergeTree keeps the row with the latest
+			// _fivetran_synced version regardless of the insert order; the update and delete files
+			// are only processed after all of them are inserted
+			totalRows, err = processFilesInParallel(ctx, in.ReplaceFiles, parallelism,
+				func(ctx context.Context, fileIdx int, replaceFile string) (int, error) {
+					log.Notice(fmt.Sprintf("[%s] Processing file %d/%d: %s", writeHistoryBatchReplaceOp, fileIdx+1, len(in.ReplaceFiles), replaceFile))
+					reader, err := openBatchFile(replaceFile, in.Keys, batchFileFormat, compression, encryption, in.GetFileParams().GetNullString())
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to open CSV file %s: %w", writeHistoryBatchReplaceOp, replaceFile, err)
+						return 0, fmt.Errorf("[%s] Failed to open batch file %s: %w", writeHistoryBatchReplaceOp, replaceFile, err)
 					}
 					defer reader.Close()
 					csvColumns, err := types.MakeCSVColumns(reader.Header(), driverColumns, metadata.ColumnsMap, true)
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to make CSV columns for file %s: %w", writeHistoryBatchReplaceOp, replaceFile, err)
+						return 0, fmt.Errorf("[%s] Failed to make CSV columns for file %s: %w", writeHistoryBatchReplaceOp, replaceFile, err)
 					}
-					log.Notice(fmt.Sprintf("[%s] Executing ReplaceBatch for %s.%s", writeHistoryBatchReplaceOp, in.SchemaName, in.Table.Name))
-					totalRows, err := conn.ReplaceBatch(ctx, in.SchemaName, in.Table, reader, csvColumns, nullStr)
+					log.Notice(fmt.Sprintf("[%s] Executing ReplaceBatch for %s.%s with file %s", writeHistoryBatchReplaceOp, in.SchemaName, in.Table.Name, replaceFile))
+					fileRows, err := conn.ReplaceBatch(ctx, in.SchemaName, in.Table, reader, csvColumns, nullStr)
 					if err != nil {
-						return fmt.Errorf("[%s] ReplaceBatch failed for %s.%s: %w", writeHistoryBatchReplaceOp, in.SchemaName, in.Table.Name, err)
+						return 0, fmt.Errorf("[%s] ReplaceBatch failed for %s.%s with file %s: %w", writeHistoryBatchReplaceOp, in.SchemaName, in.Table.Name, replaceFile, err)
 					}
-					if totalRows == 0 {
+					if fileRows == 0 {
 						logEmptyCSV(&emptyCSVWarnParams{
 							operation:  writeHistoryBatchReplaceOp,
 							schemaName: in.SchemaName,
@@ -563,19 +631,17 @@ func (s *Server) processReplaceFilesForHistoryBatch(
 							fileName:   replaceFile,
 						})
 					} else {
-						log.Notice(fmt.Sprintf("[%s] File %s contained %d rows total", writeHistoryBatchReplaceOp, replaceFile, totalRows))
+						log.Notice(fmt.Sprintf("[%s] File %s contained %d rows total", writeHistoryBatchReplaceOp, replaceFile, fileRows))
 					}
-					return nil
-				}(); err != nil {
-					return err
-				}
-			}
-			return nil
+					return fileRows, nil
+				})
+			return err
 		}, writeHistoryBatchReplaceOp)
 		if err != nil {
 			return err
 		}
-		log.Notice(fmt.Sprintf("[%s] Completed processing all replace files for %s.%s", writeHistoryBatchReplaceOp, in.SchemaName, in.Table.Name))
+		log.Notice(fmt.Sprintf("[%s] Completed processing all replace files for %s.%s (%d rows total)",
+			writeHistoryBatchReplaceOp, in.SchemaName, in.Table.Name, totalRows))
 	}
 	return nil
 }
@@ -584,6 +650,7 @@ func (s *Server) processUpdateFiles(
 	ctx context.Context,
 	in *pb.WriteBatchRequest,
 	conn *db.ClickHouseConnection,
+	batchFileFormat pb.BatchFileFormat,
 	compression pb.Compression,
 	encryption pb.Encryption,
 	nullStr string,
@@ -597,9 +664,9 @@ func (s *Server) processUpdateFiles(
 			for fileIdx, updateFile := range in.UpdateFiles {
 				log.Notice(fmt.Sprintf("[%s] Processing file %d/%d: %s", writeBatchUpdateOp, fileIdx+1, len(in.UpdateFiles), updateFile))
 				if err := func() error {
-					reader, err := csvreader.NewCSVFileReader(updateFile, in.Keys, compression, encryption)
+					reader, err := openBatchFile(updateFile, in.Keys, batchFileFormat, compression, encryption, in.GetFileParams().GetNullString())
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to open CSV file %s: %w", writeBatchUpdateOp, updateFile, err)
+						return fmt.Errorf("[%s] Failed to open batch file %s: %w", writeBatchUpdateOp, updateFile, err)
 					}
 					defer reader.Close()
 					csvColumns, err := types.MakeCSVColumns(reader.Header(), driverColumns, metadata.ColumnsMap, true)
@@ -640,6 +707,7 @@ func (s *Server) processUpdateFilesForHistoryBatch(
 	ctx context.Context,
 	in *pb.WriteHistoryBatchRequest,
 	conn *db.ClickHouseConnection,
+	batchFileFormat pb.BatchFileFormat,
 	compression pb.Compression,
 	encryption pb.Encryption,
 	nullStr string,
@@ -653,9 +721,9 @@ func (s *Server) processUpdateFilesForHistoryBatch(
 			for fileIdx, updateFile := range in.UpdateFiles {
 				log.Notice(fmt.Sprintf("[%s] Processing file %d/%d: %s", writeHistoryBatchUpdateOp, fileIdx+1, len(in.UpdateFiles), updateFile))
 				if err := func() error {
-					reader, err := csvreader.NewCSVFileReader(updateFile, in.Keys, compression, encryption)
+					reader, err := openBatchFile(updateFile, in.Keys, batchFileFormat, compression, encryption, in.GetFileParams().GetNullString())
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to open CSV file %s: %w", writeHistoryBatchUpdateOp, updateFile, err)
+						return fmt.Errorf("[%s] Failed to open batch file %s: %w", writeHistoryBatchUpdateOp, updateFile, err)
 					}
 					defer reader.Close()
 					csvColumns, err := types.MakeCSVColumns(reader.Header(), driverColumns, metadata.ColumnsMap, true)
@@ -696,6 +764,7 @@ func (s *Server) processDeleteFiles(
 	ctx context.Context,
 	in *pb.WriteBatchRequest,
 	conn *db.ClickHouseConnection,
+	batchFileFormat pb.BatchFileFormat,
 	compression pb.Compression,
 	encryption pb.Encryption,
 	metadata *types.FivetranTableMetadata,
@@ -707,9 +776,9 @@ func (s *Server) processDeleteFiles(
 			for fileIdx, deleteFile := range in.DeleteFiles {
 				log.Notice(fmt.Sprintf("[%s] Processing file %d/%d: %s", writeBatchDeleteOp, fileIdx+1, len(in.DeleteFiles), deleteFile))
 				if err := func() error {
-					reader, err := csvreader.NewCSVFileReader(deleteFile, in.Keys, compression, encryption)
+					reader, err := openBatchFile(deleteFile, in.Keys, batchFileFormat, compression, encryption, in.GetFileParams().GetNullString())
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to open CSV file %s: %w", writeBatchDeleteOp, deleteFile, err)
+						return fmt.Errorf("[%s] Failed to open batch file %s: %w", writeBatchDeleteOp, deleteFile, err)
 					}
 					defer reader.Close()
 					csvColumns, err := types.MakeCSVColumns(reader.Header(), driverColumns, metadata.ColumnsMap, true)
@@ -750,6 +819,7 @@ func (s *Server) processDeleteFilesForHistoryBatch(
 	ctx context.Context,
 	in *pb.WriteHistoryBatchRequest,
 	conn *db.ClickHouseConnection,
+	batchFileFormat pb.BatchFileFormat,
 	compression pb.Compression,
 	encryption pb.Encryption,
 	metadata *types.FivetranTableMetadata,
@@ -761,9 +831,9 @@ func (s *Server) processDeleteFilesForHistoryBatch(
 			for fileIdx, deleteFile := range in.DeleteFiles {
 				log.Notice(fmt.Sprintf("[%s] Processing file %d/%d: %s", writeHistoryBatchDeleteOp, fileIdx+1, len(in.DeleteFiles), deleteFile))
 				if err := func() error {
-					reader, err := csvreader.NewCSVFileReader(deleteFile, in.Keys, compression, encryption)
+					reader, err := openBatchFile(deleteFile, in.Keys, batchFileFormat, compression, encryption, in.GetFileParams().GetNullString())
 					if err != nil {
-						return fmt.Errorf("[%s] Failed to open CSV file %s: %w", writeHistoryBatchDeleteOp, deleteFile, err)
+						return fmt.Errorf("[%s] Failed to open batch file %s: %w", writeHistoryBatchDeleteOp, deleteFile, err)
 					}
 					defer reader.Close()
 					csvColumns, err := types.MakeCSVColumns(reader.Header(), driverColumns, metadata.ColumnsMap, false)
@@ -800,6 +870,30 @@ func (s *Server) processDeleteFilesForHistoryBatch(
 	return nil
 }
 
+// openBatchFile opens a batch file using the format that we requested from Fivetran in Capabilities,
+// see config.Settings.BatchFileFormat.
+func openBatchFile(
+	fileName string,
+	keys map[string][]byte,
+	batchFileFormat pb.BatchFileFormat,
+	compression pb.Compression,
+	encryption pb.Encryption,
+	nullStr string,
+) (files.BatchFileReader, error) {
+	if batchFileFormat == pb.BatchFileFormat_PARQUET {
+		reader, err := parquetreader.NewParquetFileReader(fileName, keys, compression, encryption, nullStr)
+		if err != nil {
+			return nil, err
+		}
+		return reader, nil
+	}
+	reader, err := csvreader.NewCSVFileReader(fileName, keys, compression, encryption)
+	if err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
 type emptyCSVWarnParams struct {
 	operation  string
 	schemaName string
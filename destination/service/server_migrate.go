@@ -10,7 +10,6 @@ import (
 	"fivetran.com/fivetran_sdk/destination/common/log"
 	"fivetran.com/fivetran_sdk/destination/db"
 	"fivetran.com/fivetran_sdk/destination/db/config"
-	"fivetran.com/fivetran_sdk/destination/db/sql"
 	"fivetran.com/fivetran_sdk/destination/db/values"
 	pb "fivetran.com/fivetran_sdk/proto"
 )
@@ -29,17 +28,19 @@ func (s *Server) Migrate(ctx context.Context, in *pb.MigrateRequest) (*pb.Migrat
 		return FailedMigrateResponse(schema, table, fmt.Errorf("migration_details.table is required")), nil
 	}
 
-	connConfig, err := config.ParseAll(in.GetConfiguration())
+	connConfig, settings, err := config.ParseAll(in.GetConfiguration())
 	if err != nil {
 		log.Error(fmt.Errorf("[Migrate] %w", err))
 		return FailedMigrateResponse(schema, table, err), nil
 	}
-	conn, err := db.GetClickHouseConnection(ctx, connConfig)
+	conn, err := db.GetClickHouseConnection(ctx, connConfig.ForDDL(), settings)
 	if err != nil {
 		log.Error(fmt.Errorf("[Migrate] Failed to connect for %s.%s: %w", schema, table, err))
 		return FailedMigrateResponse(schema, table, err), nil
 	}
 	defer conn.Close() //nolint:errcheck
+	// migrations can change the structure of multiple tables in the schema (e.g. rename or copy)
+	defer conn.InvalidateSchemaMetadata(schema)
 
 	switch op := details.GetOperation().(type) {
 	case *pb.MigrationDetails_Drop:
@@ -71,11 +72,7 @@ func handleDropOperation(
 	switch entity := drop.GetEntity().(type) {
 	case *pb.DropOperation_DropTable:
 		log.Info(fmt.Sprintf("[Migrate] Dropping table %s.%s", schema, table))
-		qualifiedName, err := sql.GetQualifiedTableName(schema, table)
-		if err != nil {
-			return FailedMigrateResponse(schema, table, err)
-		}
-		err = conn.DropTable(ctx, qualifiedName)
+		err := conn.DropTable(ctx, schema, table)
 		if err != nil {
 			return FailedMigrateResponse(schema, table, err)
 		}
@@ -376,6 +373,10 @@ func buildUpdateColumnMigrateValue(
 			schema, table, col, colDef.Type, typeErr))
 		return values.NewMigrateValueQuoted(value), nil
 	}
+	// the UTC_DATETIME columns might have a lower precision, see config.DateTimeTypes and dt.IsCompatibleType
+	if precision, ok := dt.DateTimePrecision(colDef.Type); ok && colType == pb.DataType_UTC_DATETIME {
+		return values.NewUTCDateTimeMigrateValue(value, precision)
+	}
 	return values.NewMigrateValue(colType, value)
 }
 
@@ -73,11 +73,10 @@ func runBatchSizeTest(t *testing.T, cfg batchSizeTestConfig) {
 	t.Helper()
 	ctx := context.Background()
 	conn, err := db.GetClickHouseConnection(ctx, &config.Config{
-		Host:     "localhost",
-		Port:     9000,
-		Username: "default",
-		Local:    true,
-	})
+		Addresses: []string{"localhost:9000"},
+		Username:  "default",
+		Local:     true,
+	}, config.DefaultSettings())
 	require.NoError(t, err)
 	defer conn.Close() //nolint:errcheck
 
@@ -92,7 +91,7 @@ func runBatchSizeTest(t *testing.T, cfg batchSizeTestConfig) {
 
 	qualifiedTableName, _ := sql.GetQualifiedTableName(schemaName, tableName)
 	defer func() {
-		dropStmt, _ := sql.GetDropTableStatement(qualifiedTableName)
+		dropStmt, _ := sql.GetDropTableStatement(qualifiedTableName, nil)
 		conn.Exec(ctx, dropStmt) //nolint:errcheck
 	}()
 
@@ -100,17 +99,19 @@ func runBatchSizeTest(t *testing.T, cfg batchSizeTestConfig) {
 		{
 			name: "UPDATE",
 			generate: func(csv [][]string, cols *types.CSVColumns, table sql.QualifiedTableName) (string, error) {
-				return sql.GetUpdateHistoryActiveStatement(csv, cols, table, cfg.fivetranStartIndex, pb.DataType_UTC_DATETIME)
+				return sql.GetUpdateHistoryActiveStatement(csv, cols, table, cfg.fivetranStartIndex, pb.DataType_UTC_DATETIME, nil)
 			},
 		},
 		{
-			name:     "DELETE",
-			generate: sql.GetHardDeleteStatement,
+			name: "DELETE",
+			generate: func(csv [][]string, cols *types.CSVColumns, table sql.QualifiedTableName) (string, error) {
+				return sql.GetHardDeleteStatement(csv, cols, table, nil)
+			},
 		},
 		{
 			name: "DELETE+Timestamp",
 			generate: func(csv [][]string, cols *types.CSVColumns, table sql.QualifiedTableName) (string, error) {
-				return sql.GetHardDeleteWithTimestampStatement(csv, cols, table, "_fivetran_start", cfg.fivetranStartIndex, pb.DataType_UTC_DATETIME)
+				return sql.GetHardDeleteWithTimestampStatement(csv, cols, table, "_fivetran_start", cfg.fivetranStartIndex, pb.DataType_UTC_DATETIME, nil)
 			},
 		},
 	}